@@ -1,4 +1,5 @@
 {{ config "materialized" "incremental" }}
+{{ config "unique_key" "customer_sk" }}
 
 -- SCD Type 2 Customer Dimension
 -- Tracks historical changes to customer attributes (city, state, email)
@@ -1070,7 +1070,10 @@ func TestDimCustomersModelContent(t *testing.T) {
 		t.Error("dim_customers.sql missing config directive {{ config \"materialized\" \"incremental\" }}")
 	}
 
-	// Note: unique_key is set programmatically for incremental models, not in config directive
+	// Incremental runs merge versions on their surrogate key
+	if !strings.Contains(contentStr, `{{ config "unique_key" "customer_sk" }}`) {
+		t.Error("dim_customers.sql missing config directive {{ config \"unique_key\" \"customer_sk\" }}")
+	}
 
 	// Check for ref to raw_sales
 	if !strings.Contains(contentStr, `{{ ref "raw_sales" }}`) {
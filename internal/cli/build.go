@@ -6,18 +6,34 @@ import (
 	"os"
 
 	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/domain/test"
 	testExecutor "github.com/jpconstantineau/gorchata/internal/domain/test/executor"
-	"github.com/jpconstantineau/gorchata/internal/domain/test/generic"
 	"github.com/jpconstantineau/gorchata/internal/domain/test/storage"
 	"github.com/jpconstantineau/gorchata/internal/platform"
+	"github.com/jpconstantineau/gorchata/internal/template"
 )
 
-// BuildCommand runs models and then tests (full build workflow)
+// BuildCommand runs models and snapshots in dependency order and then tests
+// (full build workflow)
 func BuildCommand(args []string) error {
+	return buildModels(args, nil)
+}
+
+// buildModels runs the build command; microbatch models with batches build
+// those in place of their usual range
+func buildModels(args []string, batches map[string][]materialization.Batch) error {
+	// Parse the run flags so that tests use the same target and vars as the models
+	var flags runFlags
+	if err := newRunFlagSet("build", &flags).Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
 	fmt.Println("Running models...")
 
 	// Run models using the run command logic
-	if err := RunCommand(args); err != nil {
+	runResults, err := runModels("build", args, batches)
+	if err != nil {
 		return fmt.Errorf("model run failed: %w", err)
 	}
 
@@ -25,11 +41,16 @@ func BuildCommand(args []string) error {
 
 	// For tests, we need to load config and run tests directly
 	// to avoid flag parsing conflicts
-	cfg, err := config.Discover("")
+	cfg, err := config.Discover(flags.Target)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	vars, err := resolveVars(cfg, flags.Vars)
+	if err != nil {
+		return err
+	}
+
 	// Create database adapter
 	adapter, err := createAdapter(cfg.Output)
 	if err != nil {
@@ -43,8 +64,21 @@ func BuildCommand(args []string) error {
 	}
 	defer adapter.Close()
 
-	// Run tests
-	if err := runTestsForBuild(ctx, cfg, adapter); err != nil {
+	// Tests are taken from the project manifest
+	m, err := loadManifest(cfg, vars, flags.FullRefresh)
+	if err != nil {
+		return err
+	}
+
+	// Run tests and record them in the run results next to the models
+	summary, err := runTestsForBuild(ctx, m.Tests, m.Macros, adapter, vars)
+	if summary != nil && runResults != nil {
+		runResults.Nodes = append(runResults.Nodes, testNodeResults(summary)...)
+		if writeErr := runResults.Write(runResultsPath); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write run results: %v\n", writeErr)
+		}
+	}
+	if err != nil {
 		return fmt.Errorf("tests failed: %w", err)
 	}
 
@@ -52,20 +86,12 @@ func BuildCommand(args []string) error {
 	return nil
 }
 
-// runTestsForBuild executes tests as part of the build command
-func runTestsForBuild(ctx context.Context, cfg *config.Config, adapter platform.DatabaseAdapter) error {
-	// Create test registry
-	registry := generic.NewDefaultRegistry()
-
-	// Discover all tests
-	allTests, err := testExecutor.DiscoverAllTests(cfg, registry)
-	if err != nil {
-		return fmt.Errorf("failed to discover tests: %w", err)
-	}
-
+// runTestsForBuild executes tests as part of the build command and returns
+// their summary, or nil if none ran. Tests can call the project's macros.
+func runTestsForBuild(ctx context.Context, allTests []*test.Test, macros *template.Macros, adapter platform.DatabaseAdapter, vars map[string]interface{}) (*test.TestSummary, error) {
 	if len(allTests) == 0 {
 		fmt.Println("No tests found")
-		return nil
+		return nil, nil
 	}
 
 	fmt.Printf("Running %d test(s)...\n\n", len(allTests))
@@ -80,10 +106,11 @@ func runTestsForBuild(ctx context.Context, cfg *config.Config, adapter platform.
 	}
 
 	// Create test engine
-	engine, err := testExecutor.NewTestEngine(adapter, nil, failureStore)
+	engine, err := testExecutor.NewTestEngine(adapter, template.New(template.WithMacros(macros)), failureStore)
 	if err != nil {
-		return fmt.Errorf("failed to create test engine: %w", err)
+		return nil, fmt.Errorf("failed to create test engine: %w", err)
 	}
+	engine.SetVars(vars)
 
 	// Create result writers
 	consoleWriter := testExecutor.NewConsoleResultWriter(os.Stdout, true)
@@ -92,7 +119,7 @@ func runTestsForBuild(ctx context.Context, cfg *config.Config, adapter platform.
 	// Execute tests
 	summary, err := engine.ExecuteTests(ctx, allTests)
 	if err != nil {
-		return fmt.Errorf("failed to execute tests: %w", err)
+		return nil, fmt.Errorf("failed to execute tests: %w", err)
 	}
 
 	// Write results
@@ -115,8 +142,8 @@ func runTestsForBuild(ctx context.Context, cfg *config.Config, adapter platform.
 
 	// Return error if failures
 	if summary.FailedTests > 0 {
-		return fmt.Errorf("%d test(s) failed", summary.FailedTests)
+		return summary, fmt.Errorf("%d test(s) failed", summary.FailedTests)
 	}
 
-	return nil
+	return summary, nil
 }
@@ -38,6 +38,8 @@ func Run(args []string) error {
 		return RunCommand(commandArgs)
 	case "seed":
 		return SeedCommand(commandArgs)
+	case "snapshot":
+		return SnapshotCommand(commandArgs)
 	case "compile":
 		return CompileCommand(commandArgs)
 	case "test":
@@ -46,6 +48,12 @@ func Run(args []string) error {
 		return BuildCommand(commandArgs)
 	case "docs":
 		return DocsCommand(commandArgs)
+	case "ls":
+		return LsCommand(commandArgs)
+	case "retry":
+		return RetryCommand(commandArgs)
+	case "run-operation":
+		return RunOperationCommand(commandArgs)
 	default:
 		return fmt.Errorf("unknown command: %s. Use 'gorchata --help' for usage information", command)
 	}
@@ -62,10 +70,14 @@ func printUsage() {
 	fmt.Println("  init      Initialize a new Gorchata project")
 	fmt.Println("  run       Execute SQL transformations against the database")
 	fmt.Println("  seed      Load seed data into the database")
+	fmt.Println("  snapshot  Record the history of changing rows in snapshot tables")
 	fmt.Println("  compile   Compile SQL templates without executing them")
 	fmt.Println("  test      Run data quality tests")
-	fmt.Println("  build     Run models and tests (full build workflow)")
+	fmt.Println("  build     Run models, snapshots and tests (full build workflow)")
 	fmt.Println("  docs      Generate documentation (not yet implemented)")
+	fmt.Println("  ls        List models and their resolved configuration")
+	fmt.Println("  retry     Re-run the failed and skipped nodes of the last invocation")
+	fmt.Println("  run-operation  Run a macro against the database (run-operation <macro> --args '{...}')")
 	fmt.Println()
 	fmt.Println("Flags:")
 	fmt.Println("  -h, --help      Show help information")
@@ -5,11 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/jpconstantineau/gorchata/internal/config"
 	"github.com/jpconstantineau/gorchata/internal/domain/dag"
-	"github.com/jpconstantineau/gorchata/internal/template"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
 )
 
 // CompileCommand compiles SQL templates without executing them
@@ -21,6 +20,8 @@ func CompileCommand(args []string) error {
 
 	fs.StringVar(&outputDir, "output-dir", "", "Directory to write compiled SQL files (default: stdout)")
 	AddCommonFlags(fs, &common)
+	AddExcludeFlag(fs, &common)
+	AddStateFlags(fs, &common)
 
 	if err := fs.Parse(args); err != nil {
 		return fmt.Errorf("failed to parse flags: %w", err)
@@ -37,68 +38,67 @@ func CompileCommand(args []string) error {
 		return fmt.Errorf("no model paths configured in project")
 	}
 
-	// Build DAG from first model path
-	builder := dag.NewBuilder()
-	graph, err := builder.BuildFromDirectory(cfg.Project.ModelPaths[0])
+	// Resolve project vars, overridden by --vars
+	vars, err := resolveVars(cfg, common.Vars)
 	if err != nil {
-		return fmt.Errorf("failed to build DAG: %w", err)
+		return err
 	}
 
-	// Validate DAG
-	if err := dag.Validate(graph); err != nil {
-		return fmt.Errorf("DAG validation failed: %w", err)
+	// Build the project manifest, which compiles every model
+	m, err := loadStateManifest(cfg, vars, common)
+	if err != nil {
+		return err
 	}
 
-	// Get topologically sorted nodes
-	sorted, err := dag.TopologicalSort(graph)
+	// Select models with --models and --exclude
+	selected, err := m.Select(common.Models, common.Exclude)
 	if err != nil {
-		return fmt.Errorf("failed to sort DAG: %w", err)
+		return fmt.Errorf("invalid model selection: %w", err)
+	}
+
+	// Resolve ref() to unselected models to the --state target; compile
+	// does not connect, so the state's database is only referenced
+	if common.Defer {
+		if err := m.Defer(selected, deferSchema(m)); err != nil {
+			return err
+		}
 	}
 
-	// Filter models if specified
-	if common.Models != "" {
-		sorted = filterModels(sorted, strings.Split(common.Models, ","))
+	selectedIDs := make(map[string]bool, len(selected))
+	for _, model := range selected {
+		selectedIDs[model.ID] = true
 	}
 
-	// Load seeds for template context
-	seedsMap, err := LoadSeedsForTemplateContext(cfg)
+	graph, err := m.Graph()
 	if err != nil {
-		return fmt.Errorf("failed to load seeds: %w", err)
+		return err
 	}
 
-	// Create template engine
-	engine := template.New()
+	// Get topologically sorted nodes
+	sorted, err := dag.TopologicalSort(graph)
+	if err != nil {
+		return fmt.Errorf("failed to sort DAG: %w", err)
+	}
 
-	// Compile each model
+	// Output each selected model in dependency order
+	compiled := 0
 	for _, node := range sorted {
-		if common.Verbose {
-			fmt.Printf("Compiling model: %s\n", node.Name)
+		if !selectedIDs[node.ID] {
+			continue
 		}
+		model, _ := m.Model(node.ID)
+		compiled++
 
-		// Get file content from node metadata
-		content, ok := node.Metadata["content"].(string)
-		if !ok {
-			return fmt.Errorf("model %s has no content", node.Name)
-		}
-
-		// Parse and render template
-		ctx := template.NewContext()
-		ctx.Seeds = seedsMap
-
-		tmpl, err := engine.Parse(node.Name, content)
-		if err != nil {
-			return fmt.Errorf("failed to parse template for model %s: %w", node.Name, err)
+		if common.Verbose {
+			fmt.Printf("Compiling model: %s\n", model.ID)
 		}
 
-		sql, err := template.Render(tmpl, ctx, nil)
-		if err != nil {
-			return fmt.Errorf("failed to render template for model %s: %w", node.Name, err)
-		}
+		sql := model.CompiledSQL
 
 		// Output result
 		if outputDir != "" {
 			// Write to file
-			outputPath := filepath.Join(outputDir, node.Name+".sql")
+			outputPath := filepath.Join(outputDir, model.ID+".sql")
 			if err := os.WriteFile(outputPath, []byte(sql), 0644); err != nil {
 				return fmt.Errorf("failed to write output file %s: %w", outputPath, err)
 			}
@@ -107,36 +107,22 @@ func CompileCommand(args []string) error {
 			}
 		} else {
 			// Write to stdout
-			fmt.Printf("-- Model: %s\n", node.Name)
+			fmt.Printf("-- Model: %s\n", model.ID)
 			fmt.Println(sql)
 			fmt.Println()
 		}
 	}
 
+	// Mirror the model folders under target/compiled/
+	if err := executor.WriteCompiledSQL(compiledDir, selected); err != nil {
+		return err
+	}
+
 	if !common.Verbose && outputDir == "" {
-		fmt.Fprintf(os.Stderr, "Compiled %d model(s)\n", len(sorted))
+		fmt.Fprintf(os.Stderr, "Compiled %d model(s)\n", compiled)
 	} else if common.Verbose {
-		fmt.Printf("\nCompiled %d model(s) successfully\n", len(sorted))
+		fmt.Printf("\nCompiled %d model(s) successfully\n", compiled)
 	}
 
 	return nil
 }
-
-// filterModels filters a slice of nodes to only include specified models
-func filterModels(nodes []*dag.Node, modelNames []string) []*dag.Node {
-	// Create a set of model names for fast lookup
-	nameSet := make(map[string]bool)
-	for _, name := range modelNames {
-		nameSet[strings.TrimSpace(name)] = true
-	}
-
-	// Filter nodes
-	filtered := make([]*dag.Node, 0)
-	for _, node := range nodes {
-		if nameSet[node.Name] {
-			filtered = append(filtered, node)
-		}
-	}
-
-	return filtered
-}
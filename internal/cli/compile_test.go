@@ -1,8 +1,10 @@
 package cli
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -290,3 +292,66 @@ default:
 		t.Error("CompileCommand() should return error for invalid template")
 	}
 }
+
+// TestCompileWritesManifest checks the manifest compile writes to
+// target/manifest.json
+func TestCompileWritesManifest(t *testing.T) {
+	tmpDir := setupLsProject(t)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := captureStdout(t, func() error { return CompileCommand([]string{}) }); err != nil {
+		t.Fatalf("CompileCommand() error = %v", err)
+	}
+
+	data, err := os.ReadFile("target/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+
+	var written struct {
+		Metadata struct {
+			ProjectName string `json:"project_name"`
+		} `json:"metadata"`
+		Nodes map[string]struct {
+			ResourceType string   `json:"resource_type"`
+			Relation     string   `json:"relation_name"`
+			DependsOn    []string `json:"depends_on"`
+			CompiledCode string   `json:"compiled_code"`
+		} `json:"nodes"`
+		ChildMap map[string][]string `json:"child_map"`
+	}
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("invalid manifest JSON: %v", err)
+	}
+
+	if written.Metadata.ProjectName != "shop" {
+		t.Errorf("project_name = %s, want shop", written.Metadata.ProjectName)
+	}
+
+	revenue, ok := written.Nodes["model.shop.revenue"]
+	if !ok {
+		t.Fatalf("model.shop.revenue missing from nodes: %v", written.Nodes)
+	}
+	if revenue.Relation != "finance_revenue" {
+		t.Errorf("revenue relation_name = %s, want finance_revenue", revenue.Relation)
+	}
+	if !reflect.DeepEqual(revenue.DependsOn, []string{"model.shop.fct_orders"}) {
+		t.Errorf("revenue depends_on = %v", revenue.DependsOn)
+	}
+	if !strings.Contains(revenue.CompiledCode, "FROM fct_orders") {
+		t.Errorf("revenue compiled_code = %q, want ref resolved", revenue.CompiledCode)
+	}
+
+	if got := written.ChildMap["model.shop.stg_orders"]; !reflect.DeepEqual(got, []string{"model.shop.fct_orders"}) {
+		t.Errorf("stg_orders children = %v", got)
+	}
+}
@@ -11,13 +11,30 @@ type CommonFlags struct {
 	FailFast    bool
 	Verbose     bool
 	FullRefresh bool
+	Vars        string
+	Exclude     string
+	State       string
+	Defer       bool
 }
 
 // AddCommonFlags registers common flags to a FlagSet
 func AddCommonFlags(fs *flag.FlagSet, cf *CommonFlags) {
 	fs.StringVar(&cf.Target, "target", "", "Target environment (from profiles.yml)")
-	fs.StringVar(&cf.Models, "models", "", "Comma-separated list of models to process")
+	fs.StringVar(&cf.Models, "models", "", "Models to process, using the selection syntax (e.g. '+fct_orders tag:finance')")
 	fs.BoolVar(&cf.FailFast, "fail-fast", false, "Stop execution on first error")
 	fs.BoolVar(&cf.Verbose, "verbose", false, "Enable verbose output")
-	fs.BoolVar(&cf.FullRefresh, "full-refresh", false, "Force full refresh for incremental models")
+	fs.BoolVar(&cf.FullRefresh, "full-refresh", false, "Force full refresh for incremental models and custom materializations")
+	fs.StringVar(&cf.Vars, "vars", "", "Variables as a JSON or YAML map, overriding project vars (e.g. '{\"start_date\": \"2024-01-01\"}')")
+}
+
+// AddExcludeFlag registers the --exclude flag of commands that select models
+func AddExcludeFlag(fs *flag.FlagSet, cf *CommonFlags) {
+	fs.StringVar(&cf.Exclude, "exclude", "", "Models to leave out, using the selection syntax")
+}
+
+// AddStateFlags registers the --state and --defer flags of commands that
+// compare the project with the artifacts of a previous invocation
+func AddStateFlags(fs *flag.FlagSet, cf *CommonFlags) {
+	fs.StringVar(&cf.State, "state", "", "Directory with the manifest.json of a previous invocation, for state: selectors")
+	fs.BoolVar(&cf.Defer, "defer", false, "Resolve ref() to unselected models to their relation in the --state target")
 }
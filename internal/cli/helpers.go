@@ -1,73 +1,160 @@
 package cli
 
 import (
+	"context"
 	"fmt"
-	"os"
 
+	"github.com/google/uuid"
 	"github.com/jpconstantineau/gorchata/internal/config"
-	"github.com/jpconstantineau/gorchata/internal/domain/seeds"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/manifest"
+	"github.com/jpconstantineau/gorchata/internal/domain/test"
+	"github.com/jpconstantineau/gorchata/internal/platform"
 )
 
-// LoadSeedsForTemplateContext loads seeds from configured paths and builds a Seeds map
-// for use in template contexts. Returns the Seeds map (seedName -> qualified table name).
-// If no seed paths are configured or no seeds are found, returns an empty map (not an error).
-func LoadSeedsForTemplateContext(cfg *config.Config) (map[string]string, error) {
-	// Return empty map if no seed paths configured
-	if len(cfg.Project.SeedPaths) == 0 {
-		return make(map[string]string), nil
+// Paths of the artifacts written under target/
+const (
+	runResultsPath = "target/run_results.json"
+	compiledDir    = "target/compiled"
+	runDir         = "target/run"
+)
+
+// stateSchema is the alias the database of the --state target is attached
+// under when deferring to it
+const stateSchema = "state"
+
+// loadManifest builds the project manifest and writes it to target/manifest.json
+func loadManifest(cfg *config.Config, vars map[string]interface{}, fullRefresh bool) (*manifest.Manifest, error) {
+	m, err := manifest.Build(cfg, manifest.BuildOptions{
+		Vars:        vars,
+		FullRefresh: fullRefresh,
+		SeedConfig:  loadOrDefaultSeedConfig(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build manifest: %w", err)
 	}
 
-	// Load seed config (or use defaults)
-	seedConfig := loadOrDefaultSeedConfig()
+	if err := m.Write(manifest.DefaultPath); err != nil {
+		return nil, err
+	}
 
-	// Load seeds from configured paths
-	var allSeeds []*seedInfo
-	for _, seedPath := range cfg.Project.SeedPaths {
-		// Check if path exists
-		if _, err := os.Stat(seedPath); os.IsNotExist(err) {
-			// Skip non-existent paths
-			continue
-		}
+	return m, nil
+}
+
+// loadStateManifest loads the manifest like loadManifest and compares it
+// with the --state manifest, which is read first so that --state target
+// compares against the previous invocation
+func loadStateManifest(cfg *config.Config, vars map[string]interface{}, common CommonFlags) (*manifest.Manifest, error) {
+	if common.State == "" && common.Defer {
+		return nil, fmt.Errorf("--defer requires --state")
+	}
 
-		// Discover seed files using configured scope
-		seedFiles, err := seeds.DiscoverSeeds(seedPath, seedConfig.Import.Scope)
+	var state *manifest.State
+	if common.State != "" {
+		var err error
+		state, err = manifest.ReadState(common.State)
 		if err != nil {
-			return nil, fmt.Errorf("failed to discover seeds in %s: %w", seedPath, err)
+			return nil, err
 		}
+	}
+
+	m, err := loadManifest(cfg, vars, common.FullRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	if state != nil {
+		if err := m.SetState(state); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// deferSchema returns the schema deferred relations are qualified with: the
+// alias of the state's database when it differs from the current one
+func deferSchema(m *manifest.Manifest) string {
+	state := m.State()
+	if state == nil || state.Database == "" || state.Database == m.Database {
+		return ""
+	}
+	return stateSchema
+}
 
-		// Load each seed
-		for _, seedFile := range seedFiles {
-			// Parse CSV
-			rows, err := seeds.ParseCSV(seedFile)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse CSV %s: %w", seedFile, err)
-			}
-
-			// Infer schema (use default sample size of 100, no overrides)
-			schema, err := seeds.InferSchema(rows, 100, nil)
-			if err != nil {
-				return nil, fmt.Errorf("failed to infer schema for %s: %w", seedFile, err)
-			}
-
-			// Resolve table name
-			tableName := seeds.ResolveTableName(seedFile, &seedConfig.Naming)
-
-			// Create seed info
-			info := &seedInfo{
-				Seed: &seeds.Seed{
-					ID:     tableName,
-					Path:   seedFile,
-					Type:   seeds.SeedTypeCSV,
-					Schema: schema,
-				},
-			}
-
-			allSeeds = append(allSeeds, info)
+// deferToState resolves ref() to unselected models to the --state target,
+// attaching its database to the connection when it is a different one
+func deferToState(ctx context.Context, adapter platform.DatabaseAdapter, m *manifest.Manifest, selected []*executor.Model) error {
+	schema := deferSchema(m)
+	if schema != "" {
+		attacher, ok := adapter.(platform.DatabaseAttacher)
+		if !ok {
+			return fmt.Errorf("--defer to another database is not supported by this adapter")
+		}
+		if err := attacher.AttachDatabase(ctx, m.State().Database, schema); err != nil {
+			return err
 		}
 	}
+	return m.Defer(selected, schema)
+}
 
-	// Build Seeds map (no schema qualification by default)
-	seedsMap := buildSeedsMapForTemplate(allSeeds, "")
+// resolveVars returns the project vars overridden by the value of the --vars flag
+func resolveVars(cfg *config.Config, flagVars string) (map[string]interface{}, error) {
+	overrides, err := config.ParseVars(flagVars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --vars: %w", err)
+	}
+	return cfg.Project.ResolveVars(overrides), nil
+}
 
-	return seedsMap, nil
+// newRunResults starts the run results of an invocation of command
+func newRunResults(command string, args []string, projectName string) *executor.RunResults {
+	return &executor.RunResults{
+		InvocationID: uuid.New().String(),
+		Command:      command,
+		Args:         args,
+		ProjectName:  projectName,
+	}
+}
+
+// writeRunArtifacts writes target/run_results.json for the invocation and,
+// for the models it executed, their compiled SQL under target/compiled/ and
+// the statements they executed under target/run/
+func writeRunArtifacts(runResults *executor.RunResults, models []*executor.Model) error {
+	if err := runResults.Write(runResultsPath); err != nil {
+		return err
+	}
+	if runResults.Result == nil {
+		return nil
+	}
+
+	if err := executor.WriteCompiledSQL(compiledDir, models); err != nil {
+		return err
+	}
+
+	return executor.WriteRunSQL(runDir, models, runResults.Result)
+}
+
+// testNodeResults converts test results to run results nodes. Warnings
+// count as successes, as they do not fail the invocation.
+func testNodeResults(summary *test.TestSummary) []executor.NodeResult {
+	nodes := make([]executor.NodeResult, 0, len(summary.TestResults))
+	for _, tr := range summary.TestResults {
+		status := executor.StatusFailed
+		switch tr.Status {
+		case test.StatusPassed, test.StatusWarning:
+			status = executor.StatusSuccess
+		case test.StatusSkipped:
+			status = executor.StatusSkipped
+		}
+		nodes = append(nodes, executor.NodeResult{
+			ResourceType: executor.ResourceTypeTest,
+			Name:         tr.TestID,
+			Status:       status,
+			StartTime:    tr.StartTime,
+			EndTime:      tr.EndTime,
+			RowsAffected: tr.FailureCount,
+			Error:        tr.ErrorMessage,
+		})
+	}
+	return nodes
 }
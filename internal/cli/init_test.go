@@ -48,6 +48,17 @@ func TestInitCommand_RequiresProjectName(t *testing.T) {
 
 // TestInitCommand_ValidatesProjectName verifies that project names are validated correctly
 func TestInitCommand_ValidatesProjectName(t *testing.T) {
+	// Valid names create their project in the working directory
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	defer os.Chdir(originalDir)
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+
 	tests := []struct {
 		name        string
 		projectName string
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/manifest"
+)
+
+// lsModel is the JSON representation of a model listed by ls
+type lsModel struct {
+	Name      string                 `json:"name"`
+	FQN       []string               `json:"fqn"`
+	Path      string                 `json:"path"`
+	Relation  string                 `json:"relation"`
+	DependsOn []string               `json:"depends_on"`
+	Config    map[string]interface{} `json:"config"`
+}
+
+// LsCommand lists the project's models along with their resolved configuration
+func LsCommand(args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+
+	var common CommonFlags
+	AddCommonFlags(fs, &common)
+	AddExcludeFlag(fs, &common)
+	AddStateFlags(fs, &common)
+
+	output := fs.String("output", "text", "Output format: text or json")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("invalid --output %q: must be text or json", *output)
+	}
+
+	// Load configuration
+	cfg, err := config.Discover(common.Target)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	vars, err := resolveVars(cfg, common.Vars)
+	if err != nil {
+		return err
+	}
+
+	m, err := loadStateManifest(cfg, vars, common)
+	if err != nil {
+		return err
+	}
+
+	// Select models with --models and --exclude
+	models, err := m.Select(common.Models, common.Exclude)
+	if err != nil {
+		return fmt.Errorf("invalid model selection: %w", err)
+	}
+
+	sort.Slice(models, func(i, j int) bool {
+		return strings.Join(models[i].FQN, ".") < strings.Join(models[j].FQN, ".")
+	})
+
+	if *output == "text" {
+		for _, model := range models {
+			fmt.Println(strings.Join(model.FQN, "."))
+		}
+		return nil
+	}
+
+	listed := make([]lsModel, 0, len(models))
+	for _, model := range models {
+		listed = append(listed, newLsModel(model))
+	}
+
+	data, err := json.MarshalIndent(listed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode models: %w", err)
+	}
+	fmt.Println(string(data))
+
+	return nil
+}
+
+// newLsModel builds the listing of a model with its fully resolved configuration
+func newLsModel(model *executor.Model) lsModel {
+	deps := model.Dependencies
+	if deps == nil {
+		deps = []string{}
+	}
+
+	return lsModel{
+		Name:      model.ID,
+		FQN:       model.FQN,
+		Path:      model.Path,
+		Relation:  model.Relation(),
+		DependsOn: deps,
+		Config:    manifest.ResolvedConfig(model),
+	}
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns everything written to stdout while fn runs
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	outC := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		outC <- string(data)
+	}()
+
+	fnErr := fn()
+	w.Close()
+	return <-outC, fnErr
+}
+
+// setupLsProject creates a project with models in nested folders and
+// folder-scoped configuration in the models: block
+func setupLsProject(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	projectConfig := `
+name: shop
+version: 1.0.0
+models:
+  shop:
+    +materialized: view
+    +tags: [shop]
+    marts:
+      +materialized: table
+      +tags: [marts]
+      +meta:
+        owner: analytics
+      finance:
+        +alias: finance_revenue
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, filepath.Join(tmpDir, "test.db"))
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	models := map[string]string{
+		"models/stg_orders.sql":            `SELECT 1 AS id`,
+		"models/marts/fct_orders.sql":      `SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/marts/finance/revenue.sql": `{{ config "materialized" "incremental" "unique_key" "id" "tags" "daily" "meta" (dict "tier" "gold") }}SELECT * FROM {{ ref "fct_orders" }}`,
+	}
+	for path, content := range models {
+		fullPath := filepath.Join(tmpDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tmpDir
+}
+
+func TestLsCommand(t *testing.T) {
+	tmpDir := setupLsProject(t)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("text output", func(t *testing.T) {
+		out, err := captureStdout(t, func() error { return LsCommand([]string{}) })
+		if err != nil {
+			t.Fatalf("LsCommand() error = %v", err)
+		}
+
+		want := "shop.marts.fct_orders\nshop.marts.finance.revenue\nshop.stg_orders\n"
+		if out != want {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		out, err := captureStdout(t, func() error { return LsCommand([]string{"--output", "json"}) })
+		if err != nil {
+			t.Fatalf("LsCommand() error = %v", err)
+		}
+
+		var listed []lsModel
+		if err := json.Unmarshal([]byte(out), &listed); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, out)
+		}
+
+		byName := make(map[string]lsModel)
+		for _, m := range listed {
+			byName[m.Name] = m
+		}
+
+		tests := []struct {
+			model        string
+			materialized string
+			tags         []interface{}
+			relation     string
+		}{
+			// Project level only
+			{"stg_orders", "view", []interface{}{"shop"}, "stg_orders"},
+			// Folder overrides project
+			{"fct_orders", "table", []interface{}{"shop", "marts"}, "fct_orders"},
+			// In-file config overrides folders; tags accumulate
+			{"revenue", "incremental", []interface{}{"shop", "marts", "daily"}, "finance_revenue"},
+		}
+
+		for _, tt := range tests {
+			m, ok := byName[tt.model]
+			if !ok {
+				t.Errorf("model %s not listed", tt.model)
+				continue
+			}
+			if m.Config["materialized"] != tt.materialized {
+				t.Errorf("%s materialized = %v, want %s", tt.model, m.Config["materialized"], tt.materialized)
+			}
+			if !reflect.DeepEqual(m.Config["tags"], tt.tags) {
+				t.Errorf("%s tags = %v, want %v", tt.model, m.Config["tags"], tt.tags)
+			}
+			if m.Relation != tt.relation {
+				t.Errorf("%s relation = %s, want %s", tt.model, m.Relation, tt.relation)
+			}
+		}
+
+		meta, _ := byName["revenue"].Config["meta"].(map[string]interface{})
+		if meta["owner"] != "analytics" || meta["tier"] != "gold" {
+			t.Errorf("revenue meta = %v, want owner and tier merged", meta)
+		}
+
+		if deps := byName["revenue"].DependsOn; len(deps) != 1 || deps[0] != "fct_orders" {
+			t.Errorf("revenue depends_on = %v, want [fct_orders]", deps)
+		}
+
+		if fqn := strings.Join(byName["revenue"].FQN, "."); fqn != "shop.marts.finance.revenue" {
+			t.Errorf("revenue fqn = %s", fqn)
+		}
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		if err := LsCommand([]string{"--output", "yaml"}); err == nil {
+			t.Error("expected error for unsupported output format")
+		}
+	})
+}
+
+// TestLsCommandWithoutProjectLevel merges configuration set directly under
+// models:, without the project name, under the folder levels
+func TestLsCommandWithoutProjectLevel(t *testing.T) {
+	tmpDir := setupLsProject(t)
+
+	projectConfig := `
+name: shop
+version: 1.0.0
+models:
+  +materialized: view
+  +tags: [all]
+  marts:
+    +materialized: table
+    +tags: [marts]
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := captureStdout(t, func() error { return LsCommand([]string{"--output", "json"}) })
+	if err != nil {
+		t.Fatalf("LsCommand() error = %v", err)
+	}
+
+	var listed []lsModel
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, out)
+	}
+
+	byName := make(map[string]lsModel)
+	for _, m := range listed {
+		byName[m.Name] = m
+	}
+
+	tests := []struct {
+		model        string
+		materialized string
+		tags         []interface{}
+	}{
+		{"stg_orders", "view", []interface{}{"all"}},
+		{"fct_orders", "table", []interface{}{"all", "marts"}},
+		{"revenue", "incremental", []interface{}{"all", "marts", "daily"}},
+	}
+	for _, tt := range tests {
+		m := byName[tt.model]
+		if m.Config["materialized"] != tt.materialized {
+			t.Errorf("%s materialized = %v, want %s", tt.model, m.Config["materialized"], tt.materialized)
+		}
+		if !reflect.DeepEqual(m.Config["tags"], tt.tags) {
+			t.Errorf("%s tags = %v, want %v", tt.model, m.Config["tags"], tt.tags)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+)
+
+// RetryCommand re-executes the failed and skipped nodes of the last
+// invocation recorded in target/run_results.json, with the same command,
+// flags, target and vars
+func RetryCommand(args []string) error {
+	fs := flag.NewFlagSet("retry", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	previous, err := executor.ReadRunResults(runResultsPath)
+	if err != nil {
+		return fmt.Errorf("no previous invocation to retry: %w", err)
+	}
+
+	var retryArgs []string
+	switch previous.Command {
+	case "run", "build", "snapshot":
+		retryArgs, err = retryModelArgs(previous)
+	case "seed":
+		retryArgs = retrySelectArgs(previous, executor.ResourceTypeSeed, ",")
+	case "test":
+		retryArgs = retrySelectArgs(previous, executor.ResourceTypeTest, " ")
+	default:
+		return fmt.Errorf("cannot retry a %q invocation", previous.Command)
+	}
+	if err != nil {
+		return err
+	}
+
+	if retryArgs == nil {
+		fmt.Printf("Nothing to retry: the last %s had no failed or skipped nodes\n", previous.Command)
+		return nil
+	}
+
+	// Microbatch models only build the batches that failed
+	batches := failedBatches(previous)
+	batchModels := make([]string, 0, len(batches))
+	for id := range batches {
+		batchModels = append(batchModels, id)
+	}
+	sort.Strings(batchModels)
+	for _, id := range batchModels {
+		fmt.Printf("Retrying %d failed batch(es) of %s\n", len(batches[id]), id)
+	}
+
+	fmt.Printf("Retrying: gorchata %s %s\n\n", previous.Command, strings.Join(retryArgs, " "))
+	switch previous.Command {
+	case "run", "snapshot":
+		_, err := runModels(previous.Command, retryArgs, batches)
+		return err
+	case "build":
+		return buildModels(retryArgs, batches)
+	default:
+		return Run(append([]string{previous.Command}, retryArgs...))
+	}
+}
+
+// failedBatches returns the failed batches of the microbatch models of a
+// run or build invocation
+func failedBatches(previous *executor.RunResults) map[string][]materialization.Batch {
+	if previous.Result == nil || (previous.Command != "run" && previous.Command != "build") {
+		return nil
+	}
+
+	batches := make(map[string][]materialization.Batch)
+	for _, mr := range previous.Result.ModelResults {
+		if failed := mr.FailedBatches(); len(failed) > 0 {
+			batches[mr.ModelID] = failed
+		}
+	}
+	return batches
+}
+
+// retryModelArgs returns the arguments of a run, build or snapshot invocation
+// selecting its failed and skipped models, which include the models downstream
+// of the failures, or nil if there is nothing to retry. Selected models that never
+// started, e.g. after --fail-fast, are retried too. For build, the models of
+// failed tests are rebuilt so that the tests run again.
+func retryModelArgs(previous *executor.RunResults) ([]string, error) {
+	unfinished := previous.Unfinished(executor.ResourceTypeModel)
+	failedTests := previous.Unfinished(executor.ResourceTypeTest)
+	if len(unfinished) == 0 && len(failedTests) == 0 {
+		return nil, nil
+	}
+
+	// Load the project as the previous invocation did to recover its selection
+	var flags runFlags
+	if err := newRunFlagSet(previous.Command, &flags).Parse(previous.Args); err != nil {
+		return nil, fmt.Errorf("failed to parse arguments of the last %s: %w", previous.Command, err)
+	}
+	cfg, err := config.Discover(flags.Target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	vars, err := resolveVars(cfg, flags.Vars)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loadStateManifest(cfg, vars, flags.CommonFlags)
+	if err != nil {
+		return nil, err
+	}
+	matched, err := m.Select(flags.Models, flags.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model selection: %w", err)
+	}
+	selected := selectForCommand(previous.Command, matched)
+
+	retry := make(map[string]bool)
+	for _, id := range unfinished {
+		retry[id] = true
+	}
+
+	executed := make(map[string]bool)
+	if previous.Result != nil {
+		for _, mr := range previous.Result.ModelResults {
+			executed[mr.ModelID] = true
+		}
+	}
+	inSelection := make(map[string]bool, len(selected))
+	for _, model := range selected {
+		inSelection[model.ID] = true
+		if len(unfinished) > 0 && !executed[model.ID] {
+			retry[model.ID] = true
+		}
+	}
+
+	for _, testID := range failedTests {
+		for _, t := range m.Tests {
+			if t.ID == testID && inSelection[t.ModelName] {
+				retry[t.ModelName] = true
+			}
+		}
+	}
+
+	// Failed tests that are not attached to a model rerun the whole build
+	if len(retry) == 0 {
+		return append([]string{}, previous.Args...), nil
+	}
+
+	ids := make([]string, 0, len(retry))
+	for id := range retry {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	args := withoutFlags(previous.Args, "models", "exclude")
+	return append(args, "--models", strings.Join(ids, " ")), nil
+}
+
+// retrySelectArgs returns the arguments of a seed or test invocation whose
+// --select lists its failed and skipped nodes, or nil if there is nothing to retry
+func retrySelectArgs(previous *executor.RunResults, resourceType, separator string) []string {
+	unfinished := previous.Unfinished(resourceType)
+	if len(unfinished) == 0 {
+		return nil
+	}
+
+	args := withoutFlags(previous.Args, "select")
+	return append(args, "--select", strings.Join(unfinished, separator))
+}
+
+// withoutFlags returns args without the given string flags and their values
+func withoutFlags(args []string, names ...string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(result, args[i:]...)
+		}
+		if !strings.HasPrefix(arg, "-") {
+			result = append(result, arg)
+			continue
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		removed := false
+		for _, n := range names {
+			if name == n {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			result = append(result, arg)
+			continue
+		}
+		if !hasValue {
+			// Skip the flag's value
+			i++
+		}
+	}
+	return result
+}
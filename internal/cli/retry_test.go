@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+)
+
+func TestWithoutFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"separate value", []string{"--models", "a+", "--target", "prod"}, []string{"--target", "prod"}},
+		{"inline value", []string{"-models=a+", "--verbose"}, []string{"--verbose"}},
+		{"several flags", []string{"--exclude", "b", "--models", "a", "--fail-fast"}, []string{"--fail-fast"}},
+		{"none", []string{"--target", "prod"}, []string{"--target", "prod"}},
+		{"after terminator", []string{"--", "--models", "a"}, []string{"--", "--models", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withoutFlags(tt.args, "models", "exclude")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withoutFlags(%v) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetrySelectArgs(t *testing.T) {
+	previous := &executor.RunResults{
+		Command: "seed",
+		Args:    []string{"--select", "a,b,c", "--verbose"},
+		Nodes: []executor.NodeResult{
+			{ResourceType: executor.ResourceTypeSeed, Name: "a", Status: executor.StatusSuccess},
+			{ResourceType: executor.ResourceTypeSeed, Name: "b", Status: executor.StatusFailed},
+			{ResourceType: executor.ResourceTypeSeed, Name: "c", Status: executor.StatusSkipped},
+		},
+	}
+
+	got := retrySelectArgs(previous, executor.ResourceTypeSeed, ",")
+	if want := []string{"--verbose", "--select", "b,c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("retrySelectArgs() = %v, want %v", got, want)
+	}
+
+	if got := retrySelectArgs(previous, executor.ResourceTypeTest, " "); got != nil {
+		t.Errorf("retrySelectArgs(test) = %v, want nil", got)
+	}
+}
+
+// TestRetryRun fails a model, fixes the cause and retries only the failed
+// model and its skipped downstream model
+func TestRetryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: shop
+version: 1.0.0
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	models := map[string]string{
+		"stg_orders":   `SELECT 1 AS id`,
+		"orders_daily": `SELECT * FROM {{ ref "stg_orders" }}`,
+		"stg_payments": `SELECT * FROM raw_payments`,
+		"payments":     `SELECT * FROM {{ ref "stg_payments" }}`,
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range models {
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", name+".sql"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--exclude", "orders_daily"}); err == nil {
+		t.Fatal("RunCommand() succeeded, want failure of stg_payments")
+	}
+
+	// Fix the failure
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE raw_payments AS SELECT 10 AS amount"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := RetryCommand([]string{}); err != nil {
+		t.Fatalf("RetryCommand() error = %v", err)
+	}
+
+	retried, err := executor.ReadRunResults(runResultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"--models", "payments stg_payments"}; !reflect.DeepEqual(retried.Args, want) {
+		t.Errorf("retry args = %v, want %v", retried.Args, want)
+	}
+	var executed []string
+	for _, mr := range retried.Result.ModelResults {
+		if mr.Status != executor.StatusSuccess {
+			t.Errorf("%s status = %s, want success", mr.ModelID, mr.Status)
+		}
+		executed = append(executed, mr.ModelID)
+	}
+	if want := []string{"stg_payments", "payments"}; !reflect.DeepEqual(executed, want) {
+		t.Errorf("retried models = %v, want %v", executed, want)
+	}
+
+	out, err := captureStdout(t, func() error { return RetryCommand([]string{}) })
+	if err != nil {
+		t.Fatalf("RetryCommand() after success error = %v", err)
+	}
+	if !strings.Contains(out, "Nothing to retry") {
+		t.Errorf("output = %q, want nothing to retry", out)
+	}
+}
+
+func TestRetryWithoutRunResults(t *testing.T) {
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RetryCommand([]string{}); err == nil || !strings.Contains(err.Error(), "no previous invocation") {
+		t.Errorf("RetryCommand() error = %v, want no previous invocation", err)
+	}
+}
+
+// TestRetryMicrobatch backfills a range of daily batches, one of which fails,
+// and retries only the failed batch
+func TestRetryMicrobatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: rail
+version: 1.0.0
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := `
+version: 2
+sources:
+  - name: rail
+    tables:
+      - name: trips
+        identifier: raw_trips
+        event_time: departed_at
+`
+	// The batch of 2024-01-02 fails until the fixes table exists
+	model := `{{ config "materialized" "incremental" "incremental_strategy" "microbatch" "event_time" "trip_date" "batch_size" "day" "begin" "2024-01-01" }}
+SELECT date(departed_at) AS trip_date, COUNT(*) AS trips
+FROM {{ source "rail" "trips" }} t{{ if eq batch_start "2024-01-02" }} LEFT JOIN fixes ON 1 = 0{{ end }}
+GROUP BY date(departed_at)`
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "models", "sources.yml"), []byte(sources), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "models", "trips_daily.sql"), []byte(model), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE raw_trips AS
+SELECT '2024-01-01 08:00:00' AS departed_at UNION ALL
+SELECT '2024-01-01 09:00:00' UNION ALL
+SELECT '2024-01-02 10:00:00' UNION ALL
+SELECT '2024-01-03 11:00:00' UNION ALL
+SELECT '2024-01-04 12:00:00'`); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--event-time-start", "2024-01-01", "--event-time-end", "2024-01-04"}); err == nil {
+		t.Fatal("RunCommand() succeeded, want failure of the 2024-01-02 batch")
+	}
+
+	first, err := executor.ReadRunResults(runResultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, br := range first.Result.ModelResults[0].Batches {
+		statuses = append(statuses, br.Batch.Start.Format("2006-01-02")+"="+string(br.Status))
+	}
+	if want := []string{"2024-01-01=success", "2024-01-02=failed", "2024-01-03=success"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("batch statuses = %v, want %v", statuses, want)
+	}
+
+	// Fix the failure
+	if _, err := db.Exec("CREATE TABLE fixes (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RetryCommand([]string{}); err != nil {
+		t.Fatalf("RetryCommand() error = %v", err)
+	}
+
+	retried, err := executor.ReadRunResults(runResultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := retried.Result.ModelResults[0].Batches
+	if len(batches) != 1 || batches[0].Batch.Start.Format("2006-01-02") != "2024-01-02" || batches[0].Status != executor.StatusSuccess {
+		t.Errorf("retried batches = %+v, want the 2024-01-02 batch only", batches)
+	}
+
+	var got string
+	if err := db.QueryRow("SELECT group_concat(trip_date || '=' || trips) FROM (SELECT * FROM trips_daily ORDER BY trip_date)").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01=2,2024-01-02=1,2024-01-03=1"; got != want {
+		t.Errorf("trips_daily = %q, want %q", got, want)
+	}
+}
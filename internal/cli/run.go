@@ -5,56 +5,116 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/jpconstantineau/gorchata/internal/config"
 	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/manifest"
 	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
 	testExecutor "github.com/jpconstantineau/gorchata/internal/domain/test/executor"
-	"github.com/jpconstantineau/gorchata/internal/domain/test/generic"
 	"github.com/jpconstantineau/gorchata/internal/domain/test/storage"
 	"github.com/jpconstantineau/gorchata/internal/platform"
 	"github.com/jpconstantineau/gorchata/internal/platform/sqlite"
 	"github.com/jpconstantineau/gorchata/internal/template"
 )
 
-// RunCommand executes SQL transformations against the database
-func RunCommand(args []string) error {
-	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+// runFlags holds the flags accepted by the run command
+type runFlags struct {
+	CommonFlags
+	RunTests       bool
+	Threads        int
+	EventTimeStart string
+	EventTimeEnd   string
+	Atomic         bool
+	PartialFailure string
+}
 
-	var common CommonFlags
-	AddCommonFlags(fs, &common)
+// newRunFlagSet creates the flag set of the run command.
+// build accepts the same flags since it forwards its arguments to run.
+func newRunFlagSet(name string, rf *runFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	AddCommonFlags(fs, &rf.CommonFlags)
+	AddExcludeFlag(fs, &rf.CommonFlags)
+	AddStateFlags(fs, &rf.CommonFlags)
 
 	// Add --test flag for run command
-	runTests := fs.Bool("test", false, "Run tests after executing models")
+	fs.BoolVar(&rf.RunTests, "test", false, "Run tests after executing models")
+
+	// Add --threads flag to control concurrent model execution
+	fs.IntVar(&rf.Threads, "threads", 0, "Maximum number of models to execute concurrently (overrides profile threads)")
+
+	// Backfill a range of event time with microbatch models
+	fs.StringVar(&rf.EventTimeStart, "event-time-start", "", "Build the batches of microbatch models from this date or timestamp")
+	fs.StringVar(&rf.EventTimeEnd, "event-time-end", "", "Build the batches of microbatch models up to this date or timestamp (exclusive)")
+
+	// Run all models in one transaction
+	fs.BoolVar(&rf.Atomic, "atomic", false, "Execute all models in one transaction, rolled back when a model fails")
+	fs.StringVar(&rf.PartialFailure, "partial-failure", "", "What an --atomic run keeps when models fail: rollback (default) or keep-successful")
+
+	return fs
+}
+
+// RunCommand executes SQL transformations against the database
+func RunCommand(args []string) error {
+	_, err := runModels("run", args, nil)
+	return err
+}
+
+// runModels executes the selected models on behalf of the named command
+// and records the invocation in the run artifacts under target/, returning
+// the recorded run results once models have executed. Microbatch models with
+// batches build those in place of their usual range.
+func runModels(command string, args []string, batches map[string][]materialization.Batch) (*executor.RunResults, error) {
+	var flags runFlags
+	fs := newRunFlagSet(command, &flags)
 
 	if err := fs.Parse(args); err != nil {
-		return fmt.Errorf("failed to parse flags: %w", err)
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	common := flags.CommonFlags
+
+	if flags.Threads < 0 {
+		return nil, fmt.Errorf("--threads must be a positive number, got %d", flags.Threads)
+	}
+
+	eventTimeStart, eventTimeEnd, err := parseEventTimeRange(flags.EventTimeStart, flags.EventTimeEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic, err := parseAtomicFlags(flags.Atomic, flags.PartialFailure)
+	if err != nil {
+		return nil, err
 	}
 
 	// Load configuration
 	cfg, err := config.Discover(common.Target)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Resolve project vars, overridden by --vars
+	vars, err := resolveVars(cfg, common.Vars)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate we have at least one model path
 	if len(cfg.Project.ModelPaths) == 0 {
-		return fmt.Errorf("no model paths configured in project")
+		return nil, fmt.Errorf("no model paths configured in project")
 	}
 
 	// Create database adapter based on output type
 	adapter, err := createAdapter(cfg.Output)
 	if err != nil {
-		return fmt.Errorf("failed to create database adapter: %w", err)
+		return nil, fmt.Errorf("failed to create database adapter: %w", err)
 	}
 
 	// Connect to database
 	ctx := context.Background()
 	if err := adapter.Connect(ctx); err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer adapter.Close()
 
@@ -62,106 +122,113 @@ func RunCommand(args []string) error {
 		fmt.Printf("Connected to %s database: %s\n", cfg.Output.Type, cfg.Output.Database)
 	}
 
-	// Load models from model paths
-	var allModels []*executor.Model
-	for _, modelPath := range cfg.Project.ModelPaths {
-		models, err := loadModelsFromDirectory(modelPath)
-		if err != nil {
-			return fmt.Errorf("failed to load models from %s: %w", modelPath, err)
-		}
-		allModels = append(allModels, models...)
+	// Build the project manifest: models, seeds, sources and tests
+	m, err := loadStateManifest(cfg, vars, common)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(allModels) == 0 {
-		return fmt.Errorf("no models found in model paths")
+	if len(m.Models) == 0 {
+		return nil, fmt.Errorf("no models found in model paths")
 	}
 
 	if common.Verbose {
-		fmt.Printf("Found %d model(s)\n", len(allModels))
+		fmt.Printf("Found %d model(s)\n", len(m.Models))
 	}
 
-	// Load seeds for template context
-	seedsMap, err := LoadSeedsForTemplateContext(cfg)
-	if err != nil {
-		return fmt.Errorf("failed to load seeds: %w", err)
+	if command == "snapshot" && len(selectForCommand(command, m.Models)) == 0 {
+		return nil, fmt.Errorf("no snapshots found in snapshot paths")
 	}
 
-	// Parse templates and extract config/dependencies
-	templateEngine := template.New()
-	tracker := newSimpleDependencyTracker()
-	templateEngineWithTracker := template.New(template.WithDependencyTracker(tracker))
-
-	for _, model := range allModels {
-		// Read model content
-		content, err := os.ReadFile(model.Path)
-		if err != nil {
-			return fmt.Errorf("failed to read model %s: %w", model.ID, err)
-		}
-
-		contentStr := string(content)
-
-		// Extract config from template
-		cfg := extractModelConfig(contentStr)
-
-		// Apply --full-refresh flag if set
-		if common.FullRefresh && cfg.Type == materialization.MaterializationIncremental {
-			cfg.FullRefresh = true
-		}
-
-		model.SetMaterializationConfig(cfg)
-
-		// Remove config() calls before parsing
-		contentStr = removeConfigCalls(contentStr)
-
-		// Store template content for engine to re-render with incremental context
-		model.SetTemplateContent(contentStr)
+	// Select models with --models and --exclude
+	selected, err := m.Select(common.Models, common.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model selection: %w", err)
+	}
+	models := selectForCommand(command, selected)
 
-		// Parse template
-		tmpl, err := templateEngineWithTracker.Parse(model.ID, contentStr)
-		if err != nil {
-			return fmt.Errorf("failed to parse template %s: %w", model.ID, err)
+	// Resolve ref() to unselected models to the --state target
+	if common.Defer {
+		if err := deferToState(ctx, adapter, m, models); err != nil {
+			return nil, err
 		}
+	}
 
-		// Render template
-		ctx := template.NewContext(
-			template.WithCurrentModel(model.ID),
-		)
-		ctx.Seeds = seedsMap
+	if common.Verbose {
+		fmt.Printf("Executing %d model(s)\n", len(models))
+	}
 
-		rendered, err := template.Render(tmpl, ctx, nil)
+	// Create execution engine; models and hooks can call the project's macros
+	templateEngine := template.New(template.WithMacros(m.Macros))
+	engine, err := executor.NewEngine(adapter, templateEngine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create execution engine: %w", err)
+	}
+	engine.SetThreads(resolveThreads(flags.Threads, cfg.Output))
+	// Every model's relation is registered so ref() resolves aliases of
+	// models that are not selected for this run
+	engine.SetRelations(m.Relations())
+	engine.SetVars(vars)
+	engine.SetTarget(m.Target)
+	engine.SetSeeds(m.SeedTables())
+	engine.SetSources(m.SourceTables())
+	engine.SetEventTimes(m.EventTimes(), m.SourceEventTimes())
+	engine.SetEventTimeRange(eventTimeStart, eventTimeEnd)
+	engine.SetBatches(batches)
+	engine.SetAtomic(atomic)
+
+	// Custom materializations are defined in the macro files of the project
+	materializations := materialization.NewDefaultRegistry()
+	if err := executor.LoadMaterializations(m.Macros, materializations, templateEngine); err != nil {
+		return nil, fmt.Errorf("failed to load materializations: %w", err)
+	}
+	engine.SetMaterializations(materializations)
+
+	if common.Verbose && engine.Threads() > 1 {
+		fmt.Printf("Using %d thread(s)\n", engine.Threads())
+	}
+	if common.Verbose && atomic != executor.AtomicOff {
+		fmt.Printf("Executing models in one transaction (partial failure: %s)\n", atomic)
+	}
+
+	// Run on-run-start hooks once before any model executes
+	if len(cfg.Project.OnRunStart) > 0 {
+		executed, err := engine.ExecuteHooks(ctx, "on-run-start", cfg.Project.OnRunStart, template.NewContext(template.WithVars(vars), template.WithTarget(m.Target)))
 		if err != nil {
-			return fmt.Errorf("failed to render template %s: %w", model.ID, err)
+			return nil, fmt.Errorf("on-run-start hook failed: %w", err)
 		}
-
-		model.SetCompiledSQL(rendered)
-
-		// Extract dependencies
-		deps := tracker.GetDependencies(model.ID)
-		for _, dep := range deps {
-			model.AddDependency(dep)
+		if common.Verbose {
+			fmt.Printf("Executed %d on-run-start statement(s)\n", len(executed))
 		}
 	}
 
-	// Filter models if specified
-	if common.Models != "" {
-		modelNames := strings.Split(common.Models, ",")
-		allModels = filterModelsByName(allModels, modelNames)
-	}
-
-	if common.Verbose {
-		fmt.Printf("Executing %d model(s)\n", len(allModels))
+	// Execute models
+	result, err := engine.ExecuteModels(ctx, models, common.FailFast)
+
+	// Run on-run-end hooks once after all models, even if some failed
+	var onRunEndErr error
+	if len(cfg.Project.OnRunEnd) > 0 {
+		executed, hookErr := engine.ExecuteHooks(ctx, "on-run-end", cfg.Project.OnRunEnd, template.NewContext(template.WithVars(vars), template.WithTarget(m.Target)))
+		if hookErr != nil {
+			onRunEndErr = fmt.Errorf("on-run-end hook failed: %w", hookErr)
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", onRunEndErr)
+		} else if common.Verbose {
+			fmt.Printf("Executed %d on-run-end statement(s)\n", len(executed))
+		}
 	}
 
-	// Create execution engine
-	engine, err := executor.NewEngine(adapter, templateEngine)
-	if err != nil {
-		return fmt.Errorf("failed to create execution engine: %w", err)
+	// Record the invocation, including failed and skipped models
+	var runResults *executor.RunResults
+	if result != nil {
+		runResults = newRunResults(command, args, cfg.Project.Name)
+		runResults.Result = result
+		if artifactErr := writeRunArtifacts(runResults, models); artifactErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write run artifacts: %v\n", artifactErr)
+		}
 	}
 
-	// Execute models
-	result, err := engine.ExecuteModels(ctx, allModels, common.FailFast)
 	if err != nil {
-		return fmt.Errorf("execution failed: %w", err)
+		return runResults, fmt.Errorf("execution failed: %w", err)
 	}
 
 	// Print results
@@ -169,13 +236,25 @@ func RunCommand(args []string) error {
 		fmt.Printf("\n")
 		for _, mr := range result.ModelResults {
 			status := "✓"
-			if mr.Status == executor.StatusFailed {
+			switch mr.Status {
+			case executor.StatusFailed:
 				status = "✗"
+			case executor.StatusSkipped:
+				status = "-"
+			case executor.StatusRolledBack:
+				status = "↺"
 			}
 			fmt.Printf("  %s %s (%.2fs)\n", status, mr.ModelID, mr.Duration().Seconds())
 			if mr.Error != "" {
 				fmt.Printf("    Error: %s\n", mr.Error)
 			}
+			for _, br := range mr.Batches {
+				batchStatus := "✓"
+				if br.Status == executor.StatusFailed {
+					batchStatus = "✗"
+				}
+				fmt.Printf("    %s batch %s (%d rows)\n", batchStatus, br.Batch, br.RowsAffected)
+			}
 		}
 		fmt.Printf("\n")
 	}
@@ -185,39 +264,76 @@ func RunCommand(args []string) error {
 		len(result.ModelResults),
 		result.Duration().Seconds())
 
+	printSkippedSummary(result)
+	printFailedBatches(result)
+	if result.RolledBackCount() > 0 {
+		fmt.Printf("Rolled back %d successful model(s): the database is as it was before the run\n", result.RolledBackCount())
+	}
+
 	if result.FailureCount() > 0 {
-		return fmt.Errorf("%d model(s) failed", result.FailureCount())
+		if result.SkippedCount() > 0 {
+			return runResults, fmt.Errorf("%d model(s) failed, %d model(s) skipped", result.FailureCount(), result.SkippedCount())
+		}
+		return runResults, fmt.Errorf("%d model(s) failed", result.FailureCount())
+	}
+
+	if onRunEndErr != nil {
+		return runResults, onRunEndErr
 	}
 
 	// Run tests if --test flag is set
-	if *runTests {
+	if flags.RunTests {
 		fmt.Println("\n========================================")
 		fmt.Println("Running tests...")
 		fmt.Println("========================================")
 
 		// Run tests using TestCommand logic
-		if err := runTestsAfterModels(ctx, cfg, adapter, common.Verbose); err != nil {
-			return fmt.Errorf("tests failed: %w", err)
+		if err := runTestsAfterModels(ctx, m, adapter, vars, common.Verbose); err != nil {
+			return runResults, fmt.Errorf("tests failed: %w", err)
 		}
 	}
 
-	return nil
+	return runResults, nil
 }
 
-// runTestsAfterModels executes tests after models have been run
-func runTestsAfterModels(ctx context.Context, cfg *config.Config, adapter platform.DatabaseAdapter, verbose bool) error {
-	// Create test registry
-	registry := generic.NewDefaultRegistry()
-
-	// Discover all tests
-	if verbose {
-		fmt.Println("Discovering tests...")
+// parseAtomicFlags returns the partial-failure policy of --atomic runs, or
+// executor.AtomicOff when --atomic is not set
+func parseAtomicFlags(atomic bool, partialFailure string) (executor.AtomicPolicy, error) {
+	if !atomic {
+		if partialFailure != "" {
+			return executor.AtomicOff, fmt.Errorf("--partial-failure requires --atomic")
+		}
+		return executor.AtomicOff, nil
 	}
 
-	allTests, err := testExecutor.DiscoverAllTests(cfg, registry)
+	policy, err := executor.ParseAtomicPolicy(partialFailure)
 	if err != nil {
-		return fmt.Errorf("failed to discover tests: %w", err)
+		return executor.AtomicOff, fmt.Errorf("invalid --partial-failure: %w", err)
 	}
+	return policy, nil
+}
+
+// selectForCommand returns the models a command executes: run leaves
+// snapshots out, snapshot executes only snapshots and build executes both.
+// Ephemeral models are never executed since they are inlined into the
+// models that ref() them.
+func selectForCommand(command string, models []*executor.Model) []*executor.Model {
+	selected := make([]*executor.Model, 0, len(models))
+	for _, model := range models {
+		if model.IsEphemeral() {
+			continue
+		}
+		if command == "build" || model.IsSnapshot() == (command == "snapshot") {
+			selected = append(selected, model)
+		}
+	}
+	return selected
+}
+
+// runTestsAfterModels executes tests after models have been run
+func runTestsAfterModels(ctx context.Context, m *manifest.Manifest, adapter platform.DatabaseAdapter, vars map[string]interface{}, verbose bool) error {
+	// Tests were discovered when the manifest was built
+	allTests := m.Tests
 
 	if len(allTests) == 0 {
 		fmt.Println("No tests found")
@@ -240,10 +356,11 @@ func runTestsAfterModels(ctx context.Context, cfg *config.Config, adapter platfo
 	}
 
 	// Create test engine
-	engine, err := testExecutor.NewTestEngine(adapter, nil, failureStore)
+	engine, err := testExecutor.NewTestEngine(adapter, template.New(template.WithMacros(m.Macros)), failureStore)
 	if err != nil {
 		return fmt.Errorf("failed to create test engine: %w", err)
 	}
+	engine.SetVars(vars)
 
 	// Create result writers
 	consoleWriter := testExecutor.NewConsoleResultWriter(os.Stdout, true)
@@ -281,145 +398,84 @@ func runTestsAfterModels(ctx context.Context, cfg *config.Config, adapter platfo
 	return nil
 }
 
-// createAdapter creates a database adapter based on output configuration
-func createAdapter(output *config.OutputConfig) (platform.DatabaseAdapter, error) {
-	switch output.Type {
-	case "sqlite":
-		connConfig := &platform.ConnectionConfig{
-			DatabasePath: output.Database,
-		}
-		return sqlite.NewSQLiteAdapter(connConfig), nil
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", output.Type)
-	}
-}
-
-// loadModelsFromDirectory loads all SQL models from a directory
-func loadModelsFromDirectory(dir string) ([]*executor.Model, error) {
-	var models []*executor.Model
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
+// printSkippedSummary lists the models that were skipped, grouped by the
+// failed upstream model that caused them to be skipped
+func printSkippedSummary(result *executor.ExecutionResult) {
+	if result.SkippedCount() == 0 {
+		return
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+	fmt.Printf("Skipped %d model(s) due to upstream failures:\n", result.SkippedCount())
+	skippedBy := result.SkippedModels()
+	for _, mr := range result.ModelResults {
+		if mr.Status != executor.StatusFailed {
 			continue
 		}
-
-		modelID := strings.TrimSuffix(entry.Name(), ".sql")
-		modelPath := filepath.Join(dir, entry.Name())
-
-		model, err := executor.NewModel(modelID, modelPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create model %s: %w", modelID, err)
+		skipped := skippedBy[mr.ModelID]
+		if len(skipped) == 0 {
+			continue
 		}
-
-		models = append(models, model)
+		fmt.Printf("  %s failed, skipped: %s\n", mr.ModelID, strings.Join(skipped, ", "))
 	}
-
-	return models, nil
 }
 
-// extractModelConfig extracts materialization config from SQL template
-func extractModelConfig(content string) materialization.MaterializationConfig {
-	config := materialization.DefaultConfig()
-
-	// Look for {{ config "materialized" "view" }} pattern (Go template syntax)
-	goTemplateRe := regexp.MustCompile(`{{\s*config\s+"materialized"\s+"(\w+)"\s*}}`)
-	matches := goTemplateRe.FindStringSubmatch(content)
-
-	if len(matches) > 1 {
-		switch matches[1] {
-		case "view":
-			config.Type = materialization.MaterializationView
-		case "table":
-			config.Type = materialization.MaterializationTable
-		case "incremental":
-			config.Type = materialization.MaterializationIncremental
+// printFailedBatches lists the failed batches of microbatch models, which
+// retry builds again
+func printFailedBatches(result *executor.ExecutionResult) {
+	for _, mr := range result.ModelResults {
+		failed := mr.FailedBatches()
+		if len(failed) == 0 {
+			continue
+		}
+		fmt.Printf("%s: %d of %d batch(es) failed:\n", mr.ModelID, len(failed), len(mr.Batches))
+		for _, batch := range failed {
+			fmt.Printf("  %s\n", batch)
 		}
-		return config
 	}
+}
 
-	// Fall back to {{ config(materialized='view') }} pattern (legacy Jinja-style syntax)
-	legacyRe := regexp.MustCompile(`{{\s*config\s*\(\s*materialized\s*=\s*['"](\w+)['"]\s*\)\s*}}`)
-	matches = legacyRe.FindStringSubmatch(content)
-
-	if len(matches) > 1 {
-		switch matches[1] {
-		case "view":
-			config.Type = materialization.MaterializationView
-		case "table":
-			config.Type = materialization.MaterializationTable
-		case "incremental":
-			config.Type = materialization.MaterializationIncremental
+// parseEventTimeRange parses the --event-time-start and --event-time-end
+// flags; an unset flag gives a zero time
+func parseEventTimeRange(startFlag, endFlag string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if startFlag != "" {
+		if start, err = materialization.ParseEventTime(startFlag); err != nil {
+			return start, end, fmt.Errorf("--event-time-start: %w", err)
 		}
-		return config
 	}
-
-	// Fall back to -- Materialization: table comment (old format)
-	oldFormatRe := regexp.MustCompile(`--\s*Materialization:\s*(\w+)`)
-	matches = oldFormatRe.FindStringSubmatch(content)
-
-	if len(matches) > 1 {
-		switch matches[1] {
-		case "view":
-			config.Type = materialization.MaterializationView
-		case "table":
-			config.Type = materialization.MaterializationTable
-		case "incremental":
-			config.Type = materialization.MaterializationIncremental
+	if endFlag != "" {
+		if end, err = materialization.ParseEventTime(endFlag); err != nil {
+			return start, end, fmt.Errorf("--event-time-end: %w", err)
 		}
 	}
-
-	return config
-}
-
-// removeConfigCalls removes {{ config ... }} from content (both Go template and legacy syntax)
-func removeConfigCalls(content string) string {
-	// Remove Go template syntax: {{ config "key" "value" }}
-	goTemplateRe := regexp.MustCompile(`{{\s*config\s+"[^"]+"\s+"[^"]+"\s*}}`)
-	content = goTemplateRe.ReplaceAllString(content, "")
-
-	// Remove legacy Jinja-style syntax: {{ config(key='value') }}
-	legacyRe := regexp.MustCompile(`{{\s*config\s*\([^}]+\)\s*}}`)
-	return legacyRe.ReplaceAllString(content, "")
-}
-
-// simpleDependencyTracker tracks template dependencies
-type simpleDependencyTracker struct {
-	dependencies map[string][]string
-}
-
-func newSimpleDependencyTracker() *simpleDependencyTracker {
-	return &simpleDependencyTracker{
-		dependencies: make(map[string][]string),
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return start, end, fmt.Errorf("--event-time-start must be before --event-time-end")
 	}
+	return start, end, nil
 }
 
-func (t *simpleDependencyTracker) AddDependency(from, to string) error {
-	t.dependencies[from] = append(t.dependencies[from], to)
-	return nil
-}
-
-func (t *simpleDependencyTracker) GetDependencies(modelID string) []string {
-	return t.dependencies[modelID]
-}
-
-// filterModelsByName filters models by name
-func filterModelsByName(models []*executor.Model, names []string) []*executor.Model {
-	nameSet := make(map[string]bool)
-	for _, name := range names {
-		nameSet[strings.TrimSpace(name)] = true
+// resolveThreads determines the worker count for model execution.
+// The --threads flag takes precedence over the threads setting of the target output.
+func resolveThreads(flagThreads int, output *config.OutputConfig) int {
+	if flagThreads > 0 {
+		return flagThreads
+	}
+	if output != nil && output.Threads > 0 {
+		return output.Threads
 	}
+	return 1
+}
 
-	var filtered []*executor.Model
-	for _, model := range models {
-		if nameSet[model.ID] {
-			filtered = append(filtered, model)
+// createAdapter creates a database adapter based on output configuration
+func createAdapter(output *config.OutputConfig) (platform.DatabaseAdapter, error) {
+	switch output.Type {
+	case "sqlite":
+		connConfig := &platform.ConnectionConfig{
+			DatabasePath: output.Database,
 		}
+		return sqlite.NewSQLiteAdapter(connConfig), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", output.Type)
 	}
-
-	return filtered
 }
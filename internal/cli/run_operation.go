@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/platform"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// RunOperationCommand renders a macro and executes its statements against
+// the target, for maintenance work that belongs to no model
+func RunOperationCommand(args []string) error {
+	fs := flag.NewFlagSet("run-operation", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gorchata run-operation <macro> [--args '{...}'] [options]")
+		fs.PrintDefaults()
+	}
+
+	var target, vars, macroArgs string
+	var verbose, printRows bool
+	fs.StringVar(&target, "target", "", "Target environment (from profiles.yml)")
+	fs.StringVar(&vars, "vars", "", "Variables as a JSON or YAML map, overriding project vars (e.g. '{\"start_date\": \"2024-01-01\"}')")
+	fs.StringVar(&macroArgs, "args", "", "Arguments of the macro as a JSON or YAML map of parameter names to values (e.g. '{\"days\": 30}')")
+	fs.BoolVar(&printRows, "print", false, "Print the rows returned by queries as a table")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose output")
+
+	// The macro name comes first, as in run-operation purge --args '{...}'
+	var macro string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		macro, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	if macro == "" && fs.NArg() > 0 {
+		macro = fs.Arg(0)
+	}
+	if macro == "" {
+		return fmt.Errorf("missing macro name: usage: gorchata run-operation <macro> [--args '{...}']")
+	}
+
+	operationArgs, err := config.ParseVars(macroArgs)
+	if err != nil {
+		return fmt.Errorf("failed to parse --args: %w", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Discover(target)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	resolvedVars, err := resolveVars(cfg, vars)
+	if err != nil {
+		return err
+	}
+
+	// The manifest loads the macros and resolves the relations ref() returns
+	m, err := loadManifest(cfg, resolvedVars, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.Macros.Lookup(macro); !ok {
+		return fmt.Errorf("macro %s is not defined in %s", macro, strings.Join(cfg.Project.MacroPaths, ", "))
+	}
+
+	adapter, err := createAdapter(cfg.Output)
+	if err != nil {
+		return fmt.Errorf("failed to create database adapter: %w", err)
+	}
+
+	ctx := context.Background()
+	if err := adapter.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer adapter.Close()
+
+	if verbose {
+		fmt.Printf("Connected to %s database: %s\n", cfg.Output.Type, cfg.Output.Database)
+	}
+
+	engine, err := executor.NewEngine(adapter, template.New(template.WithMacros(m.Macros)))
+	if err != nil {
+		return fmt.Errorf("failed to create execution engine: %w", err)
+	}
+	engine.SetRelations(m.Relations())
+	engine.SetVars(resolvedVars)
+	engine.SetSeeds(m.SeedTables())
+	engine.SetSources(m.SourceTables())
+	engine.SetTarget(m.Target)
+
+	fmt.Printf("Running operation %s\n", macro)
+	result, err := engine.RunOperation(ctx, macro, operationArgs)
+	printOperationResult(os.Stdout, result, printRows, verbose)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nOperation %s completed: %d statement(s) in %.2fs\n", macro, len(result.Statements), result.Duration().Seconds())
+	return nil
+}
+
+// printOperationResult reports the statements an operation executed and,
+// with printRows, the rows its queries returned
+func printOperationResult(w io.Writer, result *executor.OperationResult, printRows, verbose bool) {
+	for i, statement := range result.Statements {
+		summary := firstLine(statement.SQL)
+		if verbose {
+			summary = statement.SQL
+		}
+
+		if statement.Rows == nil {
+			fmt.Fprintf(w, "  %d. %s (%d row(s) affected)\n", i+1, summary, statement.RowsAffected)
+			continue
+		}
+		fmt.Fprintf(w, "  %d. %s (%d row(s) returned)\n", i+1, summary, len(statement.Rows.Rows))
+		if printRows && len(statement.Rows.Columns) > 0 {
+			printQueryResult(w, statement.Rows)
+		}
+	}
+}
+
+// printQueryResult prints the rows of a query as a table
+func printQueryResult(w io.Writer, rows *platform.QueryResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	separators := make([]string, len(rows.Columns))
+	for i, column := range rows.Columns {
+		separators[i] = strings.Repeat("-", len(column))
+	}
+	fmt.Fprintf(tw, "\n     %s\n", strings.Join(rows.Columns, "\t"))
+	fmt.Fprintf(tw, "     %s\n", strings.Join(separators, "\t"))
+
+	for _, row := range rows.Rows {
+		values := make([]string, len(row))
+		for i, value := range row {
+			switch v := value.(type) {
+			case nil:
+				values[i] = "NULL"
+			case []byte:
+				values[i] = string(v)
+			default:
+				values[i] = fmt.Sprint(v)
+			}
+		}
+		fmt.Fprintf(tw, "     %s\n", strings.Join(values, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// firstLine returns the first line of a statement, marking that it goes on
+func firstLine(sql string) string {
+	if i := strings.IndexByte(sql, '\n'); i >= 0 {
+		return strings.TrimSpace(sql[:i]) + " ..."
+	}
+	return sql
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunOperation runs macros of the project against the target with
+// arguments, vars and the target in their template context
+func TestRunOperation(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := "name: shop\nversion: 1.0.0\nvars:\n  keep_status: open\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"macros/maintenance.sql": `{% macro purge(before int, status string = "") %}
+DELETE FROM {{ ref "orders" }}
+WHERE id < {{ .before }} AND status <> '{{ if .status }}{{ .status }}{{ else }}{{ var "keep_status" }}{{ end }}';
+SELECT id, status, '{{ target.Name }}' AS target FROM {{ ref "orders" }} ORDER BY id;
+{% endmacro %}
+
+{{ define "index_orders" }}CREATE INDEX IF NOT EXISTS idx_orders_{{ .column }} ON {{ ref "orders" }} ({{ .column }}){{ end }}`,
+		"models/orders.sql": `{{ config "alias" "shop_orders" }}
+SELECT 1 AS id, 'open' AS status UNION ALL SELECT 2, 'closed' UNION ALL SELECT 3, 'closed'`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	output, err := captureStdout(t, func() error {
+		return RunOperationCommand([]string{"purge", "--args", `{"before": 3}`, "--print"})
+	})
+	if err != nil {
+		t.Fatalf("RunOperationCommand() error = %v", err)
+	}
+	for _, want := range []string{
+		"DELETE FROM shop_orders ... (1 row(s) affected)",
+		"(2 row(s) returned)",
+		"3   closed  dev",
+		"completed: 2 statement(s)",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+
+	if err := RunOperationCommand([]string{"index_orders", "--args", "column: status"}); err != nil {
+		t.Fatalf("RunOperationCommand(index_orders) error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var indexes int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_orders_status'").Scan(&indexes); err != nil {
+		t.Fatal(err)
+	}
+	if indexes != 1 {
+		t.Errorf("expected index_orders to create idx_orders_status")
+	}
+
+	errorCases := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{}, "missing macro name"},
+		{[]string{"vacuum_all"}, "macro vacuum_all is not defined"},
+		{[]string{"purge"}, "missing argument before"},
+		{[]string{"purge", "--args", `{"before": "soon"}`}, "argument before: expected int"},
+	}
+	for _, tc := range errorCases {
+		err := RunOperationCommand(tc.args)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("RunOperationCommand(%v) error = %v, want %q", tc.args, err, tc.wantErr)
+		}
+	}
+}
@@ -3,12 +3,15 @@ package cli
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
 	_ "modernc.org/sqlite"
 )
 
@@ -537,3 +540,2040 @@ WHERE 1 = 0  -- This should be removed with --full-refresh
 		t.Errorf("Table incremental_model not found after --full-refresh: %v", err)
 	}
 }
+
+// TestResolveThreads tests precedence between the --threads flag and the profile setting
+func TestResolveThreads(t *testing.T) {
+	tests := []struct {
+		name        string
+		flagThreads int
+		output      *config.OutputConfig
+		want        int
+	}{
+		{"defaults to one", 0, &config.OutputConfig{}, 1},
+		{"nil output", 0, nil, 1},
+		{"profile threads", 0, &config.OutputConfig{Threads: 4}, 4},
+		{"flag overrides profile", 8, &config.OutputConfig{Threads: 4}, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveThreads(tt.flagThreads, tt.output); got != tt.want {
+				t.Errorf("resolveThreads() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunWithThreads tests that independent models run with --threads
+func TestRunWithThreads(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: test_project
+version: 1.0.0
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+      threads: 2
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modelsDir := filepath.Join(tmpDir, "models")
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	models := map[string]string{
+		"a.sql":    `{{ config "materialized" "table" }} SELECT 1 AS id`,
+		"b.sql":    `{{ config "materialized" "table" }} SELECT 2 AS id`,
+		"c.sql":    `{{ config "materialized" "table" }} SELECT 3 AS id`,
+		"mart.sql": `{{ config "materialized" "table" }} SELECT id FROM {{ ref "a" }} UNION ALL SELECT id FROM {{ ref "b" }} UNION ALL SELECT id FROM {{ ref "c" }}`,
+	}
+	for name, content := range models {
+		if err := os.WriteFile(filepath.Join(modelsDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--threads", "4"}); err != nil {
+		t.Fatalf("RunCommand() error = %v, want nil", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM mart").Scan(&count); err != nil {
+		t.Fatalf("failed to query mart: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("mart row count = %d, want 3", count)
+	}
+
+	if err := RunCommand([]string{"--threads", "-1"}); err == nil {
+		t.Error("expected error for negative --threads")
+	}
+}
+
+// TestRunSkipsDownstreamOfFailure tests that models downstream of a failed model are skipped
+func TestRunSkipsDownstreamOfFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: test_project\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modelsDir := filepath.Join(tmpDir, "models")
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	models := map[string]string{
+		"broken.sql":     `{{ config "materialized" "table" }} SELECT * FROM missing_table`,
+		"downstream.sql": `{{ config "materialized" "table" }} SELECT * FROM {{ ref "broken" }}`,
+		"healthy.sql":    `{{ config "materialized" "table" }} SELECT 1 AS id`,
+	}
+	for name, content := range models {
+		if err := os.WriteFile(filepath.Join(modelsDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	err = RunCommand([]string{})
+	if err == nil {
+		t.Fatal("RunCommand() expected error for failed model")
+	}
+	if !strings.Contains(err.Error(), "1 model(s) skipped") {
+		t.Errorf("RunCommand() error = %v, want skipped count", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='downstream'").Scan(&count)
+	if count != 0 {
+		t.Error("downstream table should not be created when upstream fails")
+	}
+
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='healthy'").Scan(&count)
+	if count != 1 {
+		t.Error("independent model should still be executed")
+	}
+}
+
+// TestRunHooks tests model pre/post hooks and project on-run-start/on-run-end hooks
+func TestRunHooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: hooks_project
+version: 1.0.0
+on-run-start:
+  - "CREATE TABLE IF NOT EXISTS audit (event TEXT)"
+  - "INSERT INTO audit VALUES ('start')"
+on-run-end: "INSERT INTO audit VALUES ('end')"
+models:
+  hooks_project:
+    +post-hook: "INSERT INTO audit VALUES ('built {{ this }}')"
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modelsDir := filepath.Join(tmpDir, "models")
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	modelContent := `{{ config "materialized" "table" }}
+{{ config "pre_hook" "INSERT INTO audit VALUES ('pre')" }}
+{{ config "post_hook" "CREATE INDEX idx_orders_id ON {{ this }} (id)" }}
+SELECT 1 AS id`
+	if err := os.WriteFile(filepath.Join(modelsDir, "orders.sql"), []byte(modelContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v, want nil", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT event FROM audit ORDER BY rowid")
+	if err != nil {
+		t.Fatalf("failed to query audit: %v", err)
+	}
+	defer rows.Close()
+
+	var events []string
+	for rows.Next() {
+		var event string
+		if err := rows.Scan(&event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+
+	want := []string{"start", "pre", "built orders", "end"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("audit events = %v, want %v", events, want)
+	}
+
+	var indexCount int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='index' AND name='idx_orders_id'").Scan(&indexCount)
+	if indexCount != 1 {
+		t.Error("expected post_hook to create index idx_orders_id")
+	}
+}
+
+func TestRunModelConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: config_project
+version: 1.0.0
+model_paths:
+  - models
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modelsDir := filepath.Join(tmpDir, "models")
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	models := map[string]string{
+		"src.sql": `{{ config "materialized" "table" "alias" "source_rows" "tags" (list "raw") }}
+SELECT 1 AS id, 'a' AS value UNION ALL SELECT 2, 'b'`,
+		"inc.sql": `{{ config "materialized" "incremental" "unique_key" "id" }}
+{{ config "indexes" (list (dict "columns" (list "value"))) }}
+SELECT id, value FROM {{ ref "src" }}`,
+		"legacy.sql": `{{ config(materialized='view', tags='legacy') }}
+SELECT * FROM {{ ref "inc" }}`,
+		"off.sql": `{{ config "enabled" false }}
+SELECT 1 AS id`,
+	}
+	for name, content := range models {
+		if err := os.WriteFile(filepath.Join(modelsDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second run exercises the incremental merge, which requires unique_key
+	for i := 0; i < 2; i++ {
+		if err := RunCommand([]string{}); err != nil {
+			t.Fatalf("RunCommand() run %d error = %v, want nil", i+1, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	objects := map[string]int{}
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type IN ('table', 'view', 'index')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		objects[name]++
+	}
+	rows.Close()
+
+	for _, name := range []string{"source_rows", "inc", "legacy", "inc__value"} {
+		if objects[name] == 0 {
+			t.Errorf("expected database object %s to exist, have %v", name, objects)
+		}
+	}
+	for _, name := range []string{"src", "off"} {
+		if objects[name] != 0 {
+			t.Errorf("database object %s should not exist", name)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM inc").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("inc row count = %d, want 2 (no duplicates after incremental run)", count)
+	}
+}
+
+func TestRunDependsOnDisabledModel(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	projectConfig := `
+name: disabled_project
+version: 1.0.0
+model_paths:
+  - models
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, filepath.Join(tmpDir, "test.db"))
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modelsDir := filepath.Join(tmpDir, "models")
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelsDir, "off.sql"), []byte(`{{ config "enabled" false }}SELECT 1 AS id`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelsDir, "child.sql"), []byte(`SELECT * FROM {{ ref "off" }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	err = RunCommand([]string{})
+	if err == nil || !strings.Contains(err.Error(), "disabled model off") {
+		t.Errorf("RunCommand() error = %v, want error about disabled model", err)
+	}
+}
+
+func TestRunWithVars(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: vars_project
+version: 1.0.0
+vars:
+  min_id: 1
+  label: project
+on-run-end: 'CREATE TABLE IF NOT EXISTS run_label AS SELECT ''{{ var "label" }}'' AS label'
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for dir, files := range map[string]map[string]string{
+		"models": {
+			"numbers.sql": `{{ config "materialized" "incremental" "unique_key" "id" }}
+SELECT id, '{{ var "suffix" "none" }}' AS suffix
+FROM (SELECT 1 AS id UNION ALL SELECT 2 UNION ALL SELECT 3)
+WHERE id >= {{ var "min_id" }}`,
+		},
+		"tests": {
+			"assert_min_id.sql": `SELECT * FROM numbers WHERE id < {{ var "min_id" }}`,
+		},
+	} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(tmpDir, dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// --vars overrides min_id and label, suffix falls back to its default
+	if err := RunCommand([]string{"--vars", `{"min_id": 2, "label": "cli"}`}); err != nil {
+		t.Fatalf("RunCommand() error = %v, want nil", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	var suffix string
+	if err := db.QueryRow("SELECT COUNT(*), MIN(suffix) FROM numbers").Scan(&count, &suffix); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || suffix != "none" {
+		t.Errorf("numbers has %d rows with suffix %q, want 2 rows with suffix none", count, suffix)
+	}
+
+	var label string
+	if err := db.QueryRow("SELECT label FROM run_label").Scan(&label); err != nil {
+		t.Fatal(err)
+	}
+	if label != "cli" {
+		t.Errorf("on-run-end label = %q, want cli", label)
+	}
+
+	// The singular test renders var() too: it passes with min_id 2
+	if err := TestCommand([]string{"--vars", "min_id: 2"}); err != nil {
+		t.Errorf("TestCommand() error = %v, want nil", err)
+	}
+	if err := TestCommand([]string{"--vars", "min_id: 3"}); err == nil {
+		t.Error("TestCommand() with min_id 3 should fail since id 2 is below it")
+	}
+
+	// The incremental re-render uses the overridden vars as well
+	if err := RunCommand([]string{"--vars", `{"min_id": 1, "suffix": "x"}`}); err != nil {
+		t.Fatalf("second RunCommand() error = %v, want nil", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM numbers WHERE suffix = 'x'").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("after incremental run %d rows have suffix x, want 3", count)
+	}
+
+	if err := RunCommand([]string{"--vars", `{"min_id": `}); err == nil {
+		t.Error("expected error for invalid --vars")
+	}
+}
+
+// TestRunNestedModels verifies that models in nested folders are executed
+// and that the run writes the project manifest
+func TestRunNestedModels(t *testing.T) {
+	tmpDir := setupLsProject(t)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join("target", "manifest.json")); err != nil {
+		t.Errorf("target/manifest.json not written: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, relation := range []string{"stg_orders", "fct_orders", "finance_revenue"} {
+		var count int
+		if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", relation)).Scan(&count); err != nil {
+			t.Errorf("relation %s not built: %v", relation, err)
+		}
+	}
+}
+
+// TestRunWithSelector verifies graph selection with --models and --exclude
+func TestRunWithSelector(t *testing.T) {
+	tmpDir := setupLsProject(t)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tableExists := func(name string) bool {
+		var found string
+		err := db.QueryRow("SELECT name FROM sqlite_master WHERE name = ?", name).Scan(&found)
+		return err == nil
+	}
+
+	// Upstream of fct_orders, leaving out stg_orders
+	if err := RunCommand([]string{"--models", "+fct_orders", "--exclude", "stg_orders"}); err == nil {
+		t.Fatal("expected fct_orders to fail without stg_orders")
+	}
+
+	if err := RunCommand([]string{"--models", "+fct_orders"}); err != nil {
+		t.Fatalf("RunCommand(+fct_orders) error = %v", err)
+	}
+	if !tableExists("stg_orders") || !tableExists("fct_orders") {
+		t.Error("expected stg_orders and fct_orders to be built")
+	}
+	if tableExists("finance_revenue") {
+		t.Error("revenue should not be built by +fct_orders")
+	}
+
+	// A model whose dependencies are not selected runs against the existing tables
+	if err := RunCommand([]string{"--models", "revenue"}); err != nil {
+		t.Fatalf("RunCommand(revenue) error = %v", err)
+	}
+	if !tableExists("finance_revenue") {
+		t.Error("expected revenue to be built")
+	}
+
+	if err := RunCommand([]string{"--models", "unknown:x"}); err == nil || !strings.Contains(err.Error(), "invalid model selection") {
+		t.Errorf("RunCommand() error = %v, want invalid model selection", err)
+	}
+}
+
+// TestRunWritesRunArtifacts verifies run_results.json and the compiled/run trees
+func TestRunWritesRunArtifacts(t *testing.T) {
+	tmpDir := setupLsProject(t)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join("target", "run_results.json"))
+	if err != nil {
+		t.Fatalf("run_results.json not written: %v", err)
+	}
+
+	var runResults struct {
+		Metadata struct {
+			InvocationID string `json:"invocation_id"`
+			Command      string `json:"command"`
+		} `json:"metadata"`
+		Status  string `json:"status"`
+		Results []struct {
+			UniqueID     string `json:"unique_id"`
+			Status       string `json:"status"`
+			CompiledCode string `json:"compiled_code"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &runResults); err != nil {
+		t.Fatalf("invalid run_results.json: %v", err)
+	}
+
+	if runResults.Metadata.InvocationID == "" || runResults.Metadata.Command != "run" {
+		t.Errorf("metadata = %+v", runResults.Metadata)
+	}
+	if runResults.Status != "success" || len(runResults.Results) != 3 {
+		t.Errorf("status = %s with %d results, want success with 3", runResults.Status, len(runResults.Results))
+	}
+	for _, r := range runResults.Results {
+		if r.Status != "success" || r.CompiledCode == "" {
+			t.Errorf("result %+v, want success with compiled code", r)
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join("target", "compiled", "shop", "marts", "finance", "revenue.sql"),
+		filepath.Join("target", "compiled", "shop", "stg_orders.sql"),
+		filepath.Join("target", "run", "shop", "marts", "fct_orders.sql"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("artifact %s not written: %v", path, err)
+		}
+	}
+}
+
+// TestRunWithStateDefer runs modified models in dev, reading unselected
+// upstream models from the prod target recorded in the state manifest
+func TestRunWithStateDefer(t *testing.T) {
+	tmpDir := t.TempDir()
+	devDB := filepath.Join(tmpDir, "dev.db")
+	prodDB := filepath.Join(tmpDir, "prod.db")
+
+	projectConfig := `
+name: shop
+version: 1.0.0
+models:
+  shop:
+    +materialized: table
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+    prod:
+      type: sqlite
+      database: %s
+`, devDB, prodDB)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writeModel := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tmpDir, "models", name+".sql")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeModel("stg_orders", `SELECT 1 AS id`)
+	writeModel("fct_orders", `SELECT * FROM {{ ref "stg_orders" }}`)
+	writeModel("report", `SELECT id FROM {{ ref "fct_orders" }}`)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Build prod and keep its manifest as the state
+	if err := RunCommand([]string{"--target", "prod"}); err != nil {
+		t.Fatalf("RunCommand(prod) error = %v", err)
+	}
+	stateDir := filepath.Join(tmpDir, "prod-artifacts")
+	data, err := os.ReadFile(filepath.Join("target", "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "manifest.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--defer"}); err == nil || !strings.Contains(err.Error(), "--state") {
+		t.Errorf("RunCommand(--defer) error = %v, want error mentioning --state", err)
+	}
+
+	writeModel("report", `SELECT id, 2 AS version FROM {{ ref "fct_orders" }}`)
+
+	if err := RunCommand([]string{"--models", "state:modified+", "--state", stateDir, "--defer"}); err != nil {
+		t.Fatalf("RunCommand(state:modified+) error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", devDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var tables []string
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if len(tables) != 1 || tables[0] != "report" {
+		t.Errorf("dev tables = %v, want only report", tables)
+	}
+
+	var id, version int
+	if err := db.QueryRow("SELECT id, version FROM report").Scan(&id, &version); err != nil {
+		t.Fatalf("failed to query report: %v", err)
+	}
+	if id != 1 || version != 2 {
+		t.Errorf("report = (%d, %d), want (1, 2)", id, version)
+	}
+
+	compiled, err := os.ReadFile(filepath.Join("target", "run", "shop", "report.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(compiled), "state.fct_orders") {
+		t.Errorf("report run SQL = %q, want reference to state.fct_orders", compiled)
+	}
+}
+
+// TestRunIncrementalStrategies runs incremental models twice with a different
+// batch and checks how each strategy applied the second batch
+func TestRunIncrementalStrategies(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: strategies
+version: 1.0.0
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	models := map[string]string{
+		"events.sql": `{{ config "materialized" "incremental" "incremental_strategy" "append" }}
+SELECT {{ var "batch" }} AS batch`,
+		"customers.sql": `{{ config "materialized" "incremental" "incremental_strategy" "merge" "unique_key" "id" "merge_exclude_columns" "first_batch" }}
+SELECT 1 AS id, 'name{{ var "batch" }}' AS name, {{ var "batch" }} AS first_batch`,
+		"daily.sql": `{{ config "materialized" "incremental" "incremental_strategy" "insert_overwrite" "partition_by" "day" }}
+SELECT 'd1' AS day, {{ var "batch" }} AS version{{ if eq (var "batch") "1" }} UNION ALL SELECT 'd2', 1{{ end }}`,
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range models {
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, batch := range []string{"1", "2"} {
+		if err := RunCommand([]string{"--vars", fmt.Sprintf(`{"batch": "%s"}`, batch)}); err != nil {
+			t.Fatalf("RunCommand() batch %s error = %v", batch, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	queries := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"append keeps every batch", "SELECT group_concat(batch) FROM (SELECT batch FROM events ORDER BY batch)", "1,2"},
+		{"merge updates in place", "SELECT name || ':' || first_batch || ':' || COUNT(*) FROM customers", "name2:1:1"},
+		{"insert_overwrite replaces partitions", "SELECT group_concat(day || '=' || version) FROM (SELECT * FROM daily ORDER BY day)", "d1=2,d2=1"},
+	}
+	for _, q := range queries {
+		var got string
+		if err := db.QueryRow(q.query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", q.name, err)
+		}
+		if got != q.want {
+			t.Errorf("%s: got %q, want %q", q.name, got, q.want)
+		}
+	}
+}
+
+// TestRunInsertOverwriteFailedInsertKeepsRows fails the insert of an
+// insert_overwrite model after its partition was deleted and checks that the
+// partition is kept
+func TestRunInsertOverwriteFailedInsertKeepsRows(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	model := `{{ config "materialized" "incremental" "incremental_strategy" "insert_overwrite" "partition_by" "day" }}
+SELECT '2024-01-01' AS day, {{ var "version" 1 }} AS version`
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "models", "facts.sql"), []byte(model), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TRIGGER facts_no_v2 BEFORE INSERT ON facts WHEN NEW.version = 2 BEGIN SELECT RAISE(ABORT, 'version 2 rejected'); END"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--vars", `{"version": 2}`}); err == nil {
+		t.Fatal("RunCommand() with a rejected insert succeeded, want failure")
+	}
+
+	var got string
+	if err := db.QueryRow("SELECT COALESCE(group_concat(day || '=' || version), '') FROM facts").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01=1"; got != want {
+		t.Errorf("facts = %q, want %q", got, want)
+	}
+}
+
+// TestRunOnSchemaChange adds and removes a column of incremental models
+// between two runs and checks each on_schema_change policy
+func TestRunOnSchemaChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: schema_change
+version: 1.0.0
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first batch returns legacy, the second returns region instead
+	body := `SELECT {{ var "batch" }} AS id, {{ if eq (var "batch") "1" }}'old' AS legacy{{ else }}'west' AS region{{ end }}`
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, policy := range []string{"ignore", "fail", "append_new_columns", "sync_all_columns"} {
+		content := fmt.Sprintf(`{{ config "materialized" "incremental" "incremental_strategy" "append" "on_schema_change" %q }}
+%s`, policy, body)
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", "orders_"+policy+".sql"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--vars", `{"batch": "1"}`}); err != nil {
+		t.Fatalf("RunCommand() batch 1 error = %v", err)
+	}
+	if err := RunCommand([]string{"--vars", `{"batch": "2"}`, "--exclude", "orders_fail"}); err != nil {
+		t.Fatalf("RunCommand() batch 2 error = %v", err)
+	}
+	err = RunCommand([]string{"--vars", `{"batch": "2"}`, "--models", "orders_fail"})
+	if err == nil {
+		t.Error("RunCommand() with on_schema_change fail succeeded, want error")
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	queries := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"ignore keeps the columns", "SELECT group_concat(name) FROM pragma_table_info('orders_ignore')", "id,legacy"},
+		{"ignore loads common columns", "SELECT group_concat(id || '=' || coalesce(legacy, 'null')) FROM (SELECT * FROM orders_ignore ORDER BY id)", "1=old,2=null"},
+		{"fail leaves the table", "SELECT group_concat(name) || ':' || (SELECT COUNT(*) FROM orders_fail) FROM pragma_table_info('orders_fail')", "id,legacy:1"},
+		{"append_new_columns adds columns", "SELECT group_concat(name) FROM pragma_table_info('orders_append_new_columns')", "id,legacy,region"},
+		{"append_new_columns loads new columns", "SELECT group_concat(id || '=' || coalesce(region, 'null')) FROM (SELECT * FROM orders_append_new_columns ORDER BY id)", "1=null,2=west"},
+		{"sync_all_columns drops columns", "SELECT group_concat(name) FROM pragma_table_info('orders_sync_all_columns')", "id,region"},
+	}
+	for _, q := range queries {
+		var got string
+		if err := db.QueryRow(q.query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", q.name, err)
+		}
+		if got != q.want {
+			t.Errorf("%s: got %q, want %q", q.name, got, q.want)
+		}
+	}
+}
+
+// TestRunOnSchemaChangeDropsIndexedColumn removes a column that is indexed
+// and used by a view, which SQLite cannot drop, with sync_all_columns
+func TestRunOnSchemaChangeDropsIndexedColumn(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: schema_change\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The merge strategy indexes the unique key, legacy in the first batch
+	// and id in the second
+	model := `{{ config "materialized" "incremental" "incremental_strategy" "merge" "on_schema_change" "sync_all_columns" }}
+{{ if eq (var "batch") "1" }}{{ config "unique_key" "legacy" }}{{ else }}{{ config "unique_key" "id" }}{{ end }}
+SELECT {{ var "batch" }} AS id, {{ if eq (var "batch") "1" }}'old' AS legacy{{ else }}'west' AS region{{ end }}`
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "models", "orders.sql"), []byte(model), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--vars", `{"batch": "1"}`}); err != nil {
+		t.Fatalf("RunCommand() batch 1 error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE VIEW order_ids AS SELECT id, legacy FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--vars", `{"batch": "2"}`}); err != nil {
+		t.Fatalf("RunCommand() batch 2 error = %v", err)
+	}
+
+	queries := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"the column is dropped", "SELECT group_concat(name) FROM pragma_table_info('orders')", "id,region"},
+		{"the rows are kept", "SELECT group_concat(id || '=' || coalesce(region, 'null')) FROM (SELECT * FROM orders ORDER BY id)", "1=null,2=west"},
+		{"the unique key is indexed", "SELECT group_concat(name) FROM pragma_index_list('orders')", "orders__id"},
+	}
+	for _, q := range queries {
+		var got string
+		if err := db.QueryRow(q.query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", q.name, err)
+		}
+		if got != q.want {
+			t.Errorf("%s: got %q, want %q", q.name, got, q.want)
+		}
+	}
+}
+
+// TestRunMicrobatchFailedBatchKeepsRows fails the insert of a batch after
+// its rows were deleted and checks that the rows are kept
+func TestRunMicrobatchFailedBatchKeepsRows(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: rail\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// With duplicate set, the batch returns its row twice; with broken set,
+	// it fails
+	model := `{{ config "materialized" "incremental" "incremental_strategy" "microbatch" "event_time" "trip_date" "batch_size" "day" "begin" "2024-01-01" }}
+SELECT '{{ batch_start }}' AS trip_date, 1 AS trips{{ if var "duplicate" false }} UNION ALL SELECT '{{ batch_start }}', 2{{ end }}{{ if var "broken" false }} FROM missing_table{{ end }}`
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "models", "trips_daily.sql"), []byte(model), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	window := []string{"--event-time-start", "2024-01-01", "--event-time-end", "2024-01-02"}
+	if err := RunCommand(window); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE UNIQUE INDEX trips_daily__trip_date ON trips_daily (trip_date)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand(append(window, "--vars", `{"duplicate": true}`)); err == nil {
+		t.Fatal("RunCommand() with duplicate rows succeeded, want failure")
+	}
+
+	var got string
+	if err := db.QueryRow("SELECT COALESCE(group_concat(trip_date || '=' || trips), '') FROM trips_daily").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01=1"; got != want {
+		t.Errorf("trips_daily = %q, want %q", got, want)
+	}
+
+	// A full refresh with a failing batch leaves the table as it was
+	if err := RunCommand(append(window, "--full-refresh", "--vars", `{"broken": true}`)); err == nil {
+		t.Fatal("RunCommand() with a broken full refresh succeeded, want failure")
+	}
+	if err := db.QueryRow("SELECT COALESCE(group_concat(trip_date || '=' || trips), '') FROM trips_daily").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01=1"; got != want {
+		t.Errorf("trips_daily after failed full refresh = %q, want %q", got, want)
+	}
+	var tmpTables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name LIKE 'trips_daily__%tmp'").Scan(&tmpTables); err != nil {
+		t.Fatal(err)
+	}
+	if tmpTables != 0 {
+		t.Errorf("found %d temporary table(s) after failed full refresh, want 0", tmpTables)
+	}
+
+	if err := RunCommand(append(window, "--full-refresh")); err != nil {
+		t.Fatalf("RunCommand() with full refresh error = %v", err)
+	}
+	if err := db.QueryRow("SELECT COALESCE(group_concat(trip_date || '=' || trips), '') FROM trips_daily").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01=1"; got != want {
+		t.Errorf("trips_daily after full refresh = %q, want %q", got, want)
+	}
+}
+
+func TestRunEphemeralModels(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	models := map[string]string{
+		"stg_orders.sql": `{{ config "materialized" "ephemeral" }}
+SELECT 1 AS id, 10 AS amount UNION ALL SELECT 2, -5 UNION ALL SELECT 3, 7`,
+		"int_orders.sql": `{{ config "materialized" "ephemeral" }}
+SELECT * FROM {{ ref "stg_orders" }} WHERE amount > 0`,
+		"fct_orders.sql": `{{ config "materialized" "table" }}
+WITH totals AS (SELECT SUM(amount) AS total FROM {{ ref "stg_orders" }})
+SELECT o.id, o.amount, t.total FROM {{ ref "int_orders" }} o, totals t`,
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range models {
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var got string
+	if err := db.QueryRow("SELECT group_concat(id || ':' || amount || ':' || total) FROM (SELECT * FROM fct_orders ORDER BY id)").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1:10:12,3:7:12"; got != want {
+		t.Errorf("fct_orders = %q, want %q", got, want)
+	}
+
+	// Ephemeral models are never created in the database
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name IN ('stg_orders', 'int_orders')").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("found %d ephemeral relation(s) in the database, want none", count)
+	}
+
+	// The compiled SQL inlines the ephemeral models
+	if err := CompileCommand([]string{}); err != nil {
+		t.Fatalf("CompileCommand() error = %v", err)
+	}
+	compiled, err := os.ReadFile(filepath.Join("target", "compiled", "shop", "fct_orders.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(string(compiled)), "WITH __cte__stg_orders AS (") ||
+		!strings.Contains(string(compiled), "__cte__int_orders AS (") ||
+		!strings.Contains(string(compiled), "),\ntotals AS (") {
+		t.Errorf("compiled fct_orders =\n%s", compiled)
+	}
+}
+
+// TestRunEphemeralModelsWithThreads runs models that ref an ephemeral
+// model with threads: they must wait for the model the ephemeral one refs
+func TestRunEphemeralModelsWithThreads(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// src takes a while to build, so that the fct models would read it
+	// before it exists without waiting for it
+	models := map[string]string{
+		"src.sql": `{{ config "materialized" "table" }}
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 200000)
+SELECT i AS id FROM n`,
+		"eph.sql": `{{ config "materialized" "ephemeral" }}
+SELECT id FROM {{ ref "src" }} WHERE id % 1000 = 0`,
+	}
+	for i := 1; i <= 8; i++ {
+		models[fmt.Sprintf("fct_%d.sql", i)] = `{{ config "materialized" "table" }}
+SELECT COUNT(*) AS orders FROM {{ ref "eph" }}`
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range models {
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{"--threads", "4"}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 1; i <= 8; i++ {
+		var orders int
+		if err := db.QueryRow(fmt.Sprintf("SELECT orders FROM fct_%d", i)).Scan(&orders); err != nil {
+			t.Fatal(err)
+		}
+		if orders != 200 {
+			t.Errorf("fct_%d orders = %d, want 200", i, orders)
+		}
+	}
+}
+
+func TestRunAtomicSwap(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeModel := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ordersSQL := `{{ config "materialized" "table" }}
+SELECT id, amount FROM raw_orders`
+	bigOrdersSQL := `{{ config "materialized" "view" }}
+SELECT id FROM {{ ref "orders" }} WHERE amount > 5`
+	writeModel("orders.sql", ordersSQL)
+	writeModel("big_orders.sql", bigOrdersSQL)
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE raw_orders AS SELECT 1 AS id, 10 AS amount UNION ALL SELECT 2, 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(sql string) string {
+		t.Helper()
+		var got string
+		if err := db.QueryRow(sql).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		return got
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+
+	// The rebuilt table is swapped in under the view that reads it
+	if _, err := db.Exec("INSERT INTO raw_orders VALUES (3, 20)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if got := query("SELECT group_concat(id) FROM (SELECT id FROM big_orders ORDER BY id)"); got != "1,3" {
+		t.Errorf("big_orders = %q, want %q", got, "1,3")
+	}
+
+	// A table whose query fails keeps its previous contents
+	writeModel("orders.sql", `{{ config "materialized" "table" }}
+SELECT id, missing_column FROM raw_orders`)
+	if err := RunCommand([]string{}); err == nil {
+		t.Fatal("RunCommand() expected error for failing table")
+	}
+	if got := query("SELECT group_concat(id || ':' || amount) FROM (SELECT * FROM orders ORDER BY id)"); got != "1:10,2:3,3:20" {
+		t.Errorf("orders after failed run = %q", got)
+	}
+	if got := query("SELECT COUNT(*) FROM sqlite_master WHERE name LIKE '%gorchata_tmp'"); got != "0" {
+		t.Errorf("found %s temporary relation(s) after failed run", got)
+	}
+
+	// A view whose query fails keeps its previous definition
+	writeModel("orders.sql", ordersSQL)
+	writeModel("big_orders.sql", `{{ config "materialized" "view" }}
+SELECT id, FROM {{ ref "orders" }}`)
+	if err := RunCommand([]string{}); err == nil {
+		t.Fatal("RunCommand() expected error for failing view")
+	}
+	if got := query("SELECT group_concat(id) FROM (SELECT id FROM big_orders ORDER BY id)"); got != "1,3" {
+		t.Errorf("big_orders after failed run = %q, want %q", got, "1,3")
+	}
+
+	// A view can become a table and back
+	for _, materialized := range []string{"table", "view"} {
+		writeModel("big_orders.sql", strings.Replace(bigOrdersSQL, `"view"`, fmt.Sprintf("%q", materialized), 1))
+		if err := RunCommand([]string{}); err != nil {
+			t.Fatalf("RunCommand() with big_orders as a %s error = %v", materialized, err)
+		}
+		if got := query("SELECT type FROM sqlite_master WHERE name = 'big_orders'"); got != materialized {
+			t.Errorf("big_orders is a %s, want a %s", got, materialized)
+		}
+		if got := query("SELECT group_concat(id) FROM (SELECT id FROM big_orders ORDER BY id)"); got != "1,3" {
+			t.Errorf("big_orders as a %s = %q, want %q", materialized, got, "1,3")
+		}
+	}
+	if got := query("SELECT COUNT(*) FROM sqlite_master WHERE name LIKE '%gorchata_tmp'"); got != "0" {
+		t.Errorf("found %s temporary relation(s) after switching materializations", got)
+	}
+}
+
+func TestRunAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+      threads: 4
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeModel := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeModel("stg_orders.sql", `{{ config "materialized" "table" }}
+SELECT 1 AS id, 10 AS amount`)
+	writeModel("order_ids.sql", `{{ config "materialized" "view" }}
+SELECT id FROM {{ ref "stg_orders" }}`)
+	writeModel("fct_orders.sql", `{{ config "materialized" "table" }}
+SELECT id, missing_column FROM {{ ref "stg_orders" }}`)
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	relations := func() string {
+		t.Helper()
+		var names sql.NullString
+		if err := db.QueryRow("SELECT group_concat(name) FROM (SELECT name FROM sqlite_master WHERE type IN ('table', 'view') ORDER BY name)").Scan(&names); err != nil {
+			t.Fatal(err)
+		}
+		return names.String
+	}
+
+	// A failed atomic run leaves the database as it was before the run
+	if err := RunCommand([]string{"--atomic"}); err == nil {
+		t.Fatal("RunCommand() expected error for failing model")
+	}
+	if got := relations(); got != "" {
+		t.Errorf("relations after rolled back run = %q, want none", got)
+	}
+	runResults, err := executor.ReadRunResults(runResultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]executor.ExecutionStatus)
+	for _, mr := range runResults.Result.ModelResults {
+		statuses[mr.ModelID] = mr.Status
+	}
+	if statuses["stg_orders"] != executor.StatusRolledBack || statuses["order_ids"] != executor.StatusRolledBack || statuses["fct_orders"] != executor.StatusFailed {
+		t.Errorf("statuses = %v", statuses)
+	}
+	// retry executes the rolled back models again
+	if got := runResults.Unfinished(executor.ResourceTypeModel); len(got) != 3 {
+		t.Errorf("Unfinished(model) = %v, want all 3 models", got)
+	}
+
+	// keep-successful keeps the models that succeeded
+	if err := RunCommand([]string{"--atomic", "--partial-failure", "keep-successful"}); err == nil {
+		t.Fatal("RunCommand() expected error for failing model")
+	}
+	if got := relations(); got != "order_ids,stg_orders" {
+		t.Errorf("relations after keep-successful run = %q, want order_ids,stg_orders", got)
+	}
+
+	// A model configured with atomic rolls back along with its failed post-hook
+	writeModel("fct_orders.sql", `{{ config "materialized" "table" "atomic" true "post_hook" "INSERT INTO missing_table VALUES (1)" }}
+SELECT id, amount FROM {{ ref "stg_orders" }}`)
+	if err := RunCommand([]string{}); err == nil {
+		t.Fatal("RunCommand() expected error for failing post-hook")
+	}
+	if got := relations(); got != "order_ids,stg_orders" {
+		t.Errorf("relations after failed atomic model = %q, want order_ids,stg_orders", got)
+	}
+
+	err = RunCommand([]string{"--partial-failure", "keep-successful"})
+	if err == nil || !strings.Contains(err.Error(), "requires --atomic") {
+		t.Errorf("RunCommand() error = %v, want --partial-failure requires --atomic", err)
+	}
+	err = RunCommand([]string{"--atomic", "--partial-failure", "commit"})
+	if err == nil || !strings.Contains(err.Error(), "unknown partial-failure policy") {
+		t.Errorf("RunCommand() error = %v, want unknown policy", err)
+	}
+}
+
+// TestRunCustomMaterialization builds a model with a materialization defined
+// in a macro file of the project over several runs
+func TestRunCustomMaterialization(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: custom_materialization
+version: 1.0.0
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"macros/rolling_window.sql": `{% materialization rolling_window %}
+{{ if .IsIncremental }}
+INSERT INTO {{ this }} {{ .CompiledSQL }};
+{{ else }}
+DROP TABLE IF EXISTS {{ this }};
+CREATE TABLE {{ this }} AS {{ .CompiledSQL }};
+{{ end }}
+DELETE FROM {{ this }} WHERE batch <= (SELECT MAX(batch) FROM {{ this }}) - {{ config "window" }};
+{% endmaterialization %}`,
+		"models/readings.sql": `{{ config "materialized" "rolling_window" "window" 2 }}
+SELECT {{ var "batch" }} AS batch`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	runs := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--vars", `{"batch": 1}`}, "1"},
+		{[]string{"--vars", `{"batch": 2}`}, "1,2"},
+		{[]string{"--vars", `{"batch": 3}`}, "2,3"},
+		{[]string{"--vars", `{"batch": 4}`, "--full-refresh"}, "4"},
+	}
+	for _, run := range runs {
+		if err := RunCommand(run.args); err != nil {
+			t.Fatalf("RunCommand(%v) error = %v", run.args, err)
+		}
+
+		var got string
+		if err := db.QueryRow("SELECT group_concat(batch) FROM (SELECT batch FROM readings ORDER BY batch)").Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != run.want {
+			t.Errorf("after RunCommand(%v): batches = %q, want %q", run.args, got, run.want)
+		}
+	}
+}
+
+// TestRunContract builds a table from the contract declared in its schema
+// file and checks that queries breaking the contract fail the model
+func TestRunContract(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeModel := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmpDir, "models", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeModel("schema.yml", `version: 2
+models:
+  - name: orders
+    config:
+      contract: {enforced: true}
+    columns:
+      - name: id
+        data_type: integer
+        constraints:
+          - type: primary_key
+      - name: customer_id
+        data_type: integer
+        constraints:
+          - type: not_null
+          - type: foreign_key
+            expression: customers (id)
+      - name: amount
+        data_type: real
+        constraints:
+          - type: check
+            expression: amount >= 0
+`)
+	writeModel("customers.sql", `SELECT 10 AS id`)
+	ordersSQL := `SELECT c.id AS customer_id, 1 AS id, 2.5 AS amount FROM {{ ref "customers" }} c`
+	writeModel("orders.sql", ordersSQL)
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(sql string) string {
+		t.Helper()
+		var got string
+		if err := db.QueryRow(sql).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		return got
+	}
+
+	// The referenced customers table is rebuilt on the second run
+	for i := 0; i < 2; i++ {
+		if err := RunCommand([]string{}); err != nil {
+			t.Fatalf("RunCommand() error = %v", err)
+		}
+	}
+	if got := query("SELECT sql FROM sqlite_master WHERE name = 'orders'"); !strings.Contains(got, "id integer PRIMARY KEY") || !strings.HasSuffix(got, "STRICT") {
+		t.Errorf("orders table = %s, want the contract's STRICT DDL", got)
+	}
+	if got := query("SELECT id || ':' || customer_id || ':' || amount FROM orders"); got != "1:10:2.5" {
+		t.Errorf("orders = %q, want 1:10:2.5", got)
+	}
+
+	failing := []struct {
+		name string
+		sql  string
+	}{
+		{"wrong type", `SELECT c.id AS customer_id, CAST(1 AS TEXT) AS id, 2.5 AS amount FROM {{ ref "customers" }} c`},
+		{"value rejected by the STRICT table", `SELECT c.id AS customer_id, 'one' AS id, 2.5 AS amount FROM {{ ref "customers" }} c`},
+		{"missing column", `SELECT c.id AS customer_id, 1 AS id FROM {{ ref "customers" }} c`},
+		{"check constraint", `SELECT c.id AS customer_id, 1 AS id, -1.0 AS amount FROM {{ ref "customers" }} c`},
+		{"foreign key", `SELECT 99 AS customer_id, 1 AS id, 2.5 AS amount`},
+	}
+	for _, tt := range failing {
+		writeModel("orders.sql", tt.sql)
+		if err := RunCommand([]string{}); err == nil {
+			t.Errorf("%s: RunCommand() expected error", tt.name)
+		}
+		if got := query("SELECT id || ':' || customer_id || ':' || amount FROM orders"); got != "1:10:2.5" {
+			t.Errorf("%s: orders = %q, want the contents of the last run", tt.name, got)
+		}
+		if got := query("SELECT COUNT(*) FROM sqlite_master WHERE name LIKE '%gorchata_tmp'"); got != "0" {
+			t.Errorf("%s: found %s temporary table(s)", tt.name, got)
+		}
+	}
+}
+
+// TestRunMacros calls the macros of the macro paths from models, hooks,
+// materializations and tests
+func TestRunMacros(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"macros/finance.sql": `{% macro cents_to_dollars(column string, scale int = 2) %}
+ROUND({{ .column }} / 100.0, {{ .scale }})
+{% endmacro %}
+
+{{ define "raw_orders" }}{{ ref "z_raw_orders" }}{{ end }}`,
+		"macros/audit.sql": `{% macro audit(note string) %}
+INSERT INTO audit SELECT '{{ .note }}', COUNT(*) FROM {{ this }}
+{% endmacro %}
+
+{% materialization audited_table %}
+DROP TABLE IF EXISTS {{ this }};
+CREATE TABLE {{ this }} AS {{ .CompiledSQL }};
+{{ audit "materialized" }};
+{% endmaterialization %}`,
+		"models/z_raw_orders.sql": `SELECT 1 AS id, 1250 AS amount_cents UNION ALL SELECT 2, 99`,
+		"models/orders.sql": `{{ config "materialized" "audited_table" "post_hook" "{{ audit \"post hook\" }}" }}
+SELECT id, {{ cents_to_dollars "amount_cents" 1 }} AS amount FROM {{ raw_orders }}`,
+		"tests/assert_amounts.sql": `SELECT * FROM {{ ref "orders" }} WHERE amount <> {{ cents_to_dollars "CAST(amount * 100 AS INTEGER)" 1 }}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE audit (note TEXT, row_count INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if err := TestCommand([]string{}); err != nil {
+		t.Fatalf("TestCommand() error = %v", err)
+	}
+
+	var amounts string
+	if err := db.QueryRow("SELECT group_concat(amount) FROM (SELECT amount FROM orders ORDER BY id)").Scan(&amounts); err != nil {
+		t.Fatal(err)
+	}
+	if amounts != "12.5,1.0" {
+		t.Errorf("amounts = %q, want %q", amounts, "12.5,1.0")
+	}
+
+	var audit string
+	if err := db.QueryRow("SELECT group_concat(note || ':' || row_count, ',') FROM audit").Scan(&audit); err != nil {
+		t.Fatal(err)
+	}
+	if audit != "materialized:2,post hook:2" {
+		t.Errorf("audit = %q, want %q", audit, "materialized:2,post hook:2")
+	}
+
+	// Errors in macro files name the file and line
+	broken := "{% macro broken() %}\nSELECT {{ missing_function }}\n{% endmacro %}"
+	if err := os.WriteFile(filepath.Join(tmpDir, "macros", "broken.sql"), []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = RunCommand([]string{})
+	if err == nil || !strings.Contains(err.Error(), "macros/broken.sql:2") {
+		t.Errorf("RunCommand() error = %v, want the macro file and line", err)
+	}
+}
+
+func TestRunIntrospection(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"models/raw_orders.sql": `{{ config "materialized" "table" }}
+SELECT 1 AS id, 'paid' AS status, 10 AS amount
+UNION ALL SELECT 2, 'open', 5
+UNION ALL SELECT 3, 'paid', 7
+UNION ALL SELECT 4, NULL, 1`,
+		// The columns of the pivot come from the values of raw_orders.status
+		"models/order_pivot.sql": `{{ config "materialized" "table" }}
+SELECT
+{{- if execute }}
+{{- range get_column_values (ref "raw_orders") "status" }}
+  SUM(CASE WHEN status = '{{ . }}' THEN amount ELSE 0 END) AS {{ . }}_amount,
+{{- end }}
+{{- end }}
+  {{ if relation_exists this }}'rebuilt'{{ else }}'created'{{ end }} AS build
+FROM {{ ref "raw_orders" }}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compile has no connection, so the functions return placeholders
+	compiledDir := filepath.Join(tmpDir, "compiled")
+	if err := os.MkdirAll(compiledDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompileCommand([]string{"--output-dir", compiledDir}); err != nil {
+		t.Fatalf("CompileCommand() error = %v", err)
+	}
+	compiled, err := os.ReadFile(filepath.Join(compiledDir, "order_pivot.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(compiled), "_amount") || !strings.Contains(string(compiled), "'created' AS build") {
+		t.Errorf("compiled order_pivot = %q, want the placeholder results", compiled)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pivot := func() string {
+		t.Helper()
+		var open, paid int
+		var build string
+		if err := db.QueryRow("SELECT open_amount, paid_amount, build FROM order_pivot").Scan(&open, &paid, &build); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("open=%d paid=%d build=%s", open, paid, build)
+	}
+
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if got, want := pivot(), "open=5 paid=17 build=created"; got != want {
+		t.Errorf("order_pivot = %q, want %q", got, want)
+	}
+
+	// Atomic runs query through the run's transaction
+	if err := RunCommand([]string{"--atomic"}); err != nil {
+		t.Fatalf("RunCommand(--atomic) error = %v", err)
+	}
+	if got, want := pivot(), "open=5 paid=17 build=rebuilt"; got != want {
+		t.Errorf("order_pivot = %q, want %q", got, want)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
 	"github.com/jpconstantineau/gorchata/internal/domain/seeds"
 	"github.com/jpconstantineau/gorchata/internal/platform"
 )
@@ -33,6 +35,12 @@ func SeedCommand(args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	// Resolve project vars, overridden by --vars
+	vars, err := resolveVars(cfg, common.Vars)
+	if err != nil {
+		return err
+	}
+
 	// Validate we have at least one seed path
 	if len(cfg.Project.SeedPaths) == 0 {
 		if common.Verbose {
@@ -92,7 +100,16 @@ func SeedCommand(args []string) error {
 	}
 
 	// Execute seeds
-	if err := executeSeeds(ctx, adapter, seedsList, seedConfig, common.Verbose, common.FullRefresh); err != nil {
+	results, err := executeSeeds(ctx, adapter, seedsList, seedConfig, vars, common.Verbose, common.FullRefresh)
+
+	// Record the invocation so that failed seeds can be retried
+	runResults := newRunResults("seed", args, cfg.Project.Name)
+	runResults.Nodes = results
+	if writeErr := runResults.Write(runResultsPath); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write run results: %v\n", writeErr)
+	}
+
+	if err != nil {
 		return fmt.Errorf("seed execution failed: %w", err)
 	}
 
@@ -111,16 +128,7 @@ func loadOrDefaultSeedConfig() *config.SeedConfig {
 	}
 
 	// Return defaults
-	return &config.SeedConfig{
-		Version: 1,
-		Naming: config.NamingConfig{
-			Strategy: config.NamingStrategyFilename,
-		},
-		Import: config.ImportConfig{
-			BatchSize: 1000,
-			Scope:     config.ScopeTree,
-		},
-	}
+	return config.DefaultSeedConfig()
 }
 
 // loadSeedsFromPaths discovers and loads seeds from the configured paths
@@ -233,88 +241,78 @@ func filterSeeds(seedsList []*seedInfo, selectNames []string) []*seedInfo {
 	return filtered
 }
 
-// executeSeeds executes all seeds in sequence
-func executeSeeds(ctx context.Context, adapter platform.DatabaseAdapter, seedsList []*seedInfo, seedConfig *config.SeedConfig, verbose bool, fullRefresh bool) error {
-	successCount := 0
-	failureCount := 0
-
-	for _, info := range seedsList {
+// executeSeeds executes all seeds in sequence and returns the result of
+// each seed. Seeds after a failed one are not executed and reported as skipped.
+func executeSeeds(ctx context.Context, adapter platform.DatabaseAdapter, seedsList []*seedInfo, seedConfig *config.SeedConfig, vars map[string]interface{}, verbose bool, fullRefresh bool) ([]executor.NodeResult, error) {
+	results := make([]executor.NodeResult, 0, len(seedsList))
+	for i, info := range seedsList {
 		if verbose {
 			fmt.Printf("Executing seed: %s (from %s)...\n", info.Seed.ID, filepath.Base(info.Seed.Path))
 		}
 
-		var err error
+		nodeResult := executor.NodeResult{
+			ResourceType: executor.ResourceTypeSeed,
+			Name:         info.Seed.ID,
+			Status:       executor.StatusSuccess,
+			StartTime:    time.Now(),
+		}
+
+		var seedErr error
 
 		// Branch based on seed type
 		if info.Seed.Type == seeds.SeedTypeSQL {
 			// Execute SQL seed
-			// TODO: Support vars from config or flags
-			vars := make(map[string]interface{})
-			err = seeds.ExecuteSQLSeed(ctx, adapter, info.SQLContent, vars, nil)
-			if err != nil {
-				failureCount++
-				if verbose {
-					fmt.Printf("  ✗ Failed: %s\n", err)
-				}
-				return fmt.Errorf("SQL seed %s failed: %w", info.Seed.ID, err)
-			}
-
-			successCount++
-			if verbose {
+			if err := seeds.ExecuteSQLSeed(ctx, adapter, info.SQLContent, vars, nil); err != nil {
+				seedErr = fmt.Errorf("SQL seed %s failed: %w", info.Seed.ID, err)
+			} else if verbose {
 				fmt.Printf("  ✓ Success: SQL seed executed\n")
 			}
 
 		} else {
 			// Execute CSV seed
 			result, err := seeds.ExecuteSeed(ctx, adapter, info.Seed, info.Rows, seedConfig)
-			if err != nil {
-				failureCount++
-				if verbose {
-					fmt.Printf("  ✗ Failed: %s\n", err)
-				}
-				return fmt.Errorf("seed %s failed: %w", info.Seed.ID, err)
-			}
-
-			if result.Status == seeds.StatusSuccess {
-				successCount++
+			switch {
+			case err != nil:
+				seedErr = fmt.Errorf("seed %s failed: %w", info.Seed.ID, err)
+			case result.Status != seeds.StatusSuccess:
+				seedErr = fmt.Errorf("seed %s failed: %s", info.Seed.ID, result.Error)
+			default:
+				nodeResult.RowsAffected = int64(result.RowsLoaded)
 				if verbose {
 					fmt.Printf("  ✓ Success: loaded %d rows\n", result.RowsLoaded)
 				}
-			} else {
-				failureCount++
-				if verbose {
-					fmt.Printf("  ✗ Failed: %s\n", result.Error)
-				}
-				return fmt.Errorf("seed %s failed: %s", info.Seed.ID, result.Error)
 			}
 		}
-	}
-
-	fmt.Printf("\nExecuted %d/%d seed(s) successfully\n", successCount, successCount+failureCount)
-
-	if failureCount > 0 {
-		return fmt.Errorf("%d seed(s) failed", failureCount)
-	}
 
-	return nil
-}
+		nodeResult.EndTime = time.Now()
 
-// buildSeedsMapForTemplate builds a Seeds map for template context from loaded seeds
-// Maps seedName -> qualified table name (with schema prefix if configured)
-func buildSeedsMapForTemplate(seedsList []*seedInfo, schema string) map[string]string {
-	seedsMap := make(map[string]string)
-	for _, info := range seedsList {
-		// Build qualified table name
-		tableName := info.Seed.ID
-		if schema != "" {
-			tableName = schema + "." + info.Seed.ID
+		if seedErr != nil {
+			if verbose {
+				fmt.Printf("  ✗ Failed: %s\n", seedErr)
+			}
+			nodeResult.Status = executor.StatusFailed
+			nodeResult.Error = seedErr.Error()
+			results = append(results, nodeResult)
+
+			// The remaining seeds are not executed
+			for _, skipped := range seedsList[i+1:] {
+				results = append(results, executor.NodeResult{
+					ResourceType:   executor.ResourceTypeSeed,
+					Name:           skipped.Seed.ID,
+					Status:         executor.StatusSkipped,
+					StartTime:      nodeResult.EndTime,
+					EndTime:        nodeResult.EndTime,
+					Error:          fmt.Sprintf("skipped because seed %s failed", info.Seed.ID),
+					SkippedBecause: info.Seed.ID,
+				})
+			}
+			return results, seedErr
 		}
 
-		// Store in ResolvedTableName for future use
-		info.Seed.ResolvedTableName = tableName
-
-		// Add to map
-		seedsMap[info.Seed.ID] = tableName
+		results = append(results, nodeResult)
 	}
-	return seedsMap
+
+	fmt.Printf("\nExecuted %d/%d seed(s) successfully\n", len(results), len(seedsList))
+
+	return results, nil
 }
@@ -0,0 +1,9 @@
+package cli
+
+// SnapshotCommand takes the project's snapshots: it records the changes to
+// the rows returned by each snapshot's query in its slowly changing
+// dimension table. It accepts the flags of the run command.
+func SnapshotCommand(args []string) error {
+	_, err := runModels("snapshot", args, nil)
+	return err
+}
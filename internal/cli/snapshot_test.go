@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+)
+
+func TestSnapshotCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	projectConfig := `
+name: shop
+version: 1.0.0
+snapshots:
+  shop:
+    +unique_key: id
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, dbPath)
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"snapshots/customers_snapshot.sql": `{{ config "strategy" "timestamp" "updated_at" "updated_at" "invalidate_hard_deletes" true }}
+SELECT id, status, updated_at FROM raw_customers`,
+		"snapshots/crm/status_snapshot.sql": `{{ config "strategy" "check" "check_cols" (list "status") }}
+SELECT id, status FROM raw_customers`,
+		"models/customer_versions.sql": `{{ config "materialized" "table" }}
+SELECT id, COUNT(*) AS versions FROM {{ ref "customers_snapshot" }} GROUP BY id`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE raw_customers AS
+SELECT 1 AS id, 'new' AS status, '2024-01-01' AS updated_at UNION ALL
+SELECT 2, 'new', '2024-01-01' UNION ALL
+SELECT 3, 'new', '2024-01-01'`); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SnapshotCommand([]string{}); err != nil {
+		t.Fatalf("SnapshotCommand() error = %v", err)
+	}
+
+	// Customer 1 is updated, customer 2 changes without a new updated_at,
+	// customer 3 is deleted and customer 4 is new
+	if _, err := db.Exec(`
+UPDATE raw_customers SET status = 'shipped', updated_at = '2024-01-02' WHERE id = 1;
+UPDATE raw_customers SET status = 'late' WHERE id = 2;
+DELETE FROM raw_customers WHERE id = 3;
+INSERT INTO raw_customers VALUES (4, 'new', '2024-01-02');`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SnapshotCommand([]string{}); err != nil {
+		t.Fatalf("SnapshotCommand() error = %v", err)
+	}
+
+	history := func(query string) string {
+		t.Helper()
+		var got string
+		if err := db.QueryRow(query).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	customers := "SELECT group_concat(version) FROM (SELECT id || ':' || status || ':' || valid_from || ':' || CASE WHEN valid_to IS NULL THEN 'current' ELSE 'closed' END AS version FROM customers_snapshot ORDER BY id, valid_from)"
+	wantCustomers := "1:new:2024-01-01:closed,1:shipped:2024-01-02:current,2:new:2024-01-01:current,3:new:2024-01-01:closed,4:new:2024-01-02:current"
+	if got := history(customers); got != wantCustomers {
+		t.Errorf("customers_snapshot = %q, want %q", got, wantCustomers)
+	}
+	if got := history("SELECT scd_id || ' ' || valid_to FROM customers_snapshot WHERE id = 1 AND valid_to IS NOT NULL"); got != "1|2024-01-01 2024-01-02" {
+		t.Errorf("closed version of customer 1 = %q", got)
+	}
+
+	statuses := "SELECT group_concat(version) FROM (SELECT id || ':' || status || ':' || CASE WHEN valid_to IS NULL THEN 'current' ELSE 'closed' END AS version FROM status_snapshot ORDER BY id, valid_from)"
+	wantStatuses := "1:new:closed,1:shipped:current,2:new:closed,2:late:current,3:new:current,4:new:current"
+	if got := history(statuses); got != wantStatuses {
+		t.Errorf("status_snapshot = %q, want %q", got, wantStatuses)
+	}
+
+	// run builds models only, reading the snapshot through ref()
+	if err := RunCommand([]string{}); err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	runResults, err := executor.ReadRunResults(runResultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runResults.Result.ModelResults) != 1 || runResults.Result.ModelResults[0].ModelID != "customer_versions" {
+		t.Errorf("run executed %+v, want customer_versions only", runResults.Result.ModelResults)
+	}
+	if got := history("SELECT group_concat(id || '=' || versions) FROM (SELECT * FROM customer_versions ORDER BY id)"); got != "1=2,2=1,3=1,4=1" {
+		t.Errorf("customer_versions = %q", got)
+	}
+
+	// build takes the snapshots before the models that ref() them; nothing
+	// changed, so no version is added
+	if err := BuildCommand([]string{}); err != nil {
+		t.Fatalf("BuildCommand() error = %v", err)
+	}
+	built, err := executor.ReadRunResults(runResultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := make(map[string]int)
+	for i, mr := range built.Result.ModelResults {
+		order[mr.ModelID] = i + 1
+	}
+	if order["customers_snapshot"] == 0 || order["status_snapshot"] == 0 || order["customers_snapshot"] > order["customer_versions"] {
+		t.Errorf("build executed %v, want both snapshots with customers_snapshot before customer_versions", order)
+	}
+	if got := history(customers); got != wantCustomers {
+		t.Errorf("customers_snapshot after build = %q, want %q", got, wantCustomers)
+	}
+	if got := history(statuses); got != wantStatuses {
+		t.Errorf("status_snapshot after build = %q, want %q", got, wantStatuses)
+	}
+}
+
+func TestSnapshotCommandInvalidConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "gorchata_project.yml"), []byte("name: shop\nversion: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profilesConfig := fmt.Sprintf(`
+default:
+  target: dev
+  outputs:
+    dev:
+      type: sqlite
+      database: %s
+`, filepath.Join(tmpDir, "test.db"))
+	if err := os.WriteFile(filepath.Join(tmpDir, "profiles.yml"), []byte(profilesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "snapshots"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := `{{ config "strategy" "timestamp" "unique_key" "id" }}
+SELECT 1 AS id`
+	if err := os.WriteFile(filepath.Join(tmpDir, "snapshots", "orders_snapshot.sql"), []byte(snapshot), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	err = SnapshotCommand([]string{})
+	if err == nil || !strings.Contains(err.Error(), "updated_at is required") {
+		t.Errorf("SnapshotCommand() error = %v, want missing updated_at", err)
+	}
+}
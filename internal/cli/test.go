@@ -5,11 +5,12 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/jpconstantineau/gorchata/internal/config"
 	"github.com/jpconstantineau/gorchata/internal/domain/test/executor"
-	"github.com/jpconstantineau/gorchata/internal/domain/test/generic"
 	"github.com/jpconstantineau/gorchata/internal/domain/test/storage"
+	"github.com/jpconstantineau/gorchata/internal/template"
 )
 
 // TestCommand executes data quality tests
@@ -20,8 +21,8 @@ func TestCommand(args []string) error {
 	AddCommonFlags(fs, &common)
 
 	// Test-specific flags
-	selectFlag := fs.String("select", "", "Run tests matching pattern")
-	excludeFlag := fs.String("exclude", "", "Exclude tests matching pattern")
+	selectFlag := fs.String("select", "", "Run tests matching any of the patterns (space-separated)")
+	excludeFlag := fs.String("exclude", "", "Exclude tests matching any of the patterns (space-separated)")
 	tags := fs.String("tags", "", "Test with tags (comma-separated)")
 
 	if err := fs.Parse(args); err != nil {
@@ -34,6 +35,12 @@ func TestCommand(args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	// Resolve project vars, overridden by --vars
+	vars, err := resolveVars(cfg, common.Vars)
+	if err != nil {
+		return err
+	}
+
 	// Create database adapter
 	adapter, err := createAdapter(cfg.Output)
 	if err != nil {
@@ -51,18 +58,16 @@ func TestCommand(args []string) error {
 		fmt.Printf("Connected to %s database: %s\n", cfg.Output.Type, cfg.Output.Database)
 	}
 
-	// Create test registry
-	registry := generic.NewDefaultRegistry()
-
-	// Discover all tests
+	// Discover all tests as part of the project manifest
 	if common.Verbose {
 		fmt.Println("Discovering tests...")
 	}
 
-	allTests, err := executor.DiscoverAllTests(cfg, registry)
+	m, err := loadManifest(cfg, vars, common.FullRefresh)
 	if err != nil {
-		return fmt.Errorf("failed to discover tests: %w", err)
+		return err
 	}
+	allTests := m.Tests
 
 	if common.Verbose {
 		fmt.Printf("Found %d test(s)\n", len(allTests))
@@ -74,20 +79,8 @@ func TestCommand(args []string) error {
 	}
 
 	// Build selector from flags
-	var includes []string
-	if *selectFlag != "" {
-		includes = []string{*selectFlag}
-	}
-
-	var excludes []string
-	if *excludeFlag != "" {
-		excludes = []string{*excludeFlag}
-	}
-
-	var modelFilters []string
-	if common.Models != "" {
-		modelFilters = []string{common.Models}
-	}
+	includes := strings.Fields(*selectFlag)
+	excludes := strings.Fields(*excludeFlag)
 
 	var tagFilters []string
 	if *tags != "" {
@@ -95,7 +88,21 @@ func TestCommand(args []string) error {
 		tagFilters = splitCommaSeparated(*tags)
 	}
 
-	selector := executor.NewTestSelector(includes, excludes, tagFilters, modelFilters)
+	selector := executor.NewTestSelector(includes, excludes, tagFilters, nil)
+
+	// --models selects the tested models with the graph selection syntax
+	if common.Models != "" {
+		models, err := m.Select(common.Models, "")
+		if err != nil {
+			return fmt.Errorf("invalid model selection: %w", err)
+		}
+		names := make([]string, 0, len(models))
+		for _, model := range models {
+			names = append(names, model.ID)
+		}
+		selector.SelectModels(names)
+	}
+
 	selectedTests := selector.Filter(allTests)
 
 	if len(selectedTests) == 0 {
@@ -115,10 +122,11 @@ func TestCommand(args []string) error {
 	}
 
 	// Create test engine
-	engine, err := executor.NewTestEngine(adapter, nil, failureStore)
+	engine, err := executor.NewTestEngine(adapter, template.New(template.WithMacros(m.Macros)), failureStore)
 	if err != nil {
 		return fmt.Errorf("failed to create test engine: %w", err)
 	}
+	engine.SetVars(vars)
 
 	// Create result writers
 	consoleWriter := executor.NewConsoleResultWriter(os.Stdout, true)
@@ -145,6 +153,13 @@ func TestCommand(args []string) error {
 	consoleWriter.WriteSummary(summary)
 	jsonWriter.WriteSummary(summary)
 
+	// Record the invocation so that failed tests can be retried
+	runResults := newRunResults("test", args, cfg.Project.Name)
+	runResults.Nodes = testNodeResults(summary)
+	if err := runResults.Write(runResultsPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write run results: %v\n", err)
+	}
+
 	// Run cleanup if failure store was initialized
 	if failureStore != nil {
 		cleanupConfig := storage.DefaultCleanupConfig()
@@ -4,11 +4,12 @@ package config
 
 // Default paths
 const (
-	DefaultModelPath  = "models"
-	DefaultSeedPath   = "seeds"
-	DefaultTestPath   = "tests"
-	DefaultMacroPath  = "macros"
-	DefaultTargetPath = "target"
+	DefaultModelPath    = "models"
+	DefaultSeedPath     = "seeds"
+	DefaultTestPath     = "tests"
+	DefaultMacroPath    = "macros"
+	DefaultSnapshotPath = "snapshots"
+	DefaultTargetPath   = "target"
 )
 
 // Default materialization strategies
@@ -49,3 +50,8 @@ func GetDefaultTestPaths() []string {
 func GetDefaultMacroPaths() []string {
 	return []string{DefaultMacroPath}
 }
+
+// GetDefaultSnapshotPaths returns the default snapshot paths
+func GetDefaultSnapshotPaths() []string {
+	return []string{DefaultSnapshotPath}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HookList is a list of SQL hook statements.
+// In YAML it may be written either as a single string or as a list of strings.
+type HookList []string
+
+// UnmarshalYAML accepts both a scalar string and a sequence of strings
+func (h *HookList) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		var single string
+		if err := value.Decode(&single); err != nil {
+			return err
+		}
+		if single != "" {
+			*h = HookList{single}
+		}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*h = HookList(list)
+		return nil
+	default:
+		return fmt.Errorf("hooks must be a string or a list of strings (line %d)", value.Line)
+	}
+}
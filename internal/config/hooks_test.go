@@ -0,0 +1,82 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHookListUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"single string", `hooks: "ANALYZE"`, []string{"ANALYZE"}, false},
+		{"list", "hooks:\n  - VACUUM\n  - ANALYZE", []string{"VACUUM", "ANALYZE"}, false},
+		{"empty string", `hooks: ""`, nil, false},
+		{"mapping is invalid", "hooks:\n  a: b", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc struct {
+				Hooks HookList `yaml:"hooks"`
+			}
+			err := yaml.Unmarshal([]byte(tt.input), &doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(doc.Hooks) != len(tt.want) {
+				t.Fatalf("Hooks = %v, want %v", doc.Hooks, tt.want)
+			}
+			for i := range tt.want {
+				if doc.Hooks[i] != tt.want[i] {
+					t.Errorf("Hooks[%d] = %q, want %q", i, doc.Hooks[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProjectRunHooks(t *testing.T) {
+	input := `
+name: hooks_project
+version: 1.0.0
+on-run-start: "CREATE TABLE IF NOT EXISTS audit (event TEXT)"
+on-run-end:
+  - "INSERT INTO audit VALUES ('end')"
+  - "ANALYZE"
+models:
+  hooks_project:
+    +pre-hook: "DELETE FROM audit WHERE event = '{{ this }}'"
+    post-hook:
+      - "CREATE INDEX IF NOT EXISTS idx_{{ this }} ON {{ this }} (id)"
+`
+	var cfg ProjectConfig
+	if err := yaml.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if len(cfg.OnRunStart) != 1 {
+		t.Errorf("len(OnRunStart) = %d, want 1", len(cfg.OnRunStart))
+	}
+	if len(cfg.OnRunEnd) != 2 {
+		t.Errorf("len(OnRunEnd) = %d, want 2", len(cfg.OnRunEnd))
+	}
+
+	layers := cfg.ModelConfigLayers(nil)
+	if len(layers) != 1 {
+		t.Fatalf("ModelConfigLayers() returned %d layers, want 1", len(layers))
+	}
+	if _, ok := layers[0]["+pre-hook"]; !ok {
+		t.Errorf("project layer missing +pre-hook: %v", layers[0])
+	}
+	if _, ok := layers[0]["post-hook"]; !ok {
+		t.Errorf("project layer missing post-hook: %v", layers[0])
+	}
+}
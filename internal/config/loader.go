@@ -11,6 +11,9 @@ type Config struct {
 	Project  *ProjectConfig
 	Profiles *ProfilesConfig
 	Output   *OutputConfig
+
+	// Target is the name of the output selected in profiles.yml
+	Target string
 }
 
 // Load loads both project and profiles configuration and selects the target output
@@ -46,6 +49,7 @@ func Load(projectPath, profilesPath, target string) (*Config, error) {
 		Project:  project,
 		Profiles: profiles,
 		Output:   output,
+		Target:   target,
 	}, nil
 }
 
@@ -0,0 +1,87 @@
+package config
+
+import "strings"
+
+// ModelConfigLayers returns the configuration from the models: block that
+// applies to a model stored in the given folders (relative to its model path).
+// Layers are ordered from least to most specific: the project level first,
+// then one layer per matching folder, e.g. for folders ["marts", "finance"]:
+//
+//	models:
+//	  my_project:
+//	    +materialized: view     # layer 0
+//	    marts:
+//	      +materialized: table  # layer 1
+//	      finance:
+//	        +tags: [finance]    # layer 2
+//
+// The project-name level may be left out, in which case the models: block
+// itself is the project level:
+//
+//	models:
+//	  +tags: [all]              # layer 0
+//	  marts:
+//	    +materialized: table    # layer 1
+//
+// Keys prefixed with "+" are always configuration. A key without the prefix is
+// a folder when its value is a map, and configuration otherwise.
+func (c *ProjectConfig) ModelConfigLayers(folders []string) []map[string]interface{} {
+	return configLayers(projectLevel(c.Models, c.Name), folders)
+}
+
+// SnapshotConfigLayers returns the configuration from the snapshots: block
+// that applies to a snapshot stored in the given folders (relative to its
+// snapshot path), in the same way as ModelConfigLayers.
+func (c *ProjectConfig) SnapshotConfigLayers(folders []string) []map[string]interface{} {
+	return configLayers(projectLevel(c.Snapshots, c.Name), folders)
+}
+
+// projectLevel returns the project's level of a models: or snapshots: block.
+// A block without a map for the project is read as the project level itself,
+// so its keys are project-wide configuration and top-level folders.
+func projectLevel(block map[string]interface{}, projectName string) map[string]interface{} {
+	if project, ok := block[projectName].(map[string]interface{}); ok {
+		return project
+	}
+	if len(block) == 0 {
+		return nil
+	}
+	return block
+}
+
+// configLayers returns the layers of a project's level of a models: or
+// snapshots: block that apply to the given folders
+func configLayers(project map[string]interface{}, folders []string) []map[string]interface{} {
+	if project == nil {
+		return nil
+	}
+
+	layers := []map[string]interface{}{configEntries(project)}
+
+	level := project
+	for _, folder := range folders {
+		next, ok := level[folder].(map[string]interface{})
+		if !ok {
+			break
+		}
+		layers = append(layers, configEntries(next))
+		level = next
+	}
+
+	return layers
+}
+
+// configEntries returns the configuration entries of one level of the models: block
+func configEntries(level map[string]interface{}) map[string]interface{} {
+	entries := make(map[string]interface{})
+	for key, value := range level {
+		if strings.HasPrefix(key, "+") {
+			entries[key] = value
+			continue
+		}
+		if _, isFolder := value.(map[string]interface{}); !isFolder {
+			entries[key] = value
+		}
+	}
+	return entries
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestModelConfigLayers(t *testing.T) {
+	input := `
+name: shop
+version: 1.0.0
+models:
+  shop:
+    +materialized: view
+    enabled: true
+    marts:
+      +materialized: table
+      +tags: [finance]
+      finance:
+        +alias: fin
+        schema: reporting
+    staging:
+      +tags: staging
+`
+	var cfg ProjectConfig
+	if err := yaml.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		folders    []string
+		wantLayers int
+		check      func(t *testing.T, layers []map[string]interface{})
+	}{
+		{
+			name:       "root model",
+			folders:    nil,
+			wantLayers: 1,
+			check: func(t *testing.T, layers []map[string]interface{}) {
+				if layers[0]["+materialized"] != "view" || layers[0]["enabled"] != true {
+					t.Errorf("project layer = %v", layers[0])
+				}
+				if _, ok := layers[0]["marts"]; ok {
+					t.Error("folders must not be returned as configuration")
+				}
+			},
+		},
+		{
+			name:       "nested folder",
+			folders:    []string{"marts", "finance"},
+			wantLayers: 3,
+			check: func(t *testing.T, layers []map[string]interface{}) {
+				if layers[1]["+materialized"] != "table" {
+					t.Errorf("marts layer = %v", layers[1])
+				}
+				if layers[2]["+alias"] != "fin" || layers[2]["schema"] != "reporting" {
+					t.Errorf("finance layer = %v", layers[2])
+				}
+			},
+		},
+		{
+			name:       "unconfigured folder",
+			folders:    []string{"intermediate"},
+			wantLayers: 1,
+		},
+		{
+			name:       "unconfigured subfolder",
+			folders:    []string{"staging", "crm"},
+			wantLayers: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layers := cfg.ModelConfigLayers(tt.folders)
+			if len(layers) != tt.wantLayers {
+				t.Fatalf("got %d layers, want %d: %v", len(layers), tt.wantLayers, layers)
+			}
+			if tt.check != nil {
+				tt.check(t, layers)
+			}
+		})
+	}
+}
+
+func TestModelConfigLayersWithoutProjectLevel(t *testing.T) {
+	input := `
+name: shop
+version: 1.0.0
+models:
+  marts:
+    +materialized: table
+    +tags: [finance]
+`
+	var cfg ProjectConfig
+	if err := yaml.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	layers := cfg.ModelConfigLayers([]string{"marts"})
+	if len(layers) != 2 {
+		t.Fatalf("got %d layers, want 2: %v", len(layers), layers)
+	}
+	if len(layers[0]) != 0 {
+		t.Errorf("project layer = %v, want empty", layers[0])
+	}
+	if layers[1]["+materialized"] != "table" {
+		t.Errorf("marts layer = %v, want +materialized: table", layers[1])
+	}
+
+	for _, layer := range cfg.ModelConfigLayers([]string{"staging"}) {
+		if _, ok := layer["+materialized"]; ok {
+			t.Errorf("marts config applied to staging: %v", layer)
+		}
+	}
+}
+
+func TestModelConfigLayersProjectWideWithoutProjectLevel(t *testing.T) {
+	input := `
+name: shop
+version: 1.0.0
+models:
+  +materialized: view
+  +tags: [all]
+  marts:
+    +materialized: table
+snapshots:
+  +strategy: timestamp
+`
+	var cfg ProjectConfig
+	if err := yaml.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	layers := cfg.ModelConfigLayers([]string{"marts"})
+	if len(layers) != 2 {
+		t.Fatalf("got %d layers, want 2: %v", len(layers), layers)
+	}
+	if layers[0]["+materialized"] != "view" || layers[1]["+materialized"] != "table" {
+		t.Errorf("layers = %v, want view then table", layers)
+	}
+	if _, ok := layers[0]["marts"]; ok {
+		t.Errorf("project layer = %v, want no folder entries", layers[0])
+	}
+	if tags, ok := layers[0]["+tags"].([]interface{}); !ok || len(tags) != 1 || tags[0] != "all" {
+		t.Errorf("project tags = %v, want [all]", layers[0]["+tags"])
+	}
+
+	if snapshot := cfg.SnapshotConfigLayers(nil); len(snapshot) != 1 || snapshot[0]["+strategy"] != "timestamp" {
+		t.Errorf("SnapshotConfigLayers() = %v", snapshot)
+	}
+}
+
+func TestModelConfigLayersProjectLevelWins(t *testing.T) {
+	cfg := &ProjectConfig{
+		Name: "shop",
+		Models: map[string]interface{}{
+			"shop":  map[string]interface{}{"+materialized": "view"},
+			"marts": map[string]interface{}{"+materialized": "table"},
+		},
+	}
+
+	layers := cfg.ModelConfigLayers([]string{"marts"})
+	if len(layers) != 1 || layers[0]["+materialized"] != "view" {
+		t.Errorf("ModelConfigLayers() = %v, want only the shop level", layers)
+	}
+}
+
+func TestSnapshotConfigLayers(t *testing.T) {
+	input := `
+name: shop
+version: 1.0.0
+models:
+  shop:
+    +materialized: view
+snapshots:
+  shop:
+    +strategy: timestamp
+    crm:
+      +unique_key: customer_id
+`
+	var cfg ProjectConfig
+	if err := yaml.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	layers := cfg.SnapshotConfigLayers([]string{"crm"})
+	if len(layers) != 2 {
+		t.Fatalf("got %d layers, want 2: %v", len(layers), layers)
+	}
+	if layers[0]["+strategy"] != "timestamp" || layers[1]["+unique_key"] != "customer_id" {
+		t.Errorf("layers = %v", layers)
+	}
+	if _, ok := layers[0]["+materialized"]; ok {
+		t.Error("the models: block must not apply to snapshots")
+	}
+}
@@ -25,6 +25,8 @@ type Profile struct {
 type OutputConfig struct {
 	Type     string `yaml:"type"`
 	Database string `yaml:"database"`
+	// Threads is the maximum number of models executed concurrently (default 1)
+	Threads int `yaml:"threads"`
 	// Additional fields can be added as needed for other database types
 }
 
@@ -180,6 +182,10 @@ func (o *OutputConfig) Validate() error {
 		return fmt.Errorf("type is required")
 	}
 
+	if o.Threads < 0 {
+		return fmt.Errorf("threads must be a positive number, got %d", o.Threads)
+	}
+
 	// Type-specific validation
 	switch strings.ToLower(o.Type) {
 	case "sqlite":
@@ -281,6 +281,24 @@ func TestOutputValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid threads",
+			output: &OutputConfig{
+				Type:     "sqlite",
+				Database: "./test.db",
+				Threads:  4,
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative threads",
+			output: &OutputConfig{
+				Type:     "sqlite",
+				Database: "./test.db",
+				Threads:  -1,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
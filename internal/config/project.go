@@ -9,15 +9,19 @@ import (
 
 // ProjectConfig represents the gorchata_project.yml configuration
 type ProjectConfig struct {
-	Name       string                            `yaml:"name"`
-	Version    string                            `yaml:"version"`
-	Profile    string                            `yaml:"profile"`
-	ModelPaths []string                          `yaml:"model-paths"`
-	SeedPaths  []string                          `yaml:"seed-paths"`
-	TestPaths  []string                          `yaml:"test-paths"`
-	MacroPaths []string                          `yaml:"macro-paths"`
-	Vars       map[string]interface{}            `yaml:"vars"`
-	Models     map[string]map[string]interface{} `yaml:"models"`
+	Name          string                 `yaml:"name"`
+	Version       string                 `yaml:"version"`
+	Profile       string                 `yaml:"profile"`
+	ModelPaths    []string               `yaml:"model-paths"`
+	SeedPaths     []string               `yaml:"seed-paths"`
+	TestPaths     []string               `yaml:"test-paths"`
+	MacroPaths    []string               `yaml:"macro-paths"`
+	SnapshotPaths []string               `yaml:"snapshot-paths"`
+	Vars          map[string]interface{} `yaml:"vars"`
+	Models        map[string]interface{} `yaml:"models"`
+	Snapshots     map[string]interface{} `yaml:"snapshots"`
+	OnRunStart    HookList               `yaml:"on-run-start"`
+	OnRunEnd      HookList               `yaml:"on-run-end"`
 }
 
 // LoadProject loads and parses a gorchata_project.yml file
@@ -57,11 +61,17 @@ func (c *ProjectConfig) applyDefaults() {
 	if len(c.MacroPaths) == 0 {
 		c.MacroPaths = []string{"macros"}
 	}
+	if len(c.SnapshotPaths) == 0 {
+		c.SnapshotPaths = []string{"snapshots"}
+	}
 	if c.Vars == nil {
 		c.Vars = make(map[string]interface{})
 	}
 	if c.Models == nil {
-		c.Models = make(map[string]map[string]interface{})
+		c.Models = make(map[string]interface{})
+	}
+	if c.Snapshots == nil {
+		c.Snapshots = make(map[string]interface{})
 	}
 }
 
@@ -52,7 +52,7 @@ func TestLoadProject(t *testing.T) {
 		t.Fatal("Models is nil, want non-nil map")
 	}
 
-	myProject, ok := cfg.Models["my_project"]
+	myProject, ok := cfg.Models["my_project"].(map[string]interface{})
 	if !ok {
 		t.Fatal("Models[my_project] not found")
 	}
@@ -114,6 +114,13 @@ func TestLoadProjectDefaults(t *testing.T) {
 		t.Errorf("MacroPaths[0] = %q, want %q", cfg.MacroPaths[0], expectedMacroPaths[0])
 	}
 
+	expectedSnapshotPaths := []string{"snapshots"}
+	if len(cfg.SnapshotPaths) != len(expectedSnapshotPaths) {
+		t.Errorf("len(SnapshotPaths) = %d, want %d", len(cfg.SnapshotPaths), len(expectedSnapshotPaths))
+	} else if cfg.SnapshotPaths[0] != expectedSnapshotPaths[0] {
+		t.Errorf("SnapshotPaths[0] = %q, want %q", cfg.SnapshotPaths[0], expectedSnapshotPaths[0])
+	}
+
 	// Profile should default to empty string or be handled gracefully
 	// Vars should be initialized as empty map
 	if cfg.Vars == nil {
@@ -43,6 +43,20 @@ type ImportConfig struct {
 	Scope     string `yaml:"scope"`
 }
 
+// DefaultSeedConfig returns the seed configuration used when no seed.yml exists
+func DefaultSeedConfig() *SeedConfig {
+	return &SeedConfig{
+		Version: 1,
+		Naming: NamingConfig{
+			Strategy: NamingStrategyFilename,
+		},
+		Import: ImportConfig{
+			BatchSize: 1000,
+			Scope:     ScopeTree,
+		},
+	}
+}
+
 // ParseSeedConfig loads and parses a seed.yml file
 func ParseSeedConfig(filePath string) (*SeedConfig, error) {
 	data, err := os.ReadFile(filePath)
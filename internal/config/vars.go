@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseVars parses the value of the --vars flag. The value is a map written
+// as JSON or YAML, e.g. '{"start_date": "2024-01-01"}' or 'start_date: 2024-01-01'.
+// An empty value yields an empty map.
+func ParseVars(value string) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	if strings.TrimSpace(value) == "" {
+		return vars, nil
+	}
+
+	// JSON is a subset of YAML, so one decoder handles both
+	if err := yaml.Unmarshal([]byte(value), &vars); err != nil {
+		return nil, fmt.Errorf("invalid vars %q: must be a JSON or YAML map: %w", value, err)
+	}
+
+	return vars, nil
+}
+
+// ResolveVars returns the project vars with the given overrides applied.
+// Overrides replace project vars of the same name.
+func (c *ProjectConfig) ResolveVars(overrides map[string]interface{}) map[string]interface{} {
+	vars := make(map[string]interface{}, len(c.Vars)+len(overrides))
+	for name, value := range c.Vars {
+		vars[name] = value
+	}
+	for name, value := range overrides {
+		vars[name] = value
+	}
+	return vars
+}
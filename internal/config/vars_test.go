@@ -0,0 +1,57 @@
+package config
+
+import "testing"
+
+func TestParseVars(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"empty", "", map[string]interface{}{}, false},
+		{"json", `{"start_date": "2024-01-01", "limit": 10}`, map[string]interface{}{"start_date": "2024-01-01", "limit": 10}, false},
+		{"yaml", "region: west", map[string]interface{}{"region": "west"}, false},
+		{"yaml flow", "{region: west}", map[string]interface{}{"region": "west"}, false},
+		{"not a map", `["a", "b"]`, nil, true},
+		{"invalid", `{"start_date": `, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVars(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseVars() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseVars()[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveVars(t *testing.T) {
+	cfg := &ProjectConfig{
+		Vars: map[string]interface{}{"start_date": "2020-01-01", "region": "west"},
+	}
+
+	vars := cfg.ResolveVars(map[string]interface{}{"start_date": "2024-01-01"})
+
+	if vars["start_date"] != "2024-01-01" {
+		t.Errorf("start_date = %v, want override 2024-01-01", vars["start_date"])
+	}
+	if vars["region"] != "west" {
+		t.Errorf("region = %v, want project value west", vars["region"])
+	}
+	if cfg.Vars["start_date"] != "2020-01-01" {
+		t.Error("ResolveVars must not modify the project vars")
+	}
+}
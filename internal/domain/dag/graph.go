@@ -2,6 +2,7 @@ package dag
 
 import (
 	"fmt"
+	"sort"
 )
 
 // Graph represents a directed acyclic graph (DAG) of dependencies.
@@ -14,13 +15,18 @@ type Graph struct {
 	// If "model_orders" depends on "model_users", we have:
 	// edges["model_orders"] = ["model_users"]
 	edges map[string][]string
+
+	// reverseEdges stores the inverse adjacency list: to -> [from1, from2, ...]
+	// For the example above, reverseEdges["model_users"] = ["model_orders"]
+	reverseEdges map[string][]string
 }
 
 // NewGraph creates a new empty graph.
 func NewGraph() *Graph {
 	return &Graph{
-		nodes: make(map[string]*Node),
-		edges: make(map[string][]string),
+		nodes:        make(map[string]*Node),
+		edges:        make(map[string][]string),
+		reverseEdges: make(map[string][]string),
 	}
 }
 
@@ -59,6 +65,7 @@ func (g *Graph) AddEdge(from, to string) error {
 	}
 
 	g.edges[from] = append(g.edges[from], to)
+	g.reverseEdges[to] = append(g.reverseEdges[to], from)
 	return nil
 }
 
@@ -107,3 +114,55 @@ func (g *Graph) HasEdge(from, to string) bool {
 	}
 	return false
 }
+
+// GetDependents returns the list of node IDs that directly depend on the given node.
+// Returns an empty slice if no node depends on it or it doesn't exist.
+func (g *Graph) GetDependents(id string) []string {
+	dependents, exists := g.reverseEdges[id]
+	if !exists {
+		return []string{}
+	}
+
+	// Return a copy to prevent external modification
+	result := make([]string, len(dependents))
+	copy(result, dependents)
+	return result
+}
+
+// Descendants returns the IDs of all nodes that depend on the given node,
+// directly or transitively. The result is sorted and excludes the node itself.
+func (g *Graph) Descendants(id string) []string {
+	return g.walk(id, g.reverseEdges, 0)
+}
+
+// Ancestors returns the IDs of all nodes the given node depends on,
+// directly or transitively. The result is sorted and excludes the node itself.
+func (g *Graph) Ancestors(id string) []string {
+	return g.walk(id, g.edges, 0)
+}
+
+// walk performs a breadth-first traversal from id following the given adjacency list.
+// A positive depth limits the traversal to that many edges; 0 means unlimited.
+func (g *Graph) walk(id string, adjacency map[string][]string, depth int) []string {
+	visited := map[string]bool{id: true}
+	level := []string{id}
+	result := []string{}
+
+	for distance := 1; len(level) > 0 && (depth == 0 || distance <= depth); distance++ {
+		var next []string
+		for _, current := range level {
+			for _, neighbour := range adjacency[current] {
+				if visited[neighbour] {
+					continue
+				}
+				visited[neighbour] = true
+				result = append(result, neighbour)
+				next = append(next, neighbour)
+			}
+		}
+		level = next
+	}
+
+	sort.Strings(result)
+	return result
+}
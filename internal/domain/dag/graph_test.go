@@ -199,3 +199,66 @@ func TestGetNodes(t *testing.T) {
 		t.Error("expected model_products in nodes")
 	}
 }
+
+func TestGetDependents(t *testing.T) {
+	g := NewGraph()
+
+	g.AddNode(&Node{ID: "users", Name: "users", Type: "model"})
+	g.AddNode(&Node{ID: "orders", Name: "orders", Type: "model"})
+	g.AddNode(&Node{ID: "payments", Name: "payments", Type: "model"})
+
+	g.AddEdge("orders", "users")
+	g.AddEdge("payments", "users")
+
+	dependents := g.GetDependents("users")
+	if len(dependents) != 2 {
+		t.Fatalf("expected 2 dependents, got %d", len(dependents))
+	}
+
+	if len(g.GetDependents("orders")) != 0 {
+		t.Error("expected no dependents for orders")
+	}
+
+	if len(g.GetDependents("missing")) != 0 {
+		t.Error("expected no dependents for missing node")
+	}
+}
+
+func TestDescendantsAndAncestors(t *testing.T) {
+	g := NewGraph()
+
+	// raw -> stg -> fct -> rpt, plus an unrelated node
+	for _, id := range []string{"raw", "stg", "fct", "rpt", "other"} {
+		g.AddNode(&Node{ID: id, Name: id, Type: "model"})
+	}
+	g.AddEdge("stg", "raw")
+	g.AddEdge("fct", "stg")
+	g.AddEdge("rpt", "fct")
+	g.AddEdge("rpt", "stg")
+
+	descendants := g.Descendants("stg")
+	want := []string{"fct", "rpt"}
+	if len(descendants) != len(want) {
+		t.Fatalf("Descendants(stg) = %v, want %v", descendants, want)
+	}
+	for i := range want {
+		if descendants[i] != want[i] {
+			t.Errorf("Descendants(stg)[%d] = %s, want %s", i, descendants[i], want[i])
+		}
+	}
+
+	ancestors := g.Ancestors("rpt")
+	want = []string{"fct", "raw", "stg"}
+	if len(ancestors) != len(want) {
+		t.Fatalf("Ancestors(rpt) = %v, want %v", ancestors, want)
+	}
+	for i := range want {
+		if ancestors[i] != want[i] {
+			t.Errorf("Ancestors(rpt)[%d] = %s, want %s", i, ancestors[i], want[i])
+		}
+	}
+
+	if len(g.Descendants("other")) != 0 {
+		t.Error("expected no descendants for isolated node")
+	}
+}
@@ -0,0 +1,334 @@
+package dag
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Node metadata keys used by selection methods
+const (
+	// MetadataFilePath holds the path of the file that defines the node (string)
+	MetadataFilePath = "file_path"
+
+	// MetadataTags holds the tags of the node ([]string)
+	MetadataTags = "tags"
+
+	// MetadataConfig holds the resolved configuration of the node (map[string]interface{})
+	MetadataConfig = "config"
+
+	// MetadataState holds how the node compares to a previous state: "new",
+	// "modified" or empty when unchanged (string)
+	MetadataState = "state"
+)
+
+// Selector selects nodes of a graph using the graph selection syntax.
+//
+// A selector is a space-separated union of terms; each term is a
+// comma-separated intersection of criteria. A criterion is one of:
+//
+//	name             node name, * matches any characters
+//	tag:finance      nodes with the tag
+//	path:models/x    nodes defined in the file or under the directory
+//	config.key:val   nodes whose resolved config key equals val
+//	state:new        nodes absent from the previous state
+//	state:modified   nodes that are new or changed since the previous state
+//
+// and may be combined with graph operators:
+//
+//	+name    the node and all its ancestors (2+name: up to 2 levels)
+//	name+    the node and all its descendants (name+1: 1 level)
+//	@name    the node, its descendants and all ancestors of both
+type Selector struct {
+	// union holds intersections of criteria
+	union [][]selectorCriterion
+}
+
+// selectorCriterion is a single criterion with its graph operators
+type selectorCriterion struct {
+	method string
+	value  string
+
+	parents        bool
+	parentsDepth   int
+	children       bool
+	childrenDepth  int
+	childrenParent bool
+}
+
+// ParseSelector parses a selector expression. An empty expression selects nothing.
+func ParseSelector(expr string) (*Selector, error) {
+	selector := &Selector{}
+
+	for _, term := range strings.Fields(expr) {
+		var intersection []selectorCriterion
+		for _, part := range strings.Split(term, ",") {
+			if part == "" {
+				return nil, fmt.Errorf("invalid selector %q: empty criterion", term)
+			}
+			criterion, err := parseSelectorCriterion(part)
+			if err != nil {
+				return nil, err
+			}
+			intersection = append(intersection, criterion)
+		}
+		selector.union = append(selector.union, intersection)
+	}
+
+	return selector, nil
+}
+
+// parseSelectorCriterion parses a criterion such as "2+tag:finance+"
+func parseSelectorCriterion(part string) (selectorCriterion, error) {
+	c := selectorCriterion{method: "name"}
+	rest := part
+
+	if strings.HasPrefix(rest, "@") {
+		c.childrenParent = true
+		rest = rest[1:]
+	}
+
+	// Parent operator: "+" or "n+" before the criterion
+	if i := strings.Index(rest, "+"); i >= 0 && i < len(rest)-1 && isDigits(rest[:i]) {
+		if c.childrenParent {
+			return c, fmt.Errorf("invalid selector %q: @ cannot be combined with +", part)
+		}
+		c.parents = true
+		c.parentsDepth, _ = strconv.Atoi(rest[:i])
+		rest = rest[i+1:]
+	}
+
+	// Child operator: "+" or "+n" after the criterion
+	if i := strings.LastIndex(rest, "+"); i >= 0 && isDigits(rest[i+1:]) {
+		if c.childrenParent {
+			return c, fmt.Errorf("invalid selector %q: @ cannot be combined with +", part)
+		}
+		c.children = true
+		c.childrenDepth, _ = strconv.Atoi(rest[i+1:])
+		rest = rest[:i]
+	}
+
+	if rest == "" || strings.ContainsAny(rest, "+@") {
+		return c, fmt.Errorf("invalid selector %q", part)
+	}
+	c.value = rest
+
+	if method, value, ok := strings.Cut(rest, ":"); ok {
+		if method != "name" && method != "tag" && method != "path" && method != "state" && !strings.HasPrefix(method, "config.") {
+			return c, fmt.Errorf("invalid selector %q: unknown method %s", part, method)
+		}
+		if value == "" {
+			return c, fmt.Errorf("invalid selector %q: missing value", part)
+		}
+		if method == "state" && value != "new" && value != "modified" {
+			return c, fmt.Errorf("invalid selector %q: state must be new or modified", part)
+		}
+		c.method, c.value = method, value
+	}
+
+	return c, nil
+}
+
+// isDigits reports whether s is empty or made of decimal digits only
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// UsesMethod reports whether any criterion of the selector uses the given method
+func (s *Selector) UsesMethod(method string) bool {
+	for _, intersection := range s.union {
+		for _, criterion := range intersection {
+			if criterion.method == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Select returns the sorted IDs of the graph nodes matched by the selector
+func (s *Selector) Select(g *Graph) []string {
+	selected := make(map[string]bool)
+
+	for _, intersection := range s.union {
+		var matched map[string]bool
+		for i, criterion := range intersection {
+			ids := criterion.selectNodes(g)
+			if i == 0 {
+				matched = ids
+				continue
+			}
+			for id := range matched {
+				if !ids[id] {
+					delete(matched, id)
+				}
+			}
+		}
+		for id := range matched {
+			selected[id] = true
+		}
+	}
+
+	return sortedIDs(selected)
+}
+
+// selectNodes returns the nodes matching the criterion, expanded by its graph operators
+func (c selectorCriterion) selectNodes(g *Graph) map[string]bool {
+	selected := make(map[string]bool)
+
+	for _, node := range g.GetNodes() {
+		if !c.matches(node) {
+			continue
+		}
+		selected[node.ID] = true
+
+		if c.parents || c.childrenParent {
+			for _, id := range g.walk(node.ID, g.edges, c.parentsDepth) {
+				selected[id] = true
+			}
+		}
+
+		if c.children || c.childrenParent {
+			for _, child := range g.walk(node.ID, g.reverseEdges, c.childrenDepth) {
+				selected[child] = true
+				if c.childrenParent {
+					for _, id := range g.Ancestors(child) {
+						selected[id] = true
+					}
+				}
+			}
+		}
+	}
+
+	return selected
+}
+
+// matches reports whether the node itself satisfies the criterion
+func (c selectorCriterion) matches(node *Node) bool {
+	switch {
+	case c.method == "name":
+		matched, err := path.Match(c.value, node.Name)
+		return err == nil && matched
+
+	case c.method == "tag":
+		tags, _ := node.Metadata[MetadataTags].([]string)
+		for _, tag := range tags {
+			if tag == c.value {
+				return true
+			}
+		}
+		return false
+
+	case c.method == "path":
+		filePath, _ := node.Metadata[MetadataFilePath].(string)
+		return filePath != "" && pathWithin(filePath, c.value)
+
+	case c.method == "state":
+		state, _ := node.Metadata[MetadataState].(string)
+		// New nodes are also considered modified
+		return state == c.value || (c.value == "modified" && state == "new")
+
+	case strings.HasPrefix(c.method, "config."):
+		config, _ := node.Metadata[MetadataConfig].(map[string]interface{})
+		value, ok := config[strings.TrimPrefix(c.method, "config.")]
+		return ok && configValueMatches(value, c.value)
+	}
+
+	return false
+}
+
+// pathWithin reports whether file is the given path or lies under it
+func pathWithin(file, dir string) bool {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	return absFile == absDir || strings.HasPrefix(absFile, absDir+string(filepath.Separator))
+}
+
+// configValueMatches compares a config value with a selector value.
+// List values match when any of their elements matches.
+func configValueMatches(value interface{}, want string) bool {
+	switch v := value.(type) {
+	case []string:
+		for _, item := range v {
+			if item == want {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if fmt.Sprint(item) == want {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(v) == want
+	}
+}
+
+// SelectNodes returns the sorted IDs of the nodes matched by the include
+// selector minus those matched by the exclude selector. An empty include
+// selector selects every node.
+func SelectNodes(g *Graph, include, exclude string) ([]string, error) {
+	var selected []string
+	if strings.TrimSpace(include) == "" {
+		for _, node := range g.GetNodes() {
+			selected = append(selected, node.ID)
+		}
+		sort.Strings(selected)
+	} else {
+		includeSelector, err := ParseSelector(include)
+		if err != nil {
+			return nil, err
+		}
+		selected = includeSelector.Select(g)
+	}
+
+	if strings.TrimSpace(exclude) == "" {
+		return selected, nil
+	}
+
+	excludeSelector, err := ParseSelector(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool)
+	for _, id := range excludeSelector.Select(g) {
+		excluded[id] = true
+	}
+
+	result := make([]string, 0, len(selected))
+	for _, id := range selected {
+		if !excluded[id] {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// sortedIDs returns the keys of a set in sorted order
+func sortedIDs(set map[string]bool) []string {
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
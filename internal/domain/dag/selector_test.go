@@ -0,0 +1,134 @@
+package dag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newSelectorTestGraph builds:
+//
+//	raw_orders <- stg_orders <- fct_orders <- report
+//	stg_customers <- fct_orders
+//	other (isolated)
+func newSelectorTestGraph(t *testing.T) *Graph {
+	t.Helper()
+	g := NewGraph()
+
+	nodes := []struct {
+		id           string
+		path         string
+		tags         []string
+		materialized string
+		state        string
+	}{
+		{"raw_orders", "models/staging/raw_orders.sql", []string{"raw"}, "view", ""},
+		{"stg_orders", "models/staging/stg_orders.sql", nil, "view", "modified"},
+		{"stg_customers", "models/staging/stg_customers.sql", nil, "view", ""},
+		{"fct_orders", "models/marts/fct_orders.sql", []string{"finance"}, "incremental", ""},
+		{"report", "models/marts/report.sql", []string{"finance", "daily"}, "table", ""},
+		{"other", "models/other.sql", nil, "table", "new"},
+	}
+	for _, n := range nodes {
+		node := &Node{
+			ID:   n.id,
+			Name: n.id,
+			Type: "model",
+			Metadata: map[string]interface{}{
+				MetadataFilePath: n.path,
+				MetadataTags:     n.tags,
+				MetadataConfig:   map[string]interface{}{"materialized": n.materialized, "tags": n.tags},
+				MetadataState:    n.state,
+			},
+		}
+		if err := g.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edges := [][2]string{
+		{"stg_orders", "raw_orders"},
+		{"fct_orders", "stg_orders"},
+		{"fct_orders", "stg_customers"},
+		{"report", "fct_orders"},
+	}
+	for _, e := range edges {
+		if err := g.AddEdge(e[0], e[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return g
+}
+
+func TestSelectNodes(t *testing.T) {
+	g := newSelectorTestGraph(t)
+
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		want    []string
+	}{
+		{"empty selects all", "", "", []string{"fct_orders", "other", "raw_orders", "report", "stg_customers", "stg_orders"}},
+		{"name", "fct_orders", "", []string{"fct_orders"}},
+		{"wildcard", "stg_*", "", []string{"stg_customers", "stg_orders"}},
+		{"ancestors", "+fct_orders", "", []string{"fct_orders", "raw_orders", "stg_customers", "stg_orders"}},
+		{"ancestors with depth", "1+fct_orders", "", []string{"fct_orders", "stg_customers", "stg_orders"}},
+		{"descendants", "stg_orders+", "", []string{"fct_orders", "report", "stg_orders"}},
+		{"descendants with depth", "raw_orders+1", "", []string{"raw_orders", "stg_orders"}},
+		{"both directions", "+stg_orders+", "", []string{"fct_orders", "raw_orders", "report", "stg_orders"}},
+		{"at operator", "@stg_orders", "", []string{"fct_orders", "raw_orders", "report", "stg_customers", "stg_orders"}},
+		{"tag", "tag:finance", "", []string{"fct_orders", "report"}},
+		{"path directory", "path:models/marts", "", []string{"fct_orders", "report"}},
+		{"path file", "path:models/other.sql", "", []string{"other"}},
+		{"config", "config.materialized:incremental", "", []string{"fct_orders"}},
+		{"config list value", "config.tags:daily", "", []string{"report"}},
+		{"state new", "state:new", "", []string{"other"}},
+		{"state modified includes new", "state:modified", "", []string{"other", "stg_orders"}},
+		{"state modified descendants", "state:modified+", "", []string{"fct_orders", "other", "report", "stg_orders"}},
+		{"union", "other raw_orders", "", []string{"other", "raw_orders"}},
+		{"intersection", "tag:finance,config.materialized:table", "", []string{"report"}},
+		{"intersection with graph operator", "+report,path:models/staging", "", []string{"raw_orders", "stg_customers", "stg_orders"}},
+		{"exclude", "+fct_orders", "stg_customers", []string{"fct_orders", "raw_orders", "stg_orders"}},
+		{"exclude with graph operator", "", "raw_orders+", []string{"other", "stg_customers"}},
+		{"no match", "missing", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectNodes(g, tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("SelectNodes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectNodes(%q, %q) = %v, want %v", tt.include, tt.exclude, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSelector_Errors(t *testing.T) {
+	tests := []struct {
+		expr        string
+		errContains string
+	}{
+		{"unknown:x", "unknown method"},
+		{"tag:", "missing value"},
+		{"state:removed", "state must be new or modified"},
+		{"a,,b", "empty criterion"},
+		{"@+a", "cannot be combined"},
+		{"@a+", "cannot be combined"},
+		{"+", "invalid selector"},
+		{"a+b+c", "invalid selector"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseSelector(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("ParseSelector(%q) error = %v, want error containing %q", tt.expr, err, tt.errContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+)
+
+// Resource types of the nodes recorded in run results
+const (
+	ResourceTypeModel = "model"
+	ResourceTypeSeed  = "seed"
+	ResourceTypeTest  = "test"
+)
+
+// RunResults describes a single invocation and the result of every node it
+// executed. It is written to target/run_results.json.
+type RunResults struct {
+	// InvocationID uniquely identifies the invocation
+	InvocationID string
+
+	// Command is the command that was invoked, e.g. "run"
+	Command string
+
+	// Args are the arguments the command was invoked with
+	Args []string
+
+	// ProjectName is used to build the unique ID of each node
+	ProjectName string
+
+	// Result is the outcome of the model executions, if any
+	Result *ExecutionResult
+
+	// Nodes are the outcomes of the seeds and tests the invocation executed
+	Nodes []NodeResult
+}
+
+// NodeResult is the outcome of a seed or test recorded in run results
+type NodeResult struct {
+	// ResourceType is ResourceTypeSeed or ResourceTypeTest
+	ResourceType string
+
+	// Name is the name of the seed or test
+	Name string
+
+	// Status is StatusSuccess, StatusFailed or StatusSkipped
+	Status ExecutionStatus
+
+	// StartTime and EndTime delimit the execution
+	StartTime time.Time
+	EndTime   time.Time
+
+	// RowsAffected is the number of rows loaded or failing the test
+	RowsAffected int64
+
+	// Error contains the error message if execution failed
+	Error string
+
+	// SkippedBecause is the name of the failed node that caused this node to
+	// be skipped (only set when Status is StatusSkipped)
+	SkippedBecause string
+}
+
+// runResultsJSON is the serialized form of RunResults
+type runResultsJSON struct {
+	Metadata    runMetadataJSON `json:"metadata"`
+	Status      ExecutionStatus `json:"status"`
+	ElapsedTime float64         `json:"elapsed_time"`
+	Results     []runResultJSON `json:"results"`
+}
+
+type runMetadataJSON struct {
+	InvocationID string    `json:"invocation_id"`
+	Command      string    `json:"command"`
+	Args         []string  `json:"args"`
+	ProjectName  string    `json:"project_name,omitempty"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
+
+type runResultJSON struct {
+	UniqueID       string          `json:"unique_id"`
+	ResourceType   string          `json:"resource_type"`
+	Name           string          `json:"name"`
+	Status         ExecutionStatus `json:"status"`
+	StartedAt      time.Time       `json:"started_at"`
+	CompletedAt    time.Time       `json:"completed_at"`
+	ExecutionTime  float64         `json:"execution_time"`
+	RowsAffected   int64           `json:"rows_affected"`
+	CompiledCode   string          `json:"compiled_code,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	SkippedBecause string          `json:"skipped_because,omitempty"`
+	Batches        []batchJSON     `json:"batch_results,omitempty"`
+}
+
+type batchJSON struct {
+	Start        time.Time       `json:"batch_start"`
+	End          time.Time       `json:"batch_end"`
+	Status       ExecutionStatus `json:"status"`
+	RowsAffected int64           `json:"rows_affected"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// Write writes the run results as indented JSON, creating the parent directory if needed
+func (r *RunResults) Write(path string) error {
+	out := runResultsJSON{
+		Metadata: runMetadataJSON{
+			InvocationID: r.InvocationID,
+			Command:      r.Command,
+			Args:         r.Args,
+			ProjectName:  r.ProjectName,
+			GeneratedAt:  time.Now(),
+		},
+		Status:  r.Status(),
+		Results: []runResultJSON{},
+	}
+	if out.Metadata.Args == nil {
+		out.Metadata.Args = []string{}
+	}
+
+	if r.Result != nil {
+		for _, mr := range r.Result.ModelResults {
+			out.Results = append(out.Results, runResultJSON{
+				UniqueID:       r.uniqueID(ResourceTypeModel, mr.ModelID),
+				ResourceType:   ResourceTypeModel,
+				Name:           mr.ModelID,
+				Status:         mr.Status,
+				StartedAt:      mr.StartTime,
+				CompletedAt:    mr.EndTime,
+				ExecutionTime:  mr.Duration().Seconds(),
+				RowsAffected:   mr.RowsAffected,
+				CompiledCode:   mr.CompiledSQL,
+				Error:          mr.Error,
+				SkippedBecause: mr.SkippedBecause,
+				Batches:        batchesJSON(mr.Batches),
+			})
+		}
+	}
+	for _, nr := range r.Nodes {
+		out.Results = append(out.Results, runResultJSON{
+			UniqueID:       r.uniqueID(nr.ResourceType, nr.Name),
+			ResourceType:   nr.ResourceType,
+			Name:           nr.Name,
+			Status:         nr.Status,
+			StartedAt:      nr.StartTime,
+			CompletedAt:    nr.EndTime,
+			ExecutionTime:  nr.EndTime.Sub(nr.StartTime).Seconds(),
+			RowsAffected:   nr.RowsAffected,
+			Error:          nr.Error,
+			SkippedBecause: nr.SkippedBecause,
+		})
+	}
+
+	// The elapsed time spans from the first start to the last completion
+	var first, last time.Time
+	for _, result := range out.Results {
+		if first.IsZero() || result.StartedAt.Before(first) {
+			first = result.StartedAt
+		}
+		if result.CompletedAt.After(last) {
+			last = result.CompletedAt
+		}
+	}
+	if !first.IsZero() {
+		out.ElapsedTime = last.Sub(first).Seconds()
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run results: %w", err)
+	}
+
+	return writeArtifact(path, data)
+}
+
+// ReadRunResults reads run results written by Write
+func ReadRunResults(path string) (*RunResults, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run results: %w", err)
+	}
+
+	var in runResultsJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("failed to parse run results %s: %w", path, err)
+	}
+
+	r := &RunResults{
+		InvocationID: in.Metadata.InvocationID,
+		Command:      in.Metadata.Command,
+		Args:         in.Metadata.Args,
+		ProjectName:  in.Metadata.ProjectName,
+	}
+
+	for _, result := range in.Results {
+		if result.ResourceType == ResourceTypeModel {
+			if r.Result == nil {
+				r.Result = NewExecutionResult()
+			}
+			r.Result.AddModelResult(ModelResult{
+				ModelID:        result.Name,
+				Status:         result.Status,
+				StartTime:      result.StartedAt,
+				EndTime:        result.CompletedAt,
+				Error:          result.Error,
+				RowsAffected:   result.RowsAffected,
+				CompiledSQL:    result.CompiledCode,
+				SkippedBecause: result.SkippedBecause,
+				Batches:        batchResults(result.Batches),
+			})
+			continue
+		}
+
+		r.Nodes = append(r.Nodes, NodeResult{
+			ResourceType:   result.ResourceType,
+			Name:           result.Name,
+			Status:         result.Status,
+			StartTime:      result.StartedAt,
+			EndTime:        result.CompletedAt,
+			RowsAffected:   result.RowsAffected,
+			Error:          result.Error,
+			SkippedBecause: result.SkippedBecause,
+		})
+	}
+	if r.Result != nil {
+		r.Result.Complete()
+	}
+
+	return r, nil
+}
+
+// Status returns StatusFailed if any node failed, and StatusSuccess otherwise
+func (r *RunResults) Status() ExecutionStatus {
+	if r.Result != nil && r.Result.FailureCount() > 0 {
+		return StatusFailed
+	}
+	for _, nr := range r.Nodes {
+		if nr.Status == StatusFailed {
+			return StatusFailed
+		}
+	}
+	return StatusSuccess
+}
+
+// Unfinished returns the names of the nodes of the given resource type that
+// failed, were skipped or were rolled back, in execution order
+func (r *RunResults) Unfinished(resourceType string) []string {
+	var names []string
+	if resourceType == ResourceTypeModel {
+		if r.Result == nil {
+			return nil
+		}
+		for _, mr := range r.Result.ModelResults {
+			if mr.Status == StatusFailed || mr.Status == StatusSkipped || mr.Status == StatusRolledBack {
+				names = append(names, mr.ModelID)
+			}
+		}
+		return names
+	}
+
+	for _, nr := range r.Nodes {
+		if nr.ResourceType == resourceType && (nr.Status == StatusFailed || nr.Status == StatusSkipped) {
+			names = append(names, nr.Name)
+		}
+	}
+	return names
+}
+
+// batchesJSON converts batch results to their serialized form
+func batchesJSON(batches []BatchResult) []batchJSON {
+	var out []batchJSON
+	for _, br := range batches {
+		out = append(out, batchJSON{
+			Start:        br.Batch.Start,
+			End:          br.Batch.End,
+			Status:       br.Status,
+			RowsAffected: br.RowsAffected,
+			Error:        br.Error,
+		})
+	}
+	return out
+}
+
+// batchResults converts serialized batch results back to BatchResult
+func batchResults(batches []batchJSON) []BatchResult {
+	var out []BatchResult
+	for _, b := range batches {
+		out = append(out, BatchResult{
+			Batch:        materialization.Batch{Start: b.Start, End: b.End},
+			Status:       b.Status,
+			RowsAffected: b.RowsAffected,
+			Error:        b.Error,
+		})
+	}
+	return out
+}
+
+// uniqueID returns the unique ID of a node, e.g. model.shop.orders
+func (r *RunResults) uniqueID(resourceType, name string) string {
+	return fmt.Sprintf("%s.%s.%s", resourceType, r.ProjectName, name)
+}
+
+// WriteCompiledSQL writes the compiled SQL of each model under dir,
+// mirroring the model folders
+func WriteCompiledSQL(dir string, models []*Model) error {
+	for _, model := range models {
+		if model.CompiledSQL == "" {
+			continue
+		}
+		path := filepath.Join(dir, model.ArtifactPath())
+		if err := writeArtifact(path, []byte(model.CompiledSQL)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRunSQL writes the statements executed for each model under dir,
+// mirroring the model folders. Models that executed nothing are left out.
+func WriteRunSQL(dir string, models []*Model, result *ExecutionResult) error {
+	byID := make(map[string]*Model, len(models))
+	for _, model := range models {
+		byID[model.ID] = model
+	}
+
+	for _, mr := range result.ModelResults {
+		model, ok := byID[mr.ModelID]
+		if !ok || len(mr.SQLStatements) == 0 {
+			continue
+		}
+		content := strings.Join(mr.SQLStatements, ";\n\n") + ";\n"
+		path := filepath.Join(dir, model.ArtifactPath())
+		if err := writeArtifact(path, []byte(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArtifact writes data to path, creating the parent directory if needed
+func writeArtifact(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
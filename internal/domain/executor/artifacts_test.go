@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+)
+
+func TestRunResults_Write(t *testing.T) {
+	start := time.Now()
+	result := NewExecutionResult()
+	result.AddModelResult(ModelResult{
+		ModelID:       "orders",
+		Status:        StatusSuccess,
+		StartTime:     start,
+		EndTime:       start.Add(2 * time.Second),
+		RowsAffected:  42,
+		CompiledSQL:   "SELECT 1",
+		SQLStatements: []string{"CREATE TABLE orders AS SELECT 1"},
+	})
+	result.AddModelResult(ModelResult{
+		ModelID:   "customers",
+		Status:    StatusFailed,
+		StartTime: start,
+		EndTime:   start,
+		Error:     "no such table",
+	})
+	result.AddModelResult(NewSkippedResult("report", "customers"))
+	result.Complete()
+
+	path := filepath.Join(t.TempDir(), "target", "run_results.json")
+	runResults := &RunResults{
+		InvocationID: "abc-123",
+		Command:      "run",
+		Args:         []string{"--models", "+report"},
+		ProjectName:  "shop",
+		Result:       result,
+		Nodes: []NodeResult{
+			{ResourceType: ResourceTypeTest, Name: "not_null_orders_id", Status: StatusSuccess, StartTime: start, EndTime: start},
+		},
+	}
+	if err := runResults.Write(path); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written runResultsJSON
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if written.Metadata.InvocationID != "abc-123" || written.Metadata.Command != "run" {
+		t.Errorf("metadata = %+v", written.Metadata)
+	}
+	if written.Status != StatusFailed {
+		t.Errorf("status = %s, want failed", written.Status)
+	}
+	if len(written.Results) != 4 {
+		t.Fatalf("got %d results, want 4", len(written.Results))
+	}
+
+	orders := written.Results[0]
+	if orders.UniqueID != "model.shop.orders" || orders.ResourceType != "model" || orders.Name != "orders" || orders.RowsAffected != 42 || orders.CompiledCode != "SELECT 1" {
+		t.Errorf("orders result = %+v", orders)
+	}
+	if orders.ExecutionTime != 2 {
+		t.Errorf("orders execution_time = %v, want 2", orders.ExecutionTime)
+	}
+	if written.Results[1].Error != "no such table" {
+		t.Errorf("customers error = %q", written.Results[1].Error)
+	}
+	if written.Results[2].Status != StatusSkipped || written.Results[2].SkippedBecause != "customers" {
+		t.Errorf("report result = %+v", written.Results[2])
+	}
+	if written.Results[3].UniqueID != "test.shop.not_null_orders_id" {
+		t.Errorf("test unique_id = %s", written.Results[3].UniqueID)
+	}
+}
+
+func TestReadRunResults(t *testing.T) {
+	start := time.Now()
+	result := NewExecutionResult()
+	result.AddModelResult(ModelResult{ModelID: "orders", Status: StatusSuccess, StartTime: start, EndTime: start})
+	result.AddModelResult(ModelResult{ModelID: "customers", Status: StatusFailed, StartTime: start, EndTime: start, Error: "boom"})
+	result.AddModelResult(NewSkippedResult("report", "customers"))
+	day1 := materialization.Batch{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}
+	day2 := materialization.Batch{Start: day1.End, End: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)}
+	result.AddModelResult(ModelResult{ModelID: "trips", Status: StatusFailed, StartTime: start, EndTime: start, Batches: []BatchResult{
+		{Batch: day1, Status: StatusSuccess, RowsAffected: 3},
+		{Batch: day2, Status: StatusFailed, Error: "no such table"},
+	}})
+	result.Complete()
+
+	path := filepath.Join(t.TempDir(), "run_results.json")
+	written := &RunResults{
+		InvocationID: "abc-123",
+		Command:      "build",
+		Args:         []string{"--target", "prod"},
+		ProjectName:  "shop",
+		Result:       result,
+		Nodes: []NodeResult{
+			{ResourceType: ResourceTypeTest, Name: "unique_orders_id", Status: StatusFailed, StartTime: start, EndTime: start},
+			{ResourceType: ResourceTypeTest, Name: "not_null_orders_id", Status: StatusSuccess, StartTime: start, EndTime: start},
+		},
+	}
+	if err := written.Write(path); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	read, err := ReadRunResults(path)
+	if err != nil {
+		t.Fatalf("ReadRunResults() error = %v", err)
+	}
+
+	if read.Command != "build" || !reflect.DeepEqual(read.Args, written.Args) || read.ProjectName != "shop" {
+		t.Errorf("read metadata = %s %v %s", read.Command, read.Args, read.ProjectName)
+	}
+	if read.Status() != StatusFailed {
+		t.Errorf("Status() = %s, want failed", read.Status())
+	}
+	if got, want := read.Unfinished(ResourceTypeModel), []string{"customers", "report", "trips"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unfinished(model) = %v, want %v", got, want)
+	}
+	if got, want := read.Result.ModelResults[3].FailedBatches(), []materialization.Batch{day2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FailedBatches() = %v, want %v", got, want)
+	}
+	if got, want := read.Unfinished(ResourceTypeTest), []string{"unique_orders_id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unfinished(test) = %v, want %v", got, want)
+	}
+	if got := read.Unfinished(ResourceTypeSeed); len(got) != 0 {
+		t.Errorf("Unfinished(seed) = %v, want none", got)
+	}
+
+	if _, err := ReadRunResults(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("ReadRunResults() of a missing file succeeded, want error")
+	}
+}
+
+func TestWriteSQLArtifacts(t *testing.T) {
+	dir := t.TempDir()
+
+	orders, _ := NewModel("orders", "models/marts/orders.sql")
+	orders.FQN = []string{"shop", "marts", "orders"}
+	orders.SetCompiledSQL("SELECT 1")
+
+	customers, _ := NewModel("customers", "models/customers.sql")
+	customers.FQN = []string{"shop", "customers"}
+	customers.SetCompiledSQL("SELECT 2")
+
+	models := []*Model{orders, customers}
+
+	if err := WriteCompiledSQL(filepath.Join(dir, "compiled"), models); err != nil {
+		t.Fatalf("WriteCompiledSQL() error = %v", err)
+	}
+
+	result := NewExecutionResult()
+	result.AddModelResult(ModelResult{
+		ModelID:       "orders",
+		Status:        StatusSuccess,
+		SQLStatements: []string{"DROP TABLE IF EXISTS orders", "CREATE TABLE orders AS SELECT 1"},
+	})
+	result.AddModelResult(NewSkippedResult("customers", "orders"))
+
+	if err := WriteRunSQL(filepath.Join(dir, "run"), models, result); err != nil {
+		t.Fatalf("WriteRunSQL() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(dir, "compiled", "shop", "marts", "orders.sql"), "SELECT 1"},
+		{filepath.Join(dir, "compiled", "shop", "customers.sql"), "SELECT 2"},
+		{filepath.Join(dir, "run", "shop", "marts", "orders.sql"), "DROP TABLE IF EXISTS orders;\n\nCREATE TABLE orders AS SELECT 1;\n"},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Errorf("artifact %s not written: %v", tt.path, err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.path, data, tt.want)
+		}
+	}
+
+	// Skipped models executed nothing, so they have no run artifact
+	if _, err := os.Stat(filepath.Join(dir, "run", "shop", "customers.sql")); !os.IsNotExist(err) {
+		t.Errorf("expected no run artifact for skipped model, stat error = %v", err)
+	}
+}
@@ -16,6 +16,30 @@ import (
 type Engine struct {
 	adapter        platform.DatabaseAdapter
 	templateEngine *template.Engine
+	threads        int
+	// relations maps model IDs to the relation names they are built into
+	relations map[string]string
+	// vars are the project variables available to var() when rendering
+	vars map[string]interface{}
+	// seeds and sources resolve seed() and source() when rendering
+	seeds   map[string]string
+	sources map[string]map[string]string
+	// eventTimes and sourceEventTimes filter ref() and source() to the
+	// current batch of microbatch models
+	eventTimes       map[string]string
+	sourceEventTimes map[string]map[string]string
+	// eventTimeStart, eventTimeEnd and batches override the batches built
+	// by microbatch models
+	eventTimeStart time.Time
+	eventTimeEnd   time.Time
+	batches        map[string][]materialization.Batch
+	// atomic runs all models of ExecuteModels in one transaction and decides
+	// what is kept when models fail
+	atomic AtomicPolicy
+	// materializations holds the strategies models are materialized with
+	materializations *materialization.Registry
+	// target is the profile output returned by {{ target }}
+	target template.Target
 }
 
 // NewEngine creates a new execution engine
@@ -28,67 +52,125 @@ func NewEngine(adapter platform.DatabaseAdapter, templateEngine *template.Engine
 	}
 
 	return &Engine{
-		adapter:        adapter,
-		templateEngine: templateEngine,
+		adapter:          adapter,
+		templateEngine:   templateEngine,
+		threads:          1,
+		relations:        make(map[string]string),
+		vars:             make(map[string]interface{}),
+		seeds:            make(map[string]string),
+		sources:          make(map[string]map[string]string),
+		eventTimes:       make(map[string]string),
+		sourceEventTimes: make(map[string]map[string]string),
+		materializations: materialization.NewDefaultRegistry(),
 	}, nil
 }
 
-// ExecuteModel executes a single model
+// SetThreads sets the maximum number of models executed concurrently.
+// Values below 1 are treated as 1 (sequential execution).
+func (e *Engine) SetThreads(threads int) {
+	if threads < 1 {
+		threads = 1
+	}
+	e.threads = threads
+}
+
+// Threads returns the maximum number of models executed concurrently.
+// Atomic runs execute one model at a time in their transaction.
+func (e *Engine) Threads() int {
+	if e.atomic != AtomicOff {
+		return 1
+	}
+	return e.threads
+}
+
+// SetRelations registers the relation name (alias) of models that ref() may
+// point to but that are not part of the current run
+func (e *Engine) SetRelations(relations map[string]string) {
+	for id, relation := range relations {
+		e.relations[id] = relation
+	}
+}
+
+// SetVars sets the variables available to var() when models and hooks are rendered
+func (e *Engine) SetVars(vars map[string]interface{}) {
+	e.vars = vars
+}
+
+// SetSeeds sets the seed tables available to seed() when models are rendered
+func (e *Engine) SetSeeds(seeds map[string]string) {
+	e.seeds = seeds
+}
+
+// SetSources sets the source tables available to source() when models are rendered
+func (e *Engine) SetSources(sources map[string]map[string]string) {
+	e.sources = sources
+}
+
+// SetTarget sets the profile output returned by {{ target }} when models and
+// hooks are rendered
+func (e *Engine) SetTarget(target template.Target) {
+	e.target = target
+}
+
+// SetMaterializations sets the strategies models are materialized with,
+// replacing the built-in ones
+func (e *Engine) SetMaterializations(registry *materialization.Registry) {
+	e.materializations = registry
+}
+
+// ExecuteModel executes a single model.
+// Pre-hooks run before and post-hooks after the materialization statements.
+// Models configured with atomic, and every model of an atomic run, execute in
+// a transaction so that a model that fails leaves no change behind.
 func (e *Engine) ExecuteModel(ctx context.Context, model *Model) (ModelResult, error) {
+	if model.MaterializationConfig.Atomic || transactionFrom(ctx) != nil {
+		return e.executeAtomically(ctx, model)
+	}
+	return e.executeModel(ctx, model)
+}
+
+// executeModel executes a single model in the transaction of ctx, if any
+func (e *Engine) executeModel(ctx context.Context, model *Model) (ModelResult, error) {
 	result := ModelResult{
 		ModelID:   model.ID,
 		Status:    StatusRunning,
 		StartTime: time.Now(),
 	}
 
-	// If TemplateContent is set, render it with the correct incremental context
-	if model.TemplateContent != "" {
-		// Determine if this is an incremental run
-		// First check if the table actually exists - if not, treat as full refresh (first run)
-		tableExists := false
-		if model.MaterializationConfig.Type == materialization.MaterializationIncremental {
-			exists, err := e.adapter.TableExists(ctx, model.ID)
-			if err != nil {
-				// If we can't check table existence, log but continue (assume doesn't exist)
-				tableExists = false
-			} else {
-				tableExists = exists
-			}
-		}
-
-		isIncremental := model.MaterializationConfig.Type == materialization.MaterializationIncremental &&
-			!model.MaterializationConfig.FullRefresh &&
-			tableExists
-
-		// Build template context with incremental settings
-		tmplCtx := template.NewContext(
-			template.WithCurrentModel(model.ID),
-			template.WithIsIncremental(isIncremental),
-			template.WithCurrentModelTable(model.ID),
-		)
-
-		// Parse the template
-		tmpl, err := e.templateEngine.Parse(model.ID, model.TemplateContent)
+	// Strategies that implement Builder, like custom materializations, get
+	// the existing relation and build on it as incremental models do
+	builder, _ := e.strategy(model.MaterializationConfig).(materialization.Builder)
+
+	// Determine if this is an incremental run
+	// First check if the table actually exists - if not, treat as full refresh (first run)
+	// Snapshots also compare the columns of their query with their existing table
+	relation := model.Relation()
+	tableExists := false
+	if model.MaterializationConfig.Type == materialization.MaterializationIncremental || model.IsSnapshot() || builder != nil {
+		exists, err := e.schemaReader(ctx).TableExists(ctx, relation)
 		if err != nil {
-			result.Status = StatusFailed
-			result.Error = fmt.Sprintf("failed to parse template: %v", err)
-			result.EndTime = time.Now()
-			return result, fmt.Errorf("failed to parse template for model %s: %w", model.ID, err)
+			// If we can't check table existence, log but continue (assume doesn't exist)
+			tableExists = false
+		} else {
+			tableExists = exists
 		}
+	}
 
-		// Render the template with the incremental context
-		rendered, err := template.Render(tmpl, tmplCtx, nil)
-		if err != nil {
-			result.Status = StatusFailed
-			result.Error = fmt.Sprintf("failed to render template: %v", err)
-			result.EndTime = time.Now()
-			return result, fmt.Errorf("failed to render template for model %s: %w", model.ID, err)
-		}
+	isIncremental := (model.MaterializationConfig.Type == materialization.MaterializationIncremental || builder != nil) &&
+		!model.MaterializationConfig.FullRefresh &&
+		tableExists
 
-		// Update the compiled SQL with the newly rendered version
-		model.SetCompiledSQL(rendered)
+	// Build template context with incremental settings
+	// The same context is used for the model body and its hooks
+	tmplCtx := e.templateContext(ctx, model, relation, isIncremental)
+
+	// If TemplateContent is set, render it with the correct incremental context
+	if err := e.renderModel(model, tmplCtx); err != nil {
+		return result, result.fail(err)
 	}
 
+	result.CompiledSQL = model.CompiledSQL
+
 	// Validate model has compiled SQL
 	if model.CompiledSQL == "" {
 		result.Status = StatusFailed
@@ -97,82 +179,454 @@ func (e *Engine) ExecuteModel(ctx context.Context, model *Model) (ModelResult, e
 		return result, fmt.Errorf("model %s has no compiled SQL", model.ID)
 	}
 
+	// Render hooks before running anything so template errors fail early
+	preHooks, err := e.renderHooks(model.ID+".pre_hook", model.MaterializationConfig.PreHooks, tmplCtx)
+	if err != nil {
+		return result, result.fail(fmt.Errorf("failed to render pre-hook: %w", err))
+	}
+
+	postHooks, err := e.renderHooks(model.ID+".post_hook", model.MaterializationConfig.PostHooks, tmplCtx)
+	if err != nil {
+		return result, result.fail(fmt.Errorf("failed to render post-hook: %w", err))
+	}
+
+	// Microbatch models build each batch of event time independently
+	if isMicrobatch(model.MaterializationConfig) && !isRawDDL(model.CompiledSQL) {
+		return e.executeMicrobatch(ctx, model, relation, tableExists, preHooks, postHooks, result)
+	}
+
+	var sqlStatements []string
+	if builder != nil && !isRawDDL(model.CompiledSQL) {
+		sqlStatements, err = e.buildStatements(ctx, builder, model, relation, tableExists, isIncremental, tmplCtx)
+	} else {
+		sqlStatements, err = e.materializationStatements(ctx, relation, model.CompiledSQL, tableExists, model.MaterializationConfig)
+	}
+	if err != nil {
+		return result, result.fail(err)
+	}
+
+	// Execute pre-hooks, materialization statements and post-hooks in order
+	phases := []struct {
+		name       string
+		statements []string
+	}{
+		{"pre-hook", preHooks},
+		{"SQL", sqlStatements},
+		{"post-hook", postHooks},
+	}
+
+	for _, phase := range phases {
+		rows, err := e.executeStatements(ctx, phase.statements, result.recordStatement)
+		result.RowsAffected += rows
+		if err != nil {
+			if phase.name == "SQL" && materialization.Swaps(phase.statements, relation) {
+				e.cleanupSwap(ctx, relation)
+			}
+			return result, result.fail(fmt.Errorf("failed to execute %s: %w", phase.name, err))
+		}
+	}
+
+	// Mark as successful
+	result.Status = StatusSuccess
+	result.EndTime = time.Now()
+
+	return result, nil
+}
+
+// templateContext builds the context a model and its hooks are rendered with.
+// Its introspective functions query the database in ctx.
+func (e *Engine) templateContext(ctx context.Context, model *Model, relation string, isIncremental bool, opts ...template.ContextOption) *template.Context {
+	return template.NewContext(append([]template.ContextOption{
+		template.WithCurrentModel(model.ID),
+		template.WithIsIncremental(isIncremental),
+		template.WithCurrentModelTable(relation),
+		template.WithRelations(e.relations),
+		template.WithVars(e.vars),
+		template.WithSeeds(e.seeds),
+		template.WithSources(e.sources),
+		template.WithEventTimes(e.eventTimes, e.sourceEventTimes),
+		template.WithTarget(e.target),
+		template.WithDatabase(e.database(ctx)),
+	}, opts...)...)
+}
+
+// renderModel renders the model's template content with tmplCtx into its
+// compiled SQL. Models without template content keep their compiled SQL.
+func (e *Engine) renderModel(model *Model, tmplCtx *template.Context) error {
+	if model.TemplateContent == "" {
+		return nil
+	}
+
+	tmpl, err := e.templateEngine.Parse(model.ID, model.TemplateContent)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	rendered, err := template.Render(tmpl, tmplCtx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	// Update the compiled SQL with the newly rendered version, with the
+	// ephemeral models it depends on inlined
+	model.SetCompiledSQL(InjectCTEs(rendered, model.CTEs))
+	return nil
+}
+
+// materializationStatements returns the statements that build the model's
+// relation from its compiled SQL
+func (e *Engine) materializationStatements(ctx context.Context, relation, compiledSQL string, tableExists bool, matConfig materialization.MaterializationConfig) ([]string, error) {
 	// Check if this is raw DDL (CREATE TABLE, INSERT, UPDATE, DELETE, etc.)
 	// If so, execute directly without materialization strategy
-	// Strip SQL comments first to properly detect DDL statements
-	sqlWithoutComments := stripSQLComments(model.CompiledSQL)
-	trimmedSQL := strings.TrimSpace(strings.ToUpper(sqlWithoutComments))
-	isRawDDL := strings.HasPrefix(trimmedSQL, "CREATE ") ||
-		strings.HasPrefix(trimmedSQL, "INSERT ") ||
-		strings.HasPrefix(trimmedSQL, "UPDATE ") ||
-		strings.HasPrefix(trimmedSQL, "DELETE ") ||
-		strings.HasPrefix(trimmedSQL, "DROP ") ||
-		strings.HasPrefix(trimmedSQL, "ALTER ")
-
-	if isRawDDL {
-		// Execute raw DDL directly
+	if isRawDDL(compiledSQL) {
 		// Split by semicolons to handle multiple statements
-		statements := strings.Split(model.CompiledSQL, ";")
-		var sqlStatements []string
+		return splitStatements(compiledSQL), nil
+	}
 
-		for _, stmt := range statements {
-			stmt = strings.TrimSpace(stmt)
-			if stmt == "" {
-				continue
+	// Get materialization strategy
+	strategy, err := e.materializations.StrategyFromConfig(matConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get strategy: %w", err)
+	}
+
+	// Snapshots record when they were taken in valid_from and valid_to
+	if matConfig.Type == materialization.MaterializationSnapshot && matConfig.SnapshotTimestamp == "" {
+		matConfig.SnapshotTimestamp = time.Now().UTC().Format(materialization.SnapshotTimeFormat)
+	}
+
+	// The query of a model with an enforced contract must return the
+	// declared columns and types
+	if matConfig.Contract.Enforced {
+		if _, ok := strategy.(*materialization.TableStrategy); !ok {
+			return nil, fmt.Errorf("contracts are enforced on table models only, not %s", strategy.Name())
+		}
+		queryColumns, err := e.queryColumns(ctx, relation, compiledSQL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get columns: %w", err)
+		}
+		if err := matConfig.Contract.Check(queryColumns); err != nil {
+			return nil, fmt.Errorf("contract of %s is not satisfied: %w", relation, err)
+		}
+	}
+
+	// Incremental models and snapshots insert into explicit columns and
+	// reconcile the columns of their query with those of their existing table
+	var schemaChanges []string
+	if matConfig.NeedsColumns() {
+		schemaChanges, err = e.prepareColumns(ctx, relation, compiledSQL, tableExists, &matConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get columns: %w", err)
+		}
+	}
+
+	// Generate SQL statements
+	matConfig.ExistingRelation = e.relationType(ctx, relation)
+	sqlStatements, err := strategy.Materialize(relation, compiledSQL, matConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate SQL: %w", err)
+	}
+	sqlStatements = append(schemaChanges, sqlStatements...)
+
+	// Indexes can only be created on tables, not views or ephemeral models
+	if matConfig.Type != materialization.MaterializationView && matConfig.Type != materialization.MaterializationEphemeral {
+		sqlStatements = append(sqlStatements, materialization.IndexStatements(relation, matConfig.Indexes)...)
+	}
+
+	return sqlStatements, nil
+}
+
+// relationType returns the kind of relation the database holds under the
+// relation's name: materialization.RelationTable, materialization.RelationView,
+// or an empty string when it holds none or the adapter cannot tell
+func (e *Engine) relationType(ctx context.Context, relation string) string {
+	reader, ok := e.schemaReader(ctx).(platform.RelationReader)
+	if !ok {
+		return ""
+	}
+	relType, err := reader.RelationType(ctx, relation)
+	if err != nil {
+		return ""
+	}
+	return relType
+}
+
+// cleanupSwap removes what a failed build-then-swap of the relation leaves
+// behind. Errors are ignored, as the failure itself is reported.
+func (e *Engine) cleanupSwap(ctx context.Context, relation string) {
+	for _, sql := range materialization.SwapCleanupStatements(relation) {
+		_, _ = e.executeStatement(ctx, sql)
+	}
+}
+
+// strategy returns the strategy of a model's materialization config, or nil
+// when there is none
+func (e *Engine) strategy(matConfig materialization.MaterializationConfig) materialization.Strategy {
+	strategy, err := e.materializations.StrategyFromConfig(matConfig)
+	if err != nil {
+		return nil
+	}
+	return strategy
+}
+
+// buildStatements returns the statements a Builder builds the model's
+// relation with, given the relation already in the database
+func (e *Engine) buildStatements(ctx context.Context, builder materialization.Builder, model *Model, relation string, tableExists, isIncremental bool, tmplCtx *template.Context) ([]string, error) {
+	input := materialization.Input{
+		Relation:      relation,
+		CompiledSQL:   model.CompiledSQL,
+		Config:        model.MaterializationConfig,
+		ModelConfig:   model.Config,
+		Adapter:       e.adapter,
+		IsIncremental: isIncremental,
+		Template:      tmplCtx,
+	}
+
+	if tableExists {
+		schema, err := e.schemaReader(ctx).GetTableSchema(ctx, relation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get columns of %s: %w", relation, err)
+		}
+		input.Existing = &materialization.Relation{Name: relation, Columns: schemaColumns(schema)}
+	}
+
+	statements, err := builder.Build(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate SQL: %w", err)
+	}
+
+	return append(statements, materialization.IndexStatements(relation, model.MaterializationConfig.Indexes)...), nil
+}
+
+// executeStatements executes statements in order, passing each to record once
+// it executed, and returns the number of rows they affected. Queries return
+// their rows. Statements between materialization.BeginTransaction and
+// materialization.CommitTransaction run in one database transaction; they
+// are recorded, with BEGIN and COMMIT, once it committed.
+func (e *Engine) executeStatements(ctx context.Context, statements []string, record func(StatementResult)) (int64, error) {
+	var total int64
+	for i := 0; i < len(statements); i++ {
+		sql := statements[i]
+		if sql == materialization.BeginTransaction {
+			end := i + 1
+			for end < len(statements) && statements[end] != materialization.CommitTransaction {
+				end++
+			}
+			if err := e.executeTransaction(ctx, statements[i+1:end]); err != nil {
+				return total, err
 			}
-			if err := e.adapter.ExecuteDDL(ctx, stmt); err != nil {
-				result.Status = StatusFailed
-				result.Error = fmt.Sprintf("failed to execute SQL: %v", err)
-				result.EndTime = time.Now()
-				return result, fmt.Errorf("failed to execute SQL for model %s: %w", model.ID, err)
+			if end == len(statements) {
+				end--
 			}
-			sqlStatements = append(sqlStatements, stmt)
+			for _, sql := range statements[i : end+1] {
+				record(StatementResult{SQL: sql})
+			}
+			i = end
+			continue
 		}
 
-		result.Status = StatusSuccess
-		result.EndTime = time.Now()
-		result.SQLStatements = sqlStatements
-		return result, nil
+		statement := StatementResult{SQL: sql}
+		var err error
+		if isQuery(sql) {
+			statement.Rows, err = e.query(ctx, sql)
+		} else {
+			statement.RowsAffected, err = e.executeStatement(ctx, sql)
+		}
+		if err != nil {
+			return total, err
+		}
+		record(statement)
+		total += statement.RowsAffected
 	}
+	return total, nil
+}
 
-	// Get materialization strategy
-	strategy, err := materialization.GetStrategyFromConfig(model.MaterializationConfig)
+// executeTransaction executes statements in one database transaction, which
+// is rolled back when any of them fails. Within a transaction already, the
+// statements execute in a savepoint of it.
+func (e *Engine) executeTransaction(ctx context.Context, statements []string) error {
+	if tx := transactionFrom(ctx); tx != nil {
+		return inSavepoint(ctx, tx, "gorchata_swap", func() error {
+			for _, sql := range statements {
+				if err := tx.Exec(ctx, sql); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	tx, err := e.adapter.BeginTransaction(ctx)
 	if err != nil {
-		result.Status = StatusFailed
-		result.Error = fmt.Sprintf("failed to get strategy: %v", err)
-		result.EndTime = time.Now()
-		return result, fmt.Errorf("failed to get strategy for model %s: %w", model.ID, err)
+		return err
+	}
+	for _, sql := range statements {
+		if err := tx.Exec(ctx, sql); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				return fmt.Errorf("%w (%v)", err, rollbackErr)
+			}
+			return err
+		}
 	}
+	return tx.Commit()
+}
 
-	// Generate SQL statements
-	sqlStatements, err := strategy.Materialize(model.ID, model.CompiledSQL, model.MaterializationConfig)
+// prepareColumns fills in the columns of the model's query and, when its
+// table exists, applies the on_schema_change policy: it returns the ALTER
+// TABLE statements to run before the model and sets the columns the table
+// will have.
+func (e *Engine) prepareColumns(ctx context.Context, relation, selectSQL string, tableExists bool, config *materialization.MaterializationConfig) ([]string, error) {
+	queryColumns, err := e.queryColumns(ctx, relation, selectSQL)
 	if err != nil {
-		result.Status = StatusFailed
-		result.Error = fmt.Sprintf("failed to generate SQL: %v", err)
-		result.EndTime = time.Now()
-		return result, fmt.Errorf("failed to generate SQL for model %s: %w", model.ID, err)
+		return nil, err
+	}
+	config.Columns = columnNames(queryColumns)
+	if !tableExists || len(queryColumns) == 0 {
+		return nil, nil
+	}
+
+	schema, err := e.schemaReader(ctx).GetTableSchema(ctx, relation)
+	if err != nil {
+		return nil, err
+	}
+	statements, columns, err := materialization.SchemaChangeStatements(relation, schemaColumns(schema), queryColumns, config.SchemaChangePolicy())
+	if err != nil {
+		return nil, err
+	}
+	config.TargetColumns = columnNames(columns)
+	return statements, nil
+}
+
+// queryColumns returns the columns of a SELECT query and their types. The
+// query is materialized without rows into a temporary table whose schema is
+// read back, so that the types are those a table created from it would have.
+func (e *Engine) queryColumns(ctx context.Context, relation, selectSQL string) ([]materialization.Column, error) {
+	query := strings.TrimRight(strings.TrimSpace(selectSQL), ";")
+	probe := relation[strings.LastIndex(relation, ".")+1:] + "__schema"
+
+	if _, err := e.executeStatement(ctx, fmt.Sprintf("CREATE TEMP TABLE %s AS SELECT * FROM (%s) LIMIT 0", probe, query)); err != nil {
+		return nil, err
+	}
+	schema, err := e.schemaReader(ctx).GetTableSchema(ctx, probe)
+	if _, dropErr := e.executeStatement(ctx, fmt.Sprintf("DROP TABLE %s", probe)); err == nil {
+		err = dropErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return schemaColumns(schema), nil
+}
+
+// schemaColumns returns the columns of a table schema
+func schemaColumns(schema *platform.Schema) []materialization.Column {
+	columns := make([]materialization.Column, 0, len(schema.Columns))
+	for _, column := range schema.Columns {
+		columns = append(columns, materialization.Column{Name: column.Name, Type: column.Type, Referenced: column.Referenced})
+	}
+	return columns
+}
+
+// columnNames returns the names of columns
+func columnNames(columns []materialization.Column) []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.Name)
 	}
+	return names
+}
 
-	result.SQLStatements = sqlStatements
+// executeStatement executes a statement, in the transaction of ctx if any,
+// and returns the number of rows it affected, when the adapter reports it
+func (e *Engine) executeStatement(ctx context.Context, sql string) (int64, error) {
+	if tx := transactionFrom(ctx); tx != nil {
+		if executor, ok := tx.(platform.StatementExecutor); ok {
+			return executor.ExecuteStatement(ctx, sql)
+		}
+		return 0, tx.Exec(ctx, sql)
+	}
+	if executor, ok := e.adapter.(platform.StatementExecutor); ok {
+		return executor.ExecuteStatement(ctx, sql)
+	}
+	return 0, e.adapter.ExecuteDDL(ctx, sql)
+}
+
+// query executes a query in the transaction of ctx if any, and returns its rows
+func (e *Engine) query(ctx context.Context, sql string, args ...interface{}) (*platform.QueryResult, error) {
+	if tx := transactionFrom(ctx); tx != nil {
+		return tx.Query(ctx, sql, args...)
+	}
+	return e.adapter.ExecuteQuery(ctx, sql, args...)
+}
+
+// database returns the database the introspective functions of templates
+// query. It goes through the transaction of ctx, which is the only one that
+// sees its uncommitted changes and, on SQLite, holds the only connection.
+func (e *Engine) database(ctx context.Context) *template.Database {
+	return template.NewDatabase(ctx, e.query, e.schemaReader(ctx))
+}
+
+// schemaReader returns what reads the tables of the database in ctx: its
+// transaction when it can, since it sees the tables changed within it, and
+// the adapter otherwise
+func (e *Engine) schemaReader(ctx context.Context) platform.SchemaReader {
+	if reader, ok := transactionFrom(ctx).(platform.SchemaReader); ok {
+		return reader
+	}
+	return e.adapter
+}
+
+// ExecuteHooks renders and executes a list of hooks outside of any model,
+// such as the project's on-run-start and on-run-end hooks.
+// Returns the SQL statements that were executed.
+func (e *Engine) ExecuteHooks(ctx context.Context, name string, hooks []string, tmplCtx *template.Context) ([]string, error) {
+	if tmplCtx != nil && tmplCtx.Database == nil {
+		withDatabase := *tmplCtx
+		withDatabase.Database = e.database(ctx)
+		tmplCtx = &withDatabase
+	}
+
+	statements, err := e.renderHooks(name, hooks, tmplCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
 
-	// Execute SQL statements
-	for _, sql := range sqlStatements {
+	executed := make([]string, 0, len(statements))
+	for _, sql := range statements {
 		if err := e.adapter.ExecuteDDL(ctx, sql); err != nil {
-			result.Status = StatusFailed
-			result.Error = fmt.Sprintf("failed to execute SQL: %v", err)
-			result.EndTime = time.Now()
-			return result, fmt.Errorf("failed to execute SQL for model %s: %w", model.ID, err)
+			return executed, fmt.Errorf("failed to execute %s: %w", name, err)
 		}
+		executed = append(executed, sql)
 	}
 
-	// Mark as successful
-	result.Status = StatusSuccess
-	result.EndTime = time.Now()
+	return executed, nil
+}
 
-	return result, nil
+// renderHooks renders each hook as a template and splits the result into
+// individual SQL statements
+func (e *Engine) renderHooks(name string, hooks []string, tmplCtx *template.Context) ([]string, error) {
+	var statements []string
+	for i, hook := range hooks {
+		hookName := fmt.Sprintf("%s[%d]", name, i)
+
+		tmpl, err := e.templateEngine.Parse(hookName, hook)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", hookName, err)
+		}
+
+		rendered, err := template.Render(tmpl, tmplCtx, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, splitStatements(rendered)...)
+	}
+	return statements, nil
 }
 
-// ExecuteModels executes multiple models in topological order based on dependencies
+// ExecuteModels executes multiple models in dependency order.
+// Independent models run concurrently up to the engine's thread limit;
+// results are always reported in topological order. Atomic runs execute the
+// models in one transaction, committed or rolled back once they all ran.
 func (e *Engine) ExecuteModels(ctx context.Context, models []*Model, failFast bool) (*ExecutionResult, error) {
 	result := NewExecutionResult()
 	result.Status = StatusRunning
@@ -192,9 +646,14 @@ func (e *Engine) ExecuteModels(ctx context.Context, models []*Model, failFast bo
 		}
 	}
 
-	// Add edges for dependencies
+	// Add edges for dependencies, through the ephemeral models a model
+	// inlines. Dependencies that are not part of this run (e.g. not
+	// selected) are assumed to have been built already.
 	for _, model := range models {
-		for _, dep := range model.Dependencies {
+		for _, dep := range model.ScheduleDependencies() {
+			if _, ok := graph.GetNode(dep); !ok {
+				continue
+			}
 			if err := graph.AddEdge(model.ID, dep); err != nil {
 				result.Complete()
 				return result, fmt.Errorf("failed to add edge from %s to %s: %w", model.ID, dep, err)
@@ -209,32 +668,46 @@ func (e *Engine) ExecuteModels(ctx context.Context, models []*Model, failFast bo
 		return result, fmt.Errorf("failed to sort DAG: %w", err)
 	}
 
-	// Create a map for quick model lookup
+	// Create a map for quick model lookup and register each model's relation
+	// before anything runs, so ref() resolves aliases consistently
 	modelMap := make(map[string]*Model)
 	for _, model := range models {
 		modelMap[model.ID] = model
+		if !model.IsEphemeral() {
+			e.relations[model.ID] = model.Relation()
+		}
 	}
 
-	// Execute models in topological order
-	for _, node := range sortedNodes {
-		model, exists := modelMap[node.ID]
-		if !exists {
-			// This shouldn't happen, but handle it gracefully
-			continue
+	// Atomic runs execute every model in one transaction
+	var tx platform.Transaction
+	if e.atomic != AtomicOff {
+		tx, err = e.adapter.BeginTransaction(ctx)
+		if err != nil {
+			result.Complete()
+			return result, fmt.Errorf("failed to begin run transaction: %w", err)
 		}
+		ctx = withTransaction(ctx, tx)
+	}
 
-		modelResult, err := e.ExecuteModel(ctx, model)
+	// Execute models as soon as their dependencies have completed,
+	// bounded by the configured number of threads
+	modelResults, execErr := newScheduler(e, graph, sortedNodes, modelMap, failFast).run(ctx)
+	for _, modelResult := range modelResults {
 		result.AddModelResult(modelResult)
+	}
 
-		if err != nil {
-			if failFast {
-				result.Complete()
-				return result, fmt.Errorf("execution failed at model %s: %w", model.ID, err)
-			}
-			// Continue to next model if not fail-fast
+	if tx != nil {
+		if err := e.finishAtomicRun(tx, result, execErr != nil || result.FailureCount() > 0); err != nil {
+			result.Complete()
+			return result, err
 		}
 	}
 
+	if execErr != nil {
+		result.Complete()
+		return result, execErr
+	}
+
 	result.Complete()
 
 	// Return error if there were any failures (but only if fail-fast is disabled)
@@ -245,6 +718,73 @@ func (e *Engine) ExecuteModels(ctx context.Context, models []*Model, failFast bo
 	return result, nil
 }
 
+// isRawDDL reports whether the SQL is a DDL/DML script (CREATE TABLE, INSERT, ...)
+// rather than a SELECT that should be materialized by a strategy.
+// SQL comments are stripped first to properly detect DDL statements.
+func isRawDDL(sql string) bool {
+	trimmedSQL := strings.TrimSpace(strings.ToUpper(stripSQLComments(sql)))
+	return strings.HasPrefix(trimmedSQL, "CREATE ") ||
+		strings.HasPrefix(trimmedSQL, "INSERT ") ||
+		strings.HasPrefix(trimmedSQL, "UPDATE ") ||
+		strings.HasPrefix(trimmedSQL, "DELETE ") ||
+		strings.HasPrefix(trimmedSQL, "DROP ") ||
+		strings.HasPrefix(trimmedSQL, "ALTER ")
+}
+
+// splitStatements splits a SQL script into individual statements on semicolons,
+// ignoring semicolons inside string literals and comments. Empty statements are dropped.
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	inStringLiteral := false
+	inSingleLineComment := false
+	inMultiLineComment := false
+
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		if stmt != "" && strings.TrimSpace(stripSQLComments(stmt)) != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+
+		switch {
+		case inSingleLineComment:
+			if ch == '\n' {
+				inSingleLineComment = false
+			}
+		case inMultiLineComment:
+			if ch == '*' && i+1 < len(sql) && sql[i+1] == '/' {
+				inMultiLineComment = false
+				current.WriteByte(ch)
+				i++
+				ch = sql[i]
+			}
+		case inStringLiteral:
+			if ch == '\'' {
+				inStringLiteral = false
+			}
+		case ch == '\'':
+			inStringLiteral = true
+		case ch == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			inSingleLineComment = true
+		case ch == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			inMultiLineComment = true
+		case ch == ';':
+			flush()
+			continue
+		}
+
+		current.WriteByte(ch)
+	}
+	flush()
+
+	return statements
+}
+
 // stripSQLComments removes SQL comments from a string
 // Handles both single-line (--) and multi-line (/* */) comments
 func stripSQLComments(sql string) string {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
 	"github.com/jpconstantineau/gorchata/internal/template"
@@ -355,3 +356,79 @@ func TestFullRefreshOverride(t *testing.T) {
 		t.Errorf("expected full refresh to use DROP+CREATE strategy, but found: %v", result2.SQLStatements)
 	}
 }
+
+// TestEngineMicrobatches verifies the batches a microbatch model builds
+func TestEngineMicrobatches(t *testing.T) {
+	today := materialization.TruncateToBatch(materialization.BatchSizeDay, time.Now())
+	day := func(offset int) time.Time { return today.AddDate(0, 0, offset) }
+
+	newModel := func() *Model {
+		model, _ := NewModel("trips", "models/trips.sql")
+		model.MaterializationConfig = materialization.MaterializationConfig{
+			Type:                materialization.MaterializationIncremental,
+			IncrementalStrategy: materialization.IncrementalMicrobatch,
+			EventTime:           "departed_at",
+			BatchSize:           materialization.BatchSizeDay,
+			Begin:               day(-3).Format("2006-01-02"),
+			Lookback:            1,
+		}
+		return model
+	}
+
+	tests := []struct {
+		name        string
+		tableExists bool
+		fullRefresh bool
+		configure   func(*Engine)
+		wantStart   time.Time
+		wantEnd     time.Time
+		wantCount   int
+	}{
+		{name: "new table starts at begin", wantStart: day(-3), wantEnd: day(1), wantCount: 4},
+		{name: "existing table reprocesses the lookback", tableExists: true, wantStart: day(-1), wantEnd: day(1), wantCount: 2},
+		{name: "full refresh starts at begin", tableExists: true, fullRefresh: true, wantStart: day(-3), wantEnd: day(1), wantCount: 4},
+		{
+			name:        "event time range",
+			tableExists: true,
+			configure:   func(e *Engine) { e.SetEventTimeRange(day(-10), day(-8)) },
+			wantStart:   day(-10),
+			wantEnd:     day(-8),
+			wantCount:   2,
+		},
+		{
+			name:        "retried batches",
+			tableExists: true,
+			configure: func(e *Engine) {
+				e.SetBatches(map[string][]materialization.Batch{"trips": {{Start: day(-5), End: day(-4)}}})
+			},
+			wantStart: day(-5),
+			wantEnd:   day(-4),
+			wantCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewEngine(newMockAdapter(), template.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.configure != nil {
+				tt.configure(engine)
+			}
+			model := newModel()
+			model.MaterializationConfig.FullRefresh = tt.fullRefresh
+
+			batches, err := engine.microbatches(model, tt.tableExists)
+			if err != nil {
+				t.Fatalf("microbatches() error = %v", err)
+			}
+			if len(batches) != tt.wantCount {
+				t.Fatalf("got %d batches, want %d: %v", len(batches), tt.wantCount, batches)
+			}
+			if !batches[0].Start.Equal(tt.wantStart) || !batches[len(batches)-1].End.Equal(tt.wantEnd) {
+				t.Errorf("batches cover %s to %s, want %s to %s", batches[0].Start, batches[len(batches)-1].End, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package executor
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
@@ -19,6 +20,8 @@ type mockAdapter struct {
 	createViewErr  error
 	createTableErr error
 	executeDDLErr  error
+	failOn         string
+	transactions   []*mockTransaction
 }
 
 func newMockAdapter() *mockAdapter {
@@ -47,6 +50,9 @@ func (m *mockAdapter) ExecuteDDL(ctx context.Context, sql string) error {
 	if m.executeDDLErr != nil {
 		return m.executeDDLErr
 	}
+	if m.failOn != "" && strings.Contains(sql, m.failOn) {
+		return fmt.Errorf("database error")
+	}
 	m.executedSQL = append(m.executedSQL, sql)
 	return nil
 }
@@ -77,13 +83,18 @@ func (m *mockAdapter) CreateView(ctx context.Context, view, selectSQL string) er
 }
 
 func (m *mockAdapter) BeginTransaction(ctx context.Context) (platform.Transaction, error) {
-	return &mockTransaction{adapter: m}, nil
+	tx := &mockTransaction{exec: m.ExecuteDDL}
+	m.transactions = append(m.transactions, tx)
+	return tx, nil
 }
 
+// mockTransaction executes its statements with exec, the ExecuteDDL method of
+// the adapter that started it, and records its savepoint operations
 type mockTransaction struct {
-	adapter    *mockAdapter
+	exec       func(ctx context.Context, sql string) error
 	committed  bool
 	rolledBack bool
+	savepoints []string
 }
 
 func (t *mockTransaction) Commit() error {
@@ -97,7 +108,25 @@ func (t *mockTransaction) Rollback() error {
 }
 
 func (t *mockTransaction) Exec(ctx context.Context, sql string, args ...interface{}) error {
-	t.adapter.executedSQL = append(t.adapter.executedSQL, sql)
+	return t.exec(ctx, sql)
+}
+
+func (t *mockTransaction) Query(ctx context.Context, sql string, args ...interface{}) (*platform.QueryResult, error) {
+	return &platform.QueryResult{}, t.exec(ctx, sql)
+}
+
+func (t *mockTransaction) Savepoint(ctx context.Context, name string) error {
+	t.savepoints = append(t.savepoints, "SAVEPOINT "+name)
+	return nil
+}
+
+func (t *mockTransaction) RollbackToSavepoint(ctx context.Context, name string) error {
+	t.savepoints = append(t.savepoints, "ROLLBACK TO "+name)
+	return nil
+}
+
+func (t *mockTransaction) ReleaseSavepoint(ctx context.Context, name string) error {
+	t.savepoints = append(t.savepoints, "RELEASE "+name)
 	return nil
 }
 
@@ -165,6 +194,15 @@ func TestEngine_ExecuteModel_View(t *testing.T) {
 	if len(adapter.executedSQL) == 0 {
 		t.Error("expected SQL to be executed")
 	}
+
+	// The view is replaced in one transaction
+	if len(adapter.transactions) != 1 || !adapter.transactions[0].committed {
+		t.Errorf("expected one committed transaction, got %+v", adapter.transactions)
+	}
+	want := []string{"BEGIN", "DROP VIEW IF EXISTS test_view", "CREATE VIEW test_view AS SELECT 1 as id, 'test' as name", "COMMIT"}
+	if fmt.Sprint(result.SQLStatements) != fmt.Sprint(want) {
+		t.Errorf("SQLStatements = %q, want %q", result.SQLStatements, want)
+	}
 }
 
 func TestEngine_ExecuteModel_Table(t *testing.T) {
@@ -240,6 +278,11 @@ func TestEngine_ExecuteModel_DatabaseError(t *testing.T) {
 	if result.Error == "" {
 		t.Error("expected error message to be set")
 	}
+
+	// The failed transaction is rolled back, not committed
+	if len(adapter.transactions) != 1 || !adapter.transactions[0].rolledBack || adapter.transactions[0].committed {
+		t.Errorf("expected one rolled back transaction, got %+v", adapter.transactions)
+	}
 }
 
 func TestEngine_ExecuteModels_Sequential(t *testing.T) {
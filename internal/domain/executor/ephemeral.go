@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+)
+
+// CTE is a named common table expression that inlines an ephemeral model
+// into the models that depend on it
+type CTE struct {
+	// Name is the name ref() resolves the ephemeral model to
+	Name string
+
+	// SQL is the compiled SQL of the ephemeral model
+	SQL string
+
+	// Dependencies are the models the ephemeral model depends on
+	Dependencies []string
+}
+
+// EphemeralCTEName returns the name of the CTE an ephemeral model is inlined as
+func EphemeralCTEName(modelID string) string {
+	return "__cte__" + modelID
+}
+
+// IsEphemeral reports whether the model is ephemeral: it is never built in
+// the database, but inlined as a CTE into the models that ref() it
+func (m *Model) IsEphemeral() bool {
+	return m.MaterializationConfig.Type == materialization.MaterializationEphemeral
+}
+
+// ScheduleDependencies returns the models that must run before the model: its
+// own dependencies and those of the ephemeral models it inlines. Ephemeral
+// models are never executed, so a model waits for the models they read from.
+func (m *Model) ScheduleDependencies() []string {
+	dependencies := append([]string{}, m.Dependencies...)
+	for _, cte := range m.CTEs {
+		dependencies = append(dependencies, cte.Dependencies...)
+	}
+	return dependencies
+}
+
+// InjectCTEs adds ctes, in order, at the start of the WITH clause of a query,
+// adding a WITH clause when the query has none. Leading comments are kept
+// in front of the query.
+func InjectCTEs(sql string, ctes []CTE) string {
+	if len(ctes) == 0 {
+		return sql
+	}
+
+	definitions := make([]string, 0, len(ctes))
+	for _, cte := range ctes {
+		definitions = append(definitions, fmt.Sprintf("%s AS (\n%s\n)", cte.Name, cte.SQL))
+	}
+	list := strings.Join(definitions, ",\n")
+
+	start := leadingCommentsEnd(sql)
+	head, query := sql[:start], sql[start:]
+
+	if !startsWithKeyword(query, "WITH") {
+		return head + "WITH " + list + "\n" + query
+	}
+
+	rest := strings.TrimSpace(query[len("WITH"):])
+	with := "WITH "
+	if startsWithKeyword(rest, "RECURSIVE") {
+		with = "WITH RECURSIVE "
+		rest = strings.TrimSpace(rest[len("RECURSIVE"):])
+	}
+	return head + with + list + ",\n" + rest
+}
+
+// leadingCommentsEnd returns the index of the first character of sql that is
+// neither whitespace nor part of a comment
+func leadingCommentsEnd(sql string) int {
+	i := 0
+	for i < len(sql) {
+		switch {
+		case sql[i] == ' ' || sql[i] == '\t' || sql[i] == '\n' || sql[i] == '\r':
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return len(sql)
+			}
+			i += end + 1
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return len(sql)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// startsWithKeyword reports whether sql starts with the given keyword,
+// case-insensitively, followed by whitespace
+func startsWithKeyword(sql, keyword string) bool {
+	if len(sql) <= len(keyword) || !strings.EqualFold(sql[:len(keyword)], keyword) {
+		return false
+	}
+	next := sql[len(keyword)]
+	return next == ' ' || next == '\t' || next == '\n' || next == '\r'
+}
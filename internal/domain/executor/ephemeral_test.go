@@ -0,0 +1,62 @@
+package executor
+
+import "testing"
+
+func TestInjectCTEs(t *testing.T) {
+	ctes := []CTE{
+		{Name: "__cte__stg_orders", SQL: "SELECT * FROM raw_orders"},
+		{Name: "__cte__int_orders", SQL: "SELECT * FROM __cte__stg_orders"},
+	}
+	defs := "__cte__stg_orders AS (\nSELECT * FROM raw_orders\n),\n__cte__int_orders AS (\nSELECT * FROM __cte__stg_orders\n)"
+
+	tests := []struct {
+		name string
+		sql  string
+		ctes []CTE
+		want string
+	}{
+		{
+			name: "no CTEs",
+			sql:  "SELECT 1",
+			want: "SELECT 1",
+		},
+		{
+			name: "query without WITH",
+			sql:  "\nSELECT * FROM __cte__int_orders",
+			ctes: ctes,
+			want: "\nWITH " + defs + "\nSELECT * FROM __cte__int_orders",
+		},
+		{
+			name: "query with WITH",
+			sql:  "with totals AS (SELECT 1) SELECT * FROM totals",
+			ctes: ctes,
+			want: "WITH " + defs + ",\ntotals AS (SELECT 1) SELECT * FROM totals",
+		},
+		{
+			name: "recursive query",
+			sql:  "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 3) SELECT * FROM n",
+			ctes: ctes[:1],
+			want: "WITH RECURSIVE __cte__stg_orders AS (\nSELECT * FROM raw_orders\n),\nn(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 3) SELECT * FROM n",
+		},
+		{
+			name: "leading comments",
+			sql:  "-- orders\n/* with everything */\nWITH t AS (SELECT 1) SELECT * FROM t",
+			ctes: ctes[:1],
+			want: "-- orders\n/* with everything */\nWITH __cte__stg_orders AS (\nSELECT * FROM raw_orders\n),\nt AS (SELECT 1) SELECT * FROM t",
+		},
+		{
+			name: "table named like a keyword",
+			sql:  "SELECT * FROM without_nulls",
+			ctes: ctes[:1],
+			want: "WITH __cte__stg_orders AS (\nSELECT * FROM raw_orders\n)\nSELECT * FROM without_nulls",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InjectCTEs(tt.sql, tt.ctes); got != tt.want {
+				t.Errorf("InjectCTEs() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+func TestEngine_ExecuteModel_Hooks(t *testing.T) {
+	adapter := newMockAdapter()
+	exec, _ := NewEngine(adapter, template.New())
+
+	model, _ := NewModel("orders", "models/orders.sql")
+	model.SetCompiledSQL("SELECT 1 AS id")
+	model.SetMaterializationConfig(materialization.MaterializationConfig{
+		Type:      materialization.MaterializationTable,
+		PreHooks:  []string{"DELETE FROM audit WHERE model = 'orders'"},
+		PostHooks: []string{"CREATE INDEX idx_orders_id ON {{ this }} (id); ANALYZE {{ this }}"},
+	})
+
+	result, err := exec.ExecuteModel(context.Background(), model)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"DELETE FROM audit WHERE model = 'orders'",
+		"DROP TABLE IF EXISTS orders__gorchata_tmp",
+		"CREATE TABLE orders__gorchata_tmp AS SELECT 1 AS id",
+		"PRAGMA legacy_alter_table = ON",
+		"DROP TABLE IF EXISTS orders",
+		"ALTER TABLE orders__gorchata_tmp RENAME TO orders",
+		"PRAGMA legacy_alter_table = OFF",
+		"CREATE INDEX idx_orders_id ON orders (id)",
+		"ANALYZE orders",
+	}
+
+	if len(adapter.executedSQL) != len(want) {
+		t.Fatalf("executed %d statements, want %d: %v", len(adapter.executedSQL), len(want), adapter.executedSQL)
+	}
+	for i, sql := range want {
+		if adapter.executedSQL[i] != sql {
+			t.Errorf("statement %d = %q, want %q", i, adapter.executedSQL[i], sql)
+		}
+	}
+
+	// The swap is recorded with the BEGIN and COMMIT of its transaction
+	if len(result.SQLStatements) != len(want)+2 {
+		t.Errorf("SQLStatements length = %d, want %d", len(result.SQLStatements), len(want)+2)
+	}
+}
+
+// TestEngine_ExecuteModel_FailedSwapCleanup checks that a swap that fails
+// leaves neither its temporary table nor legacy_alter_table turned on
+func TestEngine_ExecuteModel_FailedSwapCleanup(t *testing.T) {
+	adapter := newMockAdapter()
+	adapter.failOn = "RENAME TO"
+	exec, _ := NewEngine(adapter, template.New())
+
+	model, _ := NewModel("orders", "models/orders.sql")
+	model.SetCompiledSQL("SELECT 1 AS id")
+	model.SetMaterializationConfig(materialization.MaterializationConfig{Type: materialization.MaterializationTable})
+
+	if _, err := exec.ExecuteModel(context.Background(), model); err == nil {
+		t.Fatal("expected error from failing swap")
+	}
+
+	executed := adapter.executedSQL
+	want := []string{"PRAGMA legacy_alter_table = OFF", "DROP TABLE IF EXISTS orders__gorchata_tmp"}
+	if len(executed) < len(want) || strings.Join(executed[len(executed)-len(want):], "; ") != strings.Join(want, "; ") {
+		t.Errorf("executed %v, want it to end with %v", executed, want)
+	}
+}
+
+func TestEngine_ExecuteModel_HookRenderError(t *testing.T) {
+	adapter := newMockAdapter()
+	exec, _ := NewEngine(adapter, template.New())
+
+	model, _ := NewModel("orders", "models/orders.sql")
+	model.SetCompiledSQL("SELECT 1 AS id")
+	model.SetMaterializationConfig(materialization.MaterializationConfig{
+		Type:      materialization.MaterializationTable,
+		PostHooks: []string{"ANALYZE {{ var \"missing\" }}"},
+	})
+
+	result, err := exec.ExecuteModel(context.Background(), model)
+	if err == nil {
+		t.Fatal("expected error for hook that fails to render")
+	}
+	if result.Status != StatusFailed {
+		t.Errorf("Status = %v, want %v", result.Status, StatusFailed)
+	}
+	if len(adapter.executedSQL) != 0 {
+		t.Errorf("no statements should run when a hook fails to render, got %v", adapter.executedSQL)
+	}
+}
+
+func TestEngine_ExecuteHooks(t *testing.T) {
+	adapter := newMockAdapter()
+	exec, _ := NewEngine(adapter, template.New())
+
+	ctx := template.NewContext(template.WithVars(map[string]interface{}{"run": "nightly"}))
+	executed, err := exec.ExecuteHooks(context.Background(), "on-run-start", []string{
+		"CREATE TABLE IF NOT EXISTS audit (run TEXT)",
+		"INSERT INTO audit VALUES ('{{ var \"run\" }}; started')",
+	}, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(executed) != 2 {
+		t.Fatalf("executed %d statements, want 2: %v", len(executed), executed)
+	}
+	if !strings.Contains(executed[1], "'nightly; started'") {
+		t.Errorf("semicolon inside string literal should not split statement, got %q", executed[1])
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"single", "SELECT 1", []string{"SELECT 1"}},
+		{"multiple", "CREATE TABLE a (id INT); INSERT INTO a VALUES (1);", []string{"CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"}},
+		{"string literal", "INSERT INTO a VALUES ('x;y')", []string{"INSERT INTO a VALUES ('x;y')"}},
+		{"comment with apostrophe", "-- don't split; here\nSELECT 1; SELECT 2", []string{"-- don't split; here\nSELECT 1", "SELECT 2"}},
+		{"comment only tail", "SELECT 1; -- done", []string{"SELECT 1"}},
+		{"empty", "  ;  ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.input)
+			if len(got) != len(tt.want) {
+				t.Fatalf("splitStatements() = %q, want %q", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("splitStatements()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// TemplateMaterialization is a custom materialization defined in a macro
+// file. Its body is a template rendered with the materialization.Input of
+// the model as data (.Relation, .CompiledSQL, .Existing, .IsIncremental,
+// .Config) and the functions of the model's template, where config() reads
+// any config value of the model, and the macros of the engine it is parsed
+// with. The rendered SQL is split into statements.
+type TemplateMaterialization struct {
+	name string
+	tmpl *template.Template
+}
+
+// NewTemplateMaterialization parses the body of a custom materialization
+// with the template engine
+func NewTemplateMaterialization(name, body string, engine *template.Engine) (*TemplateMaterialization, error) {
+	tmpl, err := engine.Parse("materialization."+name, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse materialization %s: %w", name, err)
+	}
+	return &TemplateMaterialization{name: name, tmpl: tmpl}, nil
+}
+
+// Name returns the materialization name
+func (m *TemplateMaterialization) Name() string {
+	return m.name
+}
+
+// Materialize renders the materialization for a model that has not been built yet
+func (m *TemplateMaterialization) Materialize(modelName string, compiledSQL string, config materialization.MaterializationConfig) ([]string, error) {
+	return m.Build(context.Background(), materialization.Input{
+		Relation:    modelName,
+		CompiledSQL: compiledSQL,
+		Config:      config,
+	})
+}
+
+// Build renders the materialization into the statements that build the model
+func (m *TemplateMaterialization) Build(ctx context.Context, input materialization.Input) ([]string, error) {
+	var tmplCtx template.Context
+	if input.Template != nil {
+		tmplCtx = *input.Template
+	} else {
+		tmplCtx = *template.NewContext(
+			template.WithCurrentModelTable(input.Relation),
+			template.WithIsIncremental(input.IsIncremental),
+		)
+	}
+
+	// config() reads the model's config; the copy keeps the model's own
+	// config untouched when the template sets values
+	tmplCtx.Config = make(map[string]interface{}, len(input.ModelConfig))
+	for key, value := range input.ModelConfig {
+		tmplCtx.Config[key] = value
+	}
+
+	rendered, err := template.Render(m.tmpl, &tmplCtx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := splitStatements(rendered)
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("materialization %s rendered no statements", m.name)
+	}
+	return statements, nil
+}
+
+// LoadMaterializations registers the custom materializations of the macro
+// files macros were loaded from. They may replace built-in ones.
+func LoadMaterializations(macros *template.Macros, registry *materialization.Registry, engine *template.Engine) error {
+	for _, block := range macros.Materializations() {
+		if block.Body == "" {
+			return fmt.Errorf("%s: materialization %s is empty", block.Location(), block.Name)
+		}
+
+		m, err := NewTemplateMaterialization(block.Name, block.Body, engine)
+		if err != nil {
+			return fmt.Errorf("%s: %w", block.Location(), err)
+		}
+		registry.Register(m.Name(), m)
+	}
+	return nil
+}
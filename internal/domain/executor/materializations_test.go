@@ -0,0 +1,158 @@
+package executor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// rollingWindow keeps the rows of the last days of a model, given by the
+// window_days config, appending new rows to the existing table
+const rollingWindow = `{% materialization rolling_window %}
+{{ if .IsIncremental }}
+INSERT INTO {{ this }} SELECT * FROM ({{ .CompiledSQL }});
+{{ else }}
+CREATE TABLE {{ .Relation }} AS {{ .CompiledSQL }};
+{{ end }}
+DELETE FROM {{ this }} WHERE day < date('now', '-{{ config "window_days" }} days');
+{% endmaterialization %}`
+
+func TestLoadMaterializations(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantNames []string
+		wantErr   string
+	}{
+		{name: "one materialization", content: rollingWindow, wantNames: []string{"rolling_window"}},
+		{
+			name:      "ignores content outside of blocks",
+			content:   "-- helpers\n{% materialization a %}SELECT 1{% endmaterialization %}\n{% materialization b %}SELECT 2{% endmaterialization %}",
+			wantNames: []string{"a", "b"},
+		},
+		{
+			name:      "replaces a built-in materialization",
+			content:   "{% materialization table %}CREATE TABLE {{ .Relation }} AS {{ .CompiledSQL }}{% endmaterialization %}",
+			wantNames: []string{"table"},
+		},
+		{name: "no materialization", content: "{{ define \"cents\" }}amount * 100{{ end }}"},
+		{name: "empty body", content: "{% materialization a %} {% endmaterialization %}", wantErr: "macros/custom.sql:1: materialization a is empty"},
+		{name: "template error", content: "\n{% materialization a %}{{ if }}{% endmaterialization %}", wantErr: "macros/custom.sql:2: failed to parse materialization a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macros, err := template.ParseMacros("macros/custom.sql", tt.content)
+			if err != nil {
+				t.Fatalf("ParseMacros() error = %v", err)
+			}
+
+			registry := materialization.NewDefaultRegistry()
+			err = LoadMaterializations(macros, registry, template.New())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadMaterializations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMaterializations() error = %v", err)
+			}
+
+			for _, name := range tt.wantNames {
+				if strategy, _ := registry.Get(name); !isTemplate(strategy) {
+					t.Errorf("%s is not the materialization of the macro file, got %T", name, strategy)
+				}
+			}
+		})
+	}
+}
+
+func isTemplate(strategy materialization.Strategy) bool {
+	_, ok := strategy.(*TemplateMaterialization)
+	return ok
+}
+
+func TestEngine_ExecuteModel_CustomMaterialization(t *testing.T) {
+	tests := []struct {
+		name        string
+		exists      bool
+		fullRefresh bool
+		want        []string
+	}{
+		{
+			name: "first run creates the table",
+			want: []string{
+				"CREATE TABLE events AS SELECT day FROM raw",
+				"DELETE FROM events WHERE day < date('now', '-7 days')",
+			},
+		},
+		{
+			name:   "later runs build on the existing table",
+			exists: true,
+			want: []string{
+				"INSERT INTO events SELECT * FROM (SELECT day FROM raw WHERE day > (SELECT MAX(day) FROM events))",
+				"DELETE FROM events WHERE day < date('now', '-7 days')",
+			},
+		},
+		{
+			name:        "full refresh creates the table again",
+			exists:      true,
+			fullRefresh: true,
+			want: []string{
+				"CREATE TABLE events AS SELECT day FROM raw",
+				"DELETE FROM events WHERE day < date('now', '-7 days')",
+			},
+		},
+	}
+
+	macros, err := template.ParseMacros("macros/rolling.sql", rollingWindow)
+	if err != nil {
+		t.Fatalf("ParseMacros() error = %v", err)
+	}
+	registry := materialization.NewDefaultRegistry()
+	if err := LoadMaterializations(macros, registry, template.New()); err != nil {
+		t.Fatalf("LoadMaterializations() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newMockAdapter()
+			adapter.tableExists["events"] = tt.exists
+			exec, _ := NewEngine(adapter, template.New())
+			exec.SetMaterializations(registry)
+
+			model, _ := NewModel("events", "models/events.sql")
+			model.TemplateContent = "SELECT day FROM raw{{ if is_incremental }} WHERE day > (SELECT MAX(day) FROM {{ this }}){{ end }}"
+			if err := model.ApplyConfig(map[string]interface{}{"materialized": "rolling_window", "window_days": 7}); err != nil {
+				t.Fatalf("ApplyConfig() error = %v", err)
+			}
+			model.MaterializationConfig.FullRefresh = tt.fullRefresh
+
+			result, err := exec.ExecuteModel(context.Background(), model)
+			if err != nil {
+				t.Fatalf("ExecuteModel() error = %v", err)
+			}
+			if !reflect.DeepEqual(result.SQLStatements, tt.want) {
+				t.Errorf("SQLStatements = %q, want %q", result.SQLStatements, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_ExecuteModel_UnknownMaterialization(t *testing.T) {
+	exec, _ := NewEngine(newMockAdapter(), template.New())
+
+	model, _ := NewModel("events", "models/events.sql")
+	model.SetCompiledSQL("SELECT 1 AS id")
+	model.MaterializationConfig.Type = "rolling_window"
+
+	_, err := exec.ExecuteModel(context.Background(), model)
+	if err == nil || !strings.Contains(err.Error(), "unknown materialization type: rolling_window") {
+		t.Errorf("ExecuteModel() error = %v, want unknown materialization type", err)
+	}
+}
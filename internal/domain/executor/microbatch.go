@@ -0,0 +1,183 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// SetEventTimes sets the event time columns of models and source tables.
+// While a microbatch model is built, ref() and source() to them only return
+// the rows of the current batch.
+func (e *Engine) SetEventTimes(models map[string]string, sources map[string]map[string]string) {
+	e.eventTimes = models
+	e.sourceEventTimes = sources
+}
+
+// SetEventTimeRange restricts microbatch models to the batches between start
+// and end, e.g. to backfill a range. A zero time leaves that bound to the model.
+func (e *Engine) SetEventTimeRange(start, end time.Time) {
+	e.eventTimeStart = start
+	e.eventTimeEnd = end
+}
+
+// SetBatches sets the batches to build for some microbatch models, in place
+// of the range they would otherwise cover, e.g. to retry failed batches
+func (e *Engine) SetBatches(batches map[string][]materialization.Batch) {
+	e.batches = batches
+}
+
+// isMicrobatch reports whether a model uses the microbatch strategy
+func isMicrobatch(config materialization.MaterializationConfig) bool {
+	return config.Type == materialization.MaterializationIncremental &&
+		config.Strategy() == materialization.IncrementalMicrobatch
+}
+
+// microbatches returns the batches a microbatch model builds. By default an
+// existing table reprocesses the current batch and its lookback batches,
+// while a new table or a full refresh builds every batch since begin.
+// The event time range and retried batches set on the engine take precedence.
+func (e *Engine) microbatches(model *Model, tableExists bool) ([]materialization.Batch, error) {
+	config := model.MaterializationConfig
+	if batches, ok := e.batches[model.ID]; ok {
+		return batches, nil
+	}
+
+	now := time.Now().UTC()
+	end := materialization.AddBatches(config.BatchSize, materialization.TruncateToBatch(config.BatchSize, now), 1)
+	if !e.eventTimeEnd.IsZero() {
+		end = e.eventTimeEnd
+	}
+
+	var start time.Time
+	switch {
+	case !e.eventTimeStart.IsZero():
+		start = e.eventTimeStart
+	case tableExists && !config.FullRefresh:
+		start = materialization.AddBatches(config.BatchSize, materialization.TruncateToBatch(config.BatchSize, now), -config.Lookback)
+	default:
+		begin, err := materialization.ParseEventTime(config.Begin)
+		if err != nil {
+			return nil, fmt.Errorf("begin: %w", err)
+		}
+		start = begin
+	}
+
+	return materialization.Batches(config.BatchSize, start, end), nil
+}
+
+// executeMicrobatch builds a microbatch model one batch at a time. Each batch
+// renders the model with batch_start and batch_end and replaces the rows of
+// its range; a failed batch does not stop the following ones. Hooks run once,
+// around all batches.
+func (e *Engine) executeMicrobatch(ctx context.Context, model *Model, relation string, tableExists bool, preHooks, postHooks []string, result ModelResult) (ModelResult, error) {
+	config := model.MaterializationConfig
+	if err := materialization.ValidateIncrementalConfig(config); err != nil {
+		return result, result.fail(fmt.Errorf("failed to generate SQL: %w", err))
+	}
+
+	batches, err := e.microbatches(model, tableExists)
+	if err != nil {
+		return result, result.fail(fmt.Errorf("failed to compute batches: %w", err))
+	}
+
+	rows, err := e.executeStatements(ctx, preHooks, result.recordStatement)
+	result.RowsAffected += rows
+	if err != nil {
+		return result, result.fail(fmt.Errorf("failed to execute pre-hook: %w", err))
+	}
+
+	// A full refresh rebuilds the table from its batches under a temporary
+	// name, which replaces the table once every batch succeeded
+	target := relation
+	if config.FullRefresh {
+		target = relation + materialization.SwapSuffix
+		if _, err := e.executeStatements(ctx, []string{fmt.Sprintf("DROP TABLE IF EXISTS %s", target)}, result.recordStatement); err != nil {
+			return result, result.fail(fmt.Errorf("failed to execute SQL: %w", err))
+		}
+		tableExists = false
+		config.FullRefresh = false
+	}
+
+	failed := 0
+	for _, batch := range batches {
+		batchResult := BatchResult{Batch: batch, Status: StatusSuccess}
+
+		rows, err := e.executeBatch(ctx, model, target, tableExists, config, batch, &result)
+		batchResult.RowsAffected = rows
+		result.RowsAffected += rows
+		if err != nil {
+			batchResult.Status = StatusFailed
+			batchResult.Error = err.Error()
+			failed++
+		} else {
+			tableExists = true
+		}
+		result.Batches = append(result.Batches, batchResult)
+	}
+	result.CompiledSQL = model.CompiledSQL
+
+	if failed > 0 {
+		if target != relation {
+			e.cleanupSwap(ctx, relation)
+		}
+		return result, result.fail(fmt.Errorf("%d of %d batch(es) failed, first error: %s", failed, len(batches), firstBatchError(result.Batches)))
+	}
+
+	if target != relation {
+		existing := e.relationType(ctx, relation)
+		if _, err := e.executeStatements(ctx, materialization.ReplaceTableStatements(target, relation, existing), result.recordStatement); err != nil {
+			e.cleanupSwap(ctx, relation)
+			return result, result.fail(fmt.Errorf("failed to execute SQL: %w", err))
+		}
+	}
+
+	rows, err = e.executeStatements(ctx, postHooks, result.recordStatement)
+	result.RowsAffected += rows
+	if err != nil {
+		return result, result.fail(fmt.Errorf("failed to execute post-hook: %w", err))
+	}
+
+	result.Status = StatusSuccess
+	result.EndTime = time.Now()
+	return result, nil
+}
+
+// executeBatch renders the model for one batch and replaces the rows of the
+// batch in its table, returning the number of rows affected
+func (e *Engine) executeBatch(ctx context.Context, model *Model, relation string, tableExists bool, config materialization.MaterializationConfig, batch materialization.Batch, result *ModelResult) (int64, error) {
+	tmplCtx := e.templateContext(ctx, model, relation, tableExists, template.WithBatch(
+		materialization.FormatBatchTime(config.BatchSize, batch.Start),
+		materialization.FormatBatchTime(config.BatchSize, batch.End),
+	))
+	if err := e.renderModel(model, tmplCtx); err != nil {
+		return 0, err
+	}
+
+	config.Batch = &batch
+	statements, err := e.materializationStatements(ctx, relation, model.CompiledSQL, tableExists, config)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := e.executeStatements(ctx, statements, result.recordStatement)
+	if err != nil {
+		// Leave no temporary table behind for the next batch
+		_, _ = e.executeStatement(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s__tmp", relation))
+		return rows, fmt.Errorf("failed to execute SQL: %w", err)
+	}
+	return rows, nil
+}
+
+// firstBatchError returns the error of the first failed batch
+func firstBatchError(batches []BatchResult) string {
+	for _, br := range batches {
+		if br.Status == StatusFailed {
+			return br.Error
+		}
+	}
+	return ""
+}
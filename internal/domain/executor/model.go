@@ -2,6 +2,7 @@ package executor
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
 )
@@ -27,8 +28,29 @@ type Model struct {
 	// Dependencies is a list of model IDs that this model depends on
 	Dependencies []string
 
-	// Metadata stores arbitrary key-value pairs for the model
+	// Metadata stores arbitrary key-value pairs for the model, including the
+	// user-defined ones of the meta config key
 	Metadata map[string]interface{}
+
+	// FQN is the fully qualified name of the model: project, folders, model name
+	FQN []string
+
+	// Config holds the resolved model configuration as set by config() calls
+	Config map[string]interface{}
+
+	// Alias overrides the name of the database relation the model is built into
+	Alias string
+
+	// Tags are labels used to select groups of models
+	Tags []string
+
+	// Enabled controls whether the model takes part in the run (default true)
+	Enabled bool
+
+	// CTEs are the ephemeral models the model depends on, directly or through
+	// other ephemeral models, in dependency order. They are injected into the
+	// compiled SQL each time the model is rendered.
+	CTEs []CTE
 }
 
 // NewModel creates a new Model instance with validation
@@ -46,6 +68,9 @@ func NewModel(id, path string) (*Model, error) {
 		MaterializationConfig: materialization.DefaultConfig(),
 		Dependencies:          []string{},
 		Metadata:              make(map[string]interface{}),
+		Config:                make(map[string]interface{}),
+		Tags:                  []string{},
+		Enabled:               true,
 	}, nil
 }
 
@@ -79,3 +104,38 @@ func (m *Model) SetMaterializationConfig(config materialization.MaterializationC
 func (m *Model) SetMetadata(key string, value interface{}) {
 	m.Metadata[key] = value
 }
+
+// Relation returns the name of the database relation the model materializes into.
+// This is the alias when one is configured, otherwise the model ID.
+func (m *Model) Relation() string {
+	if m.Alias != "" {
+		return m.Alias
+	}
+	return m.ID
+}
+
+// HasTag reports whether the model carries the given tag
+func (m *Model) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSnapshot reports whether the model is a snapshot, defined in the snapshot
+// paths rather than the model paths
+func (m *Model) IsSnapshot() bool {
+	return m.MaterializationConfig.Type == materialization.MaterializationSnapshot
+}
+
+// ArtifactPath returns the relative path of the model's SQL artifacts, which
+// mirrors the model folders: project/folders.../model.sql
+func (m *Model) ArtifactPath() string {
+	if len(m.FQN) < 2 {
+		return m.ID + ".sql"
+	}
+	parts := append([]string{}, m.FQN[:len(m.FQN)-1]...)
+	return filepath.Join(append(parts, m.ID+".sql")...)
+}
@@ -0,0 +1,496 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+)
+
+// ApplyConfig applies configuration values (as collected from config() calls)
+// to the model. Well-known keys are mapped onto typed fields; every value,
+// including unknown keys, is kept in Model.Config. Keys may use a leading "+"
+// and dashes or underscores interchangeably (e.g. "+pre-hook").
+func (m *Model) ApplyConfig(values map[string]interface{}) error {
+	if m.Config == nil {
+		m.Config = make(map[string]interface{})
+	}
+
+	// Apply keys in a stable order so errors are deterministic
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, rawKey := range keys {
+		value := values[rawKey]
+		key := NormalizeConfigKey(rawKey)
+		m.Config[key] = value
+
+		switch key {
+		case "materialized":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("materialized: %w", err)
+			}
+			m.MaterializationConfig.Type = materialization.MaterializationType(s)
+
+		case "unique_key":
+			list, err := configStringList(value)
+			if err != nil {
+				return fmt.Errorf("unique_key: %w", err)
+			}
+			m.MaterializationConfig.UniqueKey = list
+
+		case "incremental_strategy":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("incremental_strategy: %w", err)
+			}
+			m.MaterializationConfig.IncrementalStrategy = s
+
+		case "merge_update_columns":
+			list, err := configStringList(value)
+			if err != nil {
+				return fmt.Errorf("merge_update_columns: %w", err)
+			}
+			m.MaterializationConfig.MergeUpdateColumns = list
+
+		case "merge_exclude_columns":
+			list, err := configStringList(value)
+			if err != nil {
+				return fmt.Errorf("merge_exclude_columns: %w", err)
+			}
+			m.MaterializationConfig.MergeExcludeColumns = list
+
+		case "partition_by":
+			list, err := configStringList(value)
+			if err != nil {
+				return fmt.Errorf("partition_by: %w", err)
+			}
+			m.MaterializationConfig.PartitionBy = list
+
+		case "event_time":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("event_time: %w", err)
+			}
+			m.MaterializationConfig.EventTime = s
+
+		case "batch_size":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("batch_size: %w", err)
+			}
+			m.MaterializationConfig.BatchSize = strings.ToLower(s)
+
+		case "begin":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("begin: %w", err)
+			}
+			m.MaterializationConfig.Begin = s
+
+		case "lookback":
+			n, err := configInt(value)
+			if err != nil {
+				return fmt.Errorf("lookback: %w", err)
+			}
+			m.MaterializationConfig.Lookback = n
+
+		case "strategy":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("strategy: %w", err)
+			}
+			m.MaterializationConfig.SnapshotStrategy = strings.ToLower(s)
+
+		case "updated_at":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("updated_at: %w", err)
+			}
+			m.MaterializationConfig.UpdatedAt = s
+
+		case "check_cols":
+			list, err := configStringList(value)
+			if err != nil {
+				return fmt.Errorf("check_cols: %w", err)
+			}
+			m.MaterializationConfig.CheckCols = list
+
+		case "invalidate_hard_deletes":
+			b, err := configBool(value)
+			if err != nil {
+				return fmt.Errorf("invalidate_hard_deletes: %w", err)
+			}
+			m.MaterializationConfig.InvalidateHardDeletes = b
+
+		case "on_schema_change":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("on_schema_change: %w", err)
+			}
+			m.MaterializationConfig.OnSchemaChange = s
+
+		case "full_refresh":
+			b, err := configBool(value)
+			if err != nil {
+				return fmt.Errorf("full_refresh: %w", err)
+			}
+			m.MaterializationConfig.FullRefresh = b
+
+		case "atomic":
+			b, err := configBool(value)
+			if err != nil {
+				return fmt.Errorf("atomic: %w", err)
+			}
+			m.MaterializationConfig.Atomic = b
+
+		case "contract":
+			enforced, err := configContract(value)
+			if err != nil {
+				return fmt.Errorf("contract: %w", err)
+			}
+			m.MaterializationConfig.Contract.Enforced = enforced
+
+		case "pre_hook":
+			list, err := configHookList(value)
+			if err != nil {
+				return fmt.Errorf("pre_hook: %w", err)
+			}
+			m.MaterializationConfig.PreHooks = list
+
+		case "post_hook":
+			list, err := configHookList(value)
+			if err != nil {
+				return fmt.Errorf("post_hook: %w", err)
+			}
+			m.MaterializationConfig.PostHooks = list
+
+		case "indexes":
+			indexes, err := configIndexes(value)
+			if err != nil {
+				return fmt.Errorf("indexes: %w", err)
+			}
+			m.MaterializationConfig.Indexes = indexes
+
+		case "alias":
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("alias: %w", err)
+			}
+			m.Alias = s
+
+		case "tags":
+			list, err := configStringList(value)
+			if err != nil {
+				return fmt.Errorf("tags: %w", err)
+			}
+			m.Tags = list
+
+		case "enabled":
+			b, err := configBool(value)
+			if err != nil {
+				return fmt.Errorf("enabled: %w", err)
+			}
+			m.Enabled = b
+
+		case "meta":
+			meta, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("meta: expected a map, got %T", value)
+			}
+			for key, value := range meta {
+				m.SetMetadata(key, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// MergeConfig merges configuration layers ordered from least to most specific
+// (e.g. project, folder, then the model's own config() calls) into one map with
+// normalized keys. Later layers override earlier ones, except that hooks are
+// appended, tags are combined and meta maps are merged.
+func MergeConfig(layers ...map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+
+	for _, layer := range layers {
+		// Merge keys in a stable order so hooks declared with several spellings keep their order
+		keys := make([]string, 0, len(layer))
+		for key := range layer {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, rawKey := range keys {
+			key := NormalizeConfigKey(rawKey)
+			value := layer[rawKey]
+
+			previous, exists := merged[key]
+			if !exists {
+				merged[key] = value
+				continue
+			}
+
+			switch key {
+			case "pre_hook", "post_hook":
+				before, errBefore := configHookList(previous)
+				after, errAfter := configHookList(value)
+				if errBefore != nil || errAfter != nil {
+					// Keep the invalid value so ApplyConfig reports it
+					merged[key] = value
+					continue
+				}
+				merged[key] = append(append([]string{}, before...), after...)
+
+			case "tags":
+				before, errBefore := configStringList(previous)
+				after, errAfter := configStringList(value)
+				if errBefore != nil || errAfter != nil {
+					merged[key] = value
+					continue
+				}
+				merged[key] = unionStrings(before, after)
+
+			case "meta":
+				before, okBefore := previous.(map[string]interface{})
+				after, okAfter := value.(map[string]interface{})
+				if !okBefore || !okAfter {
+					merged[key] = value
+					continue
+				}
+				meta := make(map[string]interface{}, len(before)+len(after))
+				for k, v := range before {
+					meta[k] = v
+				}
+				for k, v := range after {
+					meta[k] = v
+				}
+				merged[key] = meta
+
+			default:
+				merged[key] = value
+			}
+		}
+	}
+
+	return merged
+}
+
+// unionStrings returns the values of a followed by the values of b not already in a
+func unionStrings(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	result := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, s := range list {
+			if !seen[s] {
+				seen[s] = true
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// NormalizeConfigKey converts a config key to its canonical form:
+// the dbt-style "+" prefix is removed and dashes become underscores.
+func NormalizeConfigKey(key string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(key), "+"), "-", "_")
+}
+
+// configString converts a config value to a string
+func configString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("expected a string, got %T", value)
+	}
+}
+
+// configStringList converts a config value to a list of strings.
+// A single string is split on commas, so "id,ts" and (list "id" "ts") are equivalent.
+func configStringList(value interface{}) ([]string, error) {
+	var items []string
+
+	switch v := value.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		items = strings.Split(v, ",")
+	case []string:
+		items = v
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, found %T", item)
+			}
+			items = append(items, s)
+		}
+	default:
+		return nil, fmt.Errorf("expected a string or list of strings, got %T", value)
+	}
+
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result, nil
+}
+
+// configHookList converts a config value to a list of hook statements.
+// Unlike configStringList, strings are not split since SQL may contain commas.
+func configHookList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, found %T", item)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("expected a string or list of strings, got %T", value)
+	}
+}
+
+// configBool converts a config value to a boolean
+func configBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "1":
+			return true, nil
+		case "false", "no", "0":
+			return false, nil
+		}
+		return false, fmt.Errorf("invalid boolean %q", v)
+	default:
+		return false, fmt.Errorf("expected a boolean, got %T", value)
+	}
+}
+
+// configContract returns whether a contract config value, such as
+// {enforced: true}, enforces the contract
+func configContract(value interface{}) (bool, error) {
+	contract, ok := value.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("expected a map like {enforced: true}, got %T", value)
+	}
+	enforced, ok := contract["enforced"]
+	if !ok {
+		return false, nil
+	}
+	b, err := configBool(enforced)
+	if err != nil {
+		return false, fmt.Errorf("enforced: %w", err)
+	}
+	return b, nil
+}
+
+// configInt converts a config value to an integer
+func configInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("expected an integer, got %v", v)
+		}
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer %q", v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", value)
+	}
+}
+
+// configIndexes converts a config value to index definitions.
+// Each entry is either a column list ("a,b" or a list) or a map with
+// "columns", optional "unique" and optional "name" keys.
+func configIndexes(value interface{}) ([]materialization.IndexConfig, error) {
+	var entries []interface{}
+	switch v := value.(type) {
+	case nil:
+		return []materialization.IndexConfig{}, nil
+	case []interface{}:
+		entries = v
+	case []string:
+		for _, item := range v {
+			entries = append(entries, item)
+		}
+	default:
+		entries = []interface{}{v}
+	}
+
+	indexes := make([]materialization.IndexConfig, 0, len(entries))
+	for i, entry := range entries {
+		var index materialization.IndexConfig
+
+		switch e := entry.(type) {
+		case map[string]interface{}:
+			columns, err := configStringList(e["columns"])
+			if err != nil {
+				return nil, fmt.Errorf("index %d columns: %w", i, err)
+			}
+			index.Columns = columns
+
+			if unique, ok := e["unique"]; ok {
+				b, err := configBool(unique)
+				if err != nil {
+					return nil, fmt.Errorf("index %d unique: %w", i, err)
+				}
+				index.Unique = b
+			}
+
+			if name, ok := e["name"]; ok {
+				s, err := configString(name)
+				if err != nil {
+					return nil, fmt.Errorf("index %d name: %w", i, err)
+				}
+				index.Name = s
+			}
+		default:
+			columns, err := configStringList(e)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			index.Columns = columns
+		}
+
+		if len(index.Columns) == 0 {
+			return nil, fmt.Errorf("index %d has no columns", i)
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+)
+
+func TestModel_ApplyConfig(t *testing.T) {
+	model, err := NewModel("stg_orders", "models/stg_orders.sql")
+	if err != nil {
+		t.Fatalf("NewModel() error = %v", err)
+	}
+
+	err = model.ApplyConfig(map[string]interface{}{
+		"materialized": "incremental",
+		"unique_key":   "id, ts",
+		"tags":         []interface{}{"daily", "finance"},
+		"alias":        "orders",
+		"enabled":      "true",
+		"atomic":       true,
+		"contract":     map[string]interface{}{"enforced": "true"},
+		"+post-hook":   "ANALYZE {{ this }}",
+		"meta":         map[string]interface{}{"owner": "data-team"},
+		"indexes": []interface{}{
+			map[string]interface{}{"columns": []interface{}{"customer_id"}, "unique": false},
+			"id,ts",
+		},
+		"schema": "analytics",
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+
+	cfg := model.MaterializationConfig
+	if cfg.Type != materialization.MaterializationIncremental {
+		t.Errorf("Type = %v, want incremental", cfg.Type)
+	}
+	if !reflect.DeepEqual(cfg.UniqueKey, []string{"id", "ts"}) {
+		t.Errorf("UniqueKey = %v, want [id ts]", cfg.UniqueKey)
+	}
+	if !reflect.DeepEqual(cfg.PostHooks, []string{"ANALYZE {{ this }}"}) {
+		t.Errorf("PostHooks = %v", cfg.PostHooks)
+	}
+	if len(cfg.Indexes) != 2 || cfg.Indexes[1].Columns[1] != "ts" {
+		t.Errorf("Indexes = %+v", cfg.Indexes)
+	}
+	if !model.HasTag("finance") || model.HasTag("hourly") {
+		t.Errorf("Tags = %v", model.Tags)
+	}
+	if model.Relation() != "orders" {
+		t.Errorf("Relation() = %q, want orders", model.Relation())
+	}
+	if !model.Enabled {
+		t.Error("Enabled = false, want true")
+	}
+	if !cfg.Atomic {
+		t.Error("Atomic = false, want true")
+	}
+	if !cfg.Contract.Enforced {
+		t.Error("Contract.Enforced = false, want true")
+	}
+	if model.Metadata["owner"] != "data-team" {
+		t.Errorf("Metadata = %v", model.Metadata)
+	}
+	if model.Config["schema"] != "analytics" {
+		t.Errorf("unknown keys should be kept in Config, got %v", model.Config)
+	}
+	if _, ok := model.Config["post_hook"]; !ok {
+		t.Errorf("config keys should be normalized, got %v", model.Config)
+	}
+}
+
+func TestModel_ApplyConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+	}{
+		{"invalid enabled", map[string]interface{}{"enabled": "maybe"}},
+		{"non-string materialized", map[string]interface{}{"materialized": 1}},
+		{"non-map meta", map[string]interface{}{"meta": "owner"}},
+		{"index without columns", map[string]interface{}{"indexes": []interface{}{map[string]interface{}{"unique": true}}}},
+		{"non-string tag", map[string]interface{}{"tags": []interface{}{1}}},
+		{"invalid invalidate_hard_deletes", map[string]interface{}{"invalidate_hard_deletes": "maybe"}},
+		{"invalid atomic", map[string]interface{}{"atomic": "sometimes"}},
+		{"contract that is not a map", map[string]interface{}{"contract": true}},
+		{"invalid contract enforced", map[string]interface{}{"contract": map[string]interface{}{"enforced": "sometimes"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, _ := NewModel("m", "models/m.sql")
+			if err := model.ApplyConfig(tt.values); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestModel_Relation(t *testing.T) {
+	model, _ := NewModel("stg_orders", "models/stg_orders.sql")
+	if model.Relation() != "stg_orders" {
+		t.Errorf("Relation() = %q, want stg_orders", model.Relation())
+	}
+	if !model.Enabled {
+		t.Error("models should be enabled by default")
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	merged := MergeConfig(
+		map[string]interface{}{"+materialized": "view", "+tags": []interface{}{"shop"}, "+post-hook": "A", "+meta": map[string]interface{}{"owner": "x"}},
+		map[string]interface{}{"+materialized": "table", "+tags": "marts", "+meta": map[string]interface{}{"tier": "gold"}},
+		map[string]interface{}{"materialized": "incremental", "tags": []interface{}{"shop", "daily"}, "post_hook": []interface{}{"B"}},
+	)
+
+	if merged["materialized"] != "incremental" {
+		t.Errorf("materialized = %v, want incremental", merged["materialized"])
+	}
+	if !reflect.DeepEqual(merged["tags"], []string{"shop", "marts", "daily"}) {
+		t.Errorf("tags = %v, want [shop marts daily]", merged["tags"])
+	}
+	if !reflect.DeepEqual(merged["post_hook"], []string{"A", "B"}) {
+		t.Errorf("post_hook = %v, want [A B]", merged["post_hook"])
+	}
+	meta := merged["meta"].(map[string]interface{})
+	if meta["owner"] != "x" || meta["tier"] != "gold" {
+		t.Errorf("meta = %v, want owner and tier", meta)
+	}
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
@@ -124,3 +125,25 @@ func TestModel_SetMetadata(t *testing.T) {
 		t.Errorf("Metadata owner = %v, want data-team", owner)
 	}
 }
+
+func TestModel_ArtifactPath(t *testing.T) {
+	tests := []struct {
+		name string
+		fqn  []string
+		want string
+	}{
+		{"nested", []string{"shop", "marts", "finance", "revenue"}, filepath.Join("shop", "marts", "finance", "revenue.sql")},
+		{"top level", []string{"shop", "revenue"}, filepath.Join("shop", "revenue.sql")},
+		{"no fqn", nil, "revenue.sql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, _ := NewModel("revenue", "models/revenue.sql")
+			model.FQN = tt.fqn
+			if got := model.ArtifactPath(); got != tt.want {
+				t.Errorf("ArtifactPath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
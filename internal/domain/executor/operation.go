@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/platform"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// queryKeywords are the keywords of statements that return rows
+var queryKeywords = []string{"SELECT", "WITH", "VALUES", "PRAGMA", "EXPLAIN"}
+
+// OperationResult captures the result of running a macro as an operation
+type OperationResult struct {
+	// Macro is the name of the macro that was run
+	Macro string
+
+	// StartTime is when the operation began
+	StartTime time.Time
+
+	// EndTime is when the operation completed
+	EndTime time.Time
+
+	// Statements are the statements the macro rendered, in execution order
+	Statements []StatementResult
+}
+
+// StatementResult is an executed statement
+type StatementResult struct {
+	// SQL is the statement
+	SQL string
+
+	// RowsAffected is the number of rows the statement changed, when the
+	// adapter reports it
+	RowsAffected int64
+
+	// Rows holds the rows a query returned; nil for other statements
+	Rows *platform.QueryResult
+}
+
+// Duration returns the time the operation took
+func (r *OperationResult) Duration() time.Duration {
+	return r.EndTime.Sub(r.StartTime)
+}
+
+// RunOperation renders a macro with arguments given by parameter name and
+// executes the statements it renders, outside of any model. Queries return
+// their rows; statements between BEGIN and COMMIT execute in one transaction.
+// The result holds the statements executed before any failure.
+func (e *Engine) RunOperation(ctx context.Context, macro string, args map[string]interface{}) (*OperationResult, error) {
+	result := &OperationResult{Macro: macro, StartTime: time.Now()}
+	defer func() { result.EndTime = time.Now() }()
+
+	tmplCtx := template.NewContext(
+		template.WithRelations(e.relations),
+		template.WithVars(e.vars),
+		template.WithSeeds(e.seeds),
+		template.WithSources(e.sources),
+		template.WithTarget(e.target),
+		template.WithDatabase(e.database(ctx)),
+	)
+	rendered, err := e.templateEngine.RenderMacro(macro, tmplCtx, args)
+	if err != nil {
+		return result, fmt.Errorf("failed to render macro %s: %w", macro, err)
+	}
+
+	// BEGIN and COMMIT delimit the transaction but are not reported
+	record := func(statement StatementResult) {
+		if statement.SQL != materialization.BeginTransaction && statement.SQL != materialization.CommitTransaction {
+			result.Statements = append(result.Statements, statement)
+		}
+	}
+	if _, err := e.executeStatements(ctx, splitStatements(rendered), record); err != nil {
+		return result, fmt.Errorf("failed to execute operation %s: %w", macro, err)
+	}
+
+	return result, nil
+}
+
+// isQuery reports whether a statement returns rows
+func isQuery(sql string) bool {
+	sql = sql[leadingCommentsEnd(sql):]
+	for _, keyword := range queryKeywords {
+		if startsWithKeyword(sql, keyword) {
+			return true
+		}
+	}
+	return false
+}
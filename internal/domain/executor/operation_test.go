@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+const operationMacros = `{% macro purge(table string, days int = 30) %}
+DELETE FROM {{ ref .table }} WHERE day < date('now', '-{{ .days }} days');
+BEGIN;
+DROP TABLE IF EXISTS {{ .table }}_archive;
+CREATE TABLE {{ .table }}_archive AS SELECT * FROM {{ ref .table }};
+COMMIT;
+-- How many rows are left
+SELECT COUNT(*) FROM {{ ref .table }} /* {{ target.Name }} */
+{% endmacro %}`
+
+func TestEngine_RunOperation(t *testing.T) {
+	macros, err := template.ParseMacros("macros/purge.sql", operationMacros)
+	if err != nil {
+		t.Fatalf("ParseMacros() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		failOn  string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "executes the rendered statements",
+			args: map[string]interface{}{"table": "events", "days": 7},
+			want: []string{
+				"DELETE FROM analytics.events WHERE day < date('now', '-7 days')",
+				"DROP TABLE IF EXISTS events_archive",
+				"CREATE TABLE events_archive AS SELECT * FROM analytics.events",
+				"-- How many rows are left\nSELECT COUNT(*) FROM analytics.events /* dev */",
+			},
+		},
+		{
+			name:    "missing argument",
+			args:    map[string]interface{}{"days": 7},
+			wantErr: "missing argument table",
+		},
+		{
+			name:    "unknown argument",
+			args:    map[string]interface{}{"table": "events", "weeks": 1},
+			wantErr: "has no parameter weeks",
+		},
+		{
+			name:    "failing statement",
+			args:    map[string]interface{}{"table": "events"},
+			failOn:  "CREATE TABLE",
+			want:    []string{"DELETE FROM analytics.events WHERE day < date('now', '-30 days')"},
+			wantErr: "failed to execute operation purge",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newMockAdapter()
+			adapter.failOn = tt.failOn
+			exec, _ := NewEngine(adapter, template.New(template.WithMacros(macros)))
+			exec.SetRelations(map[string]string{"events": "analytics.events"})
+			exec.SetTarget(template.Target{Name: "dev", Type: "sqlite"})
+
+			result, err := exec.RunOperation(context.Background(), "purge", tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RunOperation() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("RunOperation() error = %v", err)
+			}
+
+			var executed []string
+			for _, statement := range result.Statements {
+				executed = append(executed, statement.SQL)
+			}
+			if !reflect.DeepEqual(executed, tt.want) {
+				t.Errorf("statements = %q, want %q", executed, tt.want)
+			}
+			if len(tt.want) == 4 {
+				if result.Statements[3].Rows == nil || result.Statements[0].Rows != nil {
+					t.Errorf("only the query should return rows, got %+v", result.Statements)
+				}
+				if len(adapter.transactions) != 1 || !adapter.transactions[0].committed {
+					t.Errorf("statements between BEGIN and COMMIT should run in a transaction")
+				}
+			}
+		})
+	}
+}
+
+func TestIsQuery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT 1", true},
+		{"-- count\nwith t AS (SELECT 1) SELECT * FROM t", true},
+		{"PRAGMA table_info(events)", true},
+		{"DELETE FROM events", false},
+		{"VACUUM", false},
+	}
+
+	for _, tt := range tests {
+		if got := isQuery(tt.sql); got != tt.want {
+			t.Errorf("isQuery(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package executor
 
 import (
+	"fmt"
 	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
 )
 
 // ExecutionStatus represents the status of execution
@@ -16,6 +19,11 @@ const (
 	StatusSuccess ExecutionStatus = "success"
 	// StatusFailed indicates execution failed
 	StatusFailed ExecutionStatus = "failed"
+	// StatusSkipped indicates execution was skipped because an upstream model failed
+	StatusSkipped ExecutionStatus = "skipped"
+	// StatusRolledBack indicates execution succeeded but its changes were
+	// rolled back with the transaction of an atomic run
+	StatusRolledBack ExecutionStatus = "rolled_back"
 )
 
 // ExecutionResult captures the results of executing one or more models
@@ -53,8 +61,85 @@ type ModelResult struct {
 	// RowsAffected is the number of rows affected (if applicable)
 	RowsAffected int64
 
+	// CompiledSQL is the SQL the model was rendered to for this execution
+	CompiledSQL string
+
 	// SQLStatements are the SQL statements that were executed
 	SQLStatements []string
+
+	// SkippedBecause is the ID of the failed upstream model that caused this
+	// model to be skipped (only set when Status is StatusSkipped)
+	SkippedBecause string
+
+	// Batches are the results of the batches of a microbatch model
+	Batches []BatchResult
+}
+
+// BatchResult captures the result of building one batch of a microbatch model
+type BatchResult struct {
+	// Batch is the time range the batch covers
+	Batch materialization.Batch
+
+	// Status is StatusSuccess, StatusFailed or StatusRolledBack
+	Status ExecutionStatus
+
+	// Error contains the error message if the batch failed
+	Error string
+
+	// RowsAffected is the number of rows affected by the batch
+	RowsAffected int64
+}
+
+// fail marks the model as failed with err and returns err annotated with the model
+func (mr *ModelResult) fail(err error) error {
+	mr.Status = StatusFailed
+	mr.Error = err.Error()
+	mr.EndTime = time.Now()
+	return fmt.Errorf("model %s: %w", mr.ModelID, err)
+}
+
+// recordStatement records a statement the model executed
+func (mr *ModelResult) recordStatement(statement StatementResult) {
+	mr.SQLStatements = append(mr.SQLStatements, statement.SQL)
+}
+
+// rollBack marks the changes of the model as undone: a model or batch that
+// succeeded is marked as rolled back
+func (mr *ModelResult) rollBack() {
+	if mr.Status == StatusSuccess {
+		mr.Status = StatusRolledBack
+	}
+	for i := range mr.Batches {
+		if mr.Batches[i].Status == StatusSuccess {
+			mr.Batches[i].Status = StatusRolledBack
+		}
+	}
+}
+
+// FailedBatches returns the batches of a microbatch model that failed or
+// were rolled back, which retry builds again
+func (mr *ModelResult) FailedBatches() []materialization.Batch {
+	var batches []materialization.Batch
+	for _, br := range mr.Batches {
+		if br.Status == StatusFailed || br.Status == StatusRolledBack {
+			batches = append(batches, br.Batch)
+		}
+	}
+	return batches
+}
+
+// NewSkippedResult creates a ModelResult for a model that was not executed
+// because the upstream model failedModelID failed
+func NewSkippedResult(modelID, failedModelID string) ModelResult {
+	now := time.Now()
+	return ModelResult{
+		ModelID:        modelID,
+		Status:         StatusSkipped,
+		StartTime:      now,
+		EndTime:        now,
+		Error:          fmt.Sprintf("skipped because upstream model %s failed", failedModelID),
+		SkippedBecause: failedModelID,
+	}
 }
 
 // NewExecutionResult creates a new ExecutionResult with initial values
@@ -113,6 +198,48 @@ func (r *ExecutionResult) FailureCount() int {
 	return count
 }
 
+// SkippedCount returns the number of models skipped because of upstream failures
+func (r *ExecutionResult) SkippedCount() int {
+	count := 0
+	for _, mr := range r.ModelResults {
+		if mr.Status == StatusSkipped {
+			count++
+		}
+	}
+	return count
+}
+
+// RolledBackCount returns the number of models whose changes were rolled back
+// with the transaction of an atomic run
+func (r *ExecutionResult) RolledBackCount() int {
+	count := 0
+	for _, mr := range r.ModelResults {
+		if mr.Status == StatusRolledBack {
+			count++
+		}
+	}
+	return count
+}
+
+// rollBack marks the changes of every model as undone
+func (r *ExecutionResult) rollBack() {
+	for i := range r.ModelResults {
+		r.ModelResults[i].rollBack()
+	}
+}
+
+// SkippedModels returns the skipped model results grouped by the failed
+// upstream model that caused them to be skipped
+func (r *ExecutionResult) SkippedModels() map[string][]string {
+	skipped := make(map[string][]string)
+	for _, mr := range r.ModelResults {
+		if mr.Status == StatusSkipped {
+			skipped[mr.SkippedBecause] = append(skipped[mr.SkippedBecause], mr.ModelID)
+		}
+	}
+	return skipped
+}
+
 // Duration returns the total execution duration
 func (r *ExecutionResult) Duration() time.Duration {
 	if r.EndTime.IsZero() {
@@ -0,0 +1,170 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/dag"
+)
+
+// scheduledResult pairs a finished model with its result and execution error
+type scheduledResult struct {
+	index  int
+	result ModelResult
+	err    error
+}
+
+// scheduler runs models concurrently as soon as all of their dependencies have
+// completed, bounded by a fixed number of workers.
+type scheduler struct {
+	engine   *Engine
+	graph    *dag.Graph
+	threads  int
+	failFast bool
+
+	// order holds the topologically sorted nodes; the index of a node in this
+	// slice is used to keep dispatch and result ordering deterministic
+	order    []*dag.Node
+	position map[string]int
+	models   map[string]*Model
+
+	// pending counts the unfinished dependencies of each node
+	pending map[string]int
+	// dependents maps a node to the nodes that depend on it
+	dependents map[string][]string
+
+	// results holds the result of each node by topological position;
+	// nil means the node has not finished (or been skipped) yet
+	results []*ModelResult
+}
+
+// newScheduler prepares a scheduler for the sorted nodes of a graph
+func newScheduler(e *Engine, graph *dag.Graph, sorted []*dag.Node, models map[string]*Model, failFast bool) *scheduler {
+	threads := e.Threads()
+	if threads < 1 {
+		threads = 1
+	}
+
+	s := &scheduler{
+		engine:     e,
+		graph:      graph,
+		threads:    threads,
+		failFast:   failFast,
+		order:      sorted,
+		position:   make(map[string]int, len(sorted)),
+		models:     models,
+		pending:    make(map[string]int, len(sorted)),
+		dependents: make(map[string][]string, len(sorted)),
+		results:    make([]*ModelResult, len(sorted)),
+	}
+
+	for i, node := range sorted {
+		s.position[node.ID] = i
+	}
+
+	for _, node := range sorted {
+		deps := graph.GetDependencies(node.ID)
+		s.pending[node.ID] = len(deps)
+		for _, dep := range deps {
+			s.dependents[dep] = append(s.dependents[dep], node.ID)
+		}
+	}
+
+	return s
+}
+
+// run executes all models and returns their results in topological order.
+// When fail-fast is enabled, no new model is started after the first failure;
+// models that are already running are allowed to finish. Otherwise every
+// descendant of a failed model is recorded as skipped and never executed.
+func (s *scheduler) run(ctx context.Context) ([]ModelResult, error) {
+	var ready []int
+	for i, node := range s.order {
+		if s.pending[node.ID] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	done := make(chan scheduledResult)
+	running := 0
+	stopped := false
+	var firstErr error
+
+	for {
+		// Dispatch as many ready models as there are free workers
+		for !stopped && running < s.threads && len(ready) > 0 {
+			index := ready[0]
+			ready = ready[1:]
+
+			model, exists := s.models[s.order[index].ID]
+			if !exists {
+				// This shouldn't happen, but handle it gracefully
+				ready = s.release(index, ready)
+				continue
+			}
+
+			running++
+			go func(index int, model *Model) {
+				result, err := s.engine.ExecuteModel(ctx, model)
+				done <- scheduledResult{index: index, result: result, err: err}
+			}(index, model)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		finished := <-done
+		running--
+		s.results[finished.index] = &finished.result
+
+		if finished.err != nil {
+			failedID := s.order[finished.index].ID
+			if s.failFast {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("execution failed at model %s: %w", failedID, finished.err)
+				}
+				stopped = true
+				continue
+			}
+
+			// Skip everything downstream of the failed model. Descendants can't
+			// have started yet since one of their dependencies just finished.
+			for _, descendantID := range s.graph.Descendants(failedID) {
+				index, exists := s.position[descendantID]
+				if !exists || s.results[index] != nil {
+					continue
+				}
+				skipped := NewSkippedResult(descendantID, failedID)
+				s.results[index] = &skipped
+			}
+			continue
+		}
+
+		ready = s.release(finished.index, ready)
+	}
+
+	ordered := make([]ModelResult, 0, len(s.results))
+	for _, r := range s.results {
+		if r != nil {
+			ordered = append(ordered, *r)
+		}
+	}
+
+	return ordered, firstErr
+}
+
+// release marks a node as finished and appends any dependents whose
+// dependencies are now all satisfied to the ready queue, keeping it sorted
+// by topological position. Dependents that were already skipped are never queued.
+func (s *scheduler) release(index int, ready []int) []int {
+	for _, dependent := range s.dependents[s.order[index].ID] {
+		s.pending[dependent]--
+		if s.pending[dependent] == 0 && s.results[s.position[dependent]] == nil {
+			ready = append(ready, s.position[dependent])
+		}
+	}
+	sort.Ints(ready)
+	return ready
+}
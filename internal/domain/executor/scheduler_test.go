@@ -0,0 +1,300 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/platform"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// concurrencyAdapter is a thread-safe mock adapter that records the peak
+// number of statements executing at the same time
+type concurrencyAdapter struct {
+	*mockAdapter
+	mu       sync.Mutex
+	active   int
+	peak     int
+	delay    time.Duration
+	failOn   string
+	executed []string
+}
+
+func newConcurrencyAdapter(delay time.Duration) *concurrencyAdapter {
+	return &concurrencyAdapter{mockAdapter: newMockAdapter(), delay: delay}
+}
+
+func (a *concurrencyAdapter) ExecuteDDL(ctx context.Context, sql string) error {
+	a.mu.Lock()
+	a.active++
+	if a.active > a.peak {
+		a.peak = a.active
+	}
+	a.mu.Unlock()
+
+	// Failing statements fail immediately so that slower siblings are still running
+	failing := a.failOn != "" && strings.Contains(sql, a.failOn)
+	if !failing {
+		time.Sleep(a.delay)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active--
+	a.executed = append(a.executed, sql)
+	if failing {
+		return fmt.Errorf("database error")
+	}
+	return nil
+}
+
+func (a *concurrencyAdapter) BeginTransaction(ctx context.Context) (platform.Transaction, error) {
+	return &mockTransaction{exec: a.ExecuteDDL}, nil
+}
+
+// indexOfStatement returns the position of the first executed statement containing substr
+func (a *concurrencyAdapter) indexOfStatement(substr string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, sql := range a.executed {
+		if strings.Contains(sql, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func newViewModel(t *testing.T, id, sql string, deps ...string) *Model {
+	t.Helper()
+	model, err := NewModel(id, "models/"+id+".sql")
+	if err != nil {
+		t.Fatalf("NewModel() error = %v", err)
+	}
+	model.SetCompiledSQL(sql)
+	model.SetMaterializationConfig(materialization.MaterializationConfig{
+		Type: materialization.MaterializationView,
+	})
+	for _, dep := range deps {
+		model.AddDependency(dep)
+	}
+	return model
+}
+
+func TestEngine_SetThreads(t *testing.T) {
+	exec, _ := NewEngine(newMockAdapter(), template.New())
+
+	if exec.Threads() != 1 {
+		t.Errorf("default Threads() = %d, want 1", exec.Threads())
+	}
+
+	exec.SetThreads(4)
+	if exec.Threads() != 4 {
+		t.Errorf("Threads() = %d, want 4", exec.Threads())
+	}
+
+	exec.SetThreads(0)
+	if exec.Threads() != 1 {
+		t.Errorf("Threads() after SetThreads(0) = %d, want 1", exec.Threads())
+	}
+}
+
+func TestEngine_ExecuteModels_Parallel(t *testing.T) {
+	adapter := newConcurrencyAdapter(20 * time.Millisecond)
+	exec, _ := NewEngine(adapter, template.New())
+	exec.SetThreads(4)
+
+	// Three independent roots feeding a single mart
+	models := []*Model{
+		newViewModel(t, "mart", "SELECT * FROM a, b, c", "a", "b", "c"),
+		newViewModel(t, "a", "SELECT 1"),
+		newViewModel(t, "b", "SELECT 2"),
+		newViewModel(t, "c", "SELECT 3"),
+	}
+
+	result, err := exec.ExecuteModels(context.Background(), models, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SuccessCount() != 4 {
+		t.Errorf("SuccessCount = %d, want 4", result.SuccessCount())
+	}
+
+	if adapter.peak < 2 {
+		t.Errorf("peak concurrency = %d, want at least 2", adapter.peak)
+	}
+
+	// The mart must only start after all of its dependencies completed
+	martIdx := adapter.indexOfStatement("CREATE VIEW mart")
+	for _, dep := range []string{"a", "b", "c"} {
+		if depIdx := adapter.indexOfStatement("CREATE VIEW " + dep + " "); depIdx > martIdx {
+			t.Errorf("model %s created after mart (%d > %d)", dep, depIdx, martIdx)
+		}
+	}
+
+	// Results stay in topological order: the mart is always last
+	if last := result.ModelResults[len(result.ModelResults)-1].ModelID; last != "mart" {
+		t.Errorf("last result = %s, want mart", last)
+	}
+}
+
+func TestEngine_ExecuteModels_SingleThreadIsSequential(t *testing.T) {
+	adapter := newConcurrencyAdapter(5 * time.Millisecond)
+	exec, _ := NewEngine(adapter, template.New())
+
+	models := []*Model{
+		newViewModel(t, "a", "SELECT 1"),
+		newViewModel(t, "b", "SELECT 2"),
+		newViewModel(t, "c", "SELECT 3"),
+	}
+
+	if _, err := exec.ExecuteModels(context.Background(), models, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if adapter.peak != 1 {
+		t.Errorf("peak concurrency = %d, want 1", adapter.peak)
+	}
+}
+
+func TestEngine_ExecuteModels_ParallelFailFast(t *testing.T) {
+	adapter := newConcurrencyAdapter(10 * time.Millisecond)
+	adapter.failOn = "CREATE VIEW a "
+	exec, _ := NewEngine(adapter, template.New())
+	exec.SetThreads(2)
+
+	models := []*Model{
+		newViewModel(t, "a", "SELECT 1"),
+		newViewModel(t, "b", "SELECT 2"),
+		newViewModel(t, "c", "SELECT * FROM b", "b"),
+	}
+
+	result, err := exec.ExecuteModels(context.Background(), models, true)
+	if err == nil {
+		t.Fatal("expected error with fail-fast")
+	}
+
+	if result.Status != StatusFailed {
+		t.Errorf("Status = %v, want %v", result.Status, StatusFailed)
+	}
+
+	// a and b start together; c must never be scheduled after a failed
+	for _, mr := range result.ModelResults {
+		if mr.ModelID == "c" {
+			t.Errorf("model c should not run after fail-fast stop")
+		}
+	}
+}
+
+func TestEngine_ExecuteModels_SkipsDownstreamOfFailure(t *testing.T) {
+	for _, threads := range []int{1, 3} {
+		t.Run(fmt.Sprintf("threads=%d", threads), func(t *testing.T) {
+			adapter := newConcurrencyAdapter(time.Millisecond)
+			adapter.failOn = "CREATE VIEW stg "
+			exec, _ := NewEngine(adapter, template.New())
+			exec.SetThreads(threads)
+
+			// raw -> stg -> fct -> rpt, other is independent, rpt also depends on other
+			models := []*Model{
+				newViewModel(t, "raw", "SELECT 1"),
+				newViewModel(t, "stg", "SELECT * FROM raw", "raw"),
+				newViewModel(t, "fct", "SELECT * FROM stg", "stg"),
+				newViewModel(t, "other", "SELECT 2"),
+				newViewModel(t, "rpt", "SELECT * FROM fct, other", "fct", "other"),
+			}
+
+			result, err := exec.ExecuteModels(context.Background(), models, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			statuses := make(map[string]ModelResult)
+			for _, mr := range result.ModelResults {
+				statuses[mr.ModelID] = mr
+			}
+
+			if len(statuses) != 5 {
+				t.Fatalf("expected a result for all 5 models, got %d", len(statuses))
+			}
+
+			for id, want := range map[string]ExecutionStatus{
+				"raw":   StatusSuccess,
+				"stg":   StatusFailed,
+				"fct":   StatusSkipped,
+				"rpt":   StatusSkipped,
+				"other": StatusSuccess,
+			} {
+				if got := statuses[id].Status; got != want {
+					t.Errorf("model %s status = %v, want %v", id, got, want)
+				}
+			}
+
+			if root := statuses["rpt"].SkippedBecause; root != "stg" {
+				t.Errorf("rpt SkippedBecause = %q, want stg", root)
+			}
+
+			if adapter.indexOfStatement("CREATE VIEW fct") != -1 || adapter.indexOfStatement("CREATE VIEW rpt") != -1 {
+				t.Error("skipped models must not be executed")
+			}
+
+			if result.SkippedCount() != 2 {
+				t.Errorf("SkippedCount = %d, want 2", result.SkippedCount())
+			}
+
+			if skipped := result.SkippedModels()["stg"]; len(skipped) != 2 {
+				t.Errorf("SkippedModels()[stg] = %v, want 2 models", skipped)
+			}
+		})
+	}
+}
+
+func TestEngine_ExecuteModels_ThroughEphemeralModels(t *testing.T) {
+	// raw -> eph -> fct, where eph is ephemeral and inlined into fct
+	newModels := func() []*Model {
+		fct := newViewModel(t, "fct", "SELECT * FROM __cte__eph", "eph")
+		fct.CTEs = []CTE{{Name: EphemeralCTEName("eph"), SQL: "SELECT * FROM raw", Dependencies: []string{"raw"}}}
+		return []*Model{
+			newViewModel(t, "raw", "SELECT 1"),
+			fct,
+		}
+	}
+
+	t.Run("runs after the dependencies of the ephemeral model", func(t *testing.T) {
+		adapter := newConcurrencyAdapter(5 * time.Millisecond)
+		exec, _ := NewEngine(adapter, template.New())
+		exec.SetThreads(4)
+
+		if _, err := exec.ExecuteModels(context.Background(), newModels(), false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		raw, fct := adapter.indexOfStatement("CREATE VIEW raw"), adapter.indexOfStatement("CREATE VIEW fct")
+		if raw == -1 || fct == -1 || fct < raw {
+			t.Errorf("fct executed at %d, want after raw at %d", fct, raw)
+		}
+		if adapter.peak != 1 {
+			t.Errorf("peak concurrency = %d, want 1", adapter.peak)
+		}
+	})
+
+	t.Run("is skipped when a dependency of the ephemeral model fails", func(t *testing.T) {
+		adapter := newConcurrencyAdapter(time.Millisecond)
+		adapter.failOn = "CREATE VIEW raw "
+		exec, _ := NewEngine(adapter, template.New())
+		exec.SetThreads(4)
+
+		result, err := exec.ExecuteModels(context.Background(), newModels(), false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, mr := range result.ModelResults {
+			if mr.ModelID == "fct" && (mr.Status != StatusSkipped || mr.SkippedBecause != "raw") {
+				t.Errorf("fct status = %v skipped because %q, want skipped because raw", mr.Status, mr.SkippedBecause)
+			}
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/platform"
+)
+
+// AtomicPolicy decides what an atomic run keeps of its changes when some of
+// its models fail
+type AtomicPolicy string
+
+const (
+	// AtomicOff runs models in autocommit: the changes of every model that
+	// succeeded are kept, whatever happens to the others (default)
+	AtomicOff AtomicPolicy = ""
+	// AtomicRollback rolls the database back to its state before the run
+	// when any model fails
+	AtomicRollback AtomicPolicy = "rollback"
+	// AtomicKeepSuccessful rolls back the failed models only, keeping the
+	// models that succeeded
+	AtomicKeepSuccessful AtomicPolicy = "keep-successful"
+)
+
+// ParseAtomicPolicy converts the name of a partial-failure policy to an
+// AtomicPolicy. An empty name is AtomicRollback.
+func ParseAtomicPolicy(name string) (AtomicPolicy, error) {
+	switch AtomicPolicy(strings.ToLower(strings.TrimSpace(name))) {
+	case "", AtomicRollback:
+		return AtomicRollback, nil
+	case AtomicKeepSuccessful:
+		return AtomicKeepSuccessful, nil
+	}
+	return AtomicOff, fmt.Errorf("unknown partial-failure policy %q (expected %s or %s)", name, AtomicRollback, AtomicKeepSuccessful)
+}
+
+// SetAtomic makes ExecuteModels run all models in one transaction, with a
+// savepoint per model, and sets what the run keeps when models fail.
+// Atomic runs execute one model at a time.
+func (e *Engine) SetAtomic(policy AtomicPolicy) {
+	e.atomic = policy
+}
+
+// Atomic returns the partial-failure policy of atomic runs, or AtomicOff
+func (e *Engine) Atomic() AtomicPolicy {
+	return e.atomic
+}
+
+// transactionKey is the context key of the transaction models execute in
+type transactionKey struct{}
+
+// withTransaction returns a context in which statements execute in tx
+func withTransaction(ctx context.Context, tx platform.Transaction) context.Context {
+	return context.WithValue(ctx, transactionKey{}, tx)
+}
+
+// transactionFrom returns the transaction of ctx, or nil outside of one
+func transactionFrom(ctx context.Context) platform.Transaction {
+	tx, _ := ctx.Value(transactionKey{}).(platform.Transaction)
+	return tx
+}
+
+// inSavepoint runs fn within a savepoint of tx. The savepoint is rolled back
+// when fn fails and released otherwise.
+func inSavepoint(ctx context.Context, tx platform.Transaction, name string, fn func() error) error {
+	if err := tx.Savepoint(ctx, name); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if rollbackErr := tx.RollbackToSavepoint(ctx, name); rollbackErr != nil {
+			return fmt.Errorf("%w (%v)", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.ReleaseSavepoint(ctx, name)
+}
+
+// modelSavepoint returns the name of the savepoint a model executes in
+func modelSavepoint(modelID string) string {
+	return "gorchata_" + modelID
+}
+
+// executeAtomically executes a model in a transaction. Within an atomic run
+// the model gets a savepoint of the run transaction; otherwise, for models
+// configured with atomic, it gets a transaction of its own. Either way a
+// model that fails leaves the database as it was before the model.
+func (e *Engine) executeAtomically(ctx context.Context, model *Model) (ModelResult, error) {
+	if tx := transactionFrom(ctx); tx != nil {
+		var result ModelResult
+		var execErr error
+		err := inSavepoint(ctx, tx, modelSavepoint(model.ID), func() error {
+			result, execErr = e.executeModel(ctx, model)
+			return execErr
+		})
+		switch {
+		case execErr != nil:
+			result.rollBack()
+			return result, err
+		case err != nil:
+			return result, result.fail(fmt.Errorf("failed to release savepoint: %w", err))
+		}
+		return result, nil
+	}
+
+	tx, err := e.adapter.BeginTransaction(ctx)
+	if err != nil {
+		result := ModelResult{ModelID: model.ID, StartTime: time.Now()}
+		return result, result.fail(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	result, execErr := e.executeModel(withTransaction(ctx, tx), model)
+	if execErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return result, fmt.Errorf("%w (%v)", execErr, rollbackErr)
+		}
+		result.rollBack()
+		return result, execErr
+	}
+	if err := tx.Commit(); err != nil {
+		return result, result.fail(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+	return result, nil
+}
+
+// finishAtomicRun commits or rolls back the transaction of an atomic run
+// according to the partial-failure policy. When the run is rolled back, the
+// models that succeeded are marked as rolled back.
+func (e *Engine) finishAtomicRun(tx platform.Transaction, result *ExecutionResult, failed bool) error {
+	if !failed || e.atomic == AtomicKeepSuccessful {
+		if err := tx.Commit(); err != nil {
+			result.rollBack()
+			return fmt.Errorf("failed to commit run transaction: %w", err)
+		}
+		return nil
+	}
+
+	if err := tx.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back run transaction: %w", err)
+	}
+	result.rollBack()
+	return nil
+}
@@ -0,0 +1,180 @@
+package executor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+func TestParseAtomicPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    AtomicPolicy
+		wantErr bool
+	}{
+		{"", AtomicRollback, false},
+		{"rollback", AtomicRollback, false},
+		{"Keep-Successful", AtomicKeepSuccessful, false},
+		{"commit", AtomicOff, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAtomicPolicy(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAtomicPolicy(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseAtomicPolicy(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_ExecuteModels_Atomic(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        AtomicPolicy
+		wantCommitted bool
+		wantStatuses  map[string]ExecutionStatus
+	}{
+		{
+			name:          "rollback",
+			policy:        AtomicRollback,
+			wantCommitted: false,
+			wantStatuses:  map[string]ExecutionStatus{"stg": StatusRolledBack, "fct": StatusFailed, "rpt": StatusSkipped, "other": StatusRolledBack},
+		},
+		{
+			name:          "keep successful",
+			policy:        AtomicKeepSuccessful,
+			wantCommitted: true,
+			wantStatuses:  map[string]ExecutionStatus{"stg": StatusSuccess, "fct": StatusFailed, "rpt": StatusSkipped, "other": StatusSuccess},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newMockAdapter()
+			adapter.failOn = "broken"
+			exec, _ := NewEngine(adapter, template.New())
+			exec.SetThreads(4)
+			exec.SetAtomic(tt.policy)
+
+			if exec.Threads() != 1 {
+				t.Errorf("Threads() = %d, want 1 in an atomic run", exec.Threads())
+			}
+
+			models := []*Model{
+				newViewModel(t, "stg", "SELECT 1 AS id"),
+				newViewModel(t, "fct", "SELECT 1 AS broken", "stg"),
+				newViewModel(t, "rpt", "SELECT 1 AS id", "fct"),
+				newViewModel(t, "other", "SELECT 1 AS id"),
+			}
+
+			result, err := exec.ExecuteModels(context.Background(), models, false)
+			if err != nil {
+				t.Fatalf("ExecuteModels() error = %v", err)
+			}
+
+			statuses := make(map[string]ExecutionStatus)
+			for _, mr := range result.ModelResults {
+				statuses[mr.ModelID] = mr.Status
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+
+			if len(adapter.transactions) != 1 {
+				t.Fatalf("expected one run transaction, got %d", len(adapter.transactions))
+			}
+			tx := adapter.transactions[0]
+			if tx.committed != tt.wantCommitted || tx.rolledBack == tt.wantCommitted {
+				t.Errorf("committed = %v, rolled back = %v, want committed = %v", tx.committed, tx.rolledBack, tt.wantCommitted)
+			}
+
+			// Each model gets a savepoint, which nests the savepoint of its
+			// swap; the failed model is rolled back to its savepoint
+			wantSavepoints := map[string][]string{
+				"stg":   {"SAVEPOINT gorchata_stg", "SAVEPOINT gorchata_swap", "RELEASE gorchata_swap", "RELEASE gorchata_stg"},
+				"other": {"SAVEPOINT gorchata_other", "SAVEPOINT gorchata_swap", "RELEASE gorchata_swap", "RELEASE gorchata_other"},
+				"fct":   {"SAVEPOINT gorchata_fct", "SAVEPOINT gorchata_swap", "ROLLBACK TO gorchata_swap", "ROLLBACK TO gorchata_fct"},
+			}
+			if len(tx.savepoints) != 12 {
+				t.Fatalf("savepoints = %v, want 4 per executed model", tx.savepoints)
+			}
+			for i := 0; i < len(tx.savepoints); i += 4 {
+				id := strings.TrimPrefix(tx.savepoints[i], "SAVEPOINT gorchata_")
+				if !reflect.DeepEqual(tx.savepoints[i:i+4], wantSavepoints[id]) {
+					t.Errorf("savepoints of %s = %v, want %v", id, tx.savepoints[i:i+4], wantSavepoints[id])
+				}
+			}
+		})
+	}
+}
+
+func TestEngine_ExecuteModel_AtomicModel(t *testing.T) {
+	tests := []struct {
+		name          string
+		sql           string
+		wantErr       bool
+		wantCommitted bool
+	}{
+		{"commits a model that succeeds", "SELECT 1 AS id", false, true},
+		{"rolls back a model that fails", "SELECT 1 AS broken", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newMockAdapter()
+			adapter.failOn = "broken"
+			exec, _ := NewEngine(adapter, template.New())
+
+			model, _ := NewModel("orders", "models/orders.sql")
+			model.SetCompiledSQL(tt.sql)
+			model.SetMaterializationConfig(materialization.MaterializationConfig{
+				Type:      materialization.MaterializationTable,
+				Atomic:    true,
+				PostHooks: []string{"ANALYZE orders"},
+			})
+
+			_, err := exec.ExecuteModel(context.Background(), model)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExecuteModel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(adapter.transactions) != 1 {
+				t.Fatalf("expected one model transaction, got %d", len(adapter.transactions))
+			}
+			tx := adapter.transactions[0]
+			if tx.committed != tt.wantCommitted || tx.rolledBack == tt.wantCommitted {
+				t.Errorf("committed = %v, rolled back = %v, want committed = %v", tx.committed, tx.rolledBack, tt.wantCommitted)
+			}
+		})
+	}
+}
+
+func TestModelResult_RollBack(t *testing.T) {
+	result := &ExecutionResult{ModelResults: []ModelResult{
+		{ModelID: "stg", Status: StatusSuccess},
+		{ModelID: "fct", Status: StatusFailed, Batches: []BatchResult{
+			{Batch: materialization.Batch{}, Status: StatusSuccess},
+			{Batch: materialization.Batch{}, Status: StatusFailed},
+		}},
+		{ModelID: "rpt", Status: StatusSkipped},
+	}}
+
+	result.rollBack()
+
+	if result.ModelResults[0].Status != StatusRolledBack || result.RolledBackCount() != 1 {
+		t.Errorf("stg status = %v, RolledBackCount() = %d", result.ModelResults[0].Status, result.RolledBackCount())
+	}
+	if result.ModelResults[1].Status != StatusFailed || result.ModelResults[2].Status != StatusSkipped {
+		t.Errorf("failed and skipped models should keep their status, got %+v", result.ModelResults)
+	}
+	// Retry builds the rolled back batches again along with the failed ones
+	if got := len(result.ModelResults[1].FailedBatches()); got != 2 {
+		t.Errorf("FailedBatches() = %d batch(es), want 2", got)
+	}
+}
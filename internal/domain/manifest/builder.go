@@ -0,0 +1,543 @@
+package manifest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/dag"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/seeds"
+	testExecutor "github.com/jpconstantineau/gorchata/internal/domain/test/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/test/generic"
+	"github.com/jpconstantineau/gorchata/internal/domain/test/schema"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// BuildOptions controls how a manifest is built
+type BuildOptions struct {
+	// Vars are the resolved project variables available to var()
+	Vars map[string]interface{}
+
+	// FullRefresh marks incremental models and models of custom
+	// materializations for a full refresh
+	FullRefresh bool
+
+	// SeedConfig controls seed discovery and naming; defaults are used when nil
+	SeedConfig *config.SeedConfig
+}
+
+// Build discovers the project's models, snapshots, seeds, sources and tests
+// across all configured paths (recursively) and resolves model configuration
+// and dependencies. Model configuration is merged from the project level of
+// the models: block, then each folder level, then the model's own config()
+// calls; snapshots use the snapshots: block instead. Disabled models are left
+// out of the manifest. The macros of the macro paths are loaded first, so
+// that models can call them.
+func Build(cfg *config.Config, opts BuildOptions) (*Manifest, error) {
+	if cfg == nil || cfg.Project == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+
+	vars := opts.Vars
+	if vars == nil {
+		vars = cfg.Project.ResolveVars(nil)
+	}
+
+	m := &Manifest{
+		ProjectName: cfg.Project.Name,
+		GeneratedAt: time.Now(),
+		Vars:        vars,
+		seedRefs:    make(map[string][]string),
+	}
+
+	if cfg.Output != nil {
+		m.AdapterType = cfg.Output.Type
+		m.Database = absPath(cfg.Output.Database)
+		m.Target = template.Target{
+			Name:     cfg.Target,
+			Type:     cfg.Output.Type,
+			Database: cfg.Output.Database,
+			Threads:  cfg.Output.Threads,
+		}
+	}
+
+	var err error
+	if m.Macros, err = template.LoadMacros(cfg.Project.MacroPaths); err != nil {
+		return nil, fmt.Errorf("failed to load macros: %w", err)
+	}
+
+	if m.Seeds, err = discoverSeeds(cfg.Project.SeedPaths, opts.SeedConfig); err != nil {
+		return nil, err
+	}
+
+	if m.Sources, m.modelSchemas, err = discoverSchemas(cfg.Project.ModelPaths); err != nil {
+		return nil, err
+	}
+
+	if m.Models, err = discoverModels(cfg.Project.ModelPaths, cfg.Project.Name); err != nil {
+		return nil, err
+	}
+
+	// Snapshots are models too, so that ref() and the DAG include them
+	snapshots, err := discoverModels(cfg.Project.SnapshotPaths, cfg.Project.Name)
+	if err != nil {
+		return nil, err
+	}
+	m.snapshotIDs = make(map[string]bool, len(snapshots))
+	for _, snapshot := range snapshots {
+		if _, exists := m.Model(snapshot.ID); exists {
+			return nil, fmt.Errorf("duplicate model name %s: snapshot %s has the name of a model", snapshot.ID, snapshot.Path)
+		}
+		m.snapshotIDs[snapshot.ID] = true
+	}
+	m.Models = append(m.Models, snapshots...)
+
+	if err := m.resolveModels(cfg.Project, opts.FullRefresh); err != nil {
+		return nil, err
+	}
+
+	if m.Tests, err = testExecutor.DiscoverAllTests(cfg, generic.NewDefaultRegistry()); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// resolveModels renders every model to collect its config() calls and ref()
+// dependencies, applies its configuration, drops disabled models, validates
+// the dependency graph and finally compiles each model with aliases resolved
+func (m *Manifest) resolveModels(project *config.ProjectConfig, fullRefresh bool) error {
+	tracker := newDependencyTracker()
+	templateEngine := template.New(template.WithDependencyTracker(tracker), template.WithMacros(m.Macros))
+
+	seedTables := m.SeedTables()
+	sourceTables := m.SourceTables()
+
+	for _, model := range m.Models {
+		// Read model content
+		content, err := os.ReadFile(model.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read model %s: %w", model.ID, err)
+		}
+
+		// Legacy {{ config(key='value') }} calls are rewritten so the template engine evaluates them
+		contentStr := normalizeLegacyConfig(string(content))
+
+		// Store template content for engine to re-render with incremental context
+		model.SetTemplateContent(contentStr)
+
+		// Parse template
+		tmpl, err := templateEngine.Parse(model.ID, contentStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s: %w", model.ID, err)
+		}
+
+		// Render template; config() calls record their values on the context
+		ctx := template.NewContext(
+			template.WithCurrentModel(model.ID),
+			template.WithCurrentModelTable(model.ID),
+			template.WithSeeds(seedTables),
+			template.WithSources(sourceTables),
+			template.WithVars(m.Vars),
+			template.WithTarget(m.Target),
+		)
+
+		if _, err := template.Render(tmpl, ctx, nil); err != nil {
+			return fmt.Errorf("failed to render template %s: %w", model.ID, err)
+		}
+
+		// The FQN is project, folders..., model name
+		folders := model.FQN[1 : len(model.FQN)-1]
+		if m.snapshotIDs[model.ID] {
+			if err := applySnapshotConfig(model, project.SnapshotConfigLayers(folders), ctx.Config); err != nil {
+				return err
+			}
+			continue
+		}
+		// The config: of the model in a schema file overrides the models:
+		// block, and its columns declare the model's contract
+		modelSchema := m.modelSchemas[model.ID]
+		layers := append(project.ModelConfigLayers(folders), modelSchema.Config)
+		if err := applyModelConfig(model, contentStr, layers, ctx.Config, fullRefresh); err != nil {
+			return err
+		}
+		applyContract(model, modelSchema)
+		if model.IsSnapshot() {
+			return fmt.Errorf("model %s is materialized as a snapshot, which is reserved for the snapshot paths", model.ID)
+		}
+	}
+
+	known := make(map[string]bool, len(m.Models))
+	for _, model := range m.Models {
+		known[model.ID] = true
+	}
+	isSeed := make(map[string]bool, len(m.Seeds))
+	for _, seed := range m.Seeds {
+		isSeed[seed.ID] = true
+	}
+
+	// Sort ref() targets into model dependencies and seed references
+	for _, model := range m.Models {
+		for _, dep := range tracker.GetDependencies(model.ID) {
+			switch {
+			case known[dep]:
+				model.AddDependency(dep)
+			case isSeed[dep]:
+				m.seedRefs[model.ID] = append(m.seedRefs[model.ID], dep)
+			default:
+				return fmt.Errorf("model %s depends on %s which was not found", model.ID, dep)
+			}
+		}
+	}
+
+	// Drop models disabled with {{ config "enabled" false }} or in the models: block
+	enabled, err := filterEnabledModels(m.Models)
+	if err != nil {
+		return err
+	}
+	m.Models = enabled
+
+	graph, err := m.Graph()
+	if err != nil {
+		return err
+	}
+	if err := dag.Validate(graph); err != nil {
+		return fmt.Errorf("DAG validation failed: %w", err)
+	}
+
+	// Compile each model now that the relation of every model is known
+	return m.compileModels(m.Models)
+}
+
+// compileModels renders the given models with the relation of every model
+// resolved. ref() to an ephemeral model resolves to a CTE, and the ephemeral
+// models a model depends on are inlined at the start of its compiled SQL.
+func (m *Manifest) compileModels(models []*executor.Model) error {
+	compiler := template.New(template.WithMacros(m.Macros))
+	relations := m.Relations()
+	seedTables := m.SeedTables()
+	sourceTables := m.SourceTables()
+
+	render := func(model *executor.Model) (string, error) {
+		tmpl, err := compiler.Parse(model.ID, model.TemplateContent)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse template %s: %w", model.ID, err)
+		}
+
+		ctx := template.NewContext(
+			template.WithCurrentModel(model.ID),
+			template.WithCurrentModelTable(model.Relation()),
+			template.WithRelations(relations),
+			template.WithSeeds(seedTables),
+			template.WithSources(sourceTables),
+			template.WithVars(m.Vars),
+			template.WithTarget(m.Target),
+		)
+
+		rendered, err := template.Render(tmpl, ctx, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to render template %s: %w", model.ID, err)
+		}
+		return rendered, nil
+	}
+
+	// Every ephemeral model is rendered first since any model may inline it
+	ephemeral := make(map[string]string)
+	for _, model := range m.Models {
+		if !model.IsEphemeral() {
+			continue
+		}
+		rendered, err := render(model)
+		if err != nil {
+			return err
+		}
+		ephemeral[model.ID] = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(rendered), ";"))
+	}
+
+	for _, model := range models {
+		rendered, err := render(model)
+		if err != nil {
+			return err
+		}
+		model.CTEs = m.ephemeralCTEs(model, ephemeral)
+		model.SetCompiledSQL(executor.InjectCTEs(rendered, model.CTEs))
+	}
+
+	return nil
+}
+
+// ephemeralCTEs returns the CTEs of the ephemeral models a model depends on,
+// directly or through other ephemeral models. Each appears once, after the
+// ephemeral models it depends on.
+func (m *Manifest) ephemeralCTEs(model *executor.Model, ephemeral map[string]string) []executor.CTE {
+	var ctes []executor.CTE
+	seen := make(map[string]bool)
+
+	var visit func(model *executor.Model)
+	visit = func(model *executor.Model) {
+		for _, dep := range model.Dependencies {
+			sql, ok := ephemeral[dep]
+			if !ok || seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			cte := executor.CTE{Name: executor.EphemeralCTEName(dep), SQL: sql}
+			if parent, ok := m.Model(dep); ok {
+				visit(parent)
+				cte.Dependencies = parent.Dependencies
+			}
+			ctes = append(ctes, cte)
+		}
+	}
+	visit(model)
+
+	return ctes
+}
+
+// absPath returns the absolute form of path, or path itself if it cannot be resolved
+func absPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// rootPaths drops the paths that repeat or are nested inside another path of
+// the list. Discovery walks each path recursively, so the nested ones would
+// find the same files twice.
+func rootPaths(paths []string) []string {
+	var roots []string
+	for i, path := range paths {
+		if !nestedPath(path, paths[:i], true) && !nestedPath(path, paths[i+1:], false) {
+			roots = append(roots, path)
+		}
+	}
+	return roots
+}
+
+// nestedPath reports whether path lies inside one of the other paths, or is
+// the same path when sameCounts is set
+func nestedPath(path string, others []string, sameCounts bool) bool {
+	abs := absPath(path)
+	for _, other := range others {
+		rel, err := filepath.Rel(absPath(other), abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel != "." || sameCounts {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverModels loads all SQL models from the model paths and their subdirectories.
+// Each model's FQN is made of the project name, its folders and its name.
+// Model paths that do not exist are skipped, and model paths nested inside
+// another one are walked as part of the outer path.
+func discoverModels(modelPaths []string, projectName string) ([]*executor.Model, error) {
+	var models []*executor.Model
+	seen := make(map[string]string)
+
+	for _, dir := range rootPaths(modelPaths) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+				return nil
+			}
+
+			modelID := strings.TrimSuffix(entry.Name(), ".sql")
+
+			// Model names must be unique across folders since ref() uses the name only
+			if other, exists := seen[modelID]; exists {
+				return fmt.Errorf("duplicate model name %s: %s and %s", modelID, other, path)
+			}
+			seen[modelID] = path
+
+			model, err := executor.NewModel(modelID, path)
+			if err != nil {
+				return fmt.Errorf("failed to create model %s: %w", modelID, err)
+			}
+
+			rel, err := filepath.Rel(dir, filepath.Dir(path))
+			if err != nil {
+				return fmt.Errorf("failed to resolve folder of model %s: %w", modelID, err)
+			}
+
+			model.FQN = []string{projectName}
+			if rel != "." {
+				model.FQN = append(model.FQN, strings.Split(filepath.ToSlash(rel), "/")...)
+			}
+			model.FQN = append(model.FQN, modelID)
+
+			models = append(models, model)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load models from %s: %w", dir, err)
+		}
+	}
+
+	return models, nil
+}
+
+// discoverSeeds finds the CSV and SQL seeds in the seed paths without loading their data
+func discoverSeeds(seedPaths []string, seedConfig *config.SeedConfig) ([]*seeds.Seed, error) {
+	if seedConfig == nil {
+		seedConfig = config.DefaultSeedConfig()
+	}
+
+	var discovered []*seeds.Seed
+	for _, seedPath := range seedPaths {
+		if _, err := os.Stat(seedPath); os.IsNotExist(err) {
+			continue
+		}
+
+		seedFiles, err := seeds.DiscoverSeeds(seedPath, seedConfig.Import.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover seeds in %s: %w", seedPath, err)
+		}
+
+		for _, seedFile := range seedFiles {
+			seedType := seeds.SeedTypeCSV
+			if strings.EqualFold(filepath.Ext(seedFile), ".sql") {
+				seedType = seeds.SeedTypeSQL
+			}
+
+			tableName := seeds.ResolveTableName(seedFile, &seedConfig.Naming)
+			discovered = append(discovered, &seeds.Seed{
+				ID:                tableName,
+				Path:              seedFile,
+				Type:              seedType,
+				ResolvedTableName: tableName,
+			})
+		}
+	}
+
+	return discovered, nil
+}
+
+// schemaKey matches the top-level keys that make a YAML file a schema file
+var schemaKey = regexp.MustCompile(`(?m)^(models|sources)\s*:`)
+
+// discoverSchemas collects the tables declared in the sources: block and the
+// models declared in the models: block of the schema files found in the
+// model paths, the latter by name.
+// YAML files without those blocks are skipped, as in test discovery, but a
+// schema file that fails to parse is an error: skipping it would silently
+// drop its contracts.
+func discoverSchemas(modelPaths []string) ([]*Source, map[string]schema.ModelSchema, error) {
+	var sources []*Source
+	schemas := make(map[string]schema.ModelSchema)
+	declared := make(map[string]string)
+
+	for _, dir := range rootPaths(modelPaths) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			ext := strings.ToLower(filepath.Ext(path))
+			if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+				return nil
+			}
+
+			schemaFile, err := schema.ParseSchemaFile(path)
+			if err != nil {
+				data, readErr := os.ReadFile(path)
+				if readErr == nil && !schemaKey.Match(data) {
+					return nil
+				}
+				return err
+			}
+
+			for _, src := range schemaFile.Sources {
+				for _, table := range src.Tables {
+					identifier := table.Identifier
+					if identifier == "" {
+						identifier = table.Name
+					}
+					sources = append(sources, &Source{
+						SourceName:  src.Name,
+						Name:        table.Name,
+						Identifier:  identifier,
+						Schema:      src.Schema,
+						Description: table.Description,
+						EventTime:   table.EventTime,
+						Path:        path,
+					})
+				}
+			}
+
+			for _, model := range schemaFile.Models {
+				if other, exists := declared[model.Name]; exists {
+					return fmt.Errorf("model %s is declared in %s and %s", model.Name, other, path)
+				}
+				declared[model.Name] = path
+				schemas[model.Name] = model
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover schema files in %s: %w", dir, err)
+		}
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].SourceName != sources[j].SourceName {
+			return sources[i].SourceName < sources[j].SourceName
+		}
+		return sources[i].Name < sources[j].Name
+	})
+
+	return sources, schemas, nil
+}
+
+// dependencyTracker records the ref() calls made while rendering models
+type dependencyTracker struct {
+	dependencies map[string][]string
+}
+
+func newDependencyTracker() *dependencyTracker {
+	return &dependencyTracker{
+		dependencies: make(map[string][]string),
+	}
+}
+
+// AddDependency records a dependency once, in the order first seen
+func (t *dependencyTracker) AddDependency(from, to string) error {
+	for _, existing := range t.dependencies[from] {
+		if existing == to {
+			return nil
+		}
+	}
+	t.dependencies[from] = append(t.dependencies[from], to)
+	return nil
+}
+
+// GetDependencies returns the recorded dependencies of a model
+func (t *dependencyTracker) GetDependencies(modelID string) []string {
+	return t.dependencies[modelID]
+}
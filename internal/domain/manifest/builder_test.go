@@ -0,0 +1,415 @@
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/config"
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+)
+
+// writeProject writes the given files under a temporary directory and returns
+// a config whose model and seed paths point into it
+func writeProject(t *testing.T, files map[string]string) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &config.Config{
+		Project: &config.ProjectConfig{
+			Name:          "shop",
+			ModelPaths:    []string{filepath.Join(dir, "models"), filepath.Join(dir, "more_models")},
+			SeedPaths:     []string{filepath.Join(dir, "seeds")},
+			TestPaths:     []string{filepath.Join(dir, "tests")},
+			SnapshotPaths: []string{filepath.Join(dir, "snapshots")},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	cfg := writeProject(t, map[string]string{
+		"models/stg_orders.sql":       `SELECT * FROM {{ source "raw" "orders" }} JOIN {{ ref "countries" }}`,
+		"models/marts/fct_orders.sql": `{{ config "alias" "orders" }}SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/marts/off.sql":        `{{ config "enabled" false }}SELECT 1`,
+		"more_models/report.sql":      `SELECT * FROM {{ ref "fct_orders" }} WHERE year = {{ var "year" }}`,
+		"models/sources.yml": `version: 2
+sources:
+  - name: raw
+    schema: landing
+    tables:
+      - name: orders
+        identifier: raw_orders
+`,
+		"models/schema.yml": `version: 2
+models:
+  - name: fct_orders
+    columns:
+      - name: id
+        data_tests:
+          - not_null
+`,
+		"models/notes.yml":    "owner: analytics\n",
+		"seeds/countries.csv": "code,name\nCA,Canada\n",
+	})
+
+	m, err := Build(cfg, BuildOptions{Vars: map[string]interface{}{"year": 2024}})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	var ids []string
+	for _, model := range m.Models {
+		ids = append(ids, model.ID)
+	}
+	if want := []string{"fct_orders", "stg_orders", "report"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("models = %v, want %v", ids, want)
+	}
+
+	fct, _ := m.Model("fct_orders")
+	if want := []string{"shop", "marts", "fct_orders"}; !reflect.DeepEqual(fct.FQN, want) {
+		t.Errorf("fct_orders FQN = %v, want %v", fct.FQN, want)
+	}
+
+	report, _ := m.Model("report")
+	if want := "SELECT * FROM orders WHERE year = 2024"; report.CompiledSQL != want {
+		t.Errorf("report compiled SQL = %q, want %q", report.CompiledSQL, want)
+	}
+	if !reflect.DeepEqual(report.Dependencies, []string{"fct_orders"}) {
+		t.Errorf("report dependencies = %v", report.Dependencies)
+	}
+
+	stg, _ := m.Model("stg_orders")
+	if !strings.Contains(stg.CompiledSQL, "landing.raw_orders") {
+		t.Errorf("stg_orders compiled SQL = %q, want source resolved", stg.CompiledSQL)
+	}
+	if len(stg.Dependencies) != 0 {
+		t.Errorf("stg_orders dependencies = %v, want none", stg.Dependencies)
+	}
+	if refs := m.SeedRefs("stg_orders"); !reflect.DeepEqual(refs, []string{"countries"}) {
+		t.Errorf("stg_orders seed refs = %v, want [countries]", refs)
+	}
+
+	if len(m.Seeds) != 1 || m.Seeds[0].ID != "countries" {
+		t.Errorf("seeds = %v, want [countries]", m.Seeds)
+	}
+	if len(m.Sources) != 1 || m.Sources[0].Relation() != "landing.raw_orders" {
+		t.Errorf("sources = %v, want landing.raw_orders", m.Sources)
+	}
+	if len(m.Tests) != 1 {
+		t.Errorf("found %d test(s), want 1", len(m.Tests))
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		errContains string
+	}{
+		{
+			name:        "unknown ref",
+			files:       map[string]string{"models/a.sql": `SELECT * FROM {{ ref "missing" }}`},
+			errContains: "depends on missing which was not found",
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"models/a.sql": `SELECT * FROM {{ ref "b" }}`,
+				"models/b.sql": `SELECT * FROM {{ ref "a" }}`,
+			},
+			errContains: "DAG validation failed",
+		},
+		{
+			name: "duplicate name across folders",
+			files: map[string]string{
+				"models/a.sql":        `SELECT 1`,
+				"models/nested/a.sql": `SELECT 2`,
+			},
+			errContains: "duplicate model name a",
+		},
+		{
+			name: "schema file that fails to parse",
+			files: map[string]string{
+				"models/a.sql": `SELECT 1 AS id`,
+				"models/schema.yml": `version: 2
+models:
+  - name: a
+    config:
+      contract: {enforced: true
+`,
+			},
+			errContains: "failed to parse YAML",
+		},
+		{
+			name: "schema file without version",
+			files: map[string]string{
+				"models/a.sql": `SELECT 1 AS id`,
+				"models/schema.yml": `models:
+  - name: a
+`,
+			},
+			errContains: "missing or invalid version",
+		},
+		{
+			name: "ref to disabled model",
+			files: map[string]string{
+				"models/a.sql": `{{ config "enabled" false }}SELECT 1`,
+				"models/b.sql": `SELECT * FROM {{ ref "a" }}`,
+			},
+			errContains: "disabled model a",
+		},
+		{
+			name: "snapshot with the name of a model",
+			files: map[string]string{
+				"models/a.sql":    `SELECT 1`,
+				"snapshots/a.sql": `{{ config "strategy" "check" "unique_key" "id" "check_cols" "all" }}SELECT 1 AS id`,
+			},
+			errContains: "has the name of a model",
+		},
+		{
+			name: "snapshot without strategy",
+			files: map[string]string{
+				"snapshots/a.sql": `{{ config "unique_key" "id" }}SELECT 1 AS id`,
+			},
+			errContains: "invalid config for snapshot a: strategy is required",
+		},
+		{
+			name: "model materialized as a snapshot",
+			files: map[string]string{
+				"models/a.sql": `{{ config "materialized" "snapshot" }}SELECT 1`,
+			},
+			errContains: "reserved for the snapshot paths",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Build(writeProject(t, tt.files), BuildOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Build() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestBuildOverlappingModelPaths(t *testing.T) {
+	cfg := writeProject(t, map[string]string{
+		"models/stg_orders.sql":            `SELECT 1 AS id`,
+		"models/dimensions/dim_orders.sql": `SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/dimensions/schema.yml": `version: 2
+sources:
+  - name: raw
+    tables:
+      - name: orders
+models:
+  - name: dim_orders
+    columns:
+      - name: id
+`,
+	})
+	models := cfg.Project.ModelPaths[0]
+	cfg.Project.ModelPaths = []string{filepath.Join(models, "dimensions"), models, models}
+
+	m, err := Build(cfg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if len(m.Models) != 2 {
+		t.Fatalf("got %d models, want 2", len(m.Models))
+	}
+	dim, _ := m.Model("dim_orders")
+	if want := []string{"shop", "dimensions", "dim_orders"}; !reflect.DeepEqual(dim.FQN, want) {
+		t.Errorf("FQN = %v, want %v", dim.FQN, want)
+	}
+	if len(m.Sources) != 1 {
+		t.Errorf("got %d sources, want 1", len(m.Sources))
+	}
+}
+
+func TestBuildSnapshots(t *testing.T) {
+	cfg := writeProject(t, map[string]string{
+		"snapshots/crm/customers_snapshot.sql": `{{ config "strategy" "timestamp" "unique_key" "id" "updated_at" "updated_at" }}SELECT * FROM raw_customers`,
+		"models/customer_history.sql":          `SELECT * FROM {{ ref "customers_snapshot" }}`,
+	})
+
+	m, err := Build(cfg, BuildOptions{FullRefresh: true})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	snapshot, ok := m.Model("customers_snapshot")
+	if !ok {
+		t.Fatal("snapshot customers_snapshot not found")
+	}
+	if !snapshot.IsSnapshot() || snapshot.MaterializationConfig.FullRefresh {
+		t.Errorf("snapshot config = %+v", snapshot.MaterializationConfig)
+	}
+	if want := []string{"shop", "crm", "customers_snapshot"}; !reflect.DeepEqual(snapshot.FQN, want) {
+		t.Errorf("snapshot FQN = %v, want %v", snapshot.FQN, want)
+	}
+
+	history, _ := m.Model("customer_history")
+	if !reflect.DeepEqual(history.Dependencies, []string{"customers_snapshot"}) {
+		t.Errorf("customer_history dependencies = %v", history.Dependencies)
+	}
+	if got := m.ModelUniqueID("customers_snapshot"); got != "snapshot.shop.customers_snapshot" {
+		t.Errorf("ModelUniqueID() = %s", got)
+	}
+}
+
+func TestBuildEphemeralModels(t *testing.T) {
+	cfg := writeProject(t, map[string]string{
+		"models/stg_orders.sql": `{{ config "materialized" "ephemeral" }}SELECT * FROM raw_orders;`,
+		"models/int_orders.sql": `{{ config "materialized" "ephemeral" }}SELECT * FROM {{ ref "stg_orders" }} WHERE amount > 0`,
+		"models/fct_orders.sql": `{{ config "materialized" "table" }}SELECT * FROM {{ ref "int_orders" }} JOIN {{ ref "stg_orders" }} USING (id)`,
+		"models/dim_dates.sql":  `SELECT 1 AS d`,
+	})
+
+	m, err := Build(cfg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	// stg_orders is inlined once, before int_orders which depends on it
+	fct, _ := m.Model("fct_orders")
+	want := "WITH __cte__stg_orders AS (\nSELECT * FROM raw_orders\n),\n" +
+		"__cte__int_orders AS (\nSELECT * FROM __cte__stg_orders WHERE amount > 0\n)\n" +
+		"SELECT * FROM __cte__int_orders JOIN __cte__stg_orders USING (id)"
+	if fct.CompiledSQL != want {
+		t.Errorf("fct_orders compiled SQL =\n%s\nwant\n%s", fct.CompiledSQL, want)
+	}
+
+	dates, _ := m.Model("dim_dates")
+	if len(dates.CTEs) != 0 || dates.CompiledSQL != "SELECT 1 AS d" {
+		t.Errorf("dim_dates compiled SQL = %q", dates.CompiledSQL)
+	}
+
+	if got := m.Relations()["int_orders"]; got != "__cte__int_orders" {
+		t.Errorf("relation of int_orders = %q, want its CTE", got)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written manifestJSON
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if node := written.Nodes["model.shop.int_orders"]; node.Relation != "" {
+		t.Errorf("ephemeral relation_name = %q, want none", node.Relation)
+	}
+}
+
+func TestBuildContracts(t *testing.T) {
+	cfg := writeProject(t, map[string]string{
+		"models/orders.sql":    `{{ config "materialized" "table" }}SELECT 1 AS id, 10 AS customer_id`,
+		"models/customers.sql": `{{ config "contract" (dict "enforced" false) }}SELECT 10 AS id`,
+		"models/schema.yml": `version: 2
+models:
+  - name: orders
+    config:
+      contract: {enforced: true}
+      materialized: view
+    constraints:
+      - type: unique
+        columns: [id, customer_id]
+    columns:
+      - name: id
+        data_type: integer
+        constraints:
+          - type: primary_key
+      - name: customer_id
+        data_type: integer
+        constraints:
+          - type: foreign_key
+            expression: customers (id)
+  - name: customers
+    config:
+      contract: {enforced: true}
+`,
+	})
+
+	m, err := Build(cfg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	orders, _ := m.Model("orders")
+	contract := orders.MaterializationConfig.Contract
+	if !contract.Enforced {
+		t.Error("contract of orders should be enforced by its schema file")
+	}
+	want := []materialization.ContractColumn{
+		{Name: "id", DataType: "integer", Constraints: []materialization.Constraint{{Type: "primary_key"}}},
+		{Name: "customer_id", DataType: "integer", Constraints: []materialization.Constraint{{Type: "foreign_key", Expression: "customers (id)"}}},
+	}
+	if !reflect.DeepEqual(contract.Columns, want) {
+		t.Errorf("contract columns = %+v, want %+v", contract.Columns, want)
+	}
+	if len(contract.Constraints) != 1 || !reflect.DeepEqual(contract.Constraints[0].Columns, []string{"id", "customer_id"}) {
+		t.Errorf("contract constraints = %+v", contract.Constraints)
+	}
+	// The model's own config() overrides its schema file
+	if orders.MaterializationConfig.Type != materialization.MaterializationTable {
+		t.Errorf("orders materialized = %s, want table", orders.MaterializationConfig.Type)
+	}
+
+	customers, _ := m.Model("customers")
+	if customers.MaterializationConfig.Contract.Enforced {
+		t.Error("contract of customers should be turned off by its config() call")
+	}
+}
+
+func TestManifestSelect(t *testing.T) {
+	cfg := writeProject(t, map[string]string{
+		"models/stg_orders.sql":       `SELECT 1 AS id`,
+		"models/marts/fct_orders.sql": `{{ config "tags" "finance" }}SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/marts/report.sql":     `SELECT * FROM {{ ref "fct_orders" }}`,
+	})
+
+	m, err := Build(cfg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	tests := []struct {
+		include string
+		exclude string
+		want    []string
+	}{
+		{"", "", []string{"fct_orders", "report", "stg_orders"}},
+		{"+fct_orders", "", []string{"fct_orders", "stg_orders"}},
+		{"tag:finance+", "report", []string{"fct_orders"}},
+		{"config.materialized:table,report", "", []string{"report"}},
+	}
+
+	for _, tt := range tests {
+		models, err := m.Select(tt.include, tt.exclude)
+		if err != nil {
+			t.Fatalf("Select(%q, %q) error = %v", tt.include, tt.exclude, err)
+		}
+
+		var ids []string
+		for _, model := range models {
+			ids = append(ids, model.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Select(%q, %q) = %v, want %v", tt.include, tt.exclude, ids, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package manifest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/dag"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/seeds"
+	"github.com/jpconstantineau/gorchata/internal/domain/test"
+	"github.com/jpconstantineau/gorchata/internal/domain/test/schema"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
+// Manifest is the resolved view of a project: its models, seeds, sources and
+// tests, with configuration and dependencies resolved once and shared by every command.
+type Manifest struct {
+	// ProjectName is the name of the project the manifest was built from
+	ProjectName string
+
+	// GeneratedAt is when the manifest was built
+	GeneratedAt time.Time
+
+	// AdapterType and Database identify the target the project is built into
+	AdapterType string
+	Database    string
+
+	// Models holds the enabled models in discovery order, followed by the
+	// enabled snapshots
+	Models []*executor.Model
+
+	// Seeds holds the CSV and SQL seeds discovered in the seed paths
+	Seeds []*seeds.Seed
+
+	// Sources holds the source tables declared in schema files
+	Sources []*Source
+
+	// Tests holds the singular tests and the tests declared in schema files
+	Tests []*test.Test
+
+	// Vars are the resolved project variables used to render the project
+	Vars map[string]interface{}
+
+	// Target is the profile output the manifest was built for, returned by
+	// {{ target }}
+	Target template.Target
+
+	// Macros holds the macros defined in the macro paths
+	Macros *template.Macros
+
+	// modelSchemas holds the models declared in schema files, by name
+	modelSchemas map[string]schema.ModelSchema
+
+	// snapshotIDs holds the IDs of the models discovered in the snapshot paths
+	snapshotIDs map[string]bool
+
+	// seedRefs maps a model ID to the seeds it references with ref()
+	seedRefs map[string][]string
+
+	// state is the previous manifest compared against for state: selection
+	state *State
+
+	// stateStatus maps model IDs to StateNew or StateModified
+	stateStatus map[string]string
+
+	// deferred maps unselected model IDs to their relation in the state's target
+	deferred map[string]string
+}
+
+// Source is a table declared in the sources: block of a schema file
+type Source struct {
+	// SourceName is the name of the source the table belongs to
+	SourceName string
+
+	// Name is the table name used in {{ source "source_name" "name" }}
+	Name string
+
+	// Identifier is the table name in the database (defaults to Name)
+	Identifier string
+
+	// Schema optionally qualifies the table in the database
+	Schema string
+
+	// Description documents the table
+	Description string
+
+	// EventTime is the column holding the time of each row, used to filter
+	// the table to the current batch of microbatch models
+	EventTime string
+
+	// Path is the schema file that declares the source
+	Path string
+}
+
+// Relation returns the qualified name of the source table in the database
+func (s *Source) Relation() string {
+	if s.Schema != "" {
+		return s.Schema + "." + s.Identifier
+	}
+	return s.Identifier
+}
+
+// Model returns the model with the given ID
+func (m *Manifest) Model(id string) (*executor.Model, bool) {
+	for _, model := range m.Models {
+		if model.ID == id {
+			return model, true
+		}
+	}
+	return nil, false
+}
+
+// Relations maps each model ID to the relation it is built into, or to its
+// relation in the state's target when it is deferred. Ephemeral models map to
+// the CTE they are inlined as.
+func (m *Manifest) Relations() map[string]string {
+	relations := make(map[string]string, len(m.Models))
+	for _, model := range m.Models {
+		if model.IsEphemeral() {
+			relations[model.ID] = executor.EphemeralCTEName(model.ID)
+			continue
+		}
+		relations[model.ID] = model.Relation()
+	}
+	for id, relation := range m.deferred {
+		relations[id] = relation
+	}
+	return relations
+}
+
+// SeedTables maps each seed name to its table name, for use by seed()
+func (m *Manifest) SeedTables() map[string]string {
+	tables := make(map[string]string, len(m.Seeds))
+	for _, seed := range m.Seeds {
+		tables[seed.ID] = seed.ResolvedTableName
+	}
+	return tables
+}
+
+// SourceTables maps source and table names to their relation, for use by source()
+func (m *Manifest) SourceTables() map[string]map[string]string {
+	tables := make(map[string]map[string]string)
+	for _, source := range m.Sources {
+		if tables[source.SourceName] == nil {
+			tables[source.SourceName] = make(map[string]string)
+		}
+		tables[source.SourceName][source.Name] = source.Relation()
+	}
+	return tables
+}
+
+// EventTimes maps the models that configure event_time to their event time
+// column, used to filter ref() in microbatch models
+func (m *Manifest) EventTimes() map[string]string {
+	eventTimes := make(map[string]string)
+	for _, model := range m.Models {
+		if model.MaterializationConfig.EventTime != "" {
+			eventTimes[model.ID] = model.MaterializationConfig.EventTime
+		}
+	}
+	return eventTimes
+}
+
+// SourceEventTimes maps source and table names to the event time column of
+// the source tables that declare one, used to filter source() in microbatch models
+func (m *Manifest) SourceEventTimes() map[string]map[string]string {
+	eventTimes := make(map[string]map[string]string)
+	for _, source := range m.Sources {
+		if source.EventTime == "" {
+			continue
+		}
+		if eventTimes[source.SourceName] == nil {
+			eventTimes[source.SourceName] = make(map[string]string)
+		}
+		eventTimes[source.SourceName][source.Name] = source.EventTime
+	}
+	return eventTimes
+}
+
+// SeedRefs returns the seeds a model references with ref()
+func (m *Manifest) SeedRefs(modelID string) []string {
+	return m.seedRefs[modelID]
+}
+
+// Graph returns the dependency graph of the manifest's models
+func (m *Manifest) Graph() (*dag.Graph, error) {
+	graph := dag.NewGraph()
+
+	for _, model := range m.Models {
+		nodeType := "model"
+		if model.IsSnapshot() {
+			nodeType = "snapshot"
+		}
+		node := &dag.Node{
+			ID:           model.ID,
+			Name:         model.ID,
+			Type:         nodeType,
+			Dependencies: model.Dependencies,
+			Metadata: map[string]interface{}{
+				dag.MetadataFilePath: model.Path,
+				dag.MetadataTags:     model.Tags,
+				dag.MetadataConfig:   ResolvedConfig(model),
+				dag.MetadataState:    m.stateStatus[model.ID],
+			},
+		}
+		if err := graph.AddNode(node); err != nil {
+			return nil, fmt.Errorf("failed to add node %s to graph: %w", model.ID, err)
+		}
+	}
+
+	for _, model := range m.Models {
+		for _, dep := range model.Dependencies {
+			if err := graph.AddEdge(model.ID, dep); err != nil {
+				return nil, fmt.Errorf("failed to add edge from %s to %s: %w", model.ID, dep, err)
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+// Select returns the models matched by the include selector minus those
+// matched by the exclude selector, in manifest order. An empty include
+// selector selects every model. See dag.Selector for the syntax.
+func (m *Manifest) Select(include, exclude string) ([]*executor.Model, error) {
+	graph, err := m.Graph()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.state == nil && (usesStateMethod(include) || usesStateMethod(exclude)) {
+		return nil, fmt.Errorf("state: selectors require --state")
+	}
+
+	ids, err := dag.SelectNodes(graph, include, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	models := make([]*executor.Model, 0, len(ids))
+	for _, model := range m.Models {
+		if selected[model.ID] {
+			models = append(models, model)
+		}
+	}
+	return models, nil
+}
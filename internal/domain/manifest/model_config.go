@@ -0,0 +1,257 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+	"github.com/jpconstantineau/gorchata/internal/domain/materialization"
+	"github.com/jpconstantineau/gorchata/internal/domain/test/schema"
+)
+
+// legacyConfigRe matches Jinja-style {{ config(key='value', ...) }} calls
+var legacyConfigRe = regexp.MustCompile(`{{-?\s*config\s*\(([^}]*)\)\s*-?}}`)
+
+// legacyConfigArgRe matches a single key=value argument of a legacy config call
+var legacyConfigArgRe = regexp.MustCompile(`(\w+)\s*=\s*('[^']*'|"[^"]*"|[\w.]+)`)
+
+// materializationCommentRe matches the old -- Materialization: table comment format
+var materializationCommentRe = regexp.MustCompile(`--\s*Materialization:\s*(\w+)`)
+
+// normalizeLegacyConfig rewrites Jinja-style {{ config(materialized='view', unique_key='id') }}
+// calls into the equivalent Go template call {{ config "materialized" "view" "unique_key" "id" }}
+// so that they can be evaluated by the template engine
+func normalizeLegacyConfig(content string) string {
+	return legacyConfigRe.ReplaceAllStringFunc(content, func(call string) string {
+		args := legacyConfigRe.FindStringSubmatch(call)[1]
+
+		var parts []string
+		for _, match := range legacyConfigArgRe.FindAllStringSubmatch(args, -1) {
+			parts = append(parts, strconv.Quote(match[1]), legacyConfigValue(match[2]))
+		}
+
+		if len(parts) == 0 {
+			return ""
+		}
+		return "{{ config " + strings.Join(parts, " ") + " }}"
+	})
+}
+
+// legacyConfigValue converts a legacy config argument value to a Go template literal
+func legacyConfigValue(value string) string {
+	switch strings.ToLower(value) {
+	case "true":
+		return "true"
+	case "false":
+		return "false"
+	}
+
+	if unquoted := strings.Trim(value, `'"`); unquoted != value {
+		return strconv.Quote(unquoted)
+	}
+
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+
+	return strconv.Quote(value)
+}
+
+// applyModelConfig merges the configuration layers from the models: block
+// (least specific first) with the values collected from the model's own
+// config() calls and applies the result to the model. A model that does not
+// set materialized itself falls back to the -- Materialization: comment, then
+// to the folder or project defaults, then to table.
+func applyModelConfig(model *executor.Model, content string, layers []map[string]interface{}, values map[string]interface{}, fullRefresh bool) error {
+	model.SetMaterializationConfig(materialization.DefaultConfig())
+
+	inFile := make(map[string]interface{}, len(values)+1)
+	for key, value := range values {
+		inFile[key] = value
+	}
+	if _, ok := executor.MergeConfig(values)["materialized"]; !ok {
+		if matches := materializationCommentRe.FindStringSubmatch(content); len(matches) > 1 {
+			inFile["materialized"] = matches[1]
+		}
+	}
+
+	merged := executor.MergeConfig(append(append([]map[string]interface{}{}, layers...), inFile)...)
+	if err := model.ApplyConfig(merged); err != nil {
+		return fmt.Errorf("invalid config for model %s: %w", model.ID, err)
+	}
+
+	// Apply --full-refresh flag if set; it rebuilds incremental models and
+	// models of custom materializations from scratch
+	if fullRefresh {
+		model.MaterializationConfig.FullRefresh = true
+	}
+
+	return nil
+}
+
+// applyContract declares the contract of a model from the columns and
+// constraints of its schema file entry
+func applyContract(model *executor.Model, modelSchema schema.ModelSchema) {
+	contract := &model.MaterializationConfig.Contract
+	contract.Columns = nil
+	for _, column := range modelSchema.Columns {
+		contract.Columns = append(contract.Columns, materialization.ContractColumn{
+			Name:        column.Name,
+			DataType:    column.DataType,
+			Constraints: contractConstraints(column.Constraints),
+		})
+	}
+	contract.Constraints = contractConstraints(modelSchema.Constraints)
+}
+
+// contractConstraints converts the constraints of a schema file
+func contractConstraints(constraints []schema.ConstraintSchema) []materialization.Constraint {
+	var result []materialization.Constraint
+	for _, constraint := range constraints {
+		result = append(result, materialization.Constraint{
+			Type:       strings.ToLower(strings.TrimSpace(constraint.Type)),
+			Expression: constraint.Expression,
+			Columns:    constraint.Columns,
+		})
+	}
+	return result
+}
+
+// applySnapshotConfig merges the configuration layers from the snapshots:
+// block with the values collected from the snapshot's own config() calls and
+// applies the result to the snapshot, which is always materialized as a
+// snapshot and never fully refreshed
+func applySnapshotConfig(model *executor.Model, layers []map[string]interface{}, values map[string]interface{}) error {
+	model.SetMaterializationConfig(materialization.DefaultConfig())
+
+	merged := executor.MergeConfig(append(append([]map[string]interface{}{}, layers...), values)...)
+	if err := model.ApplyConfig(merged); err != nil {
+		return fmt.Errorf("invalid config for snapshot %s: %w", model.ID, err)
+	}
+
+	model.MaterializationConfig.Type = materialization.MaterializationSnapshot
+	if err := materialization.ValidateSnapshotConfig(model.MaterializationConfig); err != nil {
+		return fmt.Errorf("invalid config for snapshot %s: %w", model.ID, err)
+	}
+
+	return nil
+}
+
+// filterEnabledModels removes models configured with enabled=false.
+// It is an error for an enabled model to ref() a disabled one.
+func filterEnabledModels(models []*executor.Model) ([]*executor.Model, error) {
+	disabled := make(map[string]bool)
+	for _, model := range models {
+		if !model.Enabled {
+			disabled[model.ID] = true
+		}
+	}
+
+	if len(disabled) == 0 {
+		return models, nil
+	}
+
+	enabled := make([]*executor.Model, 0, len(models)-len(disabled))
+	for _, model := range models {
+		if disabled[model.ID] {
+			continue
+		}
+		for _, dep := range model.Dependencies {
+			if disabled[dep] {
+				return nil, fmt.Errorf("model %s depends on disabled model %s", model.ID, dep)
+			}
+		}
+		enabled = append(enabled, model)
+	}
+
+	return enabled, nil
+}
+
+// ResolvedConfig returns a model's configuration after merging, including the
+// defaults of settings the model does not set itself
+func ResolvedConfig(model *executor.Model) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(model.Config)+8)
+	for key, value := range model.Config {
+		resolved[key] = value
+	}
+
+	cfg := model.MaterializationConfig
+	resolved["materialized"] = string(cfg.Type)
+	resolved["enabled"] = model.Enabled
+	resolved["tags"] = model.Tags
+	resolved["meta"] = model.Metadata
+	resolved["unique_key"] = cfg.UniqueKey
+	if cfg.Type == materialization.MaterializationIncremental {
+		resolved["incremental_strategy"] = cfg.Strategy()
+		resolved["on_schema_change"] = cfg.SchemaChangePolicy()
+		if cfg.Strategy() == materialization.IncrementalMicrobatch {
+			resolved["batch_size"] = cfg.BatchSize
+			resolved["begin"] = cfg.Begin
+			resolved["lookback"] = cfg.Lookback
+		}
+	}
+	if cfg.Type == materialization.MaterializationSnapshot {
+		resolved["strategy"] = cfg.SnapshotStrategy
+		if cfg.SnapshotStrategy == materialization.SnapshotTimestamp {
+			resolved["updated_at"] = cfg.UpdatedAt
+		} else {
+			resolved["check_cols"] = cfg.CheckCols
+		}
+		resolved["invalidate_hard_deletes"] = cfg.InvalidateHardDeletes
+	}
+	if cfg.EventTime != "" {
+		resolved["event_time"] = cfg.EventTime
+	}
+	resolved["indexes"] = cfg.Indexes
+	if cfg.Atomic {
+		resolved["atomic"] = cfg.Atomic
+	}
+	if cfg.Contract.Enforced {
+		resolved["contract"] = contractConfig(cfg.Contract)
+	}
+	resolved["pre_hook"] = cfg.PreHooks
+	resolved["post_hook"] = cfg.PostHooks
+	if model.Alias != "" {
+		resolved["alias"] = model.Alias
+	}
+
+	return resolved
+}
+
+// contractConfig returns an enforced contract as resolved config. The
+// declared columns and constraints are included, since they change the DDL
+// the table is built with.
+func contractConfig(contract materialization.Contract) map[string]interface{} {
+	columns := make([]map[string]interface{}, 0, len(contract.Columns))
+	for _, column := range contract.Columns {
+		columns = append(columns, map[string]interface{}{
+			"name":        column.Name,
+			"data_type":   column.DataType,
+			"constraints": constraintConfigs(column.Constraints),
+		})
+	}
+
+	return map[string]interface{}{
+		"enforced":    true,
+		"columns":     columns,
+		"constraints": constraintConfigs(contract.Constraints),
+	}
+}
+
+// constraintConfigs returns contract constraints as resolved config
+func constraintConfigs(constraints []materialization.Constraint) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(constraints))
+	for _, constraint := range constraints {
+		entry := map[string]interface{}{"type": constraint.Type}
+		if constraint.Expression != "" {
+			entry["expression"] = constraint.Expression
+		}
+		if len(constraint.Columns) > 0 {
+			entry["columns"] = constraint.Columns
+		}
+		result = append(result, entry)
+	}
+	return result
+}
@@ -0,0 +1,21 @@
+package manifest
+
+import "testing"
+
+func TestNormalizeLegacyConfig(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{{ config(materialized='view') }}`, `{{ config "materialized" "view" }}`},
+		{`{{ config(materialized="incremental", unique_key="id") }}`, `{{ config "materialized" "incremental" "unique_key" "id" }}`},
+		{`{{ config(enabled=false) }}`, `{{ config "enabled" false }}`},
+		{`{{ config "materialized" "table" }}`, `{{ config "materialized" "table" }}`},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeLegacyConfig(tt.input); got != tt.want {
+			t.Errorf("normalizeLegacyConfig(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
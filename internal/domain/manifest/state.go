@@ -0,0 +1,211 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/dag"
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+)
+
+// States of a model compared to a previous manifest
+const (
+	// StateNew marks a model absent from the previous manifest
+	StateNew = "new"
+
+	// StateModified marks a model whose SQL, config or upstream models changed
+	StateModified = "modified"
+)
+
+// State is the manifest of a previous invocation, typically the production
+// artifacts, used for state: selection and deferral
+type State struct {
+	// ProjectName is the name of the project the manifest was built from
+	ProjectName string
+
+	// AdapterType and Database identify the target the state was built into
+	AdapterType string
+	Database    string
+
+	// Models holds the models of the previous manifest keyed by name
+	Models map[string]*StateModel
+}
+
+// StateModel is a model as recorded in a previous manifest
+type StateModel struct {
+	// Checksum is the checksum of the model's template
+	Checksum string
+
+	// Config is the model's resolved configuration
+	Config map[string]interface{}
+
+	// Relation is the relation the model was built into
+	Relation string
+
+	// DependsOn holds the unique IDs of the model's parents
+	DependsOn []string
+}
+
+// ReadState reads the manifest.json in the given directory
+func ReadState(dir string) (*State, error) {
+	path := filepath.Join(dir, "manifest.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state manifest: %w", err)
+	}
+
+	var previous manifestJSON
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("failed to parse state manifest %s: %w", path, err)
+	}
+
+	state := &State{
+		ProjectName: previous.Metadata.ProjectName,
+		AdapterType: previous.Metadata.AdapterType,
+		Database:    previous.Metadata.Database,
+		Models:      make(map[string]*StateModel),
+	}
+
+	for _, node := range previous.Nodes {
+		if node.ResourceType != "model" && node.ResourceType != "snapshot" {
+			continue
+		}
+		state.Models[node.Name] = &StateModel{
+			Checksum:  node.Checksum,
+			Config:    node.Config,
+			Relation:  node.Relation,
+			DependsOn: node.DependsOn,
+		}
+	}
+
+	return state, nil
+}
+
+// SetState compares the manifest's models with a previous state, enabling
+// the state:new and state:modified selectors and deferral
+func (m *Manifest) SetState(state *State) error {
+	m.state = state
+	m.stateStatus = make(map[string]string)
+
+	for _, model := range m.Models {
+		previous, ok := state.Models[model.ID]
+		if !ok {
+			m.stateStatus[model.ID] = StateNew
+			continue
+		}
+
+		modified, err := m.modifiedSince(model, previous)
+		if err != nil {
+			return err
+		}
+		if modified {
+			m.stateStatus[model.ID] = StateModified
+		}
+	}
+
+	return nil
+}
+
+// State returns the previous state set with SetState, or nil
+func (m *Manifest) State() *State {
+	return m.state
+}
+
+// StateStatus returns StateNew or StateModified for a model that differs
+// from the state, and an empty string otherwise
+func (m *Manifest) StateStatus(modelID string) string {
+	return m.stateStatus[modelID]
+}
+
+// modifiedSince reports whether a model's SQL, resolved config or list of
+// upstream models differ from the previous state. Changes to the SQL of an
+// upstream model are not detected here; state:modified+ selects the models
+// downstream of it.
+func (m *Manifest) modifiedSince(model *executor.Model, previous *StateModel) (bool, error) {
+	if Checksum(model) != previous.Checksum {
+		return true, nil
+	}
+
+	// Compare configs through their JSON form, as the state was read from JSON
+	current, err := json.Marshal(ResolvedConfig(model))
+	if err != nil {
+		return false, fmt.Errorf("failed to encode config of %s: %w", model.ID, err)
+	}
+	recorded, err := json.Marshal(previous.Config)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode state config of %s: %w", model.ID, err)
+	}
+	if !bytes.Equal(current, recorded) {
+		return true, nil
+	}
+
+	parents := m.parentIDs(model)
+	recordedParents := append([]string{}, previous.DependsOn...)
+	sort.Strings(recordedParents)
+	if len(parents) != len(recordedParents) {
+		return true, nil
+	}
+	for i := range parents {
+		if parents[i] != recordedParents[i] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Defer resolves ref() to models that are not selected to their relation in
+// the state's target, qualified with schema when it is not empty, and
+// recompiles the selected models accordingly.
+// Models that are not in the state keep their own relation.
+func (m *Manifest) Defer(selected []*executor.Model, schema string) error {
+	if m.state == nil {
+		return fmt.Errorf("--defer requires --state")
+	}
+
+	isSelected := make(map[string]bool, len(selected))
+	for _, model := range selected {
+		isSelected[model.ID] = true
+	}
+
+	m.deferred = make(map[string]string)
+	for _, model := range m.Models {
+		previous, ok := m.state.Models[model.ID]
+		if isSelected[model.ID] || !ok || previous.Relation == "" {
+			continue
+		}
+		relation := previous.Relation
+		if schema != "" {
+			relation = schema + "." + relation
+		}
+		m.deferred[model.ID] = relation
+	}
+
+	return m.compileModels(selected)
+}
+
+// parentIDs returns the sorted unique IDs of a model's parents
+func (m *Manifest) parentIDs(model *executor.Model) []string {
+	parents := make([]string, 0, len(model.Dependencies))
+	for _, dep := range model.Dependencies {
+		parents = append(parents, m.ModelUniqueID(dep))
+	}
+	for _, seedID := range m.SeedRefs(model.ID) {
+		parents = append(parents, m.seedUniqueID(seedID))
+	}
+	sort.Strings(parents)
+	return parents
+}
+
+// usesStateMethod reports whether a selector expression uses state: criteria
+func usesStateMethod(expr string) bool {
+	selector, err := dag.ParseSelector(expr)
+	if err != nil {
+		return false
+	}
+	return selector.UsesMethod("state")
+}
@@ -0,0 +1,192 @@
+package manifest
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeState builds a manifest from the given files, writes it and reads it back as a state
+func writeState(t *testing.T, files map[string]string) *State {
+	t.Helper()
+
+	m, err := Build(writeProject(t, files), BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := m.Write(filepath.Join(dir, "manifest.json")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	state, err := ReadState(dir)
+	if err != nil {
+		t.Fatalf("ReadState() error = %v", err)
+	}
+	return state
+}
+
+func TestManifestState(t *testing.T) {
+	state := writeState(t, map[string]string{
+		"models/stg_orders.sql":       `SELECT 1 AS id`,
+		"models/marts/fct_orders.sql": `SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/marts/report.sql":     `SELECT * FROM {{ ref "fct_orders" }}`,
+	})
+
+	// stg_orders changes its SQL, report its config, and extra is added
+	m, err := Build(writeProject(t, map[string]string{
+		"models/stg_orders.sql":       `SELECT 2 AS id`,
+		"models/marts/fct_orders.sql": `SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/marts/report.sql":     `{{ config "materialized" "view" }}SELECT * FROM {{ ref "fct_orders" }}`,
+		"models/extra.sql":            `SELECT 3 AS id`,
+	}), BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if _, err := m.Select("state:modified", ""); err == nil || !strings.Contains(err.Error(), "--state") {
+		t.Errorf("Select() without state error = %v, want error mentioning --state", err)
+	}
+
+	if err := m.SetState(state); err != nil {
+		t.Fatalf("SetState() error = %v", err)
+	}
+
+	statuses := map[string]string{
+		"stg_orders": StateModified,
+		"fct_orders": "",
+		"report":     StateModified,
+		"extra":      StateNew,
+	}
+	for id, want := range statuses {
+		if got := m.StateStatus(id); got != want {
+			t.Errorf("StateStatus(%s) = %q, want %q", id, got, want)
+		}
+	}
+
+	tests := []struct {
+		include string
+		want    []string
+	}{
+		{"state:new", []string{"extra"}},
+		{"state:modified", []string{"extra", "report", "stg_orders"}},
+		{"state:modified+", []string{"extra", "fct_orders", "report", "stg_orders"}},
+	}
+	for _, tt := range tests {
+		models, err := m.Select(tt.include, "")
+		if err != nil {
+			t.Fatalf("Select(%q) error = %v", tt.include, err)
+		}
+
+		var ids []string
+		for _, model := range models {
+			ids = append(ids, model.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Select(%q) = %v, want %v", tt.include, ids, tt.want)
+		}
+	}
+}
+
+// TestManifestStateContract checks that changing a contract column or
+// constraint in a schema file makes the model modified
+func TestManifestStateContract(t *testing.T) {
+	schemaFile := func(dataType, constraint string) string {
+		return `version: 2
+models:
+  - name: orders
+    config:
+      contract: {enforced: true}
+    columns:
+      - name: id
+        data_type: ` + dataType + `
+        constraints:
+          - type: ` + constraint + `
+`
+	}
+	model := `{{ config "materialized" "table" }}SELECT 1 AS id`
+
+	state := writeState(t, map[string]string{
+		"models/orders.sql": model,
+		"models/schema.yml": schemaFile("integer", "not_null"),
+	})
+
+	tests := []struct {
+		name     string
+		schema   string
+		modified bool
+	}{
+		{"unchanged", schemaFile("integer", "not_null"), false},
+		{"data type changed", schemaFile("text", "not_null"), true},
+		{"constraint changed", schemaFile("integer", "primary_key"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Build(writeProject(t, map[string]string{
+				"models/orders.sql": model,
+				"models/schema.yml": tt.schema,
+			}), BuildOptions{})
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if err := m.SetState(state); err != nil {
+				t.Fatalf("SetState() error = %v", err)
+			}
+			if got := m.StateStatus("orders") == StateModified; got != tt.modified {
+				t.Errorf("orders modified = %v, want %v", got, tt.modified)
+			}
+		})
+	}
+}
+
+func TestManifestDefer(t *testing.T) {
+	files := map[string]string{
+		"models/stg_orders.sql":       `SELECT 1 AS id`,
+		"models/marts/fct_orders.sql": `SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/marts/report.sql":     `SELECT * FROM {{ ref "fct_orders" }} JOIN {{ ref "extra" }}`,
+		"models/extra.sql":            `SELECT 3 AS id`,
+	}
+	state := writeState(t, map[string]string{
+		"models/stg_orders.sql":       files["models/stg_orders.sql"],
+		"models/marts/fct_orders.sql": files["models/marts/fct_orders.sql"],
+	})
+
+	m, err := Build(writeProject(t, files), BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if err := m.Defer(nil, ""); err == nil {
+		t.Error("Defer() without state succeeded, want error")
+	}
+
+	if err := m.SetState(state); err != nil {
+		t.Fatalf("SetState() error = %v", err)
+	}
+
+	selected, err := m.Select("report extra", "")
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if err := m.Defer(selected, "state"); err != nil {
+		t.Fatalf("Defer() error = %v", err)
+	}
+
+	relations := m.Relations()
+	want := map[string]string{
+		"stg_orders": "state.stg_orders",
+		"fct_orders": "state.fct_orders",
+		"report":     "report",
+		"extra":      "extra",
+	}
+	if !reflect.DeepEqual(relations, want) {
+		t.Errorf("Relations() = %v, want %v", relations, want)
+	}
+
+	report, _ := m.Model("report")
+	if want := "SELECT * FROM state.fct_orders JOIN extra"; report.CompiledSQL != want {
+		t.Errorf("report CompiledSQL = %q, want %q", report.CompiledSQL, want)
+	}
+}
@@ -0,0 +1,224 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jpconstantineau/gorchata/internal/domain/executor"
+)
+
+// DefaultPath is where commands write the manifest, relative to the project root
+const DefaultPath = "target/manifest.json"
+
+// manifestJSON is the serialized form of a manifest.
+// Nodes are keyed by unique ID: "<resource type>.<project>.<name>".
+type manifestJSON struct {
+	Metadata  metadataJSON           `json:"metadata"`
+	Nodes     map[string]nodeJSON    `json:"nodes"`
+	Sources   map[string]sourceJSON  `json:"sources"`
+	ParentMap map[string][]string    `json:"parent_map"`
+	ChildMap  map[string][]string    `json:"child_map"`
+	Vars      map[string]interface{} `json:"vars"`
+}
+
+type metadataJSON struct {
+	ProjectName string    `json:"project_name"`
+	GeneratedAt time.Time `json:"generated_at"`
+	AdapterType string    `json:"adapter_type,omitempty"`
+	Database    string    `json:"database,omitempty"`
+}
+
+type nodeJSON struct {
+	UniqueID     string                 `json:"unique_id"`
+	ResourceType string                 `json:"resource_type"`
+	Name         string                 `json:"name"`
+	FQN          []string               `json:"fqn,omitempty"`
+	Path         string                 `json:"original_file_path,omitempty"`
+	Relation     string                 `json:"relation_name,omitempty"`
+	Config       map[string]interface{} `json:"config,omitempty"`
+	Tags         []string               `json:"tags,omitempty"`
+	DependsOn    []string               `json:"depends_on"`
+	RawCode      string                 `json:"raw_code,omitempty"`
+	CompiledCode string                 `json:"compiled_code,omitempty"`
+	Checksum     string                 `json:"checksum,omitempty"`
+}
+
+type sourceJSON struct {
+	UniqueID    string `json:"unique_id"`
+	SourceName  string `json:"source_name"`
+	Name        string `json:"name"`
+	Identifier  string `json:"identifier"`
+	Schema      string `json:"schema,omitempty"`
+	Description string `json:"description,omitempty"`
+	EventTime   string `json:"event_time,omitempty"`
+	Path        string `json:"original_file_path"`
+	Relation    string `json:"relation_name"`
+}
+
+// ModelUniqueID returns the unique ID of a model or snapshot in the manifest
+func (m *Manifest) ModelUniqueID(modelID string) string {
+	if model, ok := m.Model(modelID); ok {
+		return fmt.Sprintf("%s.%s.%s", resourceType(model), m.ProjectName, modelID)
+	}
+	return fmt.Sprintf("model.%s.%s", m.ProjectName, modelID)
+}
+
+// resourceType returns the resource type of a model: snapshot or model
+func resourceType(model *executor.Model) string {
+	if model.IsSnapshot() {
+		return "snapshot"
+	}
+	return "model"
+}
+
+// seedUniqueID returns the unique ID of a seed in the manifest
+func (m *Manifest) seedUniqueID(seedID string) string {
+	return fmt.Sprintf("seed.%s.%s", m.ProjectName, seedID)
+}
+
+// nodeRelation returns the relation a model is built into, or nothing for
+// ephemeral models which are never built
+func nodeRelation(model *executor.Model) string {
+	if model.IsEphemeral() {
+		return ""
+	}
+	return model.Relation()
+}
+
+// Checksum returns the SHA-256 checksum of a model's template, used to detect changes
+func Checksum(model *executor.Model) string {
+	sum := sha256.Sum256([]byte(model.TemplateContent))
+	return hex.EncodeToString(sum[:])
+}
+
+// MarshalJSON serializes the manifest
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	out := manifestJSON{
+		Metadata: metadataJSON{
+			ProjectName: m.ProjectName,
+			GeneratedAt: m.GeneratedAt,
+			AdapterType: m.AdapterType,
+			Database:    m.Database,
+		},
+		Nodes:     make(map[string]nodeJSON),
+		Sources:   make(map[string]sourceJSON),
+		ParentMap: make(map[string][]string),
+		ChildMap:  make(map[string][]string),
+		Vars:      m.Vars,
+	}
+
+	modelIDs := make(map[string]string, len(m.Models))
+	for _, model := range m.Models {
+		modelIDs[model.ID] = m.ModelUniqueID(model.ID)
+	}
+
+	for _, seed := range m.Seeds {
+		uniqueID := m.seedUniqueID(seed.ID)
+		out.Nodes[uniqueID] = nodeJSON{
+			UniqueID:     uniqueID,
+			ResourceType: "seed",
+			Name:         seed.ID,
+			FQN:          []string{m.ProjectName, seed.ID},
+			Path:         seed.Path,
+			Relation:     seed.ResolvedTableName,
+			DependsOn:    []string{},
+		}
+		out.ParentMap[uniqueID] = []string{}
+	}
+
+	for _, source := range m.Sources {
+		uniqueID := fmt.Sprintf("source.%s.%s.%s", m.ProjectName, source.SourceName, source.Name)
+		out.Sources[uniqueID] = sourceJSON{
+			UniqueID:    uniqueID,
+			SourceName:  source.SourceName,
+			Name:        source.Name,
+			Identifier:  source.Identifier,
+			Schema:      source.Schema,
+			Description: source.Description,
+			EventTime:   source.EventTime,
+			Path:        source.Path,
+			Relation:    source.Relation(),
+		}
+	}
+
+	for _, model := range m.Models {
+		uniqueID := modelIDs[model.ID]
+
+		dependsOn := m.parentIDs(model)
+
+		out.Nodes[uniqueID] = nodeJSON{
+			UniqueID:     uniqueID,
+			ResourceType: resourceType(model),
+			Name:         model.ID,
+			FQN:          model.FQN,
+			Path:         model.Path,
+			Relation:     nodeRelation(model),
+			Config:       ResolvedConfig(model),
+			Tags:         model.Tags,
+			DependsOn:    dependsOn,
+			RawCode:      model.TemplateContent,
+			CompiledCode: model.CompiledSQL,
+			Checksum:     Checksum(model),
+		}
+		out.ParentMap[uniqueID] = dependsOn
+	}
+
+	for _, t := range m.Tests {
+		uniqueID := fmt.Sprintf("test.%s.%s", m.ProjectName, t.ID)
+
+		dependsOn := []string{}
+		if modelID, ok := modelIDs[t.ModelName]; ok {
+			dependsOn = append(dependsOn, modelID)
+		}
+
+		out.Nodes[uniqueID] = nodeJSON{
+			UniqueID:     uniqueID,
+			ResourceType: "test",
+			Name:         t.Name,
+			DependsOn:    dependsOn,
+			RawCode:      t.SQLTemplate,
+		}
+		out.ParentMap[uniqueID] = dependsOn
+	}
+
+	// The child map is the inverse of the parent map; every node has an entry
+	for uniqueID := range out.ParentMap {
+		if _, ok := out.ChildMap[uniqueID]; !ok {
+			out.ChildMap[uniqueID] = []string{}
+		}
+	}
+	for uniqueID, parents := range out.ParentMap {
+		for _, parent := range parents {
+			out.ChildMap[parent] = append(out.ChildMap[parent], uniqueID)
+		}
+	}
+	for parent := range out.ChildMap {
+		sort.Strings(out.ChildMap[parent])
+	}
+
+	return json.Marshal(out)
+}
+
+// Write writes the manifest as indented JSON, creating the parent directory if needed
+func (m *Manifest) Write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifestWrite(t *testing.T) {
+	cfg := writeProject(t, map[string]string{
+		"models/stg_orders.sql": `SELECT * FROM {{ source "raw" "orders" }} JOIN {{ ref "countries" }}`,
+		"models/fct_orders.sql": `{{ config "materialized" "view" "tags" "daily" }}SELECT * FROM {{ ref "stg_orders" }}`,
+		"models/sources.yml": `version: 2
+sources:
+  - name: raw
+    tables:
+      - name: orders
+`,
+		"seeds/countries.csv": "code,name\nCA,Canada\n",
+	})
+
+	m, err := Build(cfg, BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "target", "manifest.json")
+	if err := m.Write(path); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written manifestJSON
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("invalid manifest JSON: %v", err)
+	}
+
+	fct, ok := written.Nodes["model.shop.fct_orders"]
+	if !ok {
+		t.Fatalf("model.shop.fct_orders missing from nodes")
+	}
+	if fct.Config["materialized"] != "view" {
+		t.Errorf("fct_orders materialized = %v, want view", fct.Config["materialized"])
+	}
+	if !reflect.DeepEqual(fct.Tags, []string{"daily"}) {
+		t.Errorf("fct_orders tags = %v, want [daily]", fct.Tags)
+	}
+	if fct.Checksum == "" {
+		t.Error("fct_orders checksum is empty")
+	}
+
+	if seed := written.Nodes["seed.shop.countries"]; seed.ResourceType != "seed" {
+		t.Errorf("seed.shop.countries resource_type = %q, want seed", seed.ResourceType)
+	}
+
+	if source := written.Sources["source.shop.raw.orders"]; source.Relation != "orders" {
+		t.Errorf("source relation_name = %q, want orders", source.Relation)
+	}
+
+	wantParents := []string{"seed.shop.countries"}
+	if got := written.ParentMap["model.shop.stg_orders"]; !reflect.DeepEqual(got, wantParents) {
+		t.Errorf("stg_orders parents = %v, want %v", got, wantParents)
+	}
+
+	wantChildren := []string{"model.shop.fct_orders"}
+	if got := written.ChildMap["model.shop.stg_orders"]; !reflect.DeepEqual(got, wantChildren) {
+		t.Errorf("stg_orders children = %v, want %v", got, wantChildren)
+	}
+}
@@ -10,6 +10,12 @@ const (
 	MaterializationTable MaterializationType = "table"
 	// MaterializationIncremental creates an incrementally updated table
 	MaterializationIncremental MaterializationType = "incremental"
+	// MaterializationEphemeral is never built: the model is inlined as a CTE
+	// into the models that ref() it
+	MaterializationEphemeral MaterializationType = "ephemeral"
+	// MaterializationSnapshot records the history of rows in a slowly changing
+	// dimension (type 2) table; it is the materialization of snapshots
+	MaterializationSnapshot MaterializationType = "snapshot"
 )
 
 // MaterializationConfig holds configuration for how a model should be materialized
@@ -17,27 +23,152 @@ type MaterializationConfig struct {
 	// Type specifies the materialization strategy (view, table, incremental)
 	Type MaterializationType
 
-	// UniqueKey is required for incremental materialization
+	// UniqueKey is required for the delete+insert and merge incremental
+	// strategies and for snapshots
 	// It specifies the column(s) used to identify unique rows
 	UniqueKey []string
 
+	// IncrementalStrategy selects how new rows are applied to an existing
+	// incremental table; empty means DefaultIncrementalStrategy
+	IncrementalStrategy string
+
+	// MergeUpdateColumns restricts the columns updated by the merge strategy
+	MergeUpdateColumns []string
+
+	// MergeExcludeColumns are left untouched by the merge strategy
+	MergeExcludeColumns []string
+
+	// PartitionBy holds the partition column(s) replaced by the insert_overwrite strategy
+	PartitionBy []string
+
+	// EventTime is the column holding the time of each row. It is required
+	// by the microbatch strategy, and filters the model when it is ref'd by a
+	// microbatch model.
+	EventTime string
+
+	// BatchSize is the time span of a microbatch: hour, day or month
+	BatchSize string
+
+	// Begin is the event time the first microbatch starts at
+	Begin string
+
+	// Lookback is the number of batches before the current one that an
+	// incremental microbatch run reprocesses, to pick up late rows
+	Lookback int
+
+	// Batch is the microbatch being built. The engine sets it for each batch.
+	Batch *Batch
+
+	// SnapshotStrategy selects how a snapshot detects changed rows:
+	// timestamp or check
+	SnapshotStrategy string
+
+	// UpdatedAt is the column the timestamp snapshot strategy compares
+	UpdatedAt string
+
+	// CheckCols are the columns the check snapshot strategy compares, or "all"
+	CheckCols []string
+
+	// InvalidateHardDeletes closes the current version of snapshot rows that
+	// are no longer returned by the snapshot's query
+	InvalidateHardDeletes bool
+
+	// SnapshotTimestamp is the time of the snapshot, formatted with
+	// SnapshotTimeFormat. The engine sets it when a snapshot runs.
+	SnapshotTimestamp string
+
+	// OnSchemaChange decides what happens when the columns of an incremental
+	// model's query differ from its table; empty means DefaultOnSchemaChange
+	OnSchemaChange string
+
+	// Columns are the columns returned by the model's query. The engine fills
+	// them in for strategies that need them (see NeedsColumns).
+	Columns []string
+
+	// TargetColumns are the columns of the existing table once schema changes
+	// are applied. The engine fills them in when the table exists; rows are
+	// then inserted into the columns the query and the table have in common.
+	TargetColumns []string
+
+	// ExistingRelation is the kind of relation the database already holds
+	// under the model's name, RelationTable or RelationView, or empty when it
+	// holds none. The engine fills it in; the old relation is dropped with the
+	// matching statement when it is replaced.
+	ExistingRelation string
+
 	// FullRefresh forces a full refresh even for incremental models
 	FullRefresh bool
 
+	// Atomic runs the model's hooks and statements in one transaction, so
+	// that a model that fails leaves no change behind
+	Atomic bool
+
+	// Contract declares the columns, types and constraints of the model's
+	// table; when enforced, the table is created from them
+	Contract Contract
+
 	// PreHooks are SQL statements to execute before materialization
 	PreHooks []string
 
 	// PostHooks are SQL statements to execute after materialization
 	PostHooks []string
+
+	// Indexes are created on table and incremental materializations
+	Indexes []IndexConfig
+}
+
+// Strategy returns the incremental strategy, defaulting to DefaultIncrementalStrategy
+func (c MaterializationConfig) Strategy() string {
+	if c.IncrementalStrategy == "" {
+		return DefaultIncrementalStrategy
+	}
+	return c.IncrementalStrategy
+}
+
+// SchemaChangePolicy returns the on_schema_change policy, defaulting to
+// DefaultOnSchemaChange. Snapshots always add the new columns of their query.
+func (c MaterializationConfig) SchemaChangePolicy() string {
+	if c.Type == MaterializationSnapshot {
+		return OnSchemaChangeAppendNewColumns
+	}
+	if c.OnSchemaChange == "" {
+		return DefaultOnSchemaChange
+	}
+	return c.OnSchemaChange
+}
+
+// NeedsColumns reports whether materializing requires the columns of the
+// model's query. Incremental models insert into an explicit column list so
+// that they keep working when their query and their table drift apart, and
+// so do snapshots.
+func (c MaterializationConfig) NeedsColumns() bool {
+	return (c.Type == MaterializationIncremental && !c.FullRefresh) || c.Type == MaterializationSnapshot
+}
+
+// IndexConfig describes an index to create on a materialized table
+type IndexConfig struct {
+	// Name is the index name; generated from the table and columns when empty
+	Name string `json:"name,omitempty"`
+
+	// Columns are the indexed columns, in order
+	Columns []string `json:"columns"`
+
+	// Unique creates a UNIQUE index
+	Unique bool `json:"unique"`
 }
 
 // DefaultConfig returns a MaterializationConfig with sensible defaults
 func DefaultConfig() MaterializationConfig {
 	return MaterializationConfig{
-		Type:        MaterializationTable,
-		FullRefresh: false,
-		UniqueKey:   []string{},
-		PreHooks:    []string{},
-		PostHooks:   []string{},
+		Type:                MaterializationTable,
+		FullRefresh:         false,
+		UniqueKey:           []string{},
+		MergeUpdateColumns:  []string{},
+		MergeExcludeColumns: []string{},
+		PartitionBy:         []string{},
+		CheckCols:           []string{},
+		PreHooks:            []string{},
+		PostHooks:           []string{},
+		Indexes:             []IndexConfig{},
 	}
 }
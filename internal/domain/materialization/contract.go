@@ -0,0 +1,318 @@
+package materialization
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Constraint types of contract columns and models
+const (
+	ConstraintNotNull    = "not_null"
+	ConstraintPrimaryKey = "primary_key"
+	ConstraintUnique     = "unique"
+	ConstraintCheck      = "check"
+	ConstraintForeignKey = "foreign_key"
+)
+
+// Contract declares the columns, types and constraints of a model's table.
+// When it is enforced, the table is created from the declared DDL and the
+// model fails if its query does not return the declared columns and types.
+type Contract struct {
+	// Enforced turns the contract on
+	Enforced bool
+
+	// Columns are the declared columns, in table order
+	Columns []ContractColumn
+
+	// Constraints are the constraints on several columns of the table
+	Constraints []Constraint
+}
+
+// ContractColumn is a column declared by a contract
+type ContractColumn struct {
+	Name        string
+	DataType    string
+	Constraints []Constraint
+}
+
+// Constraint is a constraint of a contract column or table
+type Constraint struct {
+	// Type is one of the Constraint* constants
+	Type string
+
+	// Expression is the condition of a check constraint, or the table and
+	// columns a foreign key references, as in "customers (id)"
+	Expression string
+
+	// Columns are the columns of a table constraint
+	Columns []string
+}
+
+// strictTypes are the column types SQLite allows in STRICT tables
+var strictTypes = map[string]bool{"INT": true, "INTEGER": true, "REAL": true, "TEXT": true, "BLOB": true, "ANY": true}
+
+// foreignKeyRe matches the "table (column, ...)" reference of a foreign key
+var foreignKeyRe = regexp.MustCompile(`^\s*([\w.]+)\s*\(([^)]*)\)\s*$`)
+
+// Validate checks that every column has a type and that the constraints are
+// complete
+func (c Contract) Validate() error {
+	if len(c.Columns) == 0 {
+		return fmt.Errorf("contract is enforced but no columns are declared")
+	}
+
+	for _, column := range c.Columns {
+		if strings.TrimSpace(column.DataType) == "" {
+			return fmt.Errorf("contract column %s has no data_type", column.Name)
+		}
+		for _, constraint := range column.Constraints {
+			if len(constraint.Columns) > 0 {
+				return fmt.Errorf("constraint %s of column %s cannot have columns", constraint.Type, column.Name)
+			}
+			if err := constraint.validate(); err != nil {
+				return fmt.Errorf("column %s: %w", column.Name, err)
+			}
+		}
+	}
+
+	for _, constraint := range c.Constraints {
+		if constraint.Type == ConstraintNotNull {
+			return fmt.Errorf("not_null is a column constraint")
+		}
+		if len(constraint.Columns) == 0 && constraint.Type != ConstraintCheck {
+			return fmt.Errorf("constraint %s of the model requires columns", constraint.Type)
+		}
+		if err := constraint.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the type of a constraint and its expression
+func (c Constraint) validate() error {
+	switch c.Type {
+	case ConstraintNotNull, ConstraintPrimaryKey, ConstraintUnique:
+		return nil
+	case ConstraintCheck:
+		if strings.TrimSpace(c.Expression) == "" {
+			return fmt.Errorf("check constraint requires an expression")
+		}
+		return nil
+	case ConstraintForeignKey:
+		if !foreignKeyRe.MatchString(c.Expression) {
+			return fmt.Errorf("foreign_key constraint requires an expression like \"customers (id)\", got %q", c.Expression)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown constraint type %q (expected %s, %s, %s, %s or %s)", c.Type,
+			ConstraintNotNull, ConstraintPrimaryKey, ConstraintUnique, ConstraintCheck, ConstraintForeignKey)
+	}
+}
+
+// Check compares the columns of a model's query with the contract and
+// returns an error listing every difference: declared columns the query
+// doesn't return (-), columns the contract doesn't declare (+) and columns
+// whose types differ (~). Types are compared by SQLite type affinity;
+// columns computed by expressions without a type match any type.
+func (c Contract) Check(queryColumns []Column) error {
+	returned := make(map[string]Column, len(queryColumns))
+	for _, column := range queryColumns {
+		returned[strings.ToLower(column.Name)] = column
+	}
+	declared := make(map[string]bool, len(c.Columns))
+
+	var diff []string
+	for _, column := range c.Columns {
+		declared[strings.ToLower(column.Name)] = true
+		queryColumn, ok := returned[strings.ToLower(column.Name)]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("  - %s %s: not returned by the query", column.Name, column.DataType))
+		case queryColumn.Type != "" && typeAffinity(queryColumn.Type) != typeAffinity(column.DataType) && !strings.EqualFold(column.DataType, "ANY"):
+			diff = append(diff, fmt.Sprintf("  ~ %s: contract type %s, query type %s", column.Name, column.DataType, queryColumn.Type))
+		}
+	}
+	for _, column := range queryColumns {
+		if !declared[strings.ToLower(column.Name)] {
+			diff = append(diff, fmt.Sprintf("  + %s %s: not declared in the contract", column.Name, column.Type))
+		}
+	}
+
+	if len(diff) > 0 {
+		return fmt.Errorf("query does not match the contract:\n%s", strings.Join(diff, "\n"))
+	}
+	return nil
+}
+
+// typeAffinity returns the SQLite affinity of a declared column type
+func typeAffinity(dataType string) string {
+	t := strings.ToUpper(dataType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case strings.Contains(t, "BLOB"), t == "":
+		return "BLOB"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "REAL"
+	default:
+		return "NUMERIC"
+	}
+}
+
+// Strict reports whether the table of the contract is created as a SQLite
+// STRICT table, which rejects values of the wrong type. That is the case
+// when every declared type is one STRICT tables allow.
+func (c Contract) Strict() bool {
+	for _, column := range c.Columns {
+		if !strictTypes[strings.ToUpper(strings.TrimSpace(column.DataType))] {
+			return false
+		}
+	}
+	return true
+}
+
+// TableDDL returns the CREATE TABLE statement of the contract's table.
+// Foreign keys are not part of it: SQLite refuses to drop a table that rows
+// of another table reference, which would keep the referenced model from
+// being rebuilt. They are checked by ForeignKeyStatements instead.
+func (c Contract) TableDDL(table string) string {
+	var definitions []string
+	for _, column := range c.Columns {
+		definition := column.Name + " " + column.DataType
+		for _, constraint := range column.Constraints {
+			if ddl := constraint.columnDDL(); ddl != "" {
+				definition += " " + ddl
+			}
+		}
+		definitions = append(definitions, definition)
+	}
+	for _, constraint := range c.Constraints {
+		if ddl := constraint.tableDDL(); ddl != "" {
+			definitions = append(definitions, ddl)
+		}
+	}
+
+	ddl := fmt.Sprintf("CREATE TABLE %s (%s)", table, strings.Join(definitions, ", "))
+	if c.Strict() {
+		ddl += " STRICT"
+	}
+	return ddl
+}
+
+// columnDDL returns the column constraint clause of a constraint
+func (c Constraint) columnDDL() string {
+	switch c.Type {
+	case ConstraintNotNull:
+		return "NOT NULL"
+	case ConstraintPrimaryKey:
+		return "PRIMARY KEY"
+	case ConstraintUnique:
+		return "UNIQUE"
+	case ConstraintCheck:
+		return fmt.Sprintf("CHECK (%s)", c.Expression)
+	}
+	return ""
+}
+
+// tableDDL returns the table constraint clause of a constraint
+func (c Constraint) tableDDL() string {
+	switch c.Type {
+	case ConstraintPrimaryKey:
+		return fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(c.Columns, ", "))
+	case ConstraintUnique:
+		return fmt.Sprintf("UNIQUE (%s)", strings.Join(c.Columns, ", "))
+	case ConstraintCheck:
+		return fmt.Sprintf("CHECK (%s)", c.Expression)
+	}
+	return ""
+}
+
+// foreignKeys returns the foreign keys of the contract with the columns
+// they apply to
+func (c Contract) foreignKeys() []Constraint {
+	var keys []Constraint
+	for _, column := range c.Columns {
+		for _, constraint := range column.Constraints {
+			if constraint.Type == ConstraintForeignKey {
+				keys = append(keys, Constraint{Type: ConstraintForeignKey, Expression: constraint.Expression, Columns: []string{column.Name}})
+			}
+		}
+	}
+	for _, constraint := range c.Constraints {
+		if constraint.Type == ConstraintForeignKey {
+			keys = append(keys, constraint)
+		}
+	}
+	return keys
+}
+
+// ForeignKeyStatements returns statements that fail when rows of table
+// reference no row of the table their foreign key points to. Each foreign
+// key is a named CHECK constraint of a temporary table the number of
+// orphaned rows is inserted into, so that the error names the foreign key.
+func (c Contract) ForeignKeyStatements(table, checkTable string) []string {
+	keys := c.foreignKeys()
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var columns, counts []string
+	for i, key := range keys {
+		match := foreignKeyRe.FindStringSubmatch(key.Expression)
+		parent := match[1]
+		parentColumns := strings.Split(match[2], ",")
+
+		var conditions, notNull []string
+		for j, column := range key.Columns {
+			if j < len(parentColumns) {
+				conditions = append(conditions, fmt.Sprintf("p.%s = c.%s", strings.TrimSpace(parentColumns[j]), column))
+			}
+			notNull = append(notNull, fmt.Sprintf("c.%s IS NOT NULL", column))
+		}
+
+		name := fmt.Sprintf("foreign key (%s) references %s", strings.Join(key.Columns, ", "), strings.TrimSpace(key.Expression))
+		columns = append(columns, fmt.Sprintf("fk%d INTEGER CONSTRAINT \"%s\" CHECK (fk%d = 0)", i+1, name, i+1))
+		counts = append(counts, fmt.Sprintf("(SELECT COUNT(*) FROM %s c WHERE %s AND NOT EXISTS (SELECT 1 FROM %s p WHERE %s))",
+			table, strings.Join(notNull, " AND "), parent, strings.Join(conditions, " AND ")))
+	}
+
+	return []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS temp.%s", checkTable),
+		fmt.Sprintf("CREATE TEMP TABLE %s (%s)", checkTable, strings.Join(columns, ", ")),
+		fmt.Sprintf("INSERT INTO %s SELECT %s", checkTable, strings.Join(counts, ", ")),
+		fmt.Sprintf("DROP TABLE %s", checkTable),
+	}
+}
+
+// ColumnNames returns the names of the declared columns
+func (c Contract) ColumnNames() []string {
+	names := make([]string, len(c.Columns))
+	for i, column := range c.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// contractTableStatements builds a table from the contract's DDL under a
+// temporary name, inserts the rows of the query into the declared columns,
+// checks the foreign keys, then swaps the table into place. Everything runs
+// in one transaction, so a model that breaks its constraints leaves no
+// temporary table behind.
+func contractTableStatements(modelName, compiledSQL string, contract Contract, existing string) []string {
+	tmpName := modelName + SwapSuffix
+	columns := strings.Join(contract.ColumnNames(), ", ")
+
+	statements := []string{
+		BeginTransaction,
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", tmpName),
+		contract.TableDDL(tmpName),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM (%s)", tmpName, columns, columns, compiledSQL),
+	}
+	statements = append(statements, contract.ForeignKeyStatements(tmpName, unqualifiedName(modelName)+"__gorchata_fk")...)
+	statements = append(statements, replaceTableStatements(tmpName, modelName, existing)...)
+	return append(statements, CommitTransaction)
+}
@@ -0,0 +1,134 @@
+package materialization
+
+import (
+	"strings"
+	"testing"
+)
+
+// ordersContract declares an orders table referencing customers
+var ordersContract = Contract{
+	Enforced: true,
+	Columns: []ContractColumn{
+		{Name: "id", DataType: "integer", Constraints: []Constraint{{Type: ConstraintPrimaryKey}}},
+		{Name: "customer_id", DataType: "INTEGER", Constraints: []Constraint{{Type: ConstraintNotNull}, {Type: ConstraintForeignKey, Expression: "customers (id)"}}},
+		{Name: "amount", DataType: "REAL", Constraints: []Constraint{{Type: ConstraintCheck, Expression: "amount >= 0"}}},
+	},
+	Constraints: []Constraint{{Type: ConstraintUnique, Columns: []string{"customer_id", "amount"}}},
+}
+
+func TestContractValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		contract Contract
+		wantErr  string
+	}{
+		{"valid", ordersContract, ""},
+		{"no columns", Contract{Enforced: true}, "no columns are declared"},
+		{"no data type", Contract{Columns: []ContractColumn{{Name: "id"}}}, "column id has no data_type"},
+		{"unknown constraint", Contract{Columns: []ContractColumn{{Name: "id", DataType: "INT", Constraints: []Constraint{{Type: "positive"}}}}}, `unknown constraint type "positive"`},
+		{"check without expression", Contract{Columns: []ContractColumn{{Name: "id", DataType: "INT", Constraints: []Constraint{{Type: ConstraintCheck}}}}}, "check constraint requires an expression"},
+		{"foreign key without reference", Contract{Columns: []ContractColumn{{Name: "id", DataType: "INT", Constraints: []Constraint{{Type: ConstraintForeignKey, Expression: "customers"}}}}}, "foreign_key constraint requires an expression"},
+		{"model constraint without columns", Contract{Columns: []ContractColumn{{Name: "id", DataType: "INT"}}, Constraints: []Constraint{{Type: ConstraintPrimaryKey}}}, "constraint primary_key of the model requires columns"},
+		{"model not null", Contract{Columns: []ContractColumn{{Name: "id", DataType: "INT"}}, Constraints: []Constraint{{Type: ConstraintNotNull, Columns: []string{"id"}}}}, "not_null is a column constraint"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.contract.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContractCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		columns  []Column
+		wantDiff []string
+	}{
+		{
+			name:    "matching columns in another order",
+			columns: []Column{{Name: "amount", Type: "REAL"}, {Name: "ID", Type: "INT"}, {Name: "customer_id", Type: "INT"}},
+		},
+		{
+			name:    "expressions without a type match any type",
+			columns: []Column{{Name: "id", Type: "INT"}, {Name: "customer_id", Type: ""}, {Name: "amount", Type: ""}},
+		},
+		{
+			name:    "every difference",
+			columns: []Column{{Name: "id", Type: "TEXT"}, {Name: "amount", Type: "REAL"}, {Name: "note", Type: "TEXT"}},
+			wantDiff: []string{
+				"  ~ id: contract type integer, query type TEXT",
+				"  - customer_id INTEGER: not returned by the query",
+				"  + note TEXT: not declared in the contract",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ordersContract.Check(tt.columns)
+			if len(tt.wantDiff) == 0 {
+				if err != nil {
+					t.Errorf("Check() error = %v", err)
+				}
+				return
+			}
+			want := "query does not match the contract:\n" + strings.Join(tt.wantDiff, "\n")
+			if err == nil || err.Error() != want {
+				t.Errorf("Check() error = %v, want %s", err, want)
+			}
+		})
+	}
+}
+
+func TestContractTableDDL(t *testing.T) {
+	want := "CREATE TABLE orders (id integer PRIMARY KEY, customer_id INTEGER NOT NULL, amount REAL CHECK (amount >= 0), UNIQUE (customer_id, amount)) STRICT"
+	if got := ordersContract.TableDDL("orders"); got != want {
+		t.Errorf("TableDDL() =\n%s\nwant\n%s", got, want)
+	}
+
+	loose := Contract{Columns: []ContractColumn{{Name: "day", DataType: "DATE"}}}
+	if got := loose.TableDDL("days"); got != "CREATE TABLE days (day DATE)" {
+		t.Errorf("TableDDL() = %s, want a table that is not STRICT", got)
+	}
+}
+
+func TestTableMaterialize_Contract(t *testing.T) {
+	got, err := (&TableStrategy{}).Materialize("orders", "SELECT * FROM raw_orders", MaterializationConfig{Type: MaterializationTable, Contract: ordersContract})
+	if err != nil {
+		t.Fatalf("Materialize() error = %v", err)
+	}
+
+	want := []string{
+		"BEGIN",
+		"DROP TABLE IF EXISTS orders__gorchata_tmp",
+		"CREATE TABLE orders__gorchata_tmp (id integer PRIMARY KEY, customer_id INTEGER NOT NULL, amount REAL CHECK (amount >= 0), UNIQUE (customer_id, amount)) STRICT",
+		"INSERT INTO orders__gorchata_tmp (id, customer_id, amount) SELECT id, customer_id, amount FROM (SELECT * FROM raw_orders)",
+		"DROP TABLE IF EXISTS temp.orders__gorchata_fk",
+		`CREATE TEMP TABLE orders__gorchata_fk (fk1 INTEGER CONSTRAINT "foreign key (customer_id) references customers (id)" CHECK (fk1 = 0))`,
+		"INSERT INTO orders__gorchata_fk SELECT (SELECT COUNT(*) FROM orders__gorchata_tmp c WHERE c.customer_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM customers p WHERE p.id = c.customer_id))",
+		"DROP TABLE orders__gorchata_fk",
+		"PRAGMA legacy_alter_table = ON",
+		"DROP TABLE IF EXISTS orders",
+		"ALTER TABLE orders__gorchata_tmp RENAME TO orders",
+		"PRAGMA legacy_alter_table = OFF",
+		"COMMIT",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Materialize() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	invalid := MaterializationConfig{Type: MaterializationTable, Contract: Contract{Enforced: true}}
+	if _, err := (&TableStrategy{}).Materialize("orders", "SELECT 1", invalid); err == nil {
+		t.Error("Materialize() should fail for a contract without columns")
+	}
+}
@@ -0,0 +1,24 @@
+package materialization
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EphemeralStrategy creates nothing in the database: ephemeral models are
+// inlined as CTEs into the models that depend on them
+type EphemeralStrategy struct{}
+
+// Materialize returns no statements
+func (e *EphemeralStrategy) Materialize(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
+	if strings.TrimSpace(modelName) == "" {
+		return nil, fmt.Errorf("model name cannot be empty")
+	}
+
+	return []string{}, nil
+}
+
+// Name returns the strategy name
+func (e *EphemeralStrategy) Name() string {
+	return "ephemeral"
+}
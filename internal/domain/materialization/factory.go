@@ -1,29 +1,96 @@
 package materialization
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
 
-// GetStrategy returns the appropriate materialization strategy for the given type
-func GetStrategy(matType MaterializationType) (Strategy, error) {
-	switch matType {
-	case MaterializationView:
-		return &ViewStrategy{}, nil
-	case MaterializationTable:
-		return &TableStrategy{}, nil
-	case MaterializationIncremental:
-		return &IncrementalStrategy{}, nil
-	default:
+// Registry manages materialization strategies by name: the built-in
+// strategies and the custom ones a project defines
+type Registry struct {
+	mu         sync.RWMutex
+	strategies map[string]Strategy
+}
+
+// NewRegistry creates a new empty strategy registry
+func NewRegistry() *Registry {
+	return &Registry{
+		strategies: make(map[string]Strategy),
+	}
+}
+
+// NewDefaultRegistry creates a registry with the built-in strategies
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(string(MaterializationView), &ViewStrategy{})
+	r.Register(string(MaterializationTable), &TableStrategy{})
+	r.Register(string(MaterializationIncremental), &IncrementalStrategy{})
+	r.Register(string(MaterializationEphemeral), &EphemeralStrategy{})
+	r.Register(string(MaterializationSnapshot), &SnapshotStrategy{})
+	return r
+}
+
+// Register adds a strategy to the registry. A strategy registered under the
+// name of another one replaces it.
+func (r *Registry) Register(name string, strategy Strategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategies[name] = strategy
+}
+
+// Get retrieves a strategy by name
+func (r *Registry) Get(name string) (Strategy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	strategy, ok := r.strategies[name]
+	return strategy, ok
+}
+
+// List returns all registered strategy names sorted alphabetically
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.strategies))
+	for name := range r.strategies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Strategy returns the strategy of the given materialization type
+func (r *Registry) Strategy(matType MaterializationType) (Strategy, error) {
+	strategy, ok := r.Get(string(matType))
+	if !ok {
 		return nil, fmt.Errorf("unknown materialization type: %s", matType)
 	}
+	return strategy, nil
 }
 
-// GetStrategyFromConfig returns the appropriate strategy based on the config
-// If no type is specified, defaults to table materialization
-func GetStrategyFromConfig(config MaterializationConfig) (Strategy, error) {
-	// Default to table if no type specified
+// StrategyFromConfig returns the strategy based on the config.
+// If no type is specified, defaults to table materialization.
+func (r *Registry) StrategyFromConfig(config MaterializationConfig) (Strategy, error) {
 	matType := config.Type
 	if matType == "" {
 		matType = MaterializationTable
 	}
 
-	return GetStrategy(matType)
+	return r.Strategy(matType)
+}
+
+// builtins holds the built-in strategies returned by GetStrategy
+var builtins = NewDefaultRegistry()
+
+// GetStrategy returns the built-in materialization strategy for the given type
+func GetStrategy(matType MaterializationType) (Strategy, error) {
+	return builtins.Strategy(matType)
+}
+
+// GetStrategyFromConfig returns the appropriate strategy based on the config
+// If no type is specified, defaults to table materialization
+func GetStrategyFromConfig(config MaterializationConfig) (Strategy, error) {
+	return builtins.StrategyFromConfig(config)
 }
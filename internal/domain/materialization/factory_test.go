@@ -1,6 +1,7 @@
 package materialization
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -29,6 +30,18 @@ func TestGetStrategy(t *testing.T) {
 			wantStrategy: "incremental",
 			wantErr:      false,
 		},
+		{
+			name:         "returns ephemeral strategy",
+			matType:      MaterializationEphemeral,
+			wantStrategy: "ephemeral",
+			wantErr:      false,
+		},
+		{
+			name:         "returns snapshot strategy",
+			matType:      MaterializationSnapshot,
+			wantStrategy: "snapshot",
+			wantErr:      false,
+		},
 		{
 			name:         "returns error for unknown strategy",
 			matType:      MaterializationType("unknown"),
@@ -134,3 +147,56 @@ func TestDefaultStrategy(t *testing.T) {
 		t.Errorf("Default strategy should be 'table', got: %v", strategy.Name())
 	}
 }
+
+// customStrategy is a strategy registered under a name of its own
+type customStrategy struct{ name string }
+
+func (s *customStrategy) Materialize(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
+	return []string{"CREATE TABLE " + modelName + " AS " + compiledSQL}, nil
+}
+
+func (s *customStrategy) Name() string {
+	return s.name
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewDefaultRegistry()
+
+	want := []string{"ephemeral", "incremental", "snapshot", "table", "view"}
+	if got := registry.List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	registry.Register("rolling_window", &customStrategy{name: "rolling_window"})
+	registry.Register("table", &customStrategy{name: "my_table"})
+
+	tests := []struct {
+		name         string
+		config       MaterializationConfig
+		wantStrategy string
+		wantErr      bool
+	}{
+		{"custom strategy", MaterializationConfig{Type: "rolling_window"}, "rolling_window", false},
+		{"replaced built-in", MaterializationConfig{Type: MaterializationTable}, "my_table", false},
+		{"defaults to table", MaterializationConfig{}, "my_table", false},
+		{"built-in", MaterializationConfig{Type: MaterializationView}, "view", false},
+		{"unknown", MaterializationConfig{Type: "unknown"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := registry.StrategyFromConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StrategyFromConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && strategy.Name() != tt.wantStrategy {
+				t.Errorf("StrategyFromConfig() = %s, want %s", strategy.Name(), tt.wantStrategy)
+			}
+		})
+	}
+
+	// The built-in strategies of GetStrategy are not affected
+	if strategy, err := GetStrategy(MaterializationTable); err != nil || strategy.Name() != "table" {
+		t.Errorf("GetStrategy(table) = %v, %v", strategy, err)
+	}
+}
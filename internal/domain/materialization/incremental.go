@@ -5,10 +5,26 @@ import (
 	"strings"
 )
 
+// Incremental strategies, selected with the incremental_strategy config
+const (
+	// IncrementalAppend inserts the new rows without touching existing ones
+	IncrementalAppend = "append"
+	// IncrementalDeleteInsert deletes the rows matching the unique key, then inserts the new rows
+	IncrementalDeleteInsert = "delete+insert"
+	// IncrementalMerge upserts the new rows on the unique key with INSERT ... ON CONFLICT DO UPDATE
+	IncrementalMerge = "merge"
+	// IncrementalInsertOverwrite replaces the partitions present in the new rows
+	IncrementalInsertOverwrite = "insert_overwrite"
+)
+
+// DefaultIncrementalStrategy is used when incremental_strategy is not set
+const DefaultIncrementalStrategy = IncrementalDeleteInsert
+
 // IncrementalStrategy implements materialization with incremental updates
 type IncrementalStrategy struct{}
 
-// Materialize generates SQL for incremental table updates using merge logic
+// Materialize generates SQL for incremental table updates using the
+// configured incremental strategy
 func (i *IncrementalStrategy) Materialize(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
 	// Validate inputs
 	if strings.TrimSpace(modelName) == "" {
@@ -19,37 +35,101 @@ func (i *IncrementalStrategy) Materialize(modelName string, compiledSQL string,
 		return nil, fmt.Errorf("compiled SQL cannot be empty")
 	}
 
-	if len(config.UniqueKey) == 0 {
-		return nil, fmt.Errorf("unique key is required for incremental materialization")
+	if err := ValidateIncrementalConfig(config); err != nil {
+		return nil, err
 	}
 
-	// If full refresh is requested, use simple drop and create
+	// If full refresh is requested, rebuild the table and swap it into place
 	if config.FullRefresh {
-		return i.fullRefresh(modelName, compiledSQL)
+		return swapTableStatements(modelName, compiledSQL, config.ExistingRelation), nil
 	}
 
-	// Otherwise, use incremental merge logic
-	return i.incrementalMerge(modelName, compiledSQL, config.UniqueKey)
+	switch config.Strategy() {
+	case IncrementalAppend:
+		return i.incrementalAppend(modelName, compiledSQL, config)
+	case IncrementalMerge:
+		return i.incrementalUpsert(modelName, compiledSQL, config)
+	case IncrementalInsertOverwrite:
+		return i.incrementalMerge(modelName, compiledSQL, config.PartitionBy, config)
+	case IncrementalMicrobatch:
+		return i.incrementalMicrobatch(modelName, compiledSQL, config)
+	default:
+		return i.incrementalMerge(modelName, compiledSQL, config.UniqueKey, config)
+	}
 }
 
-// fullRefresh performs a full table refresh (drop and recreate)
-func (i *IncrementalStrategy) fullRefresh(modelName string, compiledSQL string) ([]string, error) {
-	statements := make([]string, 0, 2)
+// ValidateIncrementalConfig checks that the incremental strategy is known and
+// that the config it requires is set
+func ValidateIncrementalConfig(config MaterializationConfig) error {
+	if err := ValidateOnSchemaChange(config.OnSchemaChange); err != nil {
+		return err
+	}
 
-	// Drop existing table
-	dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS %s", modelName)
-	statements = append(statements, dropSQL)
+	switch config.Strategy() {
+	case IncrementalAppend:
+		return nil
 
-	// Create new table from SELECT
-	createSQL := fmt.Sprintf("CREATE TABLE %s AS %s", modelName, compiledSQL)
-	statements = append(statements, createSQL)
+	case IncrementalDeleteInsert:
+		if len(config.UniqueKey) == 0 {
+			return fmt.Errorf("unique key is required for incremental materialization")
+		}
+		return nil
 
-	return statements, nil
+	case IncrementalMerge:
+		if len(config.UniqueKey) == 0 {
+			return fmt.Errorf("unique key is required for the merge incremental strategy")
+		}
+		if len(config.MergeUpdateColumns) > 0 && len(config.MergeExcludeColumns) > 0 {
+			return fmt.Errorf("merge_update_columns and merge_exclude_columns cannot be used together")
+		}
+		return nil
+
+	case IncrementalInsertOverwrite:
+		if len(config.PartitionBy) == 0 {
+			return fmt.Errorf("partition_by is required for the insert_overwrite incremental strategy")
+		}
+		return nil
+
+	case IncrementalMicrobatch:
+		if config.EventTime == "" {
+			return fmt.Errorf("event_time is required for the microbatch incremental strategy")
+		}
+		if err := ValidateBatchSize(config.BatchSize); err != nil {
+			return err
+		}
+		if config.Begin == "" {
+			return fmt.Errorf("begin is required for the microbatch incremental strategy")
+		}
+		if _, err := ParseEventTime(config.Begin); err != nil {
+			return fmt.Errorf("begin: %w", err)
+		}
+		if config.Lookback < 0 {
+			return fmt.Errorf("lookback cannot be negative, got %d", config.Lookback)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown incremental strategy %q (expected %s, %s, %s, %s or %s)", config.IncrementalStrategy,
+			IncrementalAppend, IncrementalDeleteInsert, IncrementalMerge, IncrementalInsertOverwrite, IncrementalMicrobatch)
+	}
+}
+
+// incrementalAppend inserts all new rows into the target table
+func (i *IncrementalStrategy) incrementalAppend(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
+	tempTableName := modelName + "__tmp"
+
+	return []string{
+		fmt.Sprintf("CREATE TEMP TABLE %s AS %s", tempTableName, compiledSQL),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 1=0", modelName, tempTableName),
+		insertStatement(modelName, tempTableName, insertColumns(config)),
+		fmt.Sprintf("DROP TABLE %s", tempTableName),
+	}, nil
 }
 
-// incrementalMerge performs incremental updates using temp table and merge logic
-func (i *IncrementalStrategy) incrementalMerge(modelName string, compiledSQL string, uniqueKey []string) ([]string, error) {
-	statements := make([]string, 0, 5)
+// incrementalMerge performs incremental updates using temp table and merge logic.
+// Rows of the target matching the key columns of a new row are replaced.
+func (i *IncrementalStrategy) incrementalMerge(modelName string, compiledSQL string, uniqueKey []string, config MaterializationConfig) ([]string, error) {
+	statements := make([]string, 0, 7)
 	tempTableName := modelName + "__tmp"
 
 	// Step 1: Create temporary table with new data
@@ -60,6 +140,10 @@ func (i *IncrementalStrategy) incrementalMerge(modelName string, compiledSQL str
 	createTargetSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 1=0", modelName, tempTableName)
 	statements = append(statements, createTargetSQL)
 
+	// Steps 3 and 4 run in one transaction, so a failed insert leaves the
+	// deleted rows in place
+	statements = append(statements, BeginTransaction)
+
 	// Step 3: Delete rows from target that match keys in temp table (update scenario)
 	whereClause := i.buildWhereClause(modelName, tempTableName, uniqueKey)
 	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE EXISTS (SELECT 1 FROM %s WHERE %s)",
@@ -67,8 +151,8 @@ func (i *IncrementalStrategy) incrementalMerge(modelName string, compiledSQL str
 	statements = append(statements, deleteSQL)
 
 	// Step 4: Insert all rows from temp table (includes new and updated)
-	insertSQL := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", modelName, tempTableName)
-	statements = append(statements, insertSQL)
+	insertSQL := insertStatement(modelName, tempTableName, insertColumns(config))
+	statements = append(statements, insertSQL, CommitTransaction)
 
 	// Step 5: Drop temporary table
 	dropTempSQL := fmt.Sprintf("DROP TABLE %s", tempTableName)
@@ -77,6 +161,120 @@ func (i *IncrementalStrategy) incrementalMerge(modelName string, compiledSQL str
 	return statements, nil
 }
 
+// incrementalUpsert updates existing rows in place with INSERT ... ON CONFLICT
+// DO UPDATE. SQLite requires a unique index on the key columns, which is
+// created if missing, and the columns of the model's query to build the
+// update list.
+func (i *IncrementalStrategy) incrementalUpsert(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
+	if len(config.Columns) == 0 {
+		return nil, fmt.Errorf("the merge incremental strategy requires the columns of the model's query")
+	}
+
+	insert := insertColumns(config)
+	updateColumns, err := mergeUpdateColumns(config, insert)
+	if err != nil {
+		return nil, err
+	}
+
+	tempTableName := modelName + "__tmp"
+	columns := strings.Join(insert, ", ")
+
+	conflict := "DO NOTHING"
+	if len(updateColumns) > 0 {
+		assignments := make([]string, 0, len(updateColumns))
+		for _, column := range updateColumns {
+			assignments = append(assignments, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+		conflict = "DO UPDATE SET " + strings.Join(assignments, ", ")
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TEMP TABLE %s AS %s", tempTableName, compiledSQL),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 1=0", modelName, tempTableName),
+	}
+	statements = append(statements, IndexStatements(modelName, []IndexConfig{{Columns: config.UniqueKey, Unique: true}})...)
+	statements = append(statements,
+		// WHERE true resolves the parsing ambiguity between a join constraint and ON CONFLICT
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE true ON CONFLICT (%s) %s",
+			modelName, columns, columns, tempTableName, strings.Join(config.UniqueKey, ", "), conflict),
+		fmt.Sprintf("DROP TABLE %s", tempTableName),
+	)
+
+	return statements, nil
+}
+
+// insertColumns returns the columns rows are inserted into: the columns of
+// the model's query that the target table has. It returns nil when the
+// columns of the query are unknown, in which case rows are inserted with
+// SELECT *.
+func insertColumns(config MaterializationConfig) []string {
+	if len(config.Columns) == 0 || len(config.TargetColumns) == 0 {
+		return config.Columns
+	}
+
+	inTarget := make(map[string]bool, len(config.TargetColumns))
+	for _, column := range config.TargetColumns {
+		inTarget[strings.ToLower(column)] = true
+	}
+
+	columns := make([]string, 0, len(config.Columns))
+	for _, column := range config.Columns {
+		if inTarget[strings.ToLower(column)] {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// insertStatement inserts the rows of tempTableName into modelName, naming
+// the columns when they are known
+func insertStatement(modelName, tempTableName string, columns []string) string {
+	if len(columns) == 0 {
+		return fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", modelName, tempTableName)
+	}
+	list := strings.Join(columns, ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", modelName, list, list, tempTableName)
+}
+
+// mergeUpdateColumns returns the columns a merge updates on conflict:
+// merge_update_columns when set, otherwise every non-key column of the
+// model's query except merge_exclude_columns. Only columns that are
+// inserted can be updated.
+func mergeUpdateColumns(config MaterializationConfig, insert []string) ([]string, error) {
+	known := make(map[string]bool, len(config.Columns))
+	for _, column := range config.Columns {
+		known[strings.ToLower(column)] = true
+	}
+
+	if len(config.MergeUpdateColumns) > 0 {
+		for _, column := range config.MergeUpdateColumns {
+			if !known[strings.ToLower(column)] {
+				return nil, fmt.Errorf("merge_update_columns: column %s is not returned by the model", column)
+			}
+		}
+		return config.MergeUpdateColumns, nil
+	}
+
+	skip := make(map[string]bool)
+	for _, column := range config.UniqueKey {
+		skip[strings.ToLower(column)] = true
+	}
+	for _, column := range config.MergeExcludeColumns {
+		if !known[strings.ToLower(column)] {
+			return nil, fmt.Errorf("merge_exclude_columns: column %s is not returned by the model", column)
+		}
+		skip[strings.ToLower(column)] = true
+	}
+
+	var columns []string
+	for _, column := range insert {
+		if !skip[strings.ToLower(column)] {
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
 // buildWhereClause creates a WHERE clause for matching unique keys
 func (i *IncrementalStrategy) buildWhereClause(targetTable, tempTable string, uniqueKey []string) string {
 	conditions := make([]string, 0, len(uniqueKey))
@@ -1,6 +1,7 @@
 package materialization
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -157,3 +158,201 @@ func TestIncrementalName(t *testing.T) {
 		t.Errorf("Name() = %v, want %v", got, "incremental")
 	}
 }
+
+func TestIncrementalStrategies(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MaterializationConfig
+		want   []string
+	}{
+		{
+			name:   "append",
+			config: MaterializationConfig{IncrementalStrategy: IncrementalAppend},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"INSERT INTO events SELECT * FROM events__tmp",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name:   "delete+insert",
+			config: MaterializationConfig{IncrementalStrategy: IncrementalDeleteInsert, UniqueKey: []string{"id"}},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"BEGIN",
+				"DELETE FROM events WHERE EXISTS (SELECT 1 FROM events__tmp WHERE events.id = events__tmp.id)",
+				"INSERT INTO events SELECT * FROM events__tmp",
+				"COMMIT",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name: "merge updates non-key columns",
+			config: MaterializationConfig{
+				IncrementalStrategy: IncrementalMerge,
+				UniqueKey:           []string{"id"},
+				Columns:             []string{"id", "name", "amount"},
+			},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"CREATE UNIQUE INDEX IF NOT EXISTS events__id ON events (id)",
+				"INSERT INTO events (id, name, amount) SELECT id, name, amount FROM events__tmp WHERE true ON CONFLICT (id) DO UPDATE SET name = excluded.name, amount = excluded.amount",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name: "merge with update columns",
+			config: MaterializationConfig{
+				IncrementalStrategy: IncrementalMerge,
+				UniqueKey:           []string{"id"},
+				MergeUpdateColumns:  []string{"amount"},
+				Columns:             []string{"id", "name", "amount"},
+			},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"CREATE UNIQUE INDEX IF NOT EXISTS events__id ON events (id)",
+				"INSERT INTO events (id, name, amount) SELECT id, name, amount FROM events__tmp WHERE true ON CONFLICT (id) DO UPDATE SET amount = excluded.amount",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name: "merge with excluded columns",
+			config: MaterializationConfig{
+				IncrementalStrategy: IncrementalMerge,
+				UniqueKey:           []string{"id"},
+				MergeExcludeColumns: []string{"name", "amount"},
+				Columns:             []string{"id", "name", "amount"},
+			},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"CREATE UNIQUE INDEX IF NOT EXISTS events__id ON events (id)",
+				"INSERT INTO events (id, name, amount) SELECT id, name, amount FROM events__tmp WHERE true ON CONFLICT (id) DO NOTHING",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name:   "insert_overwrite",
+			config: MaterializationConfig{IncrementalStrategy: IncrementalInsertOverwrite, PartitionBy: []string{"day"}},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"BEGIN",
+				"DELETE FROM events WHERE EXISTS (SELECT 1 FROM events__tmp WHERE events.day = events__tmp.day)",
+				"INSERT INTO events SELECT * FROM events__tmp",
+				"COMMIT",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name:   "append names the columns of the query",
+			config: MaterializationConfig{IncrementalStrategy: IncrementalAppend, Columns: []string{"id", "amount"}},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"INSERT INTO events (id, amount) SELECT id, amount FROM events__tmp",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name: "delete+insert skips columns missing from the table",
+			config: MaterializationConfig{
+				IncrementalStrategy: IncrementalDeleteInsert,
+				UniqueKey:           []string{"id"},
+				Columns:             []string{"id", "region", "amount"},
+				TargetColumns:       []string{"id", "amount", "legacy"},
+			},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"BEGIN",
+				"DELETE FROM events WHERE EXISTS (SELECT 1 FROM events__tmp WHERE events.id = events__tmp.id)",
+				"INSERT INTO events (id, amount) SELECT id, amount FROM events__tmp",
+				"COMMIT",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name: "merge updates only columns of the table",
+			config: MaterializationConfig{
+				IncrementalStrategy: IncrementalMerge,
+				UniqueKey:           []string{"id"},
+				Columns:             []string{"id", "region", "amount"},
+				TargetColumns:       []string{"id", "amount"},
+			},
+			want: []string{
+				"CREATE TEMP TABLE events__tmp AS SELECT * FROM src",
+				"CREATE TABLE IF NOT EXISTS events AS SELECT * FROM events__tmp WHERE 1=0",
+				"CREATE UNIQUE INDEX IF NOT EXISTS events__id ON events (id)",
+				"INSERT INTO events (id, amount) SELECT id, amount FROM events__tmp WHERE true ON CONFLICT (id) DO UPDATE SET amount = excluded.amount",
+				"DROP TABLE events__tmp",
+			},
+		},
+		{
+			name:   "full refresh ignores the strategy",
+			config: MaterializationConfig{IncrementalStrategy: IncrementalAppend, FullRefresh: true},
+			want: []string{
+				"DROP TABLE IF EXISTS events__gorchata_tmp",
+				"CREATE TABLE events__gorchata_tmp AS SELECT * FROM src",
+				"BEGIN",
+				"PRAGMA legacy_alter_table = ON",
+				"DROP TABLE IF EXISTS events",
+				"ALTER TABLE events__gorchata_tmp RENAME TO events",
+				"PRAGMA legacy_alter_table = OFF",
+				"COMMIT",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Type = MaterializationIncremental
+			got, err := (&IncrementalStrategy{}).Materialize("events", "SELECT * FROM src", tt.config)
+			if err != nil {
+				t.Fatalf("Materialize() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Materialize() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestIncrementalStrategies_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      MaterializationConfig
+		errContains string
+	}{
+		{"unknown strategy", MaterializationConfig{IncrementalStrategy: "upsert"}, "unknown incremental strategy"},
+		{"delete+insert without key", MaterializationConfig{IncrementalStrategy: IncrementalDeleteInsert}, "unique key is required"},
+		{"merge without key", MaterializationConfig{IncrementalStrategy: IncrementalMerge, Columns: []string{"id"}}, "unique key is required"},
+		{
+			"merge with update and exclude columns",
+			MaterializationConfig{IncrementalStrategy: IncrementalMerge, UniqueKey: []string{"id"}, MergeUpdateColumns: []string{"a"}, MergeExcludeColumns: []string{"b"}, Columns: []string{"id", "a", "b"}},
+			"cannot be used together",
+		},
+		{"merge without columns", MaterializationConfig{IncrementalStrategy: IncrementalMerge, UniqueKey: []string{"id"}}, "requires the columns"},
+		{
+			"merge with unknown update column",
+			MaterializationConfig{IncrementalStrategy: IncrementalMerge, UniqueKey: []string{"id"}, MergeUpdateColumns: []string{"missing"}, Columns: []string{"id", "a"}},
+			"not returned by the model",
+		},
+		{"insert_overwrite without partition", MaterializationConfig{IncrementalStrategy: IncrementalInsertOverwrite}, "partition_by is required"},
+		{"unknown on_schema_change", MaterializationConfig{IncrementalStrategy: IncrementalAppend, OnSchemaChange: "rebuild"}, "unknown on_schema_change"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Type = MaterializationIncremental
+			_, err := (&IncrementalStrategy{}).Materialize("events", "SELECT * FROM src", tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Materialize() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package materialization
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IndexStatements generates CREATE INDEX statements for the given table.
+// Indexes without columns are ignored.
+func IndexStatements(tableName string, indexes []IndexConfig) []string {
+	statements := make([]string, 0, len(indexes))
+
+	for _, index := range indexes {
+		if len(index.Columns) == 0 {
+			continue
+		}
+
+		name := index.Name
+		if name == "" {
+			name = fmt.Sprintf("%s__%s", tableName, strings.Join(index.Columns, "_"))
+		}
+
+		unique := ""
+		if index.Unique {
+			unique = "UNIQUE "
+		}
+
+		statements = append(statements, fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)",
+			unique, name, tableName, strings.Join(index.Columns, ", ")))
+	}
+
+	return statements
+}
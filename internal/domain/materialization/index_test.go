@@ -0,0 +1,28 @@
+package materialization
+
+import (
+	"testing"
+)
+
+func TestIndexStatements(t *testing.T) {
+	indexes := []IndexConfig{
+		{Columns: []string{"id"}, Unique: true},
+		{Name: "idx_orders_customer", Columns: []string{"customer_id", "order_date"}},
+		{Name: "ignored"},
+	}
+
+	got := IndexStatements("orders", indexes)
+	want := []string{
+		"CREATE UNIQUE INDEX IF NOT EXISTS orders__id ON orders (id)",
+		"CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders (customer_id, order_date)",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("IndexStatements() returned %d statements, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package materialization
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IncrementalMicrobatch builds an incremental model in independent batches
+// of event time, each replacing the rows of its time range
+const IncrementalMicrobatch = "microbatch"
+
+// Batch sizes of the microbatch strategy, set with the batch_size config
+const (
+	BatchSizeHour  = "hour"
+	BatchSizeDay   = "day"
+	BatchSizeMonth = "month"
+)
+
+// Batch is the time range [Start, End) of one microbatch
+type Batch struct {
+	Start time.Time
+	End   time.Time
+}
+
+// String returns the batch as "start/end" in RFC 3339
+func (b Batch) String() string {
+	return b.Start.Format(time.RFC3339) + "/" + b.End.Format(time.RFC3339)
+}
+
+// eventTimeLayouts are the formats accepted for begin and event time flags
+var eventTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseEventTime parses a date or timestamp such as "2024-01-31" or
+// "2024-01-31 06:00:00". Times without a zone are UTC.
+func ParseEventTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid event time %q (expected a date like 2024-01-31 or a timestamp like 2024-01-31 06:00:00)", value)
+}
+
+// ValidateBatchSize checks that size is a known batch size
+func ValidateBatchSize(size string) error {
+	switch size {
+	case BatchSizeHour, BatchSizeDay, BatchSizeMonth:
+		return nil
+	case "":
+		return fmt.Errorf("batch_size is required for the microbatch incremental strategy")
+	default:
+		return fmt.Errorf("unknown batch_size %q (expected %s, %s or %s)", size, BatchSizeHour, BatchSizeDay, BatchSizeMonth)
+	}
+}
+
+// TruncateToBatch returns the start of the batch containing t
+func TruncateToBatch(size string, t time.Time) time.Time {
+	t = t.UTC()
+	switch size {
+	case BatchSizeHour:
+		return t.Truncate(time.Hour)
+	case BatchSizeMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// AddBatches moves t by n batches, backwards when n is negative
+func AddBatches(size string, t time.Time, n int) time.Time {
+	switch size {
+	case BatchSizeHour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case BatchSizeMonth:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// Batches splits [start, end) into batches. The first batch starts at the
+// beginning of the batch containing start.
+func Batches(size string, start, end time.Time) []Batch {
+	var batches []Batch
+	for batchStart := TruncateToBatch(size, start); batchStart.Before(end); {
+		batchEnd := AddBatches(size, batchStart, 1)
+		batches = append(batches, Batch{Start: batchStart, End: batchEnd})
+		batchStart = batchEnd
+	}
+	return batches
+}
+
+// FormatBatchTime formats a batch boundary for comparison with event time
+// columns stored as text: timestamps for hourly batches, dates otherwise, so
+// that date and timestamp columns both compare correctly
+func FormatBatchTime(size string, t time.Time) string {
+	if size == BatchSizeHour {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+// EventTimeFilter returns the condition selecting the rows of a batch
+func EventTimeFilter(column, start, end string) string {
+	return fmt.Sprintf("%s >= '%s' AND %s < '%s'", column, start, column, end)
+}
+
+// incrementalMicrobatch replaces the rows of the configured batch with the
+// rows returned by the model's query for that batch. The rows are deleted and
+// inserted in one transaction, so a batch that fails leaves its rows as they
+// were.
+func (i *IncrementalStrategy) incrementalMicrobatch(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
+	if config.Batch == nil {
+		return nil, fmt.Errorf("the microbatch incremental strategy requires a batch")
+	}
+
+	tempTableName := modelName + "__tmp"
+	filter := EventTimeFilter(config.EventTime,
+		FormatBatchTime(config.BatchSize, config.Batch.Start),
+		FormatBatchTime(config.BatchSize, config.Batch.End))
+
+	return []string{
+		fmt.Sprintf("CREATE TEMP TABLE %s AS %s", tempTableName, compiledSQL),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 1=0", modelName, tempTableName),
+		BeginTransaction,
+		fmt.Sprintf("DELETE FROM %s WHERE %s", modelName, filter),
+		insertStatement(modelName, tempTableName, insertColumns(config)),
+		CommitTransaction,
+		fmt.Sprintf("DROP TABLE %s", tempTableName),
+	}, nil
+}
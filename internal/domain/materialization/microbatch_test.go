@@ -0,0 +1,158 @@
+package materialization
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEventTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-01-31", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), false},
+		{"2024-01-31 06:30:00", time.Date(2024, 1, 31, 6, 30, 0, 0, time.UTC), false},
+		{"2024-01-31T06:30", time.Date(2024, 1, 31, 6, 30, 0, 0, time.UTC), false},
+		{"2024-01-31T06:30:00+02:00", time.Date(2024, 1, 31, 4, 30, 0, 0, time.UTC), false},
+		{"31/01/2024", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseEventTime(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEventTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseEventTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatches(t *testing.T) {
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		size  string
+		start time.Time
+		end   time.Time
+		want  []Batch
+	}{
+		{
+			name:  "days from a start inside a day",
+			size:  BatchSizeDay,
+			start: date(1, 30, 6),
+			end:   date(2, 1, 0),
+			want:  []Batch{{date(1, 30, 0), date(1, 31, 0)}, {date(1, 31, 0), date(2, 1, 0)}},
+		},
+		{
+			name:  "hours",
+			size:  BatchSizeHour,
+			start: date(1, 1, 22),
+			end:   date(1, 2, 0),
+			want:  []Batch{{date(1, 1, 22), date(1, 1, 23)}, {date(1, 1, 23), date(1, 2, 0)}},
+		},
+		{
+			name:  "months cover a partial last month",
+			size:  BatchSizeMonth,
+			start: date(1, 15, 0),
+			end:   date(2, 10, 0),
+			want:  []Batch{{date(1, 1, 0), date(2, 1, 0)}, {date(2, 1, 0), date(3, 1, 0)}},
+		},
+		{
+			name:  "empty range",
+			size:  BatchSizeDay,
+			start: date(1, 2, 0),
+			end:   date(1, 2, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Batches(tt.size, tt.start, tt.end); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Batches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBatchTime(t *testing.T) {
+	ts := time.Date(2024, 3, 5, 7, 0, 0, 0, time.UTC)
+	if got := FormatBatchTime(BatchSizeDay, ts); got != "2024-03-05" {
+		t.Errorf("FormatBatchTime(day) = %q, want 2024-03-05", got)
+	}
+	if got := FormatBatchTime(BatchSizeHour, ts); got != "2024-03-05 07:00:00" {
+		t.Errorf("FormatBatchTime(hour) = %q, want 2024-03-05 07:00:00", got)
+	}
+}
+
+func TestIncrementalMicrobatch(t *testing.T) {
+	config := MaterializationConfig{
+		Type:                MaterializationIncremental,
+		IncrementalStrategy: IncrementalMicrobatch,
+		EventTime:           "loaded_at",
+		BatchSize:           BatchSizeDay,
+		Begin:               "2024-01-01",
+		Columns:             []string{"id", "loaded_at"},
+		Batch:               &Batch{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	got, err := (&IncrementalStrategy{}).Materialize("trips", "SELECT * FROM src", config)
+	if err != nil {
+		t.Fatalf("Materialize() error = %v", err)
+	}
+	want := []string{
+		"CREATE TEMP TABLE trips__tmp AS SELECT * FROM src",
+		"CREATE TABLE IF NOT EXISTS trips AS SELECT * FROM trips__tmp WHERE 1=0",
+		BeginTransaction,
+		"DELETE FROM trips WHERE loaded_at >= '2024-01-02' AND loaded_at < '2024-01-03'",
+		"INSERT INTO trips (id, loaded_at) SELECT id, loaded_at FROM trips__tmp",
+		CommitTransaction,
+		"DROP TABLE trips__tmp",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Materialize() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestIncrementalMicrobatch_Errors(t *testing.T) {
+	valid := MaterializationConfig{
+		Type:                MaterializationIncremental,
+		IncrementalStrategy: IncrementalMicrobatch,
+		EventTime:           "loaded_at",
+		BatchSize:           BatchSizeDay,
+		Begin:               "2024-01-01",
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(*MaterializationConfig)
+		errContains string
+	}{
+		{"without event_time", func(c *MaterializationConfig) { c.EventTime = "" }, "event_time is required"},
+		{"without batch_size", func(c *MaterializationConfig) { c.BatchSize = "" }, "batch_size is required"},
+		{"unknown batch_size", func(c *MaterializationConfig) { c.BatchSize = "week" }, "unknown batch_size"},
+		{"without begin", func(c *MaterializationConfig) { c.Begin = "" }, "begin is required"},
+		{"invalid begin", func(c *MaterializationConfig) { c.Begin = "yesterday" }, "invalid event time"},
+		{"negative lookback", func(c *MaterializationConfig) { c.Lookback = -1 }, "lookback cannot be negative"},
+		{"without batch", func(c *MaterializationConfig) {}, "requires a batch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			_, err := (&IncrementalStrategy{}).Materialize("trips", "SELECT * FROM src", config)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Materialize() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
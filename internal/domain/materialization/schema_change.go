@@ -0,0 +1,192 @@
+package materialization
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values of the on_schema_change config, which decides what happens when the
+// columns of an incremental model's query differ from its existing table
+const (
+	// OnSchemaChangeIgnore keeps the table as is; new columns are not loaded
+	// and removed columns are left NULL in new rows
+	OnSchemaChangeIgnore = "ignore"
+	// OnSchemaChangeFail fails the model
+	OnSchemaChangeFail = "fail"
+	// OnSchemaChangeAppendNewColumns adds the new columns to the table
+	OnSchemaChangeAppendNewColumns = "append_new_columns"
+	// OnSchemaChangeSyncAllColumns adds the new columns and drops the removed ones
+	OnSchemaChangeSyncAllColumns = "sync_all_columns"
+)
+
+// DefaultOnSchemaChange is used when on_schema_change is not set
+const DefaultOnSchemaChange = OnSchemaChangeIgnore
+
+// Column is a column of a table or query with its declared type
+type Column struct {
+	Name string
+	Type string
+	// Referenced reports that an index, constraint, view or trigger uses the
+	// column of a table, so that it cannot be dropped with ALTER TABLE
+	Referenced bool
+}
+
+// SchemaChange lists the columns of a model's query that are missing from its
+// table (Added) and the columns of the table the query no longer returns (Removed)
+type SchemaChange struct {
+	Added   []Column
+	Removed []Column
+}
+
+// IsEmpty reports whether the query and the table have the same columns
+func (c SchemaChange) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// String describes the change, e.g. "added: region; removed: legacy_id"
+func (c SchemaChange) String() string {
+	var parts []string
+	if len(c.Added) > 0 {
+		parts = append(parts, "added: "+strings.Join(columnNames(c.Added), ", "))
+	}
+	if len(c.Removed) > 0 {
+		parts = append(parts, "removed: "+strings.Join(columnNames(c.Removed), ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// DiffColumns compares the columns of a table with those of the query that
+// loads it. Names are compared case-insensitively, as SQL identifiers are.
+func DiffColumns(table, query []Column) SchemaChange {
+	var change SchemaChange
+
+	inTable := make(map[string]bool, len(table))
+	for _, column := range table {
+		inTable[strings.ToLower(column.Name)] = true
+	}
+	inQuery := make(map[string]bool, len(query))
+	for _, column := range query {
+		inQuery[strings.ToLower(column.Name)] = true
+		if !inTable[strings.ToLower(column.Name)] {
+			change.Added = append(change.Added, column)
+		}
+	}
+	for _, column := range table {
+		if !inQuery[strings.ToLower(column.Name)] {
+			change.Removed = append(change.Removed, column)
+		}
+	}
+
+	return change
+}
+
+// ValidateOnSchemaChange checks that policy is a known on_schema_change value
+func ValidateOnSchemaChange(policy string) error {
+	switch policy {
+	case "", OnSchemaChangeIgnore, OnSchemaChangeFail, OnSchemaChangeAppendNewColumns, OnSchemaChangeSyncAllColumns:
+		return nil
+	default:
+		return fmt.Errorf("unknown on_schema_change %q (expected %s, %s, %s or %s)", policy,
+			OnSchemaChangeIgnore, OnSchemaChangeFail, OnSchemaChangeAppendNewColumns, OnSchemaChangeSyncAllColumns)
+	}
+}
+
+// SchemaChangeStatements applies an on_schema_change policy to a table whose
+// columns differ from those of its query. It returns the ALTER TABLE
+// statements to run before loading the table and the columns the table has
+// once they ran. When a removed column is referenced, the table is rebuilt
+// without it instead of dropping it.
+func SchemaChangeStatements(tableName string, table, query []Column, policy string) ([]string, []Column, error) {
+	if err := ValidateOnSchemaChange(policy); err != nil {
+		return nil, nil, err
+	}
+
+	change := DiffColumns(table, query)
+	if change.IsEmpty() {
+		return nil, table, nil
+	}
+
+	switch policy {
+	case OnSchemaChangeFail:
+		return nil, nil, fmt.Errorf("the columns of %s changed (%s) and on_schema_change is fail", tableName, change)
+
+	case OnSchemaChangeAppendNewColumns, OnSchemaChangeSyncAllColumns:
+		var statements []string
+		columns := append([]Column{}, table...)
+
+		for _, column := range change.Added {
+			definition := column.Name
+			if column.Type != "" {
+				definition += " " + column.Type
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, definition))
+			columns = append(columns, column)
+		}
+
+		if policy == OnSchemaChangeSyncAllColumns {
+			removed := make(map[string]bool, len(change.Removed))
+			rebuild := false
+			for _, column := range change.Removed {
+				removed[strings.ToLower(column.Name)] = true
+				rebuild = rebuild || column.Referenced
+			}
+
+			kept := columns[:0]
+			for _, column := range columns {
+				if !removed[strings.ToLower(column.Name)] {
+					kept = append(kept, column)
+				}
+			}
+			columns = kept
+
+			if rebuild {
+				statements = append(statements, rebuildTableStatements(tableName, columns)...)
+			} else {
+				for _, column := range change.Removed {
+					statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, column.Name))
+				}
+			}
+		}
+
+		return statements, columns, nil
+
+	default:
+		return nil, table, nil
+	}
+}
+
+// rebuildTableStatements replace a table with a copy holding only columns,
+// in one transaction. SQLite cannot drop a column that an index, constraint,
+// view or trigger uses, so such columns are removed by rebuilding the table.
+// The indexes of the table are dropped with it; those of the model's config
+// are created again once it is loaded.
+func rebuildTableStatements(tableName string, columns []Column) []string {
+	tmpName := tableName + SwapSuffix
+
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = column.Name
+		if column.Type != "" {
+			definitions[i] += " " + column.Type
+		}
+	}
+	names := strings.Join(columnNames(columns), ", ")
+
+	statements := []string{
+		BeginTransaction,
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", tmpName),
+		fmt.Sprintf("CREATE TABLE %s (%s)", tmpName, strings.Join(definitions, ", ")),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", tmpName, names, names, tableName),
+	}
+	statements = append(statements, replaceTableStatements(tmpName, tableName, RelationTable)...)
+	return append(statements, CommitTransaction)
+}
+
+// columnNames returns the names of columns
+func columnNames(columns []Column) []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
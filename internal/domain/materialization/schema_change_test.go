@@ -0,0 +1,128 @@
+package materialization
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchemaChangeStatements(t *testing.T) {
+	table := []Column{{Name: "id", Type: "INT"}, {Name: "amount", Type: "REAL"}, {Name: "legacy", Type: "TEXT"}}
+	query := []Column{{Name: "ID", Type: "INT"}, {Name: "amount", Type: "REAL"}, {Name: "region", Type: "TEXT"}, {Name: "note"}}
+
+	tests := []struct {
+		name           string
+		policy         string
+		wantStatements []string
+		wantColumns    []string
+	}{
+		{
+			name:        "ignore",
+			policy:      OnSchemaChangeIgnore,
+			wantColumns: []string{"id", "amount", "legacy"},
+		},
+		{
+			name:        "default is ignore",
+			policy:      "",
+			wantColumns: []string{"id", "amount", "legacy"},
+		},
+		{
+			name:   "append new columns",
+			policy: OnSchemaChangeAppendNewColumns,
+			wantStatements: []string{
+				"ALTER TABLE events ADD COLUMN region TEXT",
+				"ALTER TABLE events ADD COLUMN note",
+			},
+			wantColumns: []string{"id", "amount", "legacy", "region", "note"},
+		},
+		{
+			name:   "sync all columns",
+			policy: OnSchemaChangeSyncAllColumns,
+			wantStatements: []string{
+				"ALTER TABLE events ADD COLUMN region TEXT",
+				"ALTER TABLE events ADD COLUMN note",
+				"ALTER TABLE events DROP COLUMN legacy",
+			},
+			wantColumns: []string{"id", "amount", "region", "note"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, columns, err := SchemaChangeStatements("events", table, query, tt.policy)
+			if err != nil {
+				t.Fatalf("SchemaChangeStatements() error = %v", err)
+			}
+			if !reflect.DeepEqual(statements, tt.wantStatements) {
+				t.Errorf("statements =\n%s\nwant\n%s", strings.Join(statements, "\n"), strings.Join(tt.wantStatements, "\n"))
+			}
+			if got := columnNames(columns); !reflect.DeepEqual(got, tt.wantColumns) {
+				t.Errorf("columns = %v, want %v", got, tt.wantColumns)
+			}
+		})
+	}
+}
+
+func TestSchemaChangeStatements_Rebuild(t *testing.T) {
+	table := []Column{{Name: "id", Type: "INT", Referenced: true}, {Name: "legacy", Type: "TEXT", Referenced: true}}
+	query := []Column{{Name: "id", Type: "INT"}, {Name: "region", Type: "TEXT"}}
+
+	statements, columns, err := SchemaChangeStatements("events", table, query, OnSchemaChangeSyncAllColumns)
+	if err != nil {
+		t.Fatalf("SchemaChangeStatements() error = %v", err)
+	}
+
+	want := []string{
+		"ALTER TABLE events ADD COLUMN region TEXT",
+		BeginTransaction,
+		"DROP TABLE IF EXISTS events__gorchata_tmp",
+		"CREATE TABLE events__gorchata_tmp (id INT, region TEXT)",
+		"INSERT INTO events__gorchata_tmp (id, region) SELECT id, region FROM events",
+		"PRAGMA legacy_alter_table = ON",
+		"DROP TABLE IF EXISTS events",
+		"ALTER TABLE events__gorchata_tmp RENAME TO events",
+		"PRAGMA legacy_alter_table = OFF",
+		CommitTransaction,
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("statements =\n%s\nwant\n%s", strings.Join(statements, "\n"), strings.Join(want, "\n"))
+	}
+	if got := columnNames(columns); !reflect.DeepEqual(got, []string{"id", "region"}) {
+		t.Errorf("columns = %v, want [id region]", got)
+	}
+}
+
+func TestSchemaChangeStatements_Errors(t *testing.T) {
+	table := []Column{{Name: "id"}, {Name: "legacy"}}
+	query := []Column{{Name: "id"}, {Name: "region"}}
+
+	tests := []struct {
+		name        string
+		policy      string
+		errContains string
+	}{
+		{"fail", OnSchemaChangeFail, "added: region; removed: legacy"},
+		{"unknown policy", "rebuild", "unknown on_schema_change"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := SchemaChangeStatements("events", table, query, tt.policy)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("SchemaChangeStatements() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestSchemaChangeStatements_Unchanged(t *testing.T) {
+	columns := []Column{{Name: "id"}, {Name: "amount"}}
+
+	statements, got, err := SchemaChangeStatements("events", columns, columns, OnSchemaChangeFail)
+	if err != nil {
+		t.Fatalf("SchemaChangeStatements() error = %v", err)
+	}
+	if len(statements) != 0 || !reflect.DeepEqual(got, columns) {
+		t.Errorf("SchemaChangeStatements() = %v, %v, want no statements and the table columns", statements, got)
+	}
+}
@@ -0,0 +1,209 @@
+package materialization
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Snapshot strategies, selected with the strategy config of a snapshot
+const (
+	// SnapshotTimestamp detects changed rows with a newer updated_at column
+	SnapshotTimestamp = "timestamp"
+	// SnapshotCheck detects changed rows by comparing the check_cols columns
+	SnapshotCheck = "check"
+)
+
+// Columns a snapshot adds to the rows of its query
+const (
+	// SnapshotScdIDColumn uniquely identifies each version of a row: its
+	// unique key and valid_from, separated with "|"
+	SnapshotScdIDColumn = "scd_id"
+	// SnapshotValidFromColumn is when a version of a row became current
+	SnapshotValidFromColumn = "valid_from"
+	// SnapshotValidToColumn is when a version of a row was replaced or
+	// deleted; NULL for the current version
+	SnapshotValidToColumn = "valid_to"
+)
+
+// SnapshotTimeFormat is the format of SnapshotTimestamp, that of SQLite's CURRENT_TIMESTAMP
+const SnapshotTimeFormat = "2006-01-02 15:04:05"
+
+// CheckAllColumns compares every column of the query except the unique key
+const CheckAllColumns = "all"
+
+// SnapshotStrategy records the history of the rows returned by a query as a
+// slowly changing dimension (type 2): changed rows close their current
+// version and insert a new one, so that every version is kept with the
+// range of time it was valid for.
+type SnapshotStrategy struct{}
+
+// Materialize generates SQL that takes a snapshot of the rows of compiledSQL
+func (s *SnapshotStrategy) Materialize(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
+	if strings.TrimSpace(modelName) == "" {
+		return nil, fmt.Errorf("model name cannot be empty")
+	}
+
+	if strings.TrimSpace(compiledSQL) == "" {
+		return nil, fmt.Errorf("compiled SQL cannot be empty")
+	}
+
+	if err := ValidateSnapshotConfig(config); err != nil {
+		return nil, err
+	}
+
+	checkCols, err := snapshotCheckColumns(config)
+	if err != nil {
+		return nil, err
+	}
+
+	tempTableName := modelName + "__tmp"
+	now := "CURRENT_TIMESTAMP"
+	if config.SnapshotTimestamp != "" {
+		now = "'" + config.SnapshotTimestamp + "'"
+	}
+
+	// Rows of the table and of the query are matched on the unique key
+	match := snapshotKeyMatch(modelName, "s", config.UniqueKey)
+	current := fmt.Sprintf("%s.%s IS NULL", modelName, SnapshotValidToColumn)
+
+	// A row has changed when its updated_at is newer, or when any of the
+	// checked columns differs; the new version is valid from updated_at or
+	// from the time of the snapshot respectively
+	var changed, validFrom, closedAt string
+	if config.SnapshotStrategy == SnapshotTimestamp {
+		changed = fmt.Sprintf("s.%s > %s.%s", config.UpdatedAt, modelName, config.UpdatedAt)
+		validFrom = "s." + config.UpdatedAt
+		closedAt = fmt.Sprintf("(SELECT s.%s FROM %s s WHERE %s)", config.UpdatedAt, tempTableName, match)
+	} else {
+		same := make([]string, 0, len(checkCols))
+		for _, column := range checkCols {
+			same = append(same, fmt.Sprintf("s.%s IS %s.%s", column, modelName, column))
+		}
+		changed = fmt.Sprintf("NOT (%s)", strings.Join(same, " AND "))
+		validFrom = now
+		closedAt = now
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TEMP TABLE %s AS %s", tempTableName, compiledSQL),
+		// First snapshot: an empty table with the snapshot columns
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT *, NULL AS %s, NULL AS %s, NULL AS %s FROM %s WHERE 1=0",
+			modelName, SnapshotScdIDColumn, SnapshotValidFromColumn, SnapshotValidToColumn, tempTableName),
+		// Rows are closed and their new versions inserted in one transaction,
+		// so no key is left without a current version
+		BeginTransaction,
+		// Close the current version of changed rows
+		fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s AND EXISTS (SELECT 1 FROM %s s WHERE %s AND %s)",
+			modelName, SnapshotValidToColumn, closedAt, current, tempTableName, match, changed),
+	}
+
+	// Close the current version of rows the query no longer returns
+	if config.InvalidateHardDeletes {
+		statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s AND NOT EXISTS (SELECT 1 FROM %s s WHERE %s)",
+			modelName, SnapshotValidToColumn, now, current, tempTableName, match))
+	}
+
+	// Insert a current version for new and changed rows, which are those
+	// left without one
+	scdID := make([]string, 0, len(config.UniqueKey)+1)
+	for _, key := range config.UniqueKey {
+		scdID = append(scdID, "s."+key)
+	}
+	scdID = append(scdID, validFrom)
+	values := fmt.Sprintf("%s, %s, NULL", strings.Join(scdID, " || '|' || "), validFrom)
+
+	into := ""
+	selectList := "s.*"
+	if columns := insertColumns(config); len(columns) > 0 {
+		qualified := make([]string, 0, len(columns))
+		for _, column := range columns {
+			qualified = append(qualified, "s."+column)
+		}
+		into = fmt.Sprintf(" (%s, %s, %s, %s)", strings.Join(columns, ", "),
+			SnapshotScdIDColumn, SnapshotValidFromColumn, SnapshotValidToColumn)
+		selectList = strings.Join(qualified, ", ")
+	}
+
+	statements = append(statements,
+		fmt.Sprintf("INSERT INTO %s%s SELECT %s, %s FROM %s s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s AND %s)",
+			modelName, into, selectList, values, tempTableName, modelName, match, current),
+		CommitTransaction,
+		fmt.Sprintf("DROP TABLE %s", tempTableName),
+	)
+
+	return statements, nil
+}
+
+// ValidateSnapshotConfig checks that the snapshot strategy is known and that
+// the config it requires is set
+func ValidateSnapshotConfig(config MaterializationConfig) error {
+	if len(config.UniqueKey) == 0 {
+		return fmt.Errorf("unique_key is required for snapshots")
+	}
+
+	switch config.SnapshotStrategy {
+	case SnapshotTimestamp:
+		if config.UpdatedAt == "" {
+			return fmt.Errorf("updated_at is required for the timestamp snapshot strategy")
+		}
+		return nil
+
+	case SnapshotCheck:
+		if len(config.CheckCols) == 0 {
+			return fmt.Errorf("check_cols is required for the check snapshot strategy")
+		}
+		return nil
+
+	case "":
+		return fmt.Errorf("strategy is required for snapshots (expected %s or %s)", SnapshotTimestamp, SnapshotCheck)
+
+	default:
+		return fmt.Errorf("unknown snapshot strategy %q (expected %s or %s)", config.SnapshotStrategy, SnapshotTimestamp, SnapshotCheck)
+	}
+}
+
+// snapshotCheckColumns returns the columns the check strategy compares.
+// "all" stands for every column of the query except the unique key.
+func snapshotCheckColumns(config MaterializationConfig) ([]string, error) {
+	if config.SnapshotStrategy != SnapshotCheck {
+		return nil, nil
+	}
+	if len(config.CheckCols) != 1 || !strings.EqualFold(config.CheckCols[0], CheckAllColumns) {
+		return config.CheckCols, nil
+	}
+
+	if len(config.Columns) == 0 {
+		return nil, fmt.Errorf("check_cols %s requires the columns of the snapshot's query", CheckAllColumns)
+	}
+
+	key := make(map[string]bool, len(config.UniqueKey))
+	for _, column := range config.UniqueKey {
+		key[strings.ToLower(column)] = true
+	}
+
+	var columns []string
+	for _, column := range insertColumns(config) {
+		if !key[strings.ToLower(column)] {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("check_cols %s found no column to compare besides the unique key", CheckAllColumns)
+	}
+	return columns, nil
+}
+
+// snapshotKeyMatch returns the condition matching the rows of table and
+// alias on the unique key
+func snapshotKeyMatch(table, alias string, uniqueKey []string) string {
+	conditions := make([]string, 0, len(uniqueKey))
+	for _, key := range uniqueKey {
+		conditions = append(conditions, fmt.Sprintf("%s.%s = %s.%s", table, key, alias, key))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// Name returns the strategy name
+func (s *SnapshotStrategy) Name() string {
+	return "snapshot"
+}
@@ -0,0 +1,130 @@
+package materialization
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotMaterialize(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MaterializationConfig
+		want   []string
+	}{
+		{
+			name: "timestamp strategy",
+			config: MaterializationConfig{
+				Type:             MaterializationSnapshot,
+				SnapshotStrategy: SnapshotTimestamp,
+				UniqueKey:        []string{"id"},
+				UpdatedAt:        "updated_at",
+				Columns:          []string{"id", "status", "updated_at"},
+			},
+			want: []string{
+				"CREATE TEMP TABLE orders_snapshot__tmp AS SELECT * FROM orders",
+				"CREATE TABLE IF NOT EXISTS orders_snapshot AS SELECT *, NULL AS scd_id, NULL AS valid_from, NULL AS valid_to FROM orders_snapshot__tmp WHERE 1=0",
+				BeginTransaction,
+				"UPDATE orders_snapshot SET valid_to = (SELECT s.updated_at FROM orders_snapshot__tmp s WHERE orders_snapshot.id = s.id) WHERE orders_snapshot.valid_to IS NULL AND EXISTS (SELECT 1 FROM orders_snapshot__tmp s WHERE orders_snapshot.id = s.id AND s.updated_at > orders_snapshot.updated_at)",
+				"INSERT INTO orders_snapshot (id, status, updated_at, scd_id, valid_from, valid_to) SELECT s.id, s.status, s.updated_at, s.id || '|' || s.updated_at, s.updated_at, NULL FROM orders_snapshot__tmp s WHERE NOT EXISTS (SELECT 1 FROM orders_snapshot WHERE orders_snapshot.id = s.id AND orders_snapshot.valid_to IS NULL)",
+				CommitTransaction,
+				"DROP TABLE orders_snapshot__tmp",
+			},
+		},
+		{
+			name: "check strategy with hard deletes",
+			config: MaterializationConfig{
+				Type:                  MaterializationSnapshot,
+				SnapshotStrategy:      SnapshotCheck,
+				UniqueKey:             []string{"id"},
+				CheckCols:             []string{"status", "amount"},
+				InvalidateHardDeletes: true,
+				SnapshotTimestamp:     "2024-01-02 03:04:05",
+			},
+			want: []string{
+				"CREATE TEMP TABLE orders_snapshot__tmp AS SELECT * FROM orders",
+				"CREATE TABLE IF NOT EXISTS orders_snapshot AS SELECT *, NULL AS scd_id, NULL AS valid_from, NULL AS valid_to FROM orders_snapshot__tmp WHERE 1=0",
+				BeginTransaction,
+				"UPDATE orders_snapshot SET valid_to = '2024-01-02 03:04:05' WHERE orders_snapshot.valid_to IS NULL AND EXISTS (SELECT 1 FROM orders_snapshot__tmp s WHERE orders_snapshot.id = s.id AND NOT (s.status IS orders_snapshot.status AND s.amount IS orders_snapshot.amount))",
+				"UPDATE orders_snapshot SET valid_to = '2024-01-02 03:04:05' WHERE orders_snapshot.valid_to IS NULL AND NOT EXISTS (SELECT 1 FROM orders_snapshot__tmp s WHERE orders_snapshot.id = s.id)",
+				"INSERT INTO orders_snapshot SELECT s.*, s.id || '|' || '2024-01-02 03:04:05', '2024-01-02 03:04:05', NULL FROM orders_snapshot__tmp s WHERE NOT EXISTS (SELECT 1 FROM orders_snapshot WHERE orders_snapshot.id = s.id AND orders_snapshot.valid_to IS NULL)",
+				CommitTransaction,
+				"DROP TABLE orders_snapshot__tmp",
+			},
+		},
+		{
+			name: "check all columns with a composite key",
+			config: MaterializationConfig{
+				Type:              MaterializationSnapshot,
+				SnapshotStrategy:  SnapshotCheck,
+				UniqueKey:         []string{"id", "region"},
+				CheckCols:         []string{"all"},
+				Columns:           []string{"id", "region", "status"},
+				TargetColumns:     []string{"id", "region", "status", "scd_id", "valid_from", "valid_to"},
+				SnapshotTimestamp: "2024-01-02 03:04:05",
+			},
+			want: []string{
+				"CREATE TEMP TABLE orders_snapshot__tmp AS SELECT * FROM orders",
+				"CREATE TABLE IF NOT EXISTS orders_snapshot AS SELECT *, NULL AS scd_id, NULL AS valid_from, NULL AS valid_to FROM orders_snapshot__tmp WHERE 1=0",
+				BeginTransaction,
+				"UPDATE orders_snapshot SET valid_to = '2024-01-02 03:04:05' WHERE orders_snapshot.valid_to IS NULL AND EXISTS (SELECT 1 FROM orders_snapshot__tmp s WHERE orders_snapshot.id = s.id AND orders_snapshot.region = s.region AND NOT (s.status IS orders_snapshot.status))",
+				"INSERT INTO orders_snapshot (id, region, status, scd_id, valid_from, valid_to) SELECT s.id, s.region, s.status, s.id || '|' || s.region || '|' || '2024-01-02 03:04:05', '2024-01-02 03:04:05', NULL FROM orders_snapshot__tmp s WHERE NOT EXISTS (SELECT 1 FROM orders_snapshot WHERE orders_snapshot.id = s.id AND orders_snapshot.region = s.region AND orders_snapshot.valid_to IS NULL)",
+				CommitTransaction,
+				"DROP TABLE orders_snapshot__tmp",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&SnapshotStrategy{}).Materialize("orders_snapshot", "SELECT * FROM orders", tt.config)
+			if err != nil {
+				t.Fatalf("Materialize() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Materialize() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSnapshotMaterialize_Errors(t *testing.T) {
+	valid := MaterializationConfig{
+		Type:             MaterializationSnapshot,
+		SnapshotStrategy: SnapshotTimestamp,
+		UniqueKey:        []string{"id"},
+		UpdatedAt:        "updated_at",
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(*MaterializationConfig)
+		errContains string
+	}{
+		{"without unique_key", func(c *MaterializationConfig) { c.UniqueKey = nil }, "unique_key is required"},
+		{"without strategy", func(c *MaterializationConfig) { c.SnapshotStrategy = "" }, "strategy is required"},
+		{"unknown strategy", func(c *MaterializationConfig) { c.SnapshotStrategy = "hash" }, "unknown snapshot strategy"},
+		{"timestamp without updated_at", func(c *MaterializationConfig) { c.UpdatedAt = "" }, "updated_at is required"},
+		{"check without check_cols", func(c *MaterializationConfig) { c.SnapshotStrategy = SnapshotCheck }, "check_cols is required"},
+		{"check all without columns", func(c *MaterializationConfig) {
+			c.SnapshotStrategy = SnapshotCheck
+			c.CheckCols = []string{"all"}
+		}, "requires the columns"},
+		{"check all with only the key", func(c *MaterializationConfig) {
+			c.SnapshotStrategy = SnapshotCheck
+			c.CheckCols = []string{"all"}
+			c.Columns = []string{"id"}
+		}, "no column to compare"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			_, err := (&SnapshotStrategy{}).Materialize("orders_snapshot", "SELECT * FROM orders", config)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Materialize() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
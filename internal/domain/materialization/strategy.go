@@ -1,5 +1,12 @@
 package materialization
 
+import (
+	"context"
+
+	"github.com/jpconstantineau/gorchata/internal/platform"
+	"github.com/jpconstantineau/gorchata/internal/template"
+)
+
 // Strategy defines the interface for materialization strategies
 type Strategy interface {
 	// Materialize generates and returns SQL statements to materialize the model
@@ -15,3 +22,62 @@ type Strategy interface {
 	// Name returns the strategy name (view, table, incremental)
 	Name() string
 }
+
+// Builder is implemented by strategies that need more than the model's name
+// and SQL to materialize it, such as custom strategies that build on the
+// relation already in the database. The engine calls Build instead of
+// Materialize for them.
+type Builder interface {
+	Strategy
+
+	// Build generates and returns SQL statements to materialize the model
+	// described by input. Statements between BeginTransaction and
+	// CommitTransaction execute in a transaction.
+	Build(ctx context.Context, input Input) ([]string, error)
+}
+
+// Input is what a Builder gets to materialize a model
+type Input struct {
+	// Relation is the name of the table or view the model builds
+	Relation string
+
+	// CompiledSQL is the compiled SELECT query that defines the model
+	CompiledSQL string
+
+	// Config is the materialization config of the model
+	Config MaterializationConfig
+
+	// ModelConfig holds every config value of the model, including the keys
+	// the built-in strategies don't know
+	ModelConfig map[string]interface{}
+
+	// Adapter is the database the model is built in. Within a transaction,
+	// read the existing relation from Existing rather than through Adapter.
+	Adapter platform.DatabaseAdapter
+
+	// Existing describes the relation already in the database, or is nil
+	// when the model has not been built yet
+	Existing *Relation
+
+	// IsIncremental is true when the relation exists and the run is not a
+	// full refresh
+	IsIncremental bool
+
+	// Template is the context the model was rendered with
+	Template *template.Context
+}
+
+// Relation describes a table in the database
+type Relation struct {
+	Name    string
+	Columns []Column
+}
+
+// ColumnNames returns the names of the relation's columns
+func (r *Relation) ColumnNames() []string {
+	names := make([]string, len(r.Columns))
+	for i, column := range r.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
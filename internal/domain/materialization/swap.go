@@ -0,0 +1,100 @@
+package materialization
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statements that delimit a group of statements the engine runs in a single
+// database transaction, so readers see either the old or the new relation
+const (
+	BeginTransaction  = "BEGIN"
+	CommitTransaction = "COMMIT"
+)
+
+// Kinds of relation the database can already hold under a model's name, as
+// set in MaterializationConfig.ExistingRelation
+const (
+	RelationTable = "table"
+	RelationView  = "view"
+)
+
+// SwapSuffix is appended to the name of a table to build its replacement,
+// which is renamed into place once it is complete
+const SwapSuffix = "__gorchata_tmp"
+
+// swapTableStatements builds a table under a temporary name, then replaces
+// the old table with it in one transaction. When the SELECT fails, the old
+// table is left untouched.
+//
+// SQLite checks every view when a table is renamed and fails on views that
+// reference the dropped table, so the legacy rename behaviour is enabled for
+// the swap: views over the model then resolve to the new table.
+func swapTableStatements(modelName, compiledSQL, existing string) []string {
+	tmpName := modelName + SwapSuffix
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", tmpName),
+		fmt.Sprintf("CREATE TABLE %s AS %s", tmpName, compiledSQL),
+		BeginTransaction,
+	}
+	statements = append(statements, replaceTableStatements(tmpName, modelName, existing)...)
+	return append(statements, CommitTransaction)
+}
+
+// ReplaceTableStatements replaces the existing relation of a model with the
+// table built under tmpName in one transaction
+func ReplaceTableStatements(tmpName, modelName, existing string) []string {
+	statements := append([]string{BeginTransaction}, replaceTableStatements(tmpName, modelName, existing)...)
+	return append(statements, CommitTransaction)
+}
+
+// replaceTableStatements replace the existing relation of a model with the
+// table built under tmpName. They must run in a transaction.
+func replaceTableStatements(tmpName, modelName, existing string) []string {
+	if existing == "" {
+		existing = RelationTable
+	}
+	return []string{
+		"PRAGMA legacy_alter_table = ON",
+		DropRelationStatement(modelName, existing),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmpName, unqualifiedName(modelName)),
+		"PRAGMA legacy_alter_table = OFF",
+	}
+}
+
+// DropRelationStatement drops the relation name if it exists, with DROP VIEW
+// when it is a view and DROP TABLE otherwise
+func DropRelationStatement(name, relationType string) string {
+	if relationType == RelationView {
+		return fmt.Sprintf("DROP VIEW IF EXISTS %s", name)
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s", name)
+}
+
+// Swaps reports whether statements build the model under its temporary name
+// to swap it into place
+func Swaps(statements []string, modelName string) bool {
+	tmpName := modelName + SwapSuffix
+	for _, sql := range statements {
+		if strings.Contains(sql, tmpName) {
+			return true
+		}
+	}
+	return false
+}
+
+// SwapCleanupStatements undo what a swap that failed leaves behind: the table
+// built under the temporary name, and legacy_alter_table turned on for the
+// connection, as pragmas are not rolled back with the transaction
+func SwapCleanupStatements(modelName string) []string {
+	return []string{
+		"PRAGMA legacy_alter_table = OFF",
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", modelName+SwapSuffix),
+	}
+}
+
+// unqualifiedName returns a relation name without its schema, as RENAME TO
+// keeps a table in its schema
+func unqualifiedName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -8,7 +8,10 @@ import (
 // TableStrategy implements materialization as a full-refresh table
 type TableStrategy struct{}
 
-// Materialize generates SQL to create a table with full refresh
+// Materialize generates SQL to rebuild the table with full refresh. The table
+// is built under a temporary name and swapped into place, so it is never missing.
+// With an enforced contract, the table is created from the contract's DDL
+// before the rows of the query are inserted.
 func (t *TableStrategy) Materialize(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
 	// Validate inputs
 	if strings.TrimSpace(modelName) == "" {
@@ -19,18 +22,15 @@ func (t *TableStrategy) Materialize(modelName string, compiledSQL string, config
 		return nil, fmt.Errorf("compiled SQL cannot be empty")
 	}
 
-	// Generate SQL statements for full refresh
-	statements := make([]string, 0, 2)
-
-	// Drop existing table if it exists
-	dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS %s", modelName)
-	statements = append(statements, dropSQL)
-
-	// Create new table from SELECT query
-	createSQL := fmt.Sprintf("CREATE TABLE %s AS %s", modelName, compiledSQL)
-	statements = append(statements, createSQL)
+	// A table with an enforced contract is created from its declared DDL
+	if config.Contract.Enforced {
+		if err := config.Contract.Validate(); err != nil {
+			return nil, err
+		}
+		return contractTableStatements(modelName, compiledSQL, config.Contract, config.ExistingRelation), nil
+	}
 
-	return statements, nil
+	return swapTableStatements(modelName, compiledSQL, config.ExistingRelation), nil
 }
 
 // Name returns the strategy name
@@ -21,28 +21,44 @@ func TestTableMaterialize(t *testing.T) {
 			config:      MaterializationConfig{Type: MaterializationTable},
 			wantErr:     false,
 			checkSQL: func(t *testing.T, sql []string) {
-				if len(sql) != 2 {
-					t.Errorf("expected 2 SQL statements, got %d", len(sql))
+				want := []string{
+					"DROP TABLE IF EXISTS my_table__gorchata_tmp",
+					"CREATE TABLE my_table__gorchata_tmp AS SELECT id, name FROM source_table",
+					"BEGIN",
+					"PRAGMA legacy_alter_table = ON",
+					"DROP TABLE IF EXISTS my_table",
+					"ALTER TABLE my_table__gorchata_tmp RENAME TO my_table",
+					"PRAGMA legacy_alter_table = OFF",
+					"COMMIT",
 				}
-				// Should drop existing table first
-				if !strings.Contains(sql[0], "DROP TABLE IF EXISTS") {
-					t.Errorf("expected DROP TABLE IF EXISTS, got: %s", sql[0])
+				if strings.Join(sql, "\n") != strings.Join(want, "\n") {
+					t.Errorf("Materialize() =\n%s\nwant\n%s", strings.Join(sql, "\n"), strings.Join(want, "\n"))
 				}
-				if !strings.Contains(sql[0], "my_table") {
-					t.Errorf("expected table name in DROP statement, got: %s", sql[0])
-				}
-				// Should create table from SELECT
-				if !strings.Contains(sql[1], "CREATE TABLE") {
-					t.Errorf("expected CREATE TABLE, got: %s", sql[1])
-				}
-				if !strings.Contains(sql[1], "my_table") {
-					t.Errorf("expected table name in CREATE statement, got: %s", sql[1])
+			},
+		},
+		{
+			name:        "renames into the schema of a qualified table",
+			modelName:   "analytics.my_table",
+			compiledSQL: "SELECT 1",
+			config:      MaterializationConfig{Type: MaterializationTable},
+			wantErr:     false,
+			checkSQL: func(t *testing.T, sql []string) {
+				if sql[1] != "CREATE TABLE analytics.my_table__gorchata_tmp AS SELECT 1" {
+					t.Errorf("expected the temporary table in the schema, got: %s", sql[1])
 				}
-				if !strings.Contains(sql[1], "AS") {
-					t.Errorf("expected AS in CREATE TABLE statement, got: %s", sql[1])
+				if sql[5] != "ALTER TABLE analytics.my_table__gorchata_tmp RENAME TO my_table" {
+					t.Errorf("expected rename to the unqualified name, got: %s", sql[5])
 				}
-				if !strings.Contains(sql[1], "SELECT id, name FROM source_table") {
-					t.Errorf("expected SQL in CREATE TABLE, got: %s", sql[1])
+			},
+		},
+		{
+			name:        "drops the view of an earlier run",
+			modelName:   "my_table",
+			compiledSQL: "SELECT 1",
+			config:      MaterializationConfig{Type: MaterializationTable, ExistingRelation: RelationView},
+			checkSQL: func(t *testing.T, sql []string) {
+				if sql[4] != "DROP VIEW IF EXISTS my_table" {
+					t.Errorf("expected DROP VIEW of the existing view, got: %s", sql[4])
 				}
 			},
 		},
@@ -74,12 +90,12 @@ func TestTableMaterialize(t *testing.T) {
 			config:      MaterializationConfig{Type: MaterializationTable, FullRefresh: true},
 			wantErr:     false,
 			checkSQL: func(t *testing.T, sql []string) {
-				// Should have DROP + CREATE pattern
-				if len(sql) != 2 {
-					t.Errorf("expected 2 SQL statements, got %d", len(sql))
+				// The old table is only dropped once the new one is built, in the swap transaction
+				if sql[2] != BeginTransaction || sql[len(sql)-1] != CommitTransaction {
+					t.Errorf("expected the swap in a transaction, got: %v", sql)
 				}
-				if !strings.Contains(sql[0], "DROP TABLE IF EXISTS existing_table") {
-					t.Errorf("expected DROP for existing_table, got: %s", sql[0])
+				if sql[4] != "DROP TABLE IF EXISTS existing_table" {
+					t.Errorf("expected DROP for existing_table in the transaction, got: %s", sql[4])
 				}
 			},
 		},
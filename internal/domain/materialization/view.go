@@ -8,7 +8,8 @@ import (
 // ViewStrategy implements materialization as a SQL view
 type ViewStrategy struct{}
 
-// Materialize generates SQL to create a view
+// Materialize generates SQL to create a view. The old view is dropped and the
+// new one created in one transaction, so the view is never missing.
 func (v *ViewStrategy) Materialize(modelName string, compiledSQL string, config MaterializationConfig) ([]string, error) {
 	// Validate inputs
 	if strings.TrimSpace(modelName) == "" {
@@ -19,18 +20,18 @@ func (v *ViewStrategy) Materialize(modelName string, compiledSQL string, config
 		return nil, fmt.Errorf("compiled SQL cannot be empty")
 	}
 
-	// Generate SQL statements
-	statements := make([]string, 0, 2)
-
-	// Drop existing view if it exists
-	dropSQL := fmt.Sprintf("DROP VIEW IF EXISTS %s", modelName)
-	statements = append(statements, dropSQL)
-
-	// Create the view
-	createSQL := fmt.Sprintf("CREATE VIEW %s AS %s", modelName, compiledSQL)
-	statements = append(statements, createSQL)
+	// A table built by an earlier run of the model is replaced by the view
+	existing := config.ExistingRelation
+	if existing == "" {
+		existing = RelationView
+	}
 
-	return statements, nil
+	return []string{
+		BeginTransaction,
+		DropRelationStatement(modelName, existing),
+		fmt.Sprintf("CREATE VIEW %s AS %s", modelName, compiledSQL),
+		CommitTransaction,
+	}, nil
 }
 
 // Name returns the strategy name
@@ -21,25 +21,40 @@ func TestViewMaterialize(t *testing.T) {
 			config:      MaterializationConfig{Type: MaterializationView},
 			wantErr:     false,
 			checkSQL: func(t *testing.T, sql []string) {
-				if len(sql) != 2 {
-					t.Errorf("expected 2 SQL statements, got %d", len(sql))
+				if len(sql) != 4 {
+					t.Fatalf("expected 4 SQL statements, got %d", len(sql))
+				}
+				// Should replace the view in one transaction
+				if sql[0] != BeginTransaction || sql[3] != CommitTransaction {
+					t.Errorf("expected statements in a transaction, got: %v", sql)
 				}
 				// Should drop existing view first
-				if !strings.Contains(sql[0], "DROP VIEW IF EXISTS") {
-					t.Errorf("expected DROP VIEW IF EXISTS, got: %s", sql[0])
+				if !strings.Contains(sql[1], "DROP VIEW IF EXISTS") {
+					t.Errorf("expected DROP VIEW IF EXISTS, got: %s", sql[1])
 				}
-				if !strings.Contains(sql[0], "my_view") {
-					t.Errorf("expected view name in DROP statement, got: %s", sql[0])
+				if !strings.Contains(sql[1], "my_view") {
+					t.Errorf("expected view name in DROP statement, got: %s", sql[1])
 				}
 				// Should create view
-				if !strings.Contains(sql[1], "CREATE VIEW") {
-					t.Errorf("expected CREATE VIEW, got: %s", sql[1])
+				if !strings.Contains(sql[2], "CREATE VIEW") {
+					t.Errorf("expected CREATE VIEW, got: %s", sql[2])
 				}
-				if !strings.Contains(sql[1], "my_view") {
-					t.Errorf("expected view name in CREATE statement, got: %s", sql[1])
+				if !strings.Contains(sql[2], "my_view") {
+					t.Errorf("expected view name in CREATE statement, got: %s", sql[2])
 				}
-				if !strings.Contains(sql[1], "SELECT * FROM source_table") {
-					t.Errorf("expected SQL in CREATE VIEW, got: %s", sql[1])
+				if !strings.Contains(sql[2], "SELECT * FROM source_table") {
+					t.Errorf("expected SQL in CREATE VIEW, got: %s", sql[2])
+				}
+			},
+		},
+		{
+			name:        "drops the table of an earlier run",
+			modelName:   "my_view",
+			compiledSQL: "SELECT 1",
+			config:      MaterializationConfig{Type: MaterializationView, ExistingRelation: RelationTable},
+			checkSQL: func(t *testing.T, sql []string) {
+				if sql[1] != "DROP TABLE IF EXISTS my_view" {
+					t.Errorf("expected DROP TABLE of the existing table, got: %s", sql[1])
 				}
 			},
 		},
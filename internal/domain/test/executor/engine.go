@@ -18,6 +18,7 @@ type TestEngine struct {
 	templateEngine *template.Engine
 	sampler        *Sampler
 	failureStore   storage.FailureStore
+	vars           map[string]interface{}
 }
 
 // NewTestEngine creates a new test execution engine
@@ -31,9 +32,15 @@ func NewTestEngine(adapter platform.DatabaseAdapter, templateEngine *template.En
 		templateEngine: templateEngine,
 		sampler:        NewSampler(adapter),
 		failureStore:   failureStore,
+		vars:           make(map[string]interface{}),
 	}, nil
 }
 
+// SetVars sets the variables available to var() when test SQL is rendered
+func (e *TestEngine) SetVars(vars map[string]interface{}) {
+	e.vars = vars
+}
+
 // ExecuteTests executes multiple tests in sequence and returns aggregated results
 func (e *TestEngine) ExecuteTests(ctx context.Context, tests []*test.Test) (*test.TestSummary, error) {
 	summary := test.NewTestSummary()
@@ -69,7 +76,10 @@ func (e *TestEngine) ExecuteTest(ctx context.Context, t *test.Test) (*test.TestR
 			sql = t.SQLTemplate
 		} else {
 			// Create a context for template rendering
-			templateCtx := template.NewContext()
+			templateCtx := template.NewContext(
+				template.WithVars(e.vars),
+				template.WithDatabase(template.NewDatabase(ctx, e.adapter.ExecuteQuery, e.adapter)),
+			)
 			rendered, err := template.Render(tmpl, templateCtx, nil)
 			if err != nil {
 				// If rendering fails, fall back to original SQL
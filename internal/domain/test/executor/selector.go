@@ -12,6 +12,10 @@ type TestSelector struct {
 	excludes []string // Test name patterns to exclude
 	tags     []string // Tag filters
 	models   []string // Model name filters
+
+	// selectedModels restricts tests to the models chosen with the graph
+	// selection syntax; nil means no restriction
+	selectedModels map[string]bool
 }
 
 // NewTestSelector creates a new test selector with the given filters
@@ -24,6 +28,15 @@ func NewTestSelector(includes, excludes, tags, models []string) *TestSelector {
 	}
 }
 
+// SelectModels restricts the selector to tests of the given models, typically
+// the result of a graph selection such as "+fct_orders tag:finance"
+func (s *TestSelector) SelectModels(models []string) {
+	s.selectedModels = make(map[string]bool, len(models))
+	for _, model := range models {
+		s.selectedModels[model] = true
+	}
+}
+
 // Matches returns true if the test matches the selector's criteria
 func (s *TestSelector) Matches(t *test.Test) bool {
 	// Check excludes first (highest priority)
@@ -82,6 +95,11 @@ func (s *TestSelector) Matches(t *test.Test) bool {
 		}
 	}
 
+	// Check graph-selected models
+	if s.selectedModels != nil && !s.selectedModels[t.ModelName] {
+		return false
+	}
+
 	return true
 }
 
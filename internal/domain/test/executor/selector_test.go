@@ -242,3 +242,24 @@ func TestSelector_MultipleIncludes(t *testing.T) {
 		t.Error("Should not match accepted_values pattern")
 	}
 }
+
+func TestSelector_SelectModels(t *testing.T) {
+	selector := NewTestSelector([]string{}, []string{}, []string{}, []string{})
+
+	test1, _ := test.NewTest("not_null_users_email", "not_null", "users", "email", test.GenericTest, "SELECT 1")
+	test2, _ := test.NewTest("unique_orders_id", "unique", "orders", "id", test.GenericTest, "SELECT 1")
+
+	selector.SelectModels([]string{"orders"})
+	if selector.Matches(test1) {
+		t.Error("Selector should not match tests of unselected models")
+	}
+	if !selector.Matches(test2) {
+		t.Error("Selector should match tests of selected models")
+	}
+
+	// An empty graph selection matches no test
+	selector.SelectModels(nil)
+	if selector.Matches(test2) {
+		t.Error("Selector with no selected models should not match any test")
+	}
+}
@@ -2,22 +2,51 @@ package schema
 
 // SchemaFile represents a DBT-compatible schema.yml file
 type SchemaFile struct {
-	Version        int           `yaml:"version"`
-	SeedConfigPath string        `yaml:"seed_config_path,omitempty"`
-	Models         []ModelSchema `yaml:"models"`
+	Version        int            `yaml:"version"`
+	SeedConfigPath string         `yaml:"seed_config_path,omitempty"`
+	Models         []ModelSchema  `yaml:"models"`
+	Sources        []SourceSchema `yaml:"sources,omitempty"`
+}
+
+// SourceSchema represents a source (a set of tables loaded outside of gorchata)
+type SourceSchema struct {
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description,omitempty"`
+	Schema      string              `yaml:"schema,omitempty"`
+	Tables      []SourceTableSchema `yaml:"tables"`
+}
+
+// SourceTableSchema represents a table of a source
+type SourceTableSchema struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Identifier  string `yaml:"identifier,omitempty"`
+	EventTime   string `yaml:"event_time,omitempty"`
 }
 
 // ModelSchema represents a model configuration in a schema file
 type ModelSchema struct {
-	Name        string         `yaml:"name"`
-	Description string         `yaml:"description,omitempty"`
-	Columns     []ColumnSchema `yaml:"columns,omitempty"`
-	DataTests   []interface{}  `yaml:"data_tests,omitempty"` // Table-level tests
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description,omitempty"`
+	Config      map[string]interface{} `yaml:"config,omitempty"` // e.g. contract: {enforced: true}
+	Columns     []ColumnSchema         `yaml:"columns,omitempty"`
+	Constraints []ConstraintSchema     `yaml:"constraints,omitempty"` // Constraints on several columns
+	DataTests   []interface{}          `yaml:"data_tests,omitempty"`  // Table-level tests
 }
 
 // ColumnSchema represents a column configuration
 type ColumnSchema struct {
-	Name        string        `yaml:"name"`
-	Description string        `yaml:"description,omitempty"`
-	DataTests   []interface{} `yaml:"data_tests,omitempty"` // Column-level tests
+	Name        string             `yaml:"name"`
+	Description string             `yaml:"description,omitempty"`
+	DataType    string             `yaml:"data_type,omitempty"`
+	Constraints []ConstraintSchema `yaml:"constraints,omitempty"`
+	DataTests   []interface{}      `yaml:"data_tests,omitempty"` // Column-level tests
+}
+
+// ConstraintSchema represents a constraint of a model's contract: not_null,
+// primary_key, unique, check or foreign_key
+type ConstraintSchema struct {
+	Type       string   `yaml:"type"`
+	Expression string   `yaml:"expression,omitempty"` // check condition, or foreign_key reference like "customers (id)"
+	Columns    []string `yaml:"columns,omitempty"`    // Columns of a model-level constraint
 }
@@ -32,6 +32,38 @@ type DatabaseAdapter interface {
 	BeginTransaction(ctx context.Context) (Transaction, error)
 }
 
+// StatementExecutor is implemented by adapters that can report the number of
+// rows affected by a statement
+type StatementExecutor interface {
+	// ExecuteStatement executes a statement and returns the number of rows it affected
+	ExecuteStatement(ctx context.Context, sql string) (int64, error)
+}
+
+// DatabaseAttacher is implemented by adapters that can make another database
+// available to queries under an alias, as in alias.table
+type DatabaseAttacher interface {
+	// AttachDatabase attaches the database at path under the given alias
+	AttachDatabase(ctx context.Context, path, alias string) error
+}
+
+// SchemaReader reads the tables of the database. Adapters implement it, as
+// do transactions that see the tables created or dropped within them.
+type SchemaReader interface {
+	// TableExists checks if a table exists in the database
+	TableExists(ctx context.Context, table string) (bool, error)
+
+	// GetTableSchema retrieves the schema information for a table
+	GetTableSchema(ctx context.Context, table string) (*Schema, error)
+}
+
+// RelationReader is implemented by schema readers that can tell tables from
+// views
+type RelationReader interface {
+	// RelationType returns "table" or "view" for the relation of that name,
+	// or an empty string when there is none
+	RelationType(ctx context.Context, relation string) (string, error)
+}
+
 // Transaction defines the interface for database transactions
 type Transaction interface {
 	// Commit commits the transaction
@@ -42,4 +74,18 @@ type Transaction interface {
 
 	// Exec executes a statement within the transaction
 	Exec(ctx context.Context, sql string, args ...interface{}) error
+
+	// Query executes a SELECT query within the transaction and returns results
+	Query(ctx context.Context, sql string, args ...interface{}) (*QueryResult, error)
+
+	// Savepoint marks a point of the transaction that it can be rolled back to
+	Savepoint(ctx context.Context, name string) error
+
+	// RollbackToSavepoint undoes the statements executed since the savepoint
+	// was set and releases it
+	RollbackToSavepoint(ctx context.Context, name string) error
+
+	// ReleaseSavepoint removes the savepoint, keeping the statements executed
+	// since it was set
+	ReleaseSavepoint(ctx context.Context, name string) error
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/jpconstantineau/gorchata/internal/platform"
 	_ "modernc.org/sqlite"
@@ -65,7 +67,90 @@ func (a *SQLiteAdapter) Close() error {
 
 // ExecuteQuery executes a SELECT query and returns results
 func (a *SQLiteAdapter) ExecuteQuery(ctx context.Context, sql string, args ...interface{}) (*platform.QueryResult, error) {
-	rows, err := a.db.QueryContext(ctx, sql, args...)
+	return executeQuery(ctx, a.db, sql, args...)
+}
+
+// ExecuteDDL executes a DDL statement (CREATE, ALTER, DROP, INSERT, UPDATE, DELETE)
+func (a *SQLiteAdapter) ExecuteDDL(ctx context.Context, sql string) error {
+	_, err := a.db.ExecContext(ctx, sql)
+	if err != nil {
+		return fmt.Errorf("failed to execute DDL: %w", err)
+	}
+	return nil
+}
+
+// ExecuteStatement executes a statement and returns the number of rows it affected
+func (a *SQLiteAdapter) ExecuteStatement(ctx context.Context, sql string) (int64, error) {
+	res, err := a.db.ExecContext(ctx, sql)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute statement: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil
+	}
+	return rows, nil
+}
+
+// AttachDatabase attaches another SQLite database file under the given alias
+func (a *SQLiteAdapter) AttachDatabase(ctx context.Context, path, alias string) error {
+	stmt := fmt.Sprintf("ATTACH DATABASE '%s' AS %s", strings.ReplaceAll(path, "'", "''"), alias)
+	if _, err := a.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to attach database %s: %w", path, err)
+	}
+	return nil
+}
+
+// TableExists checks if a table exists in the database
+func (a *SQLiteAdapter) TableExists(ctx context.Context, table string) (bool, error) {
+	return tableExists(ctx, a.db, table)
+}
+
+// RelationType returns "table" or "view" for the relation of that name, or
+// an empty string when there is none
+func (a *SQLiteAdapter) RelationType(ctx context.Context, relation string) (string, error) {
+	return relationType(ctx, a.db, relation)
+}
+
+// GetTableSchema retrieves the schema information for a table, including
+// temporary tables
+func (a *SQLiteAdapter) GetTableSchema(ctx context.Context, table string) (*platform.Schema, error) {
+	return tableSchema(ctx, a.db, table)
+}
+
+// CreateTableAs creates a new table from a SELECT query
+func (a *SQLiteAdapter) CreateTableAs(ctx context.Context, table, selectSQL string) error {
+	sql := fmt.Sprintf("CREATE TABLE %s AS %s", table, selectSQL)
+	return a.ExecuteDDL(ctx, sql)
+}
+
+// CreateView creates a view from a SELECT query
+func (a *SQLiteAdapter) CreateView(ctx context.Context, view, selectSQL string) error {
+	sql := fmt.Sprintf("CREATE VIEW %s AS %s", view, selectSQL)
+	return a.ExecuteDDL(ctx, sql)
+}
+
+// BeginTransaction starts a new transaction
+func (a *SQLiteAdapter) BeginTransaction(ctx context.Context) (platform.Transaction, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &sqliteTransaction{tx: tx}, nil
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx, so that the adapter and its
+// transactions query the database the same way
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// executeQuery executes a SELECT query with q and returns results
+func executeQuery(ctx context.Context, q queryer, sql string, args ...interface{}) (*platform.QueryResult, error) {
+	rows, err := q.QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -108,20 +193,11 @@ func (a *SQLiteAdapter) ExecuteQuery(ctx context.Context, sql string, args ...in
 	return result, nil
 }
 
-// ExecuteDDL executes a DDL statement (CREATE, ALTER, DROP, INSERT, UPDATE, DELETE)
-func (a *SQLiteAdapter) ExecuteDDL(ctx context.Context, sql string) error {
-	_, err := a.db.ExecContext(ctx, sql)
-	if err != nil {
-		return fmt.Errorf("failed to execute DDL: %w", err)
-	}
-	return nil
-}
-
-// TableExists checks if a table exists in the database
-func (a *SQLiteAdapter) TableExists(ctx context.Context, table string) (bool, error) {
+// tableExists checks with q if a table exists in the database
+func tableExists(ctx context.Context, q queryer, table string) (bool, error) {
 	query := "SELECT name FROM sqlite_master WHERE type='table' AND name=?"
 	var name string
-	err := a.db.QueryRowContext(ctx, query, table).Scan(&name)
+	err := q.QueryRowContext(ctx, query, table).Scan(&name)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -131,20 +207,32 @@ func (a *SQLiteAdapter) TableExists(ctx context.Context, table string) (bool, er
 	return true, nil
 }
 
-// GetTableSchema retrieves the schema information for a table
-func (a *SQLiteAdapter) GetTableSchema(ctx context.Context, table string) (*platform.Schema, error) {
-	// First check if table exists
-	exists, err := a.TableExists(ctx, table)
-	if err != nil {
-		return nil, err
+// relationType returns with q the type of the relation of that name, reading
+// the sqlite_master of its schema when the name is qualified
+func relationType(ctx context.Context, q queryer, relation string) (string, error) {
+	master, name := "sqlite_master", relation
+	if i := strings.LastIndex(relation, "."); i >= 0 {
+		master, name = relation[:i]+".sqlite_master", relation[i+1:]
 	}
-	if !exists {
-		return nil, fmt.Errorf("table %q does not exist", table)
+
+	query := fmt.Sprintf("SELECT type FROM %s WHERE name=? AND type IN ('table', 'view')", master)
+	var relType string
+	err := q.QueryRowContext(ctx, query, name).Scan(&relType)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check relation type: %w", err)
 	}
+	return relType, nil
+}
 
-	// Query table info
+// tableSchema retrieves with q the schema information for a table, including
+// temporary tables
+func tableSchema(ctx context.Context, q queryer, table string) (*platform.Schema, error) {
+	// PRAGMA table_info returns no rows for a table that does not exist
 	query := fmt.Sprintf("PRAGMA table_info(%s)", table)
-	rows, err := a.db.QueryContext(ctx, query)
+	rows, err := q.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get table schema: %w", err)
 	}
@@ -170,6 +258,7 @@ func (a *SQLiteAdapter) GetTableSchema(ctx context.Context, table string) (*plat
 			Type:       colType,
 			Nullable:   notNull == 0,
 			PrimaryKey: pk > 0,
+			Referenced: pk > 0,
 		}
 		schema.Columns = append(schema.Columns, column)
 	}
@@ -177,27 +266,66 @@ func (a *SQLiteAdapter) GetTableSchema(ctx context.Context, table string) (*plat
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating column info: %w", err)
 	}
+	if len(schema.Columns) == 0 {
+		return nil, fmt.Errorf("table %q does not exist", table)
+	}
+
+	referenced, err := referencedColumns(ctx, q, table, schema.Columns)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schema.Columns {
+		if referenced[strings.ToLower(schema.Columns[i].Name)] {
+			schema.Columns[i].Referenced = true
+		}
+	}
 
 	return schema, nil
 }
 
-// CreateTableAs creates a new table from a SELECT query
-func (a *SQLiteAdapter) CreateTableAs(ctx context.Context, table, selectSQL string) error {
-	sql := fmt.Sprintf("CREATE TABLE %s AS %s", table, selectSQL)
-	return a.ExecuteDDL(ctx, sql)
-}
+// referencedColumns returns with q the lowercased names of the columns of a
+// table that ALTER TABLE DROP COLUMN refuses to drop: those of its indexes,
+// including the ones backing UNIQUE and PRIMARY KEY constraints, and those
+// named by a view or trigger. Views and triggers are matched on the column
+// name alone, so a column may be reported that is not actually used.
+func referencedColumns(ctx context.Context, q queryer, table string, columns []platform.Column) (map[string]bool, error) {
+	referenced := make(map[string]bool)
 
-// CreateView creates a view from a SELECT query
-func (a *SQLiteAdapter) CreateView(ctx context.Context, view, selectSQL string) error {
-	sql := fmt.Sprintf("CREATE VIEW %s AS %s", view, selectSQL)
-	return a.ExecuteDDL(ctx, sql)
-}
+	indexes, err := executeQuery(ctx, q, fmt.Sprintf("PRAGMA index_list(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	for _, index := range indexes.Rows {
+		info, err := executeQuery(ctx, q, fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(fmt.Sprint(index[1]))))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get index info: %w", err)
+		}
+		for _, row := range info.Rows {
+			// Expressions of indexes have no column name
+			if name, ok := row[2].(string); ok {
+				referenced[strings.ToLower(name)] = true
+			}
+		}
+	}
 
-// BeginTransaction starts a new transaction
-func (a *SQLiteAdapter) BeginTransaction(ctx context.Context) (platform.Transaction, error) {
-	tx, err := a.db.BeginTx(ctx, nil)
+	// Partial and expression indexes, views and triggers are found in their SQL
+	name := table[strings.LastIndex(table, ".")+1:]
+	query := `SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND (type IN ('view', 'trigger') OR (type = 'index' AND tbl_name = ?1))
+UNION ALL
+SELECT sql FROM sqlite_temp_master WHERE sql IS NOT NULL AND (type IN ('view', 'trigger') OR (type = 'index' AND tbl_name = ?1))`
+	dependents, err := executeQuery(ctx, q, query, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to list views and triggers: %w", err)
 	}
-	return &sqliteTransaction{tx: tx}, nil
+	for _, column := range columns {
+		word := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(column.Name) + `\b`)
+		for _, row := range dependents.Rows {
+			if sql, ok := row[0].(string); ok && word.MatchString(sql) {
+				referenced[strings.ToLower(column.Name)] = true
+				break
+			}
+		}
+	}
+
+	return referenced, nil
 }
@@ -108,6 +108,65 @@ func TestExecuteDDL(t *testing.T) {
 	}
 }
 
+func TestExecuteStatement(t *testing.T) {
+	tmpDir := t.TempDir()
+	adapter := NewSQLiteAdapter(&platform.ConnectionConfig{DatabasePath: filepath.Join(tmpDir, "test.db")})
+
+	ctx := context.Background()
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	if _, err := adapter.ExecuteStatement(ctx, "CREATE TABLE items (id INTEGER)"); err != nil {
+		t.Fatalf("ExecuteStatement(CREATE) error = %v", err)
+	}
+
+	rows, err := adapter.ExecuteStatement(ctx, "INSERT INTO items VALUES (1), (2), (3)")
+	if err != nil {
+		t.Fatalf("ExecuteStatement(INSERT) error = %v", err)
+	}
+	if rows != 3 {
+		t.Errorf("rows affected = %d, want 3", rows)
+	}
+
+	if _, err := adapter.ExecuteStatement(ctx, "INSERT INTO missing VALUES (1)"); err == nil {
+		t.Error("expected error for missing table")
+	}
+}
+
+func TestAttachDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+
+	other := NewSQLiteAdapter(&platform.ConnectionConfig{DatabasePath: filepath.Join(tmpDir, "other.db")})
+	if err := other.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if err := other.ExecuteDDL(ctx, "CREATE TABLE items AS SELECT 1 AS id"); err != nil {
+		t.Fatalf("ExecuteDDL() error = %v", err)
+	}
+	other.Close()
+
+	adapter := NewSQLiteAdapter(&platform.ConnectionConfig{DatabasePath: filepath.Join(tmpDir, "test.db")})
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	if err := adapter.AttachDatabase(ctx, filepath.Join(tmpDir, "other.db"), "other"); err != nil {
+		t.Fatalf("AttachDatabase() error = %v", err)
+	}
+
+	result, err := adapter.ExecuteQuery(ctx, "SELECT id FROM other.items")
+	if err != nil {
+		t.Fatalf("ExecuteQuery() error = %v", err)
+	}
+	if len(result.Rows) != 1 {
+		t.Errorf("got %d rows from attached database, want 1", len(result.Rows))
+	}
+}
+
 func TestTableExists(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -149,6 +208,48 @@ func TestTableExists(t *testing.T) {
 	}
 }
 
+func TestRelationType(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	adapter := NewSQLiteAdapter(&platform.ConnectionConfig{DatabasePath: filepath.Join(tmpDir, "test.db")})
+	ctx := context.Background()
+
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	for _, sql := range []string{
+		"CREATE TABLE orders (id INTEGER)",
+		"CREATE VIEW order_ids AS SELECT id FROM orders",
+		"CREATE INDEX orders_id ON orders (id)",
+	} {
+		if err := adapter.ExecuteDDL(ctx, sql); err != nil {
+			t.Fatalf("ExecuteDDL() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		relation string
+		want     string
+	}{
+		{"orders", "table"},
+		{"order_ids", "view"},
+		{"main.order_ids", "view"},
+		{"orders_id", ""},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		got, err := adapter.RelationType(ctx, tt.relation)
+		if err != nil {
+			t.Fatalf("RelationType(%s) error = %v", tt.relation, err)
+		}
+		if got != tt.want {
+			t.Errorf("RelationType(%s) = %q, want %q", tt.relation, got, tt.want)
+		}
+	}
+}
+
 func TestExecuteQuery(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -298,6 +399,65 @@ func TestGetTableSchema(t *testing.T) {
 	}
 }
 
+func TestGetTableSchemaReferenced(t *testing.T) {
+	adapter := NewSQLiteAdapter(&platform.ConnectionConfig{DatabasePath: filepath.Join(t.TempDir(), "test.db")})
+	ctx := context.Background()
+
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	statements := []string{
+		"CREATE TABLE referenced_test (id INTEGER, code TEXT UNIQUE, region TEXT, amount REAL, note TEXT)",
+		"CREATE INDEX referenced_test__region ON referenced_test (region)",
+		"CREATE INDEX referenced_test__amount ON referenced_test (ROUND(amount))",
+		"CREATE VIEW referenced_view AS SELECT id FROM referenced_test",
+	}
+	for _, statement := range statements {
+		if err := adapter.ExecuteDDL(ctx, statement); err != nil {
+			t.Fatalf("ExecuteDDL(%s) error = %v", statement, err)
+		}
+	}
+
+	schema, err := adapter.GetTableSchema(ctx, "referenced_test")
+	if err != nil {
+		t.Fatalf("GetTableSchema() error = %v", err)
+	}
+
+	want := map[string]bool{"id": true, "code": true, "region": true, "amount": true, "note": false}
+	for _, column := range schema.Columns {
+		if column.Referenced != want[column.Name] {
+			t.Errorf("column %s Referenced = %v, want %v", column.Name, column.Referenced, want[column.Name])
+		}
+	}
+}
+
+func TestGetTableSchemaTemp(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	adapter := NewSQLiteAdapter(&platform.ConnectionConfig{DatabasePath: dbPath})
+	ctx := context.Background()
+
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	if err := adapter.ExecuteDDL(ctx, "CREATE TEMP TABLE temp_test AS SELECT 1 AS id, 'a' AS name LIMIT 0"); err != nil {
+		t.Fatalf("ExecuteDDL() error = %v", err)
+	}
+
+	schema, err := adapter.GetTableSchema(ctx, "temp_test")
+	if err != nil {
+		t.Fatalf("GetTableSchema() error = %v", err)
+	}
+	if len(schema.Columns) != 2 || schema.Columns[0].Name != "id" || schema.Columns[1].Name != "name" {
+		t.Errorf("columns = %+v, want id and name", schema.Columns)
+	}
+}
+
 func TestGetTableSchemaMissing(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -457,3 +617,30 @@ func TestBeginTransaction(t *testing.T) {
 		t.Errorf("Rollback() error = %v", err)
 	}
 }
+
+func TestMD5Function(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &platform.ConnectionConfig{
+		DatabasePath: filepath.Join(tmpDir, "test.db"),
+	}
+
+	adapter := NewSQLiteAdapter(config)
+	ctx := context.Background()
+
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	result, err := adapter.ExecuteQuery(ctx, "SELECT md5('abc'), md5(123), md5(NULL)")
+	if err != nil {
+		t.Fatalf("ExecuteQuery() error = %v", err)
+	}
+
+	want := []interface{}{"900150983cd24fb0d6963f7d28e17f72", "202cb962ac59075b964b07152d234b70", nil}
+	for i, value := range result.Rows[0] {
+		if value != want[i] {
+			t.Errorf("column %d = %v, want %v", i, value, want[i])
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package sqlite
+
+import (
+	"crypto/md5"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+
+	moderncsqlite "modernc.org/sqlite"
+)
+
+// SQLite has no hash functions, so the adapter registers the ones templates
+// generate SQL for, such as generate_surrogate_key
+func init() {
+	moderncsqlite.MustRegisterDeterministicScalarFunction("md5", 1, md5Hex)
+}
+
+// md5Hex implements md5(value), the hex MD5 hash of the text of a value, or
+// NULL for NULL
+func md5Hex(ctx *moderncsqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var data []byte
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		data = []byte(fmt.Sprint(v))
+	}
+
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
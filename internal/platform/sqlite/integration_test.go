@@ -279,6 +279,92 @@ func TestTransactionRollback(t *testing.T) {
 	}
 }
 
+func TestTransactionSavepoints(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "tx_savepoints.db")
+
+	config := &platform.ConnectionConfig{
+		DatabasePath: dbPath,
+	}
+
+	adapter := NewSQLiteAdapter(config)
+	ctx := context.Background()
+
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	tx, err := adapter.BeginTransaction(ctx)
+	if err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+
+	// DDL is transactional: the table is only visible within the transaction
+	if err := tx.Exec(ctx, "CREATE TABLE orders (id INTEGER)"); err != nil {
+		t.Fatalf("tx.Exec(CREATE) error = %v", err)
+	}
+
+	// A released savepoint keeps its statements
+	if err := tx.Savepoint(ctx, "first"); err != nil {
+		t.Fatalf("Savepoint() error = %v", err)
+	}
+	if err := tx.Exec(ctx, "INSERT INTO orders VALUES (1)"); err != nil {
+		t.Fatalf("tx.Exec(INSERT) error = %v", err)
+	}
+	if err := tx.ReleaseSavepoint(ctx, "first"); err != nil {
+		t.Fatalf("ReleaseSavepoint() error = %v", err)
+	}
+
+	// A savepoint rolled back to undoes its statements, including DDL
+	if err := tx.Savepoint(ctx, "second"); err != nil {
+		t.Fatalf("Savepoint() error = %v", err)
+	}
+	if err := tx.Exec(ctx, "INSERT INTO orders VALUES (2)"); err != nil {
+		t.Fatalf("tx.Exec(INSERT) error = %v", err)
+	}
+	if err := tx.Exec(ctx, "CREATE TABLE customers (id INTEGER)"); err != nil {
+		t.Fatalf("tx.Exec(CREATE) error = %v", err)
+	}
+	if err := tx.RollbackToSavepoint(ctx, "second"); err != nil {
+		t.Fatalf("RollbackToSavepoint() error = %v", err)
+	}
+
+	result, err := tx.Query(ctx, "SELECT id FROM orders")
+	if err != nil {
+		t.Fatalf("tx.Query() error = %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0].(int64) != 1 {
+		t.Errorf("expected only order 1 within the transaction, got %v", result.Rows)
+	}
+
+	reader, ok := tx.(platform.SchemaReader)
+	if !ok {
+		t.Fatal("expected the transaction to implement platform.SchemaReader")
+	}
+	if exists, err := reader.TableExists(ctx, "orders"); err != nil || !exists {
+		t.Errorf("TableExists(orders) = %v, %v, want true", exists, err)
+	}
+	if exists, err := reader.TableExists(ctx, "customers"); err != nil || exists {
+		t.Errorf("TableExists(customers) = %v, %v, want false after rolling back its savepoint", exists, err)
+	}
+	if _, err := reader.GetTableSchema(ctx, "orders"); err != nil {
+		t.Errorf("GetTableSchema(orders) error = %v", err)
+	}
+
+	// Rolling back the transaction drops the table created within it
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	exists, err := adapter.TableExists(ctx, "orders")
+	if err != nil {
+		t.Fatalf("TableExists() error = %v", err)
+	}
+	if exists {
+		t.Error("expected orders to be dropped by the rollback")
+	}
+}
+
 func TestMultipleConnections(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "multi_conn.db")
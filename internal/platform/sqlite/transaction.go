@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+
+	"github.com/jpconstantineau/gorchata/internal/platform"
 )
 
 // sqliteTransaction implements the Transaction interface
@@ -35,3 +38,73 @@ func (t *sqliteTransaction) Exec(ctx context.Context, sql string, args ...interf
 	}
 	return nil
 }
+
+// ExecuteStatement executes a statement within the transaction and returns
+// the number of rows it affected
+func (t *sqliteTransaction) ExecuteStatement(ctx context.Context, sql string) (int64, error) {
+	res, err := t.tx.ExecContext(ctx, sql)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute statement in transaction: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil
+	}
+	return rows, nil
+}
+
+// Query executes a SELECT query within the transaction and returns results
+func (t *sqliteTransaction) Query(ctx context.Context, sql string, args ...interface{}) (*platform.QueryResult, error) {
+	return executeQuery(ctx, t.tx, sql, args...)
+}
+
+// TableExists checks if a table exists, including tables created within the transaction
+func (t *sqliteTransaction) TableExists(ctx context.Context, table string) (bool, error) {
+	return tableExists(ctx, t.tx, table)
+}
+
+// RelationType returns "table" or "view" for the relation of that name, as
+// seen from within the transaction
+func (t *sqliteTransaction) RelationType(ctx context.Context, relation string) (string, error) {
+	return relationType(ctx, t.tx, relation)
+}
+
+// GetTableSchema retrieves the schema information for a table as seen from
+// within the transaction
+func (t *sqliteTransaction) GetTableSchema(ctx context.Context, table string) (*platform.Schema, error) {
+	return tableSchema(ctx, t.tx, table)
+}
+
+// Savepoint marks a point of the transaction that it can be rolled back to
+func (t *sqliteTransaction) Savepoint(ctx context.Context, name string) error {
+	if _, err := t.tx.ExecContext(ctx, fmt.Sprintf("SAVEPOINT %s", quoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to create savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// RollbackToSavepoint undoes the statements executed since the savepoint was
+// set and releases it
+func (t *sqliteTransaction) RollbackToSavepoint(ctx context.Context, name string) error {
+	// ROLLBACK TO leaves the savepoint on the stack, RELEASE removes it
+	for _, stmt := range []string{"ROLLBACK TO SAVEPOINT %s", "RELEASE SAVEPOINT %s"} {
+		if _, err := t.tx.ExecContext(ctx, fmt.Sprintf(stmt, quoteIdentifier(name))); err != nil {
+			return fmt.Errorf("failed to rollback to savepoint %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ReleaseSavepoint removes the savepoint, keeping the statements executed since it was set
+func (t *sqliteTransaction) ReleaseSavepoint(ctx context.Context, name string) error {
+	if _, err := t.tx.ExecContext(ctx, fmt.Sprintf("RELEASE SAVEPOINT %s", quoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to release savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a name as a SQLite identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
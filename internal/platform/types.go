@@ -25,4 +25,7 @@ type Column struct {
 	Type       string
 	Nullable   bool
 	PrimaryKey bool
+	// Referenced reports that an index, a UNIQUE or PRIMARY KEY constraint, a
+	// view or a trigger uses the column, so that it cannot be dropped in place
+	Referenced bool
 }
@@ -28,6 +28,48 @@ type Context struct {
 	// Seeds maps seed names to their qualified table names
 	// Structure: Seeds[seedName] = qualifiedTableName
 	Seeds map[string]string
+
+	// Relations maps model names to the database relation they materialize into
+	// when it differs from the model name (e.g. a model configured with an alias)
+	// Structure: Relations[modelName] = relationName
+	Relations map[string]string
+
+	// BatchStart and BatchEnd delimit the microbatch being built, formatted
+	// for comparison with event time columns. Both are empty outside of a batch.
+	BatchStart string
+	BatchEnd   string
+
+	// EventTimes maps model names to their event time column. During a batch,
+	// ref() to these models only returns the rows of the batch.
+	EventTimes map[string]string
+
+	// SourceEventTimes maps source and table names to their event time column.
+	// During a batch, source() to these tables only returns the rows of the batch.
+	// Structure: SourceEventTimes[sourceName][tableName] = column
+	SourceEventTimes map[string]map[string]string
+
+	// Target is the profile output the project runs against
+	Target Target
+
+	// Database is queried by run_query and the other introspective
+	// functions. It is nil in the parse phase, when they return placeholders.
+	Database *Database
+}
+
+// Target describes the profile output the project runs against, as returned
+// by {{ target }}, e.g. {{ target.Name }}
+type Target struct {
+	// Name is the name of the output in profiles.yml
+	Name string
+
+	// Type is the adapter type, e.g. "sqlite"
+	Type string
+
+	// Database is the database the output connects to
+	Database string
+
+	// Threads is the number of models executed concurrently
+	Threads int
 }
 
 // ContextOption configures a Context.
@@ -36,10 +78,13 @@ type ContextOption func(*Context)
 // NewContext creates a new template context with the given options.
 func NewContext(opts ...ContextOption) *Context {
 	ctx := &Context{
-		Vars:    make(map[string]interface{}),
-		Config:  make(map[string]interface{}),
-		Sources: make(map[string]map[string]string),
-		Seeds:   make(map[string]string),
+		Vars:             make(map[string]interface{}),
+		Config:           make(map[string]interface{}),
+		Sources:          make(map[string]map[string]string),
+		Seeds:            make(map[string]string),
+		Relations:        make(map[string]string),
+		EventTimes:       make(map[string]string),
+		SourceEventTimes: make(map[string]map[string]string),
 	}
 
 	for _, opt := range opts {
@@ -84,6 +129,20 @@ func WithSources(sources map[string]map[string]string) ContextOption {
 	}
 }
 
+// WithSeeds sets the seed table names for the context.
+func WithSeeds(seeds map[string]string) ContextOption {
+	return func(c *Context) {
+		c.Seeds = seeds
+	}
+}
+
+// WithRelations sets the model name to relation name mapping for the context.
+func WithRelations(relations map[string]string) ContextOption {
+	return func(c *Context) {
+		c.Relations = relations
+	}
+}
+
 // WithIsIncremental sets the incremental execution flag for the context.
 func WithIsIncremental(isIncremental bool) ContextOption {
 	return func(c *Context) {
@@ -97,3 +156,27 @@ func WithCurrentModelTable(tableName string) ContextOption {
 		c.CurrentModelTable = tableName
 	}
 }
+
+// WithBatch sets the bounds of the microbatch being built.
+func WithBatch(start, end string) ContextOption {
+	return func(c *Context) {
+		c.BatchStart = start
+		c.BatchEnd = end
+	}
+}
+
+// WithTarget sets the profile output the project runs against.
+func WithTarget(target Target) ContextOption {
+	return func(c *Context) {
+		c.Target = target
+	}
+}
+
+// WithEventTimes sets the event time columns of models and source tables,
+// used to filter them to the current batch.
+func WithEventTimes(models map[string]string, sources map[string]map[string]string) ContextOption {
+	return func(c *Context) {
+		c.EventTimes = models
+		c.SourceEventTimes = sources
+	}
+}
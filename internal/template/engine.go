@@ -1,12 +1,14 @@
 package template
 
 import (
+	"fmt"
 	"text/template"
 )
 
 // Engine wraps text/template and provides custom function support.
 type Engine struct {
 	tracker    DependencyTracker
+	macros     *Macros
 	leftDelim  string
 	rightDelim string
 }
@@ -35,6 +37,13 @@ func WithDependencyTracker(tracker DependencyTracker) EngineOption {
 	}
 }
 
+// WithMacros makes the macros callable from every template the engine parses.
+func WithMacros(macros *Macros) EngineOption {
+	return func(e *Engine) {
+		e.macros = macros
+	}
+}
+
 // WithDelimiters sets custom template delimiters.
 func WithDelimiters(left, right string) EngineOption {
 	return func(e *Engine) {
@@ -43,6 +52,27 @@ func WithDelimiters(left, right string) EngineOption {
 	}
 }
 
+// RenderMacro renders the engine's macro with the given name, with arguments
+// passed by parameter name, and the functions bound to ctx.
+func (e *Engine) RenderMacro(name string, ctx *Context, args map[string]interface{}) (string, error) {
+	macro, ok := e.macros.Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("macro %s is not defined", name)
+	}
+	if ctx == nil {
+		ctx = NewContext()
+	}
+
+	data, err := macro.namedArguments(args)
+	if err != nil {
+		return "", err
+	}
+
+	funcMap := BuildFuncMap(ctx, e.tracker)
+	e.macros.bind(funcMap)
+	return e.macros.execute(macro, funcMap, data)
+}
+
 // Parse parses a template with the given name and content.
 func (e *Engine) Parse(name, content string) (*Template, error) {
 	// Create a new text/template
@@ -54,8 +84,14 @@ func (e *Engine) Parse(name, content string) (*Template, error) {
 	// Add functions with a default context for parsing
 	// These will be replaced with actual context during rendering
 	funcMap := BuildFuncMap(NewContext(), e.tracker)
+	e.macros.bind(funcMap)
 	tmpl = tmpl.Funcs(funcMap)
 
+	// Macros can also be executed with {{ template "name" . }}
+	if err := e.macros.addTemplates(tmpl); err != nil {
+		return nil, err
+	}
+
 	// Parse the template content
 	parsed, err := tmpl.Parse(content)
 	if err != nil {
@@ -15,11 +15,42 @@ func BuildFuncMap(ctx *Context, tracker DependencyTracker) template.FuncMap {
 	return template.FuncMap{
 		"ref":            makeRefFunc(ctx, tracker),
 		"var":            makeVarFunc(ctx),
-		"config":         makeConfigFunc(ctx), // For accessing config values; materialization directives parsed separately
+		"config":         makeConfigFunc(ctx), // Reads a config value, or sets model config when given key/value pairs
+		"list":           makeListFunc(),
+		"dict":           makeDictFunc(),
 		"source":         makeSourceFunc(ctx),
 		"seed":           makeSeedFunc(ctx),
 		"env_var":        makeEnvVarFunc(),
 		"is_incremental": makeIsIncrementalFunc(ctx),
 		"this":           makeThisFunc(ctx),
+		"batch_start":    makeBatchStartFunc(ctx),
+		"batch_end":      makeBatchEndFunc(ctx),
+		"target":         makeTargetFunc(ctx),
+
+		// Introspective functions query the database when the template
+		// renders with one, and return placeholders otherwise
+		"execute":           makeExecuteFunc(ctx),
+		"run_query":         makeRunQueryFunc(ctx),
+		"get_columns":       makeGetColumnsFunc(ctx),
+		"relation_exists":   makeRelationExistsFunc(ctx),
+		"get_column_values": makeGetColumnValuesFunc(ctx),
+
+		// SQL helpers generate common SQL patterns; those reading the columns
+		// of relations return placeholders without a database too
+		"star":                   makeStarFunc(ctx),
+		"generate_surrogate_key": makeGenerateSurrogateKeyFunc(),
+		"date_spine":             makeDateSpineFunc(),
+		"union_relations":        makeUnionRelationsFunc(ctx),
+		"pivot":                  makePivotFunc(),
+		"unpivot":                makeUnpivotFunc(ctx),
+		"deduplicate":            makeDeduplicateFunc(ctx),
+		"safe_divide":            makeSafeDivideFunc(),
+
+		// Time-series helpers generate SQL over timestamped events
+		"time_bucket":      makeTimeBucketFunc(),
+		"state_durations":  makeStateDurationsFunc(),
+		"sessionize":       makeSessionizeFunc(ctx),
+		"gap_fill":         makeGapFillFunc(),
+		"interval_overlap": makeIntervalOverlapFunc(),
 	}
 }
@@ -20,20 +20,46 @@ func makeRefFunc(ctx *Context, tracker DependencyTracker) func(string) string {
 			_ = tracker.AddDependency(ctx.CurrentModel, modelName)
 		}
 
+		// Resolve the model's relation name (differs from the model name when aliased)
+		relation := modelName
+		if alias, ok := ctx.Relations[modelName]; ok && alias != "" {
+			relation = alias
+		}
+
 		// Return qualified table name
 		if ctx.Schema != "" {
-			return fmt.Sprintf("%s.%s", ctx.Schema, modelName)
+			relation = fmt.Sprintf("%s.%s", ctx.Schema, relation)
 		}
-		return modelName
+		return batchFilter(ctx, relation, ctx.EventTimes[modelName])
+	}
+}
+
+// batchFilter restricts a relation to the rows of the current microbatch when
+// it has an event time column, e.g. (SELECT * FROM orders WHERE order_date >=
+// '2024-01-01' AND order_date < '2024-01-02'). Outside of a batch the relation
+// is returned unchanged.
+func batchFilter(ctx *Context, relation, eventTime string) string {
+	if ctx.BatchStart == "" || eventTime == "" {
+		return relation
 	}
+	return fmt.Sprintf("(SELECT * FROM %s WHERE %s >= '%s' AND %s < '%s')",
+		relation, eventTime, ctx.BatchStart, eventTime, ctx.BatchEnd)
 }
 
 // makeVarFunc creates a var() function for template use.
-// Retrieves value from Context.Vars map, returns error if not found.
-func makeVarFunc(ctx *Context) func(string) (interface{}, error) {
-	return func(varName string) (interface{}, error) {
+// Retrieves value from Context.Vars map. An optional second argument is returned
+// as the default when the variable is not set; otherwise a missing variable is an error.
+func makeVarFunc(ctx *Context) func(string, ...interface{}) (interface{}, error) {
+	return func(varName string, defaults ...interface{}) (interface{}, error) {
+		if len(defaults) > 1 {
+			return nil, fmt.Errorf("var accepts at most one default value, got %d", len(defaults))
+		}
+
 		val, ok := ctx.Vars[varName]
 		if !ok {
+			if len(defaults) == 1 {
+				return defaults[0], nil
+			}
 			return nil, fmt.Errorf("variable not found: %s", varName)
 		}
 		return val, nil
@@ -41,20 +67,132 @@ func makeVarFunc(ctx *Context) func(string) (interface{}, error) {
 }
 
 // makeConfigFunc creates a config() function for template use.
-// Access configuration from Context.Config, supports dot notation for nested keys.
-func makeConfigFunc(ctx *Context) func(string) (interface{}, error) {
-	return func(key string) (interface{}, error) {
-		// Support dot notation for nested keys
-		if strings.Contains(key, ".") {
-			return getNestedConfig(ctx.Config, key)
+// With a single key it reads a value from Context.Config, supporting dot notation
+// for nested keys. With key/value pairs or a map it sets configuration on the
+// context and renders nothing, e.g.:
+//
+//	{{ config "materialized" "incremental" "unique_key" "id,ts" }}
+//	{{ config (dict "materialized" "incremental" "unique_key" (list "id" "ts")) }}
+//
+// Hook keys (pre_hook, post_hook) accumulate across calls instead of overwriting.
+func makeConfigFunc(ctx *Context) func(...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		switch {
+		case len(args) == 0:
+			return nil, fmt.Errorf("config requires a key, key/value pairs or a map")
+
+		case len(args) == 1:
+			// Map form sets every entry
+			if values, ok := args[0].(map[string]interface{}); ok {
+				for key, value := range values {
+					setConfigValue(ctx, key, value)
+				}
+				return "", nil
+			}
+
+			key, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("config key must be a string, got %T", args[0])
+			}
+
+			// Support dot notation for nested keys
+			if strings.Contains(key, ".") {
+				return getNestedConfig(ctx.Config, key)
+			}
+
+			// Simple key lookup
+			val, ok := ctx.Config[key]
+			if !ok {
+				return nil, fmt.Errorf("config key not found: %s", key)
+			}
+			return val, nil
+
+		case len(args)%2 != 0:
+			return nil, fmt.Errorf("config requires key/value pairs, got %d arguments", len(args))
+
+		default:
+			for i := 0; i < len(args); i += 2 {
+				key, ok := args[i].(string)
+				if !ok {
+					return nil, fmt.Errorf("config key must be a string, got %T", args[i])
+				}
+				setConfigValue(ctx, key, args[i+1])
+			}
+			return "", nil
 		}
+	}
+}
 
-		// Simple key lookup
-		val, ok := ctx.Config[key]
-		if !ok {
-			return nil, fmt.Errorf("config key not found: %s", key)
+// setConfigValue stores a config value on the context.
+// Hook values are appended so that several hook declarations can coexist.
+func setConfigValue(ctx *Context, key string, value interface{}) {
+	if ctx.Config == nil {
+		ctx.Config = make(map[string]interface{})
+	}
+
+	if isHookKey(key) {
+		ctx.Config[key] = appendConfigList(ctx.Config[key], value)
+		return
+	}
+
+	ctx.Config[key] = value
+}
+
+// isHookKey reports whether a config key holds hook statements
+func isHookKey(key string) bool {
+	switch strings.TrimPrefix(key, "+") {
+	case "pre_hook", "post_hook", "pre-hook", "post-hook":
+		return true
+	}
+	return false
+}
+
+// appendConfigList appends a value (scalar or list) to an existing config list
+func appendConfigList(existing, value interface{}) []interface{} {
+	var result []interface{}
+
+	for _, v := range []interface{}{existing, value} {
+		switch items := v.(type) {
+		case nil:
+		case []interface{}:
+			result = append(result, items...)
+		case []string:
+			for _, item := range items {
+				result = append(result, item)
+			}
+		default:
+			result = append(result, items)
 		}
-		return val, nil
+	}
+
+	return result
+}
+
+// makeListFunc creates a list() function that builds a list from its arguments,
+// e.g. {{ config "unique_key" (list "id" "ts") }}
+func makeListFunc() func(...interface{}) []interface{} {
+	return func(items ...interface{}) []interface{} {
+		return items
+	}
+}
+
+// makeDictFunc creates a dict() function that builds a map from key/value pairs,
+// e.g. {{ config (dict "materialized" "table" "alias" "orders") }}
+func makeDictFunc() func(...interface{}) (map[string]interface{}, error) {
+	return func(pairs ...interface{}) (map[string]interface{}, error) {
+		if len(pairs)%2 != 0 {
+			return nil, fmt.Errorf("dict requires key/value pairs, got %d arguments", len(pairs))
+		}
+
+		result := make(map[string]interface{}, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			key, ok := pairs[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("dict key must be a string, got %T", pairs[i])
+			}
+			result[key] = pairs[i+1]
+		}
+		return result, nil
 	}
 }
 
@@ -96,7 +234,7 @@ func makeSourceFunc(ctx *Context) func(string, string) (string, error) {
 			return "", fmt.Errorf("table %s not found in source %s", tableName, sourceName)
 		}
 
-		return qualifiedName, nil
+		return batchFilter(ctx, qualifiedName, ctx.SourceEventTimes[sourceName][tableName]), nil
 	}
 }
 
@@ -165,3 +303,29 @@ func makeThisFunc(ctx *Context) func() (string, error) {
 		return ctx.CurrentModelTable, nil
 	}
 }
+
+// makeBatchStartFunc creates a batch_start() function for template use.
+// Returns the inclusive start of the microbatch being built, or an empty
+// string outside of a batch.
+func makeBatchStartFunc(ctx *Context) func() string {
+	return func() string {
+		return ctx.BatchStart
+	}
+}
+
+// makeBatchEndFunc creates a batch_end() function for template use.
+// Returns the exclusive end of the microbatch being built, or an empty
+// string outside of a batch.
+func makeBatchEndFunc(ctx *Context) func() string {
+	return func() string {
+		return ctx.BatchEnd
+	}
+}
+
+// makeTargetFunc creates a target() function that returns the profile output
+// the project runs against, e.g. {{ target.Name }} or {{ target.Type }}
+func makeTargetFunc(ctx *Context) func() Target {
+	return func() Target {
+		return ctx.Target
+	}
+}
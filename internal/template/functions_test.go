@@ -163,6 +163,51 @@ func TestVarFunctionMissing(t *testing.T) {
 	})
 }
 
+func TestVarFunctionDefault(t *testing.T) {
+	ctx := NewContext(WithVars(map[string]interface{}{"region": "west"}))
+	varFunc := makeVarFunc(ctx)
+
+	t.Run("returns default when variable not found", func(t *testing.T) {
+		result, err := varFunc("start_date", "2020-01-01")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "2020-01-01" {
+			t.Errorf("expected '2020-01-01', got %v", result)
+		}
+	})
+
+	t.Run("prefers variable over default", func(t *testing.T) {
+		result, err := varFunc("region", "east")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "west" {
+			t.Errorf("expected 'west', got %v", result)
+		}
+	})
+
+	t.Run("rejects more than one default", func(t *testing.T) {
+		if _, err := varFunc("region", "a", "b"); err == nil {
+			t.Error("expected error for extra default values")
+		}
+	})
+
+	t.Run("works in templates", func(t *testing.T) {
+		tmpl, err := New().Parse("vars", `{{ var "region" }}-{{ var "missing" "none" }}`)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		rendered, err := Render(tmpl, ctx, nil)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if rendered != "west-none" {
+			t.Errorf("rendered = %q, want %q", rendered, "west-none")
+		}
+	})
+}
+
 func TestConfigFunction(t *testing.T) {
 	t.Run("retrieves simple config value", func(t *testing.T) {
 		config := map[string]interface{}{
@@ -232,6 +277,96 @@ func TestConfigFunctionNested(t *testing.T) {
 	})
 }
 
+func TestConfigFunctionSetter(t *testing.T) {
+	t.Run("sets key/value pairs", func(t *testing.T) {
+		ctx := NewContext()
+		configFunc := makeConfigFunc(ctx)
+
+		result, err := configFunc("materialized", "incremental", "unique_key", "id,ts")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "" {
+			t.Errorf("setter should render as empty string, got %v", result)
+		}
+		if ctx.Config["materialized"] != "incremental" || ctx.Config["unique_key"] != "id,ts" {
+			t.Errorf("unexpected config: %v", ctx.Config)
+		}
+	})
+
+	t.Run("sets every entry of a map", func(t *testing.T) {
+		ctx := NewContext()
+		configFunc := makeConfigFunc(ctx)
+
+		if _, err := configFunc(map[string]interface{}{"alias": "orders", "enabled": false}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ctx.Config["alias"] != "orders" || ctx.Config["enabled"] != false {
+			t.Errorf("unexpected config: %v", ctx.Config)
+		}
+	})
+
+	t.Run("appends hooks instead of overwriting", func(t *testing.T) {
+		ctx := NewContext()
+		configFunc := makeConfigFunc(ctx)
+
+		configFunc("post_hook", "SELECT 1")
+		configFunc("post_hook", "SELECT 2")
+
+		hooks, ok := ctx.Config["post_hook"].([]interface{})
+		if !ok || len(hooks) != 2 {
+			t.Fatalf("expected 2 post hooks, got %v", ctx.Config["post_hook"])
+		}
+	})
+
+	t.Run("rejects odd number of arguments", func(t *testing.T) {
+		configFunc := makeConfigFunc(NewContext())
+		if _, err := configFunc("a", "b", "c"); err == nil {
+			t.Error("expected error for odd number of arguments")
+		}
+	})
+}
+
+func TestConfigInTemplate(t *testing.T) {
+	engine := New()
+	tmpl, err := engine.Parse("model", `{{ config "tags" (list "daily" "finance") "meta" (dict "owner" "data-team") }}SELECT 1`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	ctx := NewContext()
+	rendered, err := Render(tmpl, ctx, nil)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if rendered != "SELECT 1" {
+		t.Errorf("rendered = %q, want %q", rendered, "SELECT 1")
+	}
+
+	tags, ok := ctx.Config["tags"].([]interface{})
+	if !ok || len(tags) != 2 || tags[0] != "daily" {
+		t.Errorf("tags = %v, want [daily finance]", ctx.Config["tags"])
+	}
+
+	meta, ok := ctx.Config["meta"].(map[string]interface{})
+	if !ok || meta["owner"] != "data-team" {
+		t.Errorf("meta = %v, want owner=data-team", ctx.Config["meta"])
+	}
+}
+
+func TestRefFunctionWithRelations(t *testing.T) {
+	ctx := NewContext(WithRelations(map[string]string{"stg_orders": "orders"}))
+	refFunc := makeRefFunc(ctx, nil)
+
+	if got := refFunc("stg_orders"); got != "orders" {
+		t.Errorf("ref(stg_orders) = %q, want %q", got, "orders")
+	}
+	if got := refFunc("customers"); got != "customers" {
+		t.Errorf("ref(customers) = %q, want %q", got, "customers")
+	}
+}
+
 func TestSourceFunction(t *testing.T) {
 	t.Run("retrieves source table reference", func(t *testing.T) {
 		sources := map[string]map[string]string{
@@ -681,3 +816,62 @@ WHERE updated_at > (SELECT MAX(updated_at) FROM analytics.target_table)
 		}
 	})
 }
+
+func TestBatchFilter(t *testing.T) {
+	sources := map[string]map[string]string{"rail": {"trips": "raw_trips", "cars": "raw_cars"}}
+	eventTimes := map[string]string{"stg_loads": "loaded_at"}
+	sourceEventTimes := map[string]map[string]string{"rail": {"trips": "departed_at"}}
+
+	t.Run("filters inputs with an event time during a batch", func(t *testing.T) {
+		ctx := NewContext(
+			WithSources(sources),
+			WithEventTimes(eventTimes, sourceEventTimes),
+			WithBatch("2024-01-02", "2024-01-03"),
+		)
+
+		if got, want := makeRefFunc(ctx, nil)("stg_loads"), "(SELECT * FROM stg_loads WHERE loaded_at >= '2024-01-02' AND loaded_at < '2024-01-03')"; got != want {
+			t.Errorf("ref() = %q, want %q", got, want)
+		}
+		if got := makeRefFunc(ctx, nil)("dim_mines"); got != "dim_mines" {
+			t.Errorf("ref() without event time = %q, want dim_mines", got)
+		}
+
+		got, err := makeSourceFunc(ctx)("rail", "trips")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "(SELECT * FROM raw_trips WHERE departed_at >= '2024-01-02' AND departed_at < '2024-01-03')"; got != want {
+			t.Errorf("source() = %q, want %q", got, want)
+		}
+		if got, _ := makeSourceFunc(ctx)("rail", "cars"); got != "raw_cars" {
+			t.Errorf("source() without event time = %q, want raw_cars", got)
+		}
+	})
+
+	t.Run("leaves inputs unfiltered outside of a batch", func(t *testing.T) {
+		ctx := NewContext(WithSources(sources), WithEventTimes(eventTimes, sourceEventTimes))
+
+		if got := makeRefFunc(ctx, nil)("stg_loads"); got != "stg_loads" {
+			t.Errorf("ref() = %q, want stg_loads", got)
+		}
+		if got, _ := makeSourceFunc(ctx)("rail", "trips"); got != "raw_trips" {
+			t.Errorf("source() = %q, want raw_trips", got)
+		}
+	})
+}
+
+func TestBatchBoundsInTemplate(t *testing.T) {
+	engine := New()
+	tmpl, err := engine.Parse("loads", `WHERE loaded_at >= '{{ batch_start }}' AND loaded_at < '{{ batch_end }}'`)
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	got, err := Render(tmpl, NewContext(WithBatch("2024-01-02 05:00:00", "2024-01-02 06:00:00")), nil)
+	if err != nil {
+		t.Fatalf("failed to render template: %v", err)
+	}
+	if want := "WHERE loaded_at >= '2024-01-02 05:00:00' AND loaded_at < '2024-01-02 06:00:00'"; got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,424 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sourceRelationColumn is the column union_relations adds to tell the
+// relation each row comes from
+const sourceRelationColumn = "_gorchata_source_relation"
+
+// rowNumberColumn numbers the rows deduplicate keeps the first of
+const rowNumberColumn = "_gorchata_row_number"
+
+// surrogateKeyNull stands for NULL in the text generate_surrogate_key hashes,
+// so that NULL and an empty string give different keys
+const surrogateKeyNull = "_gorchata_surrogate_key_null_"
+
+// dateLiteral matches the dates and times date_spine quotes, leaving other
+// bounds as SQL expressions
+var dateLiteral = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}( \d{2}:\d{2}(:\d{2})?)?$`)
+
+// datePart is the step of a date spine
+type datePart struct {
+	function string // DATE or DATETIME
+	count    int
+	unit     string
+}
+
+// dateParts are the steps date_spine supports
+var dateParts = map[string]datePart{
+	"minute":  {"DATETIME", 1, "minutes"},
+	"hour":    {"DATETIME", 1, "hours"},
+	"day":     {"DATE", 1, "days"},
+	"week":    {"DATE", 7, "days"},
+	"month":   {"DATE", 1, "months"},
+	"quarter": {"DATE", 3, "months"},
+	"year":    {"DATE", 1, "years"},
+}
+
+// quoteIdentifier quotes a name as a SQL identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteString quotes a value as a SQL string literal
+func quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// sqlLiteral formats a value of a template as a SQL literal
+func sqlLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("value is NULL")
+	case string:
+		return quoteString(v), nil
+	case []byte:
+		return quoteString(string(v)), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v (%T)", value, value)
+	}
+}
+
+// stringArgs flattens the arguments of a function, which may be strings or
+// lists of strings, e.g. {{ star (ref "orders") "a" "b" }} or
+// {{ star (ref "orders") (list "a" "b") }}
+func stringArgs(args []interface{}) ([]string, error) {
+	var values []string
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			values = append(values, v)
+		case []string:
+			values = append(values, v...)
+		case []interface{}:
+			nested, err := stringArgs(v)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, nested...)
+		default:
+			return nil, fmt.Errorf("expected a string or a list of strings, got %v (%T)", arg, arg)
+		}
+	}
+	return values, nil
+}
+
+// helperOptions reads the optional dict of options of a function, e.g.
+// {{ pivot "status" $values (dict "agg" "MAX") }}, with defaults for the
+// options not given
+func helperOptions(options []map[string]interface{}, defaults map[string]interface{}) (map[string]interface{}, error) {
+	if len(options) > 1 {
+		return nil, fmt.Errorf("expected at most one dict of options, got %d", len(options))
+	}
+
+	result := make(map[string]interface{}, len(defaults))
+	for key, value := range defaults {
+		result[key] = value
+	}
+	if len(options) == 0 {
+		return result, nil
+	}
+
+	for key, value := range options[0] {
+		if _, ok := defaults[key]; !ok {
+			known := make([]string, 0, len(defaults))
+			for name := range defaults {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown option %s (expected one of %s)", key, strings.Join(known, ", "))
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// containsFold reports whether names contains name, ignoring case as SQL does
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// relationColumns returns the names of the columns of a relation, except
+// those excluded
+func relationColumns(ctx *Context, relation string, except []string) ([]string, error) {
+	columns, err := ctx.Database.columns(relation)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !containsFold(except, column.Name) {
+			names = append(names, column.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("every column of %s is excluded", relation)
+	}
+	return names, nil
+}
+
+// joinColumns quotes columns and joins them into a select list
+func joinColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// makeStarFunc creates a star() function that lists the columns of a
+// relation except those given, e.g.
+// SELECT {{ star (ref "orders") "_loaded_at" }} FROM {{ ref "orders" }}.
+// It returns * in the parse phase.
+func makeStarFunc(ctx *Context) func(string, ...interface{}) (string, error) {
+	return func(relation string, except ...interface{}) (string, error) {
+		excluded, err := stringArgs(except)
+		if err != nil {
+			return "", fmt.Errorf("star failed: %w", err)
+		}
+		if ctx.Database == nil {
+			return "*", nil
+		}
+
+		columns, err := relationColumns(ctx, relation, excluded)
+		if err != nil {
+			return "", fmt.Errorf("star failed: %w", err)
+		}
+		return joinColumns(columns), nil
+	}
+}
+
+// makeGenerateSurrogateKeyFunc creates a generate_surrogate_key() function
+// that hashes columns into one key, e.g.
+// {{ generate_surrogate_key "order_id" "line_number" }} AS order_line_key.
+// Rows with the same values, including NULLs, get the same key.
+func makeGenerateSurrogateKeyFunc() func(...interface{}) (string, error) {
+	return func(args ...interface{}) (string, error) {
+		columns, err := stringArgs(args)
+		if err != nil {
+			return "", fmt.Errorf("generate_surrogate_key failed: %w", err)
+		}
+		if len(columns) == 0 {
+			return "", fmt.Errorf("generate_surrogate_key failed: no columns given")
+		}
+
+		parts := make([]string, len(columns))
+		for i, column := range columns {
+			parts[i] = fmt.Sprintf("COALESCE(CAST(%s AS TEXT), %s)", column, quoteString(surrogateKeyNull))
+		}
+		return fmt.Sprintf("md5(%s)", strings.Join(parts, " || '-' || ")), nil
+	}
+}
+
+// makeDateSpineFunc creates a date_spine() function that generates a query
+// returning one row per step from start up to, but excluding, end, e.g.
+// {{ date_spine "day" "2024-01-01" "2025-01-01" }} returns the days of 2024
+// in a date_day column. Bounds that are dates or times are quoted; others,
+// such as DATE('now'), are SQL expressions.
+func makeDateSpineFunc() func(string, string, string) (string, error) {
+	return func(part, start, end string) (string, error) {
+		step, ok := dateParts[part]
+		if !ok {
+			known := make([]string, 0, len(dateParts))
+			for name := range dateParts {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return "", fmt.Errorf("date_spine failed: unknown date part %s (expected one of %s)", part, strings.Join(known, ", "))
+		}
+
+		if dateLiteral.MatchString(start) {
+			start = quoteString(start)
+		}
+		if dateLiteral.MatchString(end) {
+			end = quoteString(end)
+		}
+		column := "date_" + part
+		first := fmt.Sprintf("%s(%s)", step.function, start)
+		last := fmt.Sprintf("%s(%s)", step.function, end)
+
+		// Every step is computed from start, so that a month overflowing into
+		// the next, as January 31st + 1 month does, does not shift the others
+		next := fmt.Sprintf("%s(%s, '+' || ((n + 1) * %d) || ' %s')", step.function, start, step.count, step.unit)
+
+		return fmt.Sprintf(`WITH RECURSIVE date_spine(n, %[1]s) AS (
+  SELECT 0, %[2]s WHERE %[2]s < %[3]s
+  UNION ALL
+  SELECT n + 1, %[4]s FROM date_spine WHERE %[4]s < %[3]s
+)
+SELECT %[1]s FROM date_spine`, column, first, last, next), nil
+	}
+}
+
+// makeUnionRelationsFunc creates a union_relations() function that unions
+// relations whose columns differ, e.g.
+// {{ union_relations (ref "orders_2023") (ref "orders_2024") }}. Columns
+// missing from a relation are NULL, and _gorchata_source_relation tells the
+// relation of each row. In the parse phase every relation selects *.
+func makeUnionRelationsFunc(ctx *Context) func(...interface{}) (string, error) {
+	return func(args ...interface{}) (string, error) {
+		relations, err := stringArgs(args)
+		if err != nil {
+			return "", fmt.Errorf("union_relations failed: %w", err)
+		}
+		if len(relations) == 0 {
+			return "", fmt.Errorf("union_relations failed: no relations given")
+		}
+
+		selects := make([]string, len(relations))
+		if ctx.Database == nil {
+			for i, relation := range relations {
+				selects[i] = fmt.Sprintf("SELECT %s AS %s, * FROM %s", quoteString(relation), sourceRelationColumn, relation)
+			}
+			return strings.Join(selects, "\nUNION ALL\n"), nil
+		}
+
+		// The union has the columns of every relation, in the order they first appear
+		var union []string
+		columns := make([][]string, len(relations))
+		for i, relation := range relations {
+			columns[i], err = relationColumns(ctx, relation, nil)
+			if err != nil {
+				return "", fmt.Errorf("union_relations failed: %w", err)
+			}
+			for _, column := range columns[i] {
+				if !containsFold(union, column) {
+					union = append(union, column)
+				}
+			}
+		}
+
+		for i, relation := range relations {
+			list := []string{fmt.Sprintf("%s AS %s", quoteString(relation), sourceRelationColumn)}
+			for _, column := range union {
+				if containsFold(columns[i], column) {
+					list = append(list, quoteIdentifier(column))
+				} else {
+					list = append(list, "NULL AS "+quoteIdentifier(column))
+				}
+			}
+			selects[i] = fmt.Sprintf("SELECT %s FROM %s", strings.Join(list, ", "), relation)
+		}
+		return strings.Join(selects, "\nUNION ALL\n"), nil
+	}
+}
+
+// makePivotFunc creates a pivot() function that aggregates a column into
+// one column per value, e.g.
+// {{ pivot "status" (get_column_values (ref "orders") "status") }}, which
+// counts the rows of each status. Options are given as a dict: agg (SUM),
+// then (1), else (0), prefix and suffix of the column names, as in
+// {{ pivot "status" $statuses (dict "then" "amount" "suffix" "_amount") }}.
+// Without values, as in the parse phase where get_column_values returns none,
+// pivot returns a NULL column named after the pivoted column so that the
+// query stays valid.
+func makePivotFunc() func(string, []interface{}, ...map[string]interface{}) (string, error) {
+	return func(column string, values []interface{}, options ...map[string]interface{}) (string, error) {
+		opts, err := helperOptions(options, map[string]interface{}{
+			"agg":    "SUM",
+			"then":   1,
+			"else":   0,
+			"prefix": "",
+			"suffix": "",
+		})
+		if err != nil {
+			return "", fmt.Errorf("pivot failed: %w", err)
+		}
+
+		if len(values) == 0 {
+			alias := fmt.Sprint(opts["prefix"], column, opts["suffix"])
+			return fmt.Sprintf("NULL AS %s", quoteIdentifier(alias)), nil
+		}
+
+		columns := make([]string, len(values))
+		for i, value := range values {
+			literal, err := sqlLiteral(value)
+			if err != nil {
+				return "", fmt.Errorf("pivot failed: value %d: %w", i+1, err)
+			}
+			alias := fmt.Sprint(opts["prefix"], value, opts["suffix"])
+			columns[i] = fmt.Sprintf("%s(CASE WHEN %s = %s THEN %v ELSE %v END) AS %s",
+				opts["agg"], column, literal, opts["then"], opts["else"], quoteIdentifier(alias))
+		}
+		return strings.Join(columns, ",\n  "), nil
+	}
+}
+
+// makeUnpivotFunc creates an unpivot() function that turns the columns of a
+// relation into rows of name and value, keeping the columns given, e.g.
+// {{ unpivot (ref "monthly_sales") (list "store_id") }}. Options are given as
+// a dict: cast_to (TEXT), the type of the values, field_name (field_name) and
+// value_name (value), the names of the new columns, and remove, the columns
+// to drop. In the parse phase no column is unpivoted.
+func makeUnpivotFunc(ctx *Context) func(string, interface{}, ...map[string]interface{}) (string, error) {
+	return func(relation string, exclude interface{}, options ...map[string]interface{}) (string, error) {
+		opts, err := helperOptions(options, map[string]interface{}{
+			"cast_to":    "TEXT",
+			"field_name": "field_name",
+			"value_name": "value",
+			"remove":     []interface{}{},
+		})
+		if err != nil {
+			return "", fmt.Errorf("unpivot failed: %w", err)
+		}
+		kept, err := stringArgs([]interface{}{exclude})
+		if err != nil {
+			return "", fmt.Errorf("unpivot failed: exclude: %w", err)
+		}
+		removed, err := stringArgs([]interface{}{opts["remove"]})
+		if err != nil {
+			return "", fmt.Errorf("unpivot failed: remove: %w", err)
+		}
+
+		fieldName := quoteIdentifier(fmt.Sprint(opts["field_name"]))
+		valueName := quoteIdentifier(fmt.Sprint(opts["value_name"]))
+		keptList := ""
+		if len(kept) > 0 {
+			keptList = joinColumns(kept) + ", "
+		}
+
+		if ctx.Database == nil {
+			return fmt.Sprintf("SELECT %sNULL AS %s, NULL AS %s FROM %s", keptList, fieldName, valueName, relation), nil
+		}
+
+		columns, err := relationColumns(ctx, relation, append(kept, removed...))
+		if err != nil {
+			return "", fmt.Errorf("unpivot failed: %w", err)
+		}
+
+		selects := make([]string, len(columns))
+		for i, column := range columns {
+			selects[i] = fmt.Sprintf("SELECT %s%s AS %s, CAST(%s AS %v) AS %s FROM %s",
+				keptList, quoteString(column), fieldName, quoteIdentifier(column), opts["cast_to"], valueName, relation)
+		}
+		return strings.Join(selects, "\nUNION ALL\n"), nil
+	}
+}
+
+// makeDeduplicateFunc creates a deduplicate() function that generates a
+// query keeping the first row of each partition of a relation, e.g.
+// {{ deduplicate (ref "raw_events") "event_id" "loaded_at DESC" }}. In the
+// parse phase the query also returns the _gorchata_row_number it filters on.
+func makeDeduplicateFunc(ctx *Context) func(string, string, string) (string, error) {
+	return func(relation, partitionBy, orderBy string) (string, error) {
+		columns := "*"
+		if ctx.Database != nil {
+			names, err := relationColumns(ctx, relation, nil)
+			if err != nil {
+				return "", fmt.Errorf("deduplicate failed: %w", err)
+			}
+			columns = joinColumns(names)
+		}
+
+		return fmt.Sprintf(`SELECT %[1]s FROM (
+  SELECT *, ROW_NUMBER() OVER (PARTITION BY %[2]s ORDER BY %[3]s) AS %[4]s FROM %[5]s
+) WHERE %[4]s = 1`, columns, partitionBy, orderBy, rowNumberColumn, relation), nil
+	}
+}
+
+// makeSafeDivideFunc creates a safe_divide() function that divides without
+// failing or truncating: it returns NULL when the denominator is 0, e.g.
+// {{ safe_divide "SUM(revenue)" "COUNT(*)" }}
+func makeSafeDivideFunc() func(interface{}, interface{}) string {
+	return func(numerator, denominator interface{}) string {
+		return fmt.Sprintf("CAST(%v AS REAL) / NULLIF(%v, 0)", numerator, denominator)
+	}
+}
@@ -0,0 +1,205 @@
+package template
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/platform"
+	"github.com/jpconstantineau/gorchata/internal/platform/sqlite"
+)
+
+// newHelperDatabase creates a SQLite database holding the tables the SQL
+// helpers are tested against
+func newHelperDatabase(t *testing.T) *sqlite.SQLiteAdapter {
+	t.Helper()
+	adapter := sqlite.NewSQLiteAdapter(&platform.ConnectionConfig{
+		DatabasePath: filepath.Join(t.TempDir(), "helpers.db"),
+	})
+	ctx := context.Background()
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { adapter.Close() })
+
+	statements := []string{
+		`CREATE TABLE orders (id INTEGER, status TEXT, amount INTEGER, _loaded_at TEXT)`,
+		`INSERT INTO orders VALUES (1, 'paid', 10, 'x'), (2, 'open', 5, 'x'), (3, 'paid', 7, 'x'), (4, NULL, 1, 'x')`,
+		`CREATE TABLE orders_2023 (id INTEGER, amount INTEGER)`,
+		`INSERT INTO orders_2023 VALUES (1, 10)`,
+		`CREATE TABLE orders_2024 (id INTEGER, note TEXT, amount INTEGER)`,
+		`INSERT INTO orders_2024 VALUES (2, 'rush', 20)`,
+		`CREATE TABLE events (event_id INTEGER, payload TEXT, loaded_at INTEGER)`,
+		`INSERT INTO events VALUES (1, 'old', 1), (1, 'new', 2), (2, 'only', 1)`,
+		`CREATE VIEW sales AS SELECT 1 AS store_id, 100 AS jan, 200 AS feb, 'x' AS note`,
+	}
+	for _, statement := range statements {
+		if err := adapter.ExecuteDDL(ctx, statement); err != nil {
+			t.Fatalf("ExecuteDDL(%s) error = %v", statement, err)
+		}
+	}
+	return adapter
+}
+
+// formatRows formats rows as values joined by | and rows joined by ;
+func formatRows(result *platform.QueryResult) string {
+	rows := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		values := make([]string, len(row))
+		for j, value := range row {
+			if value == nil {
+				values[j] = "NULL"
+			} else {
+				values[j] = fmt.Sprint(value)
+			}
+		}
+		rows[i] = strings.Join(values, "|")
+	}
+	return strings.Join(rows, ";")
+}
+
+func TestSQLHelpers(t *testing.T) {
+	adapter := newHelperDatabase(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		content   string
+		want      string
+		wantParse string
+		wantErr   string
+	}{
+		{
+			name:      "star",
+			content:   `SELECT {{ star "orders" "_loaded_at" "status" }} FROM orders WHERE id = 1`,
+			want:      "1|10",
+			wantParse: `SELECT * FROM orders WHERE id = 1`,
+		},
+		{
+			name:    "star excluding a list",
+			content: `SELECT {{ star "orders" (list "_LOADED_AT" "amount") }} FROM orders WHERE id = 2`,
+			want:    "2|open",
+		},
+		{
+			name:    "star of a missing relation",
+			content: `SELECT {{ star "missing" }} FROM missing`,
+			wantErr: "star failed",
+		},
+		{
+			name:    "generate_surrogate_key",
+			content: `SELECT {{ generate_surrogate_key "id" "status" }} = md5('4-_gorchata_surrogate_key_null_'), {{ generate_surrogate_key "id" }} = {{ generate_surrogate_key (list "id") }}, (SELECT COUNT(DISTINCT {{ generate_surrogate_key "id" "status" }}) FROM orders) FROM orders WHERE id = 4`,
+			want:    "1|1|4",
+		},
+		{
+			name:    "date_spine by day",
+			content: `SELECT COUNT(*), MIN(date_day), MAX(date_day) FROM ({{ date_spine "day" "2024-01-01" "2024-03-01" }})`,
+			want:    "60|2024-01-01|2024-02-29",
+		},
+		{
+			name:    "date_spine by month from the 31st",
+			content: `SELECT group_concat(date_month, ',') FROM ({{ date_spine "month" "2024-01-31" "2024-05-01" }})`,
+			want:    "2024-01-31,2024-03-02,2024-03-31",
+		},
+		{
+			name:    "date_spine with SQL bounds",
+			content: `WITH dates AS ({{ date_spine "week" "DATE('2024-01-01')" "DATE('2024-01-01', '+21 days')" }}) SELECT group_concat(date_week, ',') FROM dates`,
+			want:    "2024-01-01,2024-01-08,2024-01-15",
+		},
+		{
+			name:    "date_spine with an empty range",
+			content: `SELECT COUNT(*) FROM ({{ date_spine "hour" "2024-01-01 00:00" "2024-01-01 00:00" }})`,
+			want:    "0",
+		},
+		{
+			name:    "date_spine with an unknown date part",
+			content: `{{ date_spine "fortnight" "2024-01-01" "2024-02-01" }}`,
+			wantErr: "unknown date part fortnight",
+		},
+		{
+			name:      "union_relations",
+			content:   `SELECT * FROM ({{ union_relations "orders_2023" "orders_2024" }}) ORDER BY id`,
+			want:      "orders_2023|1|10|NULL;orders_2024|2|20|rush",
+			wantParse: "SELECT * FROM (SELECT 'orders_2023' AS _gorchata_source_relation, * FROM orders_2023\nUNION ALL\nSELECT 'orders_2024' AS _gorchata_source_relation, * FROM orders_2024) ORDER BY id",
+		},
+		{
+			name:      "pivot",
+			content:   `SELECT {{ pivot "status" (get_column_values "orders" "status") }} FROM orders`,
+			want:      "1|2",
+			wantParse: `SELECT NULL AS "status" FROM orders`,
+		},
+		{
+			name:    "pivot without values",
+			content: `SELECT {{ pivot "status" (list) (dict "suffix" "_amount") }} FROM orders WHERE id = 1`,
+			want:    "NULL",
+		},
+		{
+			name:    "pivot with options",
+			content: `SELECT {{ pivot "status" (list "paid" "open") (dict "agg" "MAX" "then" "amount" "suffix" "_amount") }} FROM orders`,
+			want:    "10|5",
+		},
+		{
+			name:    "pivot with an unknown option",
+			content: `SELECT {{ pivot "status" (list "paid") (dict "aggregate" "MAX") }} FROM orders`,
+			wantErr: "unknown option aggregate",
+		},
+		{
+			name:      "unpivot",
+			content:   `SELECT * FROM ({{ unpivot "sales" (list "store_id") (dict "remove" (list "note") "value_name" "sales") }}) ORDER BY field_name`,
+			want:      "1|feb|200;1|jan|100",
+			wantParse: `SELECT * FROM (SELECT "store_id", NULL AS "field_name", NULL AS "sales" FROM sales) ORDER BY field_name`,
+		},
+		{
+			name:    "deduplicate",
+			content: `SELECT * FROM ({{ deduplicate "events" "event_id" "loaded_at DESC" }}) ORDER BY event_id`,
+			want:    "1|new|2;2|only|1",
+		},
+		{
+			name:    "safe_divide",
+			content: `SELECT {{ safe_divide "amount" 4 }}, {{ safe_divide "amount" "0" }} FROM orders WHERE id = 2`,
+			want:    "1.25|NULL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New().Parse(tt.name, tt.content)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			db := NewDatabase(ctx, adapter.ExecuteQuery, adapter)
+			sql, err := Render(tmpl, NewContext(WithDatabase(db)), nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			result, err := adapter.ExecuteQuery(ctx, sql)
+			if err != nil {
+				t.Fatalf("ExecuteQuery(%s) error = %v", sql, err)
+			}
+			if got := formatRows(result); got != tt.want {
+				t.Errorf("rows = %q, want %q\nSQL: %s", got, tt.want, sql)
+			}
+
+			// Without a database, the helpers reading columns return placeholders
+			if tt.wantParse == "" {
+				return
+			}
+			got, err := Render(tmpl, NewContext(), nil)
+			if err != nil {
+				t.Fatalf("Render() in the parse phase error = %v", err)
+			}
+			if got != tt.wantParse {
+				t.Errorf("Render() in the parse phase = %q, want %q", got, tt.wantParse)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package template
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jpconstantineau/gorchata/internal/platform"
+)
+
+// QueryFunc executes a query and returns its rows, like the ExecuteQuery
+// method of an adapter or the Query method of a transaction
+type QueryFunc func(ctx context.Context, sql string, args ...interface{}) (*platform.QueryResult, error)
+
+// Database gives the introspective functions (run_query, get_columns,
+// relation_exists and get_column_values) access to the database while a
+// template renders. Templates rendered without one, such as by compile, are
+// in the parse phase: execute is false and those functions return
+// placeholder results.
+type Database struct {
+	ctx    context.Context
+	query  QueryFunc
+	schema platform.SchemaReader
+}
+
+// NewDatabase creates the database templates query with query and read the
+// columns of relations from with schema
+func NewDatabase(ctx context.Context, query QueryFunc, schema platform.SchemaReader) *Database {
+	return &Database{ctx: ctx, query: query, schema: schema}
+}
+
+// QueryResult holds the rows returned by run_query
+type QueryResult struct {
+	Columns []string
+	Rows    [][]interface{}
+
+	// placeholder is set for the result of the parse phase, which has no
+	// rows but every column
+	placeholder bool
+}
+
+// Column returns the values of a column of the result, e.g.
+// {{ range (run_query "SELECT DISTINCT status FROM orders").Column "status" }}
+func (r *QueryResult) Column(name string) ([]interface{}, error) {
+	if r.placeholder {
+		return []interface{}{}, nil
+	}
+	for i, column := range r.Columns {
+		if column != name {
+			continue
+		}
+		values := make([]interface{}, len(r.Rows))
+		for j, row := range r.Rows {
+			values[j] = row[i]
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("query result has no column %s", name)
+}
+
+// Column is a column of a relation, as returned by get_columns
+type Column struct {
+	Name string
+	Type string
+}
+
+// WithDatabase sets the database the introspective functions query.
+func WithDatabase(db *Database) ContextOption {
+	return func(c *Context) {
+		c.Database = db
+	}
+}
+
+// makeExecuteFunc creates an execute() function that reports whether the
+// template renders with access to the database, e.g. {{ if execute }}
+func makeExecuteFunc(ctx *Context) func() bool {
+	return func() bool {
+		return ctx.Database != nil
+	}
+}
+
+// makeRunQueryFunc creates a run_query() function that executes a query and
+// returns its rows. It returns no rows in the parse phase.
+func makeRunQueryFunc(ctx *Context) func(string) (*QueryResult, error) {
+	return func(sql string) (*QueryResult, error) {
+		if ctx.Database == nil {
+			return &QueryResult{placeholder: true}, nil
+		}
+
+		result, err := ctx.Database.query(ctx.Database.ctx, sql)
+		if err != nil {
+			return nil, fmt.Errorf("run_query failed: %w", err)
+		}
+
+		rows := make([][]interface{}, len(result.Rows))
+		for i, row := range result.Rows {
+			rows[i] = make([]interface{}, len(row))
+			for j, value := range row {
+				// Text may be returned as bytes, which templates would print as numbers
+				if b, ok := value.([]byte); ok {
+					value = string(b)
+				}
+				rows[i][j] = value
+			}
+		}
+		return &QueryResult{Columns: result.Columns, Rows: rows}, nil
+	}
+}
+
+// columns returns the columns of a relation
+func (db *Database) columns(relation string) ([]Column, error) {
+	schema, err := db.schema.GetTableSchema(db.ctx, relation)
+	if err != nil {
+		return nil, err
+	}
+	if len(schema.Columns) == 0 {
+		return nil, fmt.Errorf("relation %s does not exist", relation)
+	}
+
+	columns := make([]Column, 0, len(schema.Columns))
+	for _, column := range schema.Columns {
+		columns = append(columns, Column{Name: column.Name, Type: column.Type})
+	}
+	return columns, nil
+}
+
+// makeGetColumnsFunc creates a get_columns() function that returns the
+// columns of a relation, e.g. {{ range get_columns (ref "orders") }}{{ .Name }}{{ end }}.
+// It returns no columns in the parse phase.
+func makeGetColumnsFunc(ctx *Context) func(string) ([]Column, error) {
+	return func(relation string) ([]Column, error) {
+		if ctx.Database == nil {
+			return []Column{}, nil
+		}
+
+		columns, err := ctx.Database.columns(relation)
+		if err != nil {
+			return nil, fmt.Errorf("get_columns failed: %w", err)
+		}
+		return columns, nil
+	}
+}
+
+// makeRelationExistsFunc creates a relation_exists() function that reports
+// whether a table or view exists. It returns false in the parse phase.
+func makeRelationExistsFunc(ctx *Context) func(string) (bool, error) {
+	return func(relation string) (bool, error) {
+		if ctx.Database == nil {
+			return false, nil
+		}
+
+		exists, err := ctx.Database.schema.TableExists(ctx.Database.ctx, relation)
+		if err != nil {
+			return false, fmt.Errorf("relation_exists failed: %w", err)
+		}
+		if exists {
+			return true, nil
+		}
+
+		// Views are not tables, but their columns can be read
+		_, err = ctx.Database.columns(relation)
+		return err == nil, nil
+	}
+}
+
+// makeGetColumnValuesFunc creates a get_column_values() function that returns
+// the distinct values of a column, sorted and without NULL, e.g.
+// {{ range get_column_values (ref "orders") "status" }}. It returns no values
+// in the parse phase.
+func makeGetColumnValuesFunc(ctx *Context) func(string, string) ([]interface{}, error) {
+	runQuery := makeRunQueryFunc(ctx)
+	return func(relation, column string) ([]interface{}, error) {
+		if ctx.Database == nil {
+			return []interface{}{}, nil
+		}
+
+		result, err := runQuery(fmt.Sprintf("SELECT DISTINCT %s AS value FROM %s WHERE %s IS NOT NULL ORDER BY 1", column, relation, column))
+		if err != nil {
+			return nil, fmt.Errorf("get_column_values failed: %w", err)
+		}
+		return result.Column("value")
+	}
+}
@@ -0,0 +1,136 @@
+package template
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/platform"
+)
+
+// fakeSchema serves the columns of the tables it holds
+type fakeSchema map[string][]platform.Column
+
+func (s fakeSchema) TableExists(ctx context.Context, table string) (bool, error) {
+	_, ok := s[table]
+	return ok, nil
+}
+
+func (s fakeSchema) GetTableSchema(ctx context.Context, table string) (*platform.Schema, error) {
+	columns, ok := s[table]
+	if !ok {
+		return nil, fmt.Errorf("table %q does not exist", table)
+	}
+	return &platform.Schema{TableName: table, Columns: columns}, nil
+}
+
+func newFakeDatabase(queries *[]string) *Database {
+	query := func(ctx context.Context, sql string, args ...interface{}) (*platform.QueryResult, error) {
+		*queries = append(*queries, sql)
+		if strings.Contains(sql, "broken") {
+			return nil, fmt.Errorf("no such table: broken")
+		}
+		return &platform.QueryResult{
+			Columns: []string{"value"},
+			Rows:    [][]interface{}{{"closed"}, {[]byte("open")}},
+		}, nil
+	}
+	schema := fakeSchema{"orders": {{Name: "id", Type: "INTEGER"}, {Name: "status", Type: "TEXT"}}}
+	return NewDatabase(context.Background(), query, schema)
+}
+
+func TestIntrospectionFunctions(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        string
+		wantParse   string
+		wantQueries []string
+		wantErr     string
+	}{
+		{
+			name:      "execute",
+			content:   `{{ if execute }}execute{{ else }}parse{{ end }}`,
+			want:      "execute",
+			wantParse: "parse",
+		},
+		{
+			name:        "run_query",
+			content:     `{{ range (run_query "SELECT status AS value FROM orders").Column "value" }}{{ . }};{{ end }}`,
+			want:        "closed;open;",
+			wantParse:   "",
+			wantQueries: []string{"SELECT status AS value FROM orders"},
+		},
+		{
+			name:      "get_columns",
+			content:   `{{ range get_columns "orders" }}{{ .Name }} {{ .Type }};{{ end }}`,
+			want:      "id INTEGER;status TEXT;",
+			wantParse: "",
+		},
+		{
+			name:      "relation_exists",
+			content:   `{{ relation_exists "orders" }} {{ relation_exists "missing" }}`,
+			want:      "true false",
+			wantParse: "false false",
+		},
+		{
+			name:        "get_column_values",
+			content:     `{{ range get_column_values "orders" "status" }}, COUNT(CASE WHEN status = '{{ . }}' THEN 1 END) AS {{ . }}{{ end }}`,
+			want:        ", COUNT(CASE WHEN status = 'closed' THEN 1 END) AS closed, COUNT(CASE WHEN status = 'open' THEN 1 END) AS open",
+			wantParse:   "",
+			wantQueries: []string{"SELECT DISTINCT status AS value FROM orders WHERE status IS NOT NULL ORDER BY 1"},
+		},
+		{
+			name:    "get_columns of a missing relation",
+			content: `{{ get_columns "missing" }}`,
+			wantErr: `get_columns failed: table "missing" does not exist`,
+		},
+		{
+			name:    "failing query",
+			content: `{{ run_query "SELECT * FROM broken" }}`,
+			wantErr: "run_query failed: no such table: broken",
+		},
+		{
+			name:    "missing result column",
+			content: `{{ (run_query "SELECT 1").Column "id" }}`,
+			wantErr: "query result has no column id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New().Parse(tt.name, tt.content)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			var queries []string
+			got, err := Render(tmpl, NewContext(WithDatabase(newFakeDatabase(&queries))), nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+			if tt.wantQueries != nil && strings.Join(queries, "\n") != strings.Join(tt.wantQueries, "\n") {
+				t.Errorf("queries = %q, want %q", queries, tt.wantQueries)
+			}
+
+			// Without a database, the functions return placeholders
+			got, err = Render(tmpl, NewContext(), nil)
+			if err != nil {
+				t.Fatalf("Render() in the parse phase error = %v", err)
+			}
+			if got != tt.wantParse {
+				t.Errorf("Render() in the parse phase = %q, want %q", got, tt.wantParse)
+			}
+		})
+	}
+}
@@ -0,0 +1,678 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Parameter types of macros declared with a {% macro %} header
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamFloat  = "float"
+	ParamBool   = "bool"
+	ParamList   = "list"
+	ParamAny    = "any"
+)
+
+// maxMacroDepth bounds how deeply macros may call each other, so that a
+// macro calling itself fails instead of exhausting the stack
+const maxMacroDepth = 100
+
+var (
+	// blockTag matches the opening tag of a {% name ... %} block
+	blockTag = regexp.MustCompile(`\{%\s*(\w+)\s*(.*?)\s*%\}`)
+
+	// macroHeader matches the name and parameters of a {% macro %} tag
+	macroHeader = regexp.MustCompile(`^(\w+)\s*(?:\((.*)\))?$`)
+
+	// macroParam matches a parameter: name, optional type and optional default
+	macroParam = regexp.MustCompile(`^(\w+)(?:\s+(\w+))?(?:\s*=\s*(.+))?$`)
+
+	// defineAction matches the {{ define "name" }} actions of a macro file
+	defineAction = regexp.MustCompile(`\{\{-?\s*define\s+"([^"]*)"`)
+
+	// identifier matches the names macros can be called by
+	identifier = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// reservedNames are the keywords and built-in functions of text/template,
+// which macros cannot be named after
+var reservedNames = map[string]bool{
+	"and": true, "or": true, "not": true, "len": true, "index": true, "slice": true,
+	"print": true, "printf": true, "println": true, "call": true, "html": true, "js": true, "urlquery": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"if": true, "else": true, "end": true, "range": true, "with": true, "define": true, "template": true,
+	"block": true, "break": true, "continue": true, "nil": true, "true": true, "false": true,
+}
+
+// MacroParam is a parameter of a macro
+type MacroParam struct {
+	Name string
+
+	// Type is one of the Param* constants
+	Type string
+
+	// Default is the value of the parameter when the call leaves it out;
+	// parameters without a default are required
+	Default    interface{}
+	HasDefault bool
+}
+
+// Macro is a reusable template defined in a macro file. Macros are called
+// like functions from models, tests and hooks and return their rendered SQL:
+//
+//	{% macro cents_to_dollars(column string, scale int = 2) %}
+//	ROUND({{ .column }} / 100.0, {{ .scale }})
+//	{% endmacro %}
+//
+//	SELECT {{ cents_to_dollars "amount" }} AS amount FROM ...
+//
+// Parameters of a macro header are positional; their values are checked
+// against the declared type and accessed by name. A {{ define "name" }}
+// block is a macro too: it gets its only argument as ".", a list of its
+// arguments when there are several, or nil when there are none.
+type Macro struct {
+	Name   string
+	Params []MacroParam
+
+	// Path and Line locate the macro's definition
+	Path string
+	Line int
+
+	// typed is set for macros declared with a {% macro %} header
+	typed bool
+}
+
+// Location returns the file and line the macro is defined at
+func (m *Macro) Location() string {
+	return fmt.Sprintf("%s:%d", m.Path, m.Line)
+}
+
+// arguments checks the arguments of a call and returns the data the
+// macro is rendered with
+func (m *Macro) arguments(args []interface{}) (interface{}, error) {
+	if !m.typed {
+		switch len(args) {
+		case 0:
+			return nil, nil
+		case 1:
+			return args[0], nil
+		default:
+			return args, nil
+		}
+	}
+
+	if len(args) > len(m.Params) {
+		return nil, fmt.Errorf("macro %s (%s) takes %d argument(s), got %d", m.Name, m.Location(), len(m.Params), len(args))
+	}
+
+	named := make(map[string]interface{}, len(args))
+	for i, value := range args {
+		named[m.Params[i].Name] = value
+	}
+	return m.namedArguments(named)
+}
+
+// namedArguments checks the arguments of a call by parameter name and
+// returns the data the macro is rendered with. Macros without a header get
+// the arguments as they are.
+func (m *Macro) namedArguments(args map[string]interface{}) (interface{}, error) {
+	if !m.typed {
+		return args, nil
+	}
+
+	data := make(map[string]interface{}, len(m.Params))
+	for _, param := range m.Params {
+		value, ok := args[param.Name]
+		if !ok {
+			if !param.HasDefault {
+				return nil, fmt.Errorf("macro %s (%s) is missing argument %s", m.Name, m.Location(), param.Name)
+			}
+			data[param.Name] = param.Default
+			continue
+		}
+
+		converted, err := convertParam(param.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("macro %s (%s): argument %s: %w", m.Name, m.Location(), param.Name, err)
+		}
+		data[param.Name] = converted
+	}
+
+	var unknown []string
+	for name := range args {
+		if _, ok := data[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("macro %s (%s) has no parameter %s", m.Name, m.Location(), strings.Join(unknown, ", "))
+	}
+	return data, nil
+}
+
+// convertParam converts the value of an argument to the parameter type
+func convertParam(paramType string, value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+
+	switch paramType {
+	case ParamAny:
+		return value, nil
+	case ParamString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case ParamBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case ParamInt:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return int(v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(v.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			if f := v.Float(); f == float64(int(f)) {
+				return int(f), nil
+			}
+		}
+	case ParamFloat:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(v.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return v.Float(), nil
+		}
+	case ParamList:
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			list := make([]interface{}, v.Len())
+			for i := range list {
+				list[i] = v.Index(i).Interface()
+			}
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s, got %v (%T)", paramType, value, value)
+}
+
+// MaterializationBlock is a {% materialization name %} ... {% endmaterialization %}
+// block of a macro file, which defines a custom materialization
+type MaterializationBlock struct {
+	// Name is the name models use the materialization by
+	Name string
+
+	// Path is the macro file the block is in
+	Path string
+
+	// Line is the line of the opening tag
+	Line int
+
+	// Body is the template between the tags, without surrounding whitespace
+	Body string
+}
+
+// Location returns the file and line the materialization is defined at
+func (b MaterializationBlock) Location() string {
+	return fmt.Sprintf("%s:%d", b.Path, b.Line)
+}
+
+// Macros holds the macros of a project by name
+type Macros struct {
+	macros map[string]*Macro
+
+	// set holds the parsed template of every macro under its name
+	set *template.Template
+
+	// materializations are the materialization blocks of the macro files
+	materializations []MaterializationBlock
+}
+
+// Names returns the names of the macros sorted alphabetically
+func (ms *Macros) Names() []string {
+	if ms == nil {
+		return nil
+	}
+	names := make([]string, 0, len(ms.macros))
+	for name := range ms.macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Materializations returns the materialization blocks of the macro files, in
+// the order they were loaded
+func (ms *Macros) Materializations() []MaterializationBlock {
+	if ms == nil {
+		return nil
+	}
+	return ms.materializations
+}
+
+// Lookup returns the macro with the given name
+func (ms *Macros) Lookup(name string) (*Macro, bool) {
+	if ms == nil {
+		return nil, false
+	}
+	macro, ok := ms.macros[name]
+	return macro, ok
+}
+
+// bind adds a function for every macro to funcMap. The macros render with
+// the functions of funcMap, so ref(), var(), this and the other macros
+// resolve as they do in the template calling them.
+func (ms *Macros) bind(funcMap template.FuncMap) {
+	if ms == nil {
+		return
+	}
+
+	depth := 0
+	for name, macro := range ms.macros {
+		macro := macro
+		funcMap[name] = func(args ...interface{}) (string, error) {
+			if depth >= maxMacroDepth {
+				return "", fmt.Errorf("macro %s (%s): macros are nested more than %d deep", macro.Name, macro.Location(), maxMacroDepth)
+			}
+			depth++
+			defer func() { depth-- }()
+			return ms.render(macro, funcMap, args)
+		}
+	}
+}
+
+// render renders a macro with the given arguments
+func (ms *Macros) render(macro *Macro, funcMap template.FuncMap, args []interface{}) (string, error) {
+	data, err := macro.arguments(args)
+	if err != nil {
+		return "", err
+	}
+	return ms.execute(macro, funcMap, data)
+}
+
+// execute renders a macro with the data its arguments were checked into
+func (ms *Macros) execute(macro *Macro, funcMap template.FuncMap, data interface{}) (string, error) {
+	clone, err := ms.set.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to clone macro %s: %w", macro.Name, err)
+	}
+	clone = clone.Funcs(funcMap).Option("missingkey=error")
+
+	var buf bytes.Buffer
+	if err := clone.ExecuteTemplate(&buf, macro.Name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// addTemplates associates the macros with tmpl, so that they can also be
+// executed with {{ template "name" . }}
+func (ms *Macros) addTemplates(tmpl *template.Template) error {
+	if ms == nil {
+		return nil
+	}
+	for _, name := range ms.Names() {
+		if _, err := tmpl.AddParseTree(name, ms.set.Lookup(name).Tree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// macroFile is a macro file being loaded
+type macroFile struct {
+	path    string
+	content string
+}
+
+// block is a {% name ... %} ... {% endname %} block of a macro file
+type block struct {
+	tag    string
+	args   string
+	start  int // offset of the opening tag
+	body   int // offset of the body
+	end    int // offset of the closing tag
+	after  int // offset after the closing tag
+	header int // line of the opening tag
+}
+
+// blocks returns the {% %} blocks of a macro file
+func (f macroFile) blocks() ([]block, error) {
+	var blocks []block
+	offset := 0
+	for {
+		loc := blockTag.FindStringSubmatchIndex(f.content[offset:])
+		if loc == nil {
+			return blocks, nil
+		}
+
+		b := block{
+			tag:   f.content[offset+loc[2] : offset+loc[3]],
+			args:  f.content[offset+loc[4] : offset+loc[5]],
+			start: offset + loc[0],
+			body:  offset + loc[1],
+		}
+		b.header = f.line(b.start)
+		if strings.HasPrefix(b.tag, "end") {
+			return nil, fmt.Errorf("%s:%d: unexpected {%% %s %%}", f.path, b.header, b.tag)
+		}
+
+		endTag := regexp.MustCompile(`\{%\s*end` + regexp.QuoteMeta(b.tag) + `\s*%\}`)
+		end := endTag.FindStringIndex(f.content[b.body:])
+		if end == nil {
+			return nil, fmt.Errorf("%s:%d: {%% %s %%} has no {%% end%s %%}", f.path, b.header, b.tag, b.tag)
+		}
+		b.end = b.body + end[0]
+		b.after = b.body + end[1]
+
+		blocks = append(blocks, b)
+		offset = b.after
+	}
+}
+
+// line returns the line of an offset of the file
+func (f macroFile) line(offset int) int {
+	return 1 + strings.Count(f.content[:offset], "\n")
+}
+
+// blank replaces every byte but the line breaks of s with a space, so that
+// text parsed after it keeps its line and offset in the file
+func blank(s string) string {
+	b := []byte(s)
+	for i := range b {
+		if b[i] != '\n' {
+			b[i] = ' '
+		}
+	}
+	return string(b)
+}
+
+// LoadMacros loads the macros defined in the .sql files of the macro
+// directories: {% macro name(param type = default, ...) %} ... {% endmacro %}
+// blocks and {{ define "name" }} ... {{ end }} blocks. The
+// {% materialization name %} ... {% endmaterialization %} blocks of custom
+// materializations are collected for the executor to parse; other {% %}
+// blocks are ignored. Missing directories define no macros. Errors name the
+// file and line at fault.
+func LoadMacros(dirs []string) (*Macros, error) {
+	var files []macroFile
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+				return nil
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			files = append(files, macroFile{path: filepath.ToSlash(path), content: string(content)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parseMacros(files)
+}
+
+// ParseMacros parses the macros defined in the content of a macro file
+func ParseMacros(path, content string) (*Macros, error) {
+	return parseMacros([]macroFile{{path: path, content: content}})
+}
+
+// macroBody is the body of a {% macro %} block to parse
+type macroBody struct {
+	macro *Macro
+
+	// text is the body after the blanked text before it, so that errors
+	// report lines and columns of the file
+	text string
+}
+
+// parseMacros declares the macros of every file first, so that macros can
+// call macros of any file, then parses them. It collects the materialization
+// blocks of the files on the way.
+func parseMacros(files []macroFile) (*Macros, error) {
+	ms := &Macros{macros: make(map[string]*Macro)}
+
+	builtins := BuildFuncMap(NewContext(), nil)
+	declare := func(macro *Macro) error {
+		switch {
+		case !identifier.MatchString(macro.Name):
+			return fmt.Errorf("%s: macro name %q is not an identifier", macro.Location(), macro.Name)
+		case reservedNames[macro.Name] || builtins[macro.Name] != nil:
+			return fmt.Errorf("%s: macro %s has the name of a built-in function", macro.Location(), macro.Name)
+		}
+		if other, exists := ms.macros[macro.Name]; exists {
+			return fmt.Errorf("macro %s is defined in %s and %s", macro.Name, other.Location(), macro.Location())
+		}
+		ms.macros[macro.Name] = macro
+		return nil
+	}
+
+	var bodies []macroBody
+	defineFiles := make([]macroFile, len(files))
+	for i, file := range files {
+		blocks, err := file.blocks()
+		if err != nil {
+			return nil, err
+		}
+
+		// Every block is blanked out of the file, which leaves the
+		// {{ define }} blocks
+		defines := file.content
+		for _, b := range blocks {
+			defines = defines[:b.start] + blank(defines[b.start:b.after]) + defines[b.after:]
+
+			if b.tag == "materialization" {
+				if err := ms.addMaterialization(file, b); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if b.tag != "macro" {
+				continue
+			}
+			macro, err := parseMacroHeader(file.path, b.header, b.args)
+			if err != nil {
+				return nil, err
+			}
+			if err := declare(macro); err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, macroBody{macro: macro, text: blank(file.content[:b.body]) + file.content[b.body:b.end]})
+		}
+
+		for _, match := range defineAction.FindAllStringSubmatchIndex(defines, -1) {
+			macro := &Macro{Name: defines[match[2]:match[3]], Path: file.path, Line: file.line(match[0])}
+			if err := declare(macro); err != nil {
+				return nil, err
+			}
+		}
+		defineFiles[i] = macroFile{path: file.path, content: defines}
+	}
+
+	// Macros are parsed with placeholder functions; they are bound to the
+	// template being rendered when they are called
+	funcMap := BuildFuncMap(NewContext(), nil)
+	for name := range ms.macros {
+		funcMap[name] = func(args ...interface{}) (string, error) { return "", nil }
+	}
+	ms.set = template.New("macros").Funcs(funcMap)
+
+	for _, body := range bodies {
+		tmpl, err := template.New(body.macro.Path).Funcs(funcMap).Parse(body.text)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ms.set.AddParseTree(body.macro.Name, tmpl.Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range defineFiles {
+		tmpl, err := template.New(file.path).Funcs(funcMap).Parse(file.content)
+		if err != nil {
+			return nil, err
+		}
+		for _, defined := range tmpl.Templates() {
+			if macro := ms.macros[defined.Name()]; macro == nil || macro.typed {
+				continue
+			}
+			if _, err := ms.set.AddParseTree(defined.Name(), defined.Tree); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ms, nil
+}
+
+// addMaterialization collects a materialization block of a macro file.
+// Materializations may not share a name.
+func (ms *Macros) addMaterialization(file macroFile, b block) error {
+	m := MaterializationBlock{
+		Name: b.args,
+		Path: file.path,
+		Line: b.header,
+		Body: strings.TrimSpace(file.content[b.body:b.end]),
+	}
+	if !identifier.MatchString(m.Name) {
+		return fmt.Errorf("%s: materialization name %q is not an identifier", m.Location(), m.Name)
+	}
+	for _, other := range ms.materializations {
+		if other.Name == m.Name {
+			return fmt.Errorf("materialization %s is defined in %s and %s", m.Name, other.Location(), m.Location())
+		}
+	}
+	ms.materializations = append(ms.materializations, m)
+	return nil
+}
+
+// parseMacroHeader parses the name and parameters of a {% macro %} tag
+func parseMacroHeader(path string, line int, header string) (*Macro, error) {
+	match := macroHeader.FindStringSubmatch(header)
+	if match == nil {
+		return nil, fmt.Errorf("%s:%d: invalid macro header %q (expected {%% macro name(param type = default, ...) %%})", path, line, header)
+	}
+
+	macro := &Macro{Name: match[1], Path: path, Line: line, typed: true}
+	if strings.TrimSpace(match[2]) == "" {
+		return macro, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, text := range splitParams(match[2]) {
+		param, err := parseMacroParam(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("%s: macro %s: %w", macro.Location(), macro.Name, err)
+		}
+		if seen[param.Name] {
+			return nil, fmt.Errorf("%s: macro %s: duplicate parameter %s", macro.Location(), macro.Name, param.Name)
+		}
+		if n := len(macro.Params); n > 0 && macro.Params[n-1].HasDefault && !param.HasDefault {
+			return nil, fmt.Errorf("%s: macro %s: parameter %s without a default follows a parameter with one", macro.Location(), macro.Name, param.Name)
+		}
+		seen[param.Name] = true
+		macro.Params = append(macro.Params, param)
+	}
+	return macro, nil
+}
+
+// splitParams splits a parameter list on the commas outside of quotes
+func splitParams(list string) []string {
+	var params []string
+	var quote rune
+	start := 0
+	for i, r := range list {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ',':
+			params = append(params, list[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, list[start:])
+}
+
+// parseMacroParam parses a parameter: "name", "name type" or either one
+// followed by "= default"
+func parseMacroParam(text string) (MacroParam, error) {
+	match := macroParam.FindStringSubmatch(text)
+	if match == nil {
+		return MacroParam{}, fmt.Errorf("invalid parameter %q (expected name type = default)", text)
+	}
+
+	param := MacroParam{Name: match[1], Type: match[2]}
+	switch param.Type {
+	case "":
+		param.Type = ParamAny
+	case ParamString, ParamInt, ParamFloat, ParamBool, ParamList, ParamAny:
+	default:
+		return MacroParam{}, fmt.Errorf("parameter %s has unknown type %q (expected %s, %s, %s, %s, %s or %s)",
+			param.Name, param.Type, ParamString, ParamInt, ParamFloat, ParamBool, ParamList, ParamAny)
+	}
+
+	if match[3] == "" {
+		return param, nil
+	}
+	literal, err := parseLiteral(strings.TrimSpace(match[3]))
+	if err != nil {
+		return MacroParam{}, fmt.Errorf("parameter %s: %w", param.Name, err)
+	}
+	if literal != nil {
+		if literal, err = convertParam(param.Type, literal); err != nil {
+			return MacroParam{}, fmt.Errorf("default of parameter %s: %w", param.Name, err)
+		}
+	}
+	param.Default = literal
+	param.HasDefault = true
+	return param, nil
+}
+
+// parseLiteral parses the default value of a parameter: a quoted string,
+// a number, true, false or none
+func parseLiteral(text string) (interface{}, error) {
+	switch {
+	case text == "true" || text == "false":
+		return text == "true", nil
+	case text == "none":
+		return nil, nil
+	case len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0]:
+		return text[1 : len(text)-1], nil
+	}
+	if i, err := strconv.Atoi(text); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid default %s (expected a quoted string, a number, true, false or none)", text)
+}
@@ -0,0 +1,252 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMacroFile = `-- Finance helpers
+{% macro cents_to_dollars(column string, scale int = 2) %}
+ROUND({{ .column }} / 100.0, {{ .scale }})
+{% endmacro %}
+
+{% macro in_list(values list) %}
+IN ({{ range $i, $v := .values }}{{ if $i }}, {{ end }}'{{ $v }}'{{ end }})
+{% endmacro %}
+
+{{ define "amount" }}{{ cents_to_dollars . }}{{ end }}
+
+{{ define "orders" }}{{ ref "orders" }}{{ end }}
+
+{% materialization custom %}
+CREATE TABLE {{ .Relation }} AS {{ .CompiledSQL }}
+{% endmaterialization %}
+`
+
+func renderWithMacros(t *testing.T, macros *Macros, content string, ctx *Context) (string, error) {
+	t.Helper()
+	tmpl, err := New(WithMacros(macros)).Parse("model", content)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return Render(tmpl, ctx, nil)
+}
+
+func TestParseMacros(t *testing.T) {
+	macros, err := ParseMacros("macros/finance.sql", testMacroFile)
+	if err != nil {
+		t.Fatalf("ParseMacros() error = %v", err)
+	}
+
+	if got, want := macros.Names(), []string{"amount", "cents_to_dollars", "in_list", "orders"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	macro, ok := macros.Lookup("cents_to_dollars")
+	if !ok {
+		t.Fatal("cents_to_dollars is not defined")
+	}
+	if macro.Location() != "macros/finance.sql:2" {
+		t.Errorf("Location() = %s, want macros/finance.sql:2", macro.Location())
+	}
+	wantParams := []MacroParam{
+		{Name: "column", Type: ParamString},
+		{Name: "scale", Type: ParamInt, Default: 2, HasDefault: true},
+	}
+	if !reflect.DeepEqual(macro.Params, wantParams) {
+		t.Errorf("Params = %+v, want %+v", macro.Params, wantParams)
+	}
+
+	if macro, _ := macros.Lookup("amount"); macro.Location() != "macros/finance.sql:10" {
+		t.Errorf("amount Location() = %s, want macros/finance.sql:10", macro.Location())
+	}
+
+	want := []MaterializationBlock{{
+		Name: "custom",
+		Path: "macros/finance.sql",
+		Line: 14,
+		Body: "CREATE TABLE {{ .Relation }} AS {{ .CompiledSQL }}",
+	}}
+	if got := macros.Materializations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Materializations() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMacroCalls(t *testing.T) {
+	macros, err := ParseMacros("macros/finance.sql", testMacroFile)
+	if err != nil {
+		t.Fatalf("ParseMacros() error = %v", err)
+	}
+
+	ctx := NewContext(WithRelations(map[string]string{"orders": "analytics.orders"}))
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{"default argument", `{{ cents_to_dollars "amount" }}`, "ROUND(amount / 100.0, 2)", ""},
+		{"all arguments", `{{ cents_to_dollars "amount" 4 }}`, "ROUND(amount / 100.0, 4)", ""},
+		{"list argument", `status {{ in_list (list "open" "paid") }}`, "status IN ('open', 'paid')", ""},
+		{"define calling a macro", `{{ amount "total" }}`, "ROUND(total / 100.0, 2)", ""},
+		{"template action", `{{ template "amount" "total" }}`, "ROUND(total / 100.0, 2)", ""},
+		{"macro resolving ref", `SELECT * FROM {{ orders }}`, "SELECT * FROM analytics.orders", ""},
+		{"wrong type", `{{ cents_to_dollars "amount" "two" }}`, "", "macro cents_to_dollars (macros/finance.sql:2): argument scale: expected int, got two (string)"},
+		{"missing argument", `{{ cents_to_dollars }}`, "", "macro cents_to_dollars (macros/finance.sql:2) is missing argument column"},
+		{"too many arguments", `{{ cents_to_dollars "a" 1 2 }}`, "", "takes 2 argument(s), got 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderWithMacros(t, macros, tt.content, ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "parse error reports file and line",
+			content: "{% macro broken(column) %}\nSELECT\n  {{ .column }\n{% endmacro %}\n",
+			wantErr: "macros/broken.sql:3",
+		},
+		{
+			name:    "unknown function in a define",
+			content: "\n\n{{ define \"broken\" }}{{ missing }}{{ end }}\n",
+			wantErr: "macros/broken.sql:3",
+		},
+		{
+			name:    "unknown parameter type",
+			content: "{% macro broken(column text) %}{{ .column }}{% endmacro %}",
+			wantErr: `macros/broken.sql:1: macro broken: parameter column has unknown type "text"`,
+		},
+		{
+			name:    "default of the wrong type",
+			content: "{% macro broken(scale int = 'two') %}{{ .scale }}{% endmacro %}",
+			wantErr: "default of parameter scale: expected int",
+		},
+		{
+			name:    "required parameter after a default",
+			content: "{% macro broken(scale int = 2, column string) %}{% endmacro %}",
+			wantErr: "parameter column without a default follows a parameter with one",
+		},
+		{
+			name:    "built-in function name",
+			content: "\n{% macro ref(name) %}{{ .name }}{% endmacro %}",
+			wantErr: "macros/broken.sql:2: macro ref has the name of a built-in function",
+		},
+		{
+			name:    "unclosed block",
+			content: "{% macro broken() %}SELECT 1",
+			wantErr: "macros/broken.sql:1: {% macro %} has no {% endmacro %}",
+		},
+		{
+			name:    "duplicate macro",
+			content: "{% macro twice() %}1{% endmacro %}\n{{ define \"twice\" }}2{{ end }}",
+			wantErr: "macro twice is defined in macros/broken.sql:1 and macros/broken.sql:2",
+		},
+		{
+			name:    "materialization name",
+			content: "{% materialization rolling window %}SELECT 1{% endmaterialization %}",
+			wantErr: `macros/broken.sql:1: materialization name "rolling window" is not an identifier`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMacros("macros/broken.sql", tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseMacros() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMacroRuntimeErrorReportsMacroLine(t *testing.T) {
+	content := "{% macro lookup(name string) %}\nSELECT\n  {{ var .name }}\n{% endmacro %}\n\n{% macro forever() %}{{ forever }}{% endmacro %}\n"
+	macros, err := ParseMacros("macros/lookup.sql", content)
+	if err != nil {
+		t.Fatalf("ParseMacros() error = %v", err)
+	}
+
+	_, err = renderWithMacros(t, macros, `{{ lookup "missing" }}`, NewContext())
+	if err == nil || !strings.Contains(err.Error(), "macros/lookup.sql:3:") {
+		t.Errorf("Render() error = %v, want the macro file and line", err)
+	}
+
+	_, err = renderWithMacros(t, macros, `{{ forever }}`, NewContext())
+	if err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("Render() error = %v, want the nesting limit", err)
+	}
+}
+
+func TestLoadMacros(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Macros may call macros of other files
+	write("macros/a.sql", `{% macro greeting(name) %}hello {{ quote .name }}{% endmacro %}`)
+	write("macros/utils/b.sql", `{{ define "quote" }}'{{ . }}'{{ end }}`)
+	write("macros/notes.md", `{{ define "ignored" }}{{ end }}`)
+	write("macros/materializations/rolling.sql", `{% materialization rolling_window %}SELECT 1{% endmaterialization %}`)
+
+	macros, err := LoadMacros([]string{filepath.Join(dir, "macros"), filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatalf("LoadMacros() error = %v", err)
+	}
+	if got, want := macros.Names(), []string{"greeting", "quote"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	if got := macros.Materializations(); len(got) != 1 || got[0].Location() != filepath.ToSlash(filepath.Join(dir, "macros/materializations/rolling.sql"))+":1" {
+		t.Errorf("Materializations() = %+v, want rolling_window", got)
+	}
+
+	got, err := renderWithMacros(t, macros, `{{ greeting "world" }}`, NewContext())
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got != "hello 'world'" {
+		t.Errorf("Render() = %q, want %q", got, "hello 'world'")
+	}
+
+	write("other/c.sql", `{{ define "quote" }}"{{ . }}"{{ end }}`)
+	_, err = LoadMacros([]string{filepath.Join(dir, "macros"), filepath.Join(dir, "other")})
+	if err == nil || !strings.Contains(err.Error(), "macro quote is defined in") {
+		t.Errorf("LoadMacros() error = %v, want duplicate macro", err)
+	}
+
+	write("more/rolling.sql", `{% materialization rolling_window %}SELECT 2{% endmaterialization %}`)
+	_, err = LoadMacros([]string{filepath.Join(dir, "macros"), filepath.Join(dir, "more")})
+	if err == nil || !strings.Contains(err.Error(), "materialization rolling_window is defined in") {
+		t.Errorf("LoadMacros() error = %v, want duplicate materialization", err)
+	}
+}
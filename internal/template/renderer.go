@@ -6,7 +6,8 @@ import (
 )
 
 // Render executes a template with the given context and data.
-// The context provides custom functions (ref, var, config, etc.).
+// The context provides custom functions (ref, var, config, etc.) and the
+// macros of the engine.
 // The data is passed as the root data object to the template (accessible via .).
 func Render(tmpl *Template, ctx *Context, data interface{}) (string, error) {
 	if tmpl == nil {
@@ -18,6 +19,7 @@ func Render(tmpl *Template, ctx *Context, data interface{}) (string, error) {
 
 	// Build FuncMap with the actual context
 	funcMap := BuildFuncMap(ctx, tmpl.engine.tracker)
+	tmpl.engine.macros.bind(funcMap)
 
 	// Clone the template and add functions
 	// This allows us to use the same parsed template with different contexts
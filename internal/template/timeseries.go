@@ -0,0 +1,277 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// newSessionColumn flags the rows sessionize starts a session at
+const newSessionColumn = "_gorchata_new_session"
+
+// bucketInterval matches the intervals of time buckets, e.g. "15 minutes"
+var bucketInterval = regexp.MustCompile(`^(\d+)\s*([a-z]+?)s?$`)
+
+// bucketUnits are the seconds of the units of bucket intervals
+var bucketUnits = map[string]int{
+	"second": 1,
+	"minute": 60,
+	"hour":   3600,
+	"day":    86400,
+}
+
+// bucketSeconds returns the length in seconds of a bucket interval, given as
+// a number of seconds or as a count and unit, e.g. "15 minutes"
+func bucketSeconds(interval interface{}) (int, error) {
+	switch v := interval.(type) {
+	case int:
+		if v <= 0 {
+			return 0, fmt.Errorf("interval must be positive, got %d", v)
+		}
+		return v, nil
+	case string:
+		match := bucketInterval.FindStringSubmatch(strings.ToLower(strings.TrimSpace(v)))
+		if match == nil {
+			return 0, fmt.Errorf("invalid interval %q (expected e.g. \"15 minutes\")", v)
+		}
+		unit, ok := bucketUnits[match[2]]
+		if !ok {
+			return 0, fmt.Errorf("invalid interval %q: unknown unit %s (expected second, minute, hour or day)", v, match[2])
+		}
+		count, err := strconv.Atoi(match[1])
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid interval %q: count must be positive", v)
+		}
+		return count * unit, nil
+	default:
+		return 0, fmt.Errorf("invalid interval %v (%T)", interval, interval)
+	}
+}
+
+// timestampValue quotes a timestamp written as a date or time, leaving other
+// values as SQL expressions
+func timestampValue(value string) string {
+	if dateLiteral.MatchString(value) {
+		return quoteString(value)
+	}
+	return value
+}
+
+// partitionColumns reads the columns to partition by, a string or a list,
+// where an empty string means none
+func partitionColumns(partitionBy interface{}) ([]string, error) {
+	columns, err := stringArgs([]interface{}{partitionBy})
+	if err != nil {
+		return nil, fmt.Errorf("partition_by: %w", err)
+	}
+
+	var result []string
+	for _, column := range columns {
+		if column != "" {
+			result = append(result, column)
+		}
+	}
+	return result, nil
+}
+
+// partitionClause returns the PARTITION BY clause of a window, followed by a
+// space, or nothing without columns
+func partitionClause(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return "PARTITION BY " + strings.Join(columns, ", ") + " "
+}
+
+// selectPrefix returns columns as the start of a select list, or nothing
+// without columns
+func selectPrefix(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return strings.Join(columns, ", ") + ", "
+}
+
+// secondsBetween returns the SQL expression of the seconds from start to end
+func secondsBetween(start, end string) string {
+	return fmt.Sprintf("ROUND((julianday(%s) - julianday(%s)) * 86400, 3)", end, start)
+}
+
+// timeBucket returns the SQL expression of the start of the bucket of a
+// timestamp, as 'YYYY-MM-DD HH:MM:SS'
+func timeBucket(timestamp string, seconds int) string {
+	return fmt.Sprintf("DATETIME((CAST(strftime('%%s', %s) AS INTEGER) / %d) * %d, 'unixepoch')", timestamp, seconds, seconds)
+}
+
+// makeTimeBucketFunc creates a time_bucket() function that truncates a
+// timestamp to the start of its bucket, e.g.
+// {{ time_bucket "event_timestamp" "15 minutes" }}. Buckets are aligned on
+// midnight UTC.
+func makeTimeBucketFunc() func(string, interface{}) (string, error) {
+	return func(timestamp string, interval interface{}) (string, error) {
+		seconds, err := bucketSeconds(interval)
+		if err != nil {
+			return "", fmt.Errorf("time_bucket failed: %w", err)
+		}
+		return timeBucket(timestamp, seconds), nil
+	}
+}
+
+// makeStateDurationsFunc creates a state_durations() function that generates
+// a query turning events into the intervals a state lasted, e.g.
+// {{ state_durations (ref "raw_alarm_events") "tag_id" "event_timestamp" "event_type" }}.
+// Consecutive events of the same state form one interval, which lasts until
+// the next state starts. The query returns the partition columns, state,
+// state_start, state_end and duration_seconds; state_end and
+// duration_seconds are NULL for the current state of each partition.
+func makeStateDurationsFunc() func(string, interface{}, string, string) (string, error) {
+	return func(relation string, partitionBy interface{}, timestamp, state string) (string, error) {
+		partition, err := partitionColumns(partitionBy)
+		if err != nil {
+			return "", fmt.Errorf("state_durations failed: %w", err)
+		}
+		window := partitionClause(partition)
+		columns := selectPrefix(partition)
+
+		return fmt.Sprintf(`WITH state_changes AS (
+  SELECT %[1]s%[3]s AS state, %[4]s AS state_timestamp,
+    CASE WHEN LAG(%[3]s) OVER (%[2]sORDER BY %[4]s) IS %[3]s THEN 0 ELSE 1 END AS is_change
+  FROM %[5]s
+), state_islands AS (
+  SELECT *, SUM(is_change) OVER (%[2]sORDER BY state_timestamp ROWS UNBOUNDED PRECEDING) AS island
+  FROM state_changes
+), state_intervals AS (
+  SELECT %[1]sstate, MIN(state_timestamp) AS state_start
+  FROM state_islands
+  GROUP BY %[1]sisland, state
+), state_bounds AS (
+  SELECT *, LEAD(state_start) OVER (%[2]sORDER BY state_start) AS state_end
+  FROM state_intervals
+)
+SELECT %[1]sstate, state_start, state_end, %[6]s AS duration_seconds
+FROM state_bounds`, columns, window, state, timestamp, relation, secondsBetween("state_start", "state_end")), nil
+	}
+}
+
+// makeSessionizeFunc creates a sessionize() function that generates a query
+// numbering the sessions of rows, where a session ends when the next row is
+// more than a number of seconds later, e.g.
+// {{ sessionize (ref "raw_alarm_events") "tag_id" "event_timestamp" 600 }}.
+// The query returns the columns of the relation and session_number, which
+// counts from 1 in each partition. In the parse phase it also returns the
+// _gorchata_new_session flag it sums.
+func makeSessionizeFunc(ctx *Context) func(string, interface{}, string, interface{}) (string, error) {
+	return func(relation string, partitionBy interface{}, timestamp string, gap interface{}) (string, error) {
+		partition, err := partitionColumns(partitionBy)
+		if err != nil {
+			return "", fmt.Errorf("sessionize failed: %w", err)
+		}
+		seconds, err := bucketSeconds(gap)
+		if err != nil {
+			return "", fmt.Errorf("sessionize failed: gap: %w", err)
+		}
+
+		columns := "*"
+		if ctx.Database != nil {
+			names, err := relationColumns(ctx, relation, nil)
+			if err != nil {
+				return "", fmt.Errorf("sessionize failed: %w", err)
+			}
+			columns = joinColumns(names)
+		}
+		window := partitionClause(partition)
+		previous := fmt.Sprintf("LAG(%s) OVER (%sORDER BY %s)", timestamp, window, timestamp)
+
+		return fmt.Sprintf(`SELECT %[1]s, SUM(%[2]s) OVER (%[3]sORDER BY %[4]s ROWS UNBOUNDED PRECEDING) AS session_number
+FROM (
+  SELECT *, CASE WHEN %[5]s IS NULL OR %[6]s > %[7]d THEN 1 ELSE 0 END AS %[2]s
+  FROM %[8]s
+)`, columns, newSessionColumn, window, timestamp, previous, secondsBetween(previous, timestamp), seconds, relation), nil
+	}
+}
+
+// makeGapFillFunc creates a gap_fill() function that generates a query with
+// a row for every bucket of every partition from start up to, but excluding,
+// end, e.g.
+// {{ gap_fill (ref "readings") "tag_id" "read_at" "1 hour" "2024-01-01" "2024-01-02" (list "value") }}.
+// Each bucket holds the values of the last row within it; buckets without
+// rows carry the last values forward. The query returns the partition
+// columns, bucket and the value columns.
+func makeGapFillFunc() func(string, interface{}, string, interface{}, string, string, interface{}) (string, error) {
+	return func(relation string, partitionBy interface{}, timestamp string, interval interface{}, start, end string, values interface{}) (string, error) {
+		partition, err := partitionColumns(partitionBy)
+		if err != nil {
+			return "", fmt.Errorf("gap_fill failed: %w", err)
+		}
+		seconds, err := bucketSeconds(interval)
+		if err != nil {
+			return "", fmt.Errorf("gap_fill failed: %w", err)
+		}
+		valueColumns, err := stringArgs([]interface{}{values})
+		if err != nil {
+			return "", fmt.Errorf("gap_fill failed: values: %w", err)
+		}
+		if len(valueColumns) == 0 {
+			return "", fmt.Errorf("gap_fill failed: no value columns given")
+		}
+
+		first := timeBucket(timestampValue(start), seconds)
+		last := fmt.Sprintf("DATETIME(%s)", timestampValue(end))
+		next := fmt.Sprintf("DATETIME(%s, '+' || ((n + 1) * %d) || ' seconds')", first, seconds)
+
+		// The partitions every bucket is filled for
+		partitions := "SELECT 1 AS _gorchata_all"
+		if len(partition) > 0 {
+			partitions = fmt.Sprintf("SELECT DISTINCT %s FROM %s", strings.Join(partition, ", "), relation)
+		}
+
+		joins := []string{"o.bucket = g.bucket"}
+		gridColumns := make([]string, len(partition))
+		for i, column := range partition {
+			joins = append(joins, fmt.Sprintf("o.%[1]s IS g.%[1]s", column))
+			gridColumns[i] = "g." + column
+		}
+
+		var joined, filled []string
+		for i, column := range valueColumns {
+			group := fmt.Sprintf("_gorchata_group_%d", i+1)
+			joined = append(joined, fmt.Sprintf("o.%[1]s, COUNT(o.%[1]s) OVER (%[2]sORDER BY g.bucket) AS %[3]s", column, partitionClause(gridColumns), group))
+			filled = append(filled, fmt.Sprintf("FIRST_VALUE(%[1]s) OVER (%[2]sORDER BY bucket) AS %[1]s", column, partitionClause(append(append([]string{}, partition...), group))))
+		}
+
+		window := partitionClause(append(append([]string{}, partition...), timeBucket(timestamp, seconds)))
+		return fmt.Sprintf(`WITH RECURSIVE gap_fill_buckets(n, bucket) AS (
+  SELECT 0, %[1]s WHERE %[1]s < %[2]s
+  UNION ALL
+  SELECT n + 1, %[3]s FROM gap_fill_buckets WHERE %[3]s < %[2]s
+), gap_fill_grid AS (
+  SELECT p.*, b.bucket FROM (%[4]s) p CROSS JOIN gap_fill_buckets b
+), gap_fill_observations AS (
+  SELECT %[5]s%[6]s AS bucket, %[7]s,
+    ROW_NUMBER() OVER (%[8]sORDER BY %[9]s DESC) AS _gorchata_row_number
+  FROM %[10]s
+), gap_fill_joined AS (
+  SELECT %[11]sg.bucket, %[12]s
+  FROM gap_fill_grid g
+  LEFT JOIN gap_fill_observations o ON o._gorchata_row_number = 1 AND %[13]s
+)
+SELECT %[5]sbucket, %[14]s
+FROM gap_fill_joined`,
+			first, last, next, partitions,
+			selectPrefix(partition), timeBucket(timestamp, seconds), strings.Join(valueColumns, ", "),
+			window, timestamp, relation,
+			selectPrefix(gridColumns), strings.Join(joined, ", "), strings.Join(joins, " AND "),
+			strings.Join(filled, ", ")), nil
+	}
+}
+
+// makeIntervalOverlapFunc creates an interval_overlap() function that returns
+// the seconds two intervals overlap, 0 when they do not, e.g.
+// {{ interval_overlap "o.start_timestamp" "o.end_timestamp" "d.downtime_start" "d.downtime_end" }}
+func makeIntervalOverlapFunc() func(string, string, string, string) string {
+	return func(start1, end1, start2, end2 string) string {
+		return fmt.Sprintf("MAX(0, ROUND((MIN(julianday(%s), julianday(%s)) - MAX(julianday(%s), julianday(%s))) * 86400, 3))",
+			end1, end2, start1, start2)
+	}
+}
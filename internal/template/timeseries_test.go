@@ -0,0 +1,175 @@
+package template
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpconstantineau/gorchata/internal/platform"
+	"github.com/jpconstantineau/gorchata/internal/platform/sqlite"
+)
+
+// loadExampleSeed loads a seed CSV of the examples into a table of TEXT columns
+func loadExampleSeed(t *testing.T, adapter *sqlite.SQLiteAdapter, path, table string) {
+	t.Helper()
+	file, err := os.Open(filepath.Join("..", "..", "examples", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+
+	ctx := context.Background()
+	columns := make([]string, len(records[0]))
+	for i, column := range records[0] {
+		columns[i] = quoteIdentifier(column) + " TEXT"
+	}
+	if err := adapter.ExecuteDDL(ctx, "CREATE TABLE "+table+" ("+strings.Join(columns, ", ")+")"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range records[1:] {
+		values := make([]string, len(record))
+		for i, value := range record {
+			values[i] = quoteString(value)
+			if value == "" {
+				values[i] = "NULL"
+			}
+		}
+		if err := adapter.ExecuteDDL(ctx, "INSERT INTO "+table+" VALUES ("+strings.Join(values, ", ")+")"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTimeSeriesHelpers(t *testing.T) {
+	adapter := sqlite.NewSQLiteAdapter(&platform.ConnectionConfig{
+		DatabasePath: filepath.Join(t.TempDir(), "timeseries.db"),
+	})
+	ctx := context.Background()
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer adapter.Close()
+
+	loadExampleSeed(t, adapter, "dcs_alarm_example/seeds/raw_alarm_events.csv", "raw_alarm_events")
+	loadExampleSeed(t, adapter, "bottleneck_analysis/seeds/raw_operations.csv", "raw_operations")
+	loadExampleSeed(t, adapter, "bottleneck_analysis/seeds/raw_downtime.csv", "raw_downtime")
+
+	tests := []struct {
+		name      string
+		content   string
+		want      string
+		wantParse string
+		wantErr   string
+	}{
+		{
+			name:    "time_bucket",
+			content: `SELECT {{ time_bucket "event_timestamp" "1 hour" }} AS hour, COUNT(*) FROM raw_alarm_events GROUP BY hour ORDER BY COUNT(*) DESC, hour LIMIT 2`,
+			want:    "2026-02-07 08:00:00|17;2026-02-07 06:00:00|11",
+		},
+		{
+			name:    "time_bucket in seconds",
+			content: `SELECT {{ time_bucket "event_timestamp" 90 }} FROM raw_alarm_events WHERE event_id = '1'`,
+			want:    "2026-02-06 08:15:00",
+		},
+		{
+			name:    "time_bucket with an unknown unit",
+			content: `{{ time_bucket "event_timestamp" "2 fortnights" }}`,
+			wantErr: "unknown unit fortnight",
+		},
+		{
+			name:    "state_durations",
+			content: `SELECT state, state_start, state_end, duration_seconds FROM ({{ state_durations "raw_alarm_events" "tag_id" "event_timestamp" "event_type" }}) WHERE tag_id = 'FIC-101' ORDER BY state_start`,
+			want:    "ACTIVE|2026-02-06 08:15:30|2026-02-06 08:17:45|135;ACKNOWLEDGED|2026-02-06 08:17:45|2026-02-06 08:20:10|145;INACTIVE|2026-02-06 08:20:10|NULL|NULL",
+		},
+		{
+			name:    "state_durations merging consecutive states",
+			content: `SELECT state, COUNT(*), SUM(duration_seconds) FROM ({{ state_durations "raw_alarm_events" (list "tag_id") "event_timestamp" "CASE WHEN event_type = 'INACTIVE' THEN 'clear' ELSE 'alarm' END" }}) WHERE tag_id = 'TIC-105' GROUP BY state ORDER BY state`,
+			want:    "alarm|5|88;clear|5|392",
+		},
+		{
+			name:    "sessionize",
+			content: `SELECT session_number, COUNT(*), MIN(event_timestamp) FROM ({{ sessionize "raw_alarm_events" "tag_id" "event_timestamp" "2 minutes" }}) WHERE tag_id = 'TIC-105' GROUP BY session_number`,
+			want:    "1|8|2026-02-07 06:00:00;2|3|2026-02-07 06:07:40",
+		},
+		{
+			name:      "sessionize keeps the columns of the relation",
+			content:   `SELECT * FROM ({{ sessionize "raw_downtime" "" "downtime_start" 86400 }}) WHERE downtime_id = 'DT-001'`,
+			want:      "DT-001|R001|2024-01-01 12:00:00|2024-01-01 13:30:00|UNSCHEDULED|BREAKDOWN|1",
+			wantParse: "SELECT * FROM (SELECT *, SUM(_gorchata_new_session) OVER (ORDER BY downtime_start ROWS UNBOUNDED PRECEDING) AS session_number\nFROM (\n  SELECT *, CASE WHEN LAG(downtime_start) OVER (ORDER BY downtime_start) IS NULL OR ROUND((julianday(downtime_start) - julianday(LAG(downtime_start) OVER (ORDER BY downtime_start))) * 86400, 3) > 86400 THEN 1 ELSE 0 END AS _gorchata_new_session\n  FROM raw_downtime\n)) WHERE downtime_id = 'DT-001'",
+		},
+		{
+			name:    "gap_fill carries the last observation forward",
+			content: `SELECT group_concat(substr(bucket, 12, 5) || '=' || COALESCE(alarm_value, 'NULL'), ' ') FROM (SELECT * FROM ({{ gap_fill "raw_alarm_events" "tag_id" "event_timestamp" "1 minute" "2026-02-07 05:59:00" "2026-02-07 06:10:00" (list "alarm_value") }}) WHERE tag_id = 'TIC-105' ORDER BY bucket)`,
+			want:    "05:59=NULL 06:00=378.2 06:01=377.5 06:02=377.5 06:03=376.8 06:04=376.8 06:05=375.9 06:06=375.9 06:07=389.5 06:08=374.2 06:09=374.2",
+		},
+		{
+			name:    "gap_fill of several columns without partitions",
+			content: `SELECT COUNT(*), SUM(tag_id IS NULL), MAX(event_type) FROM ({{ gap_fill "raw_alarm_events" "" "event_timestamp" "1 hour" "2026-02-06" "2026-02-08" (list "tag_id" "event_type") }})`,
+			want:    "48|8|INACTIVE",
+		},
+		{
+			name:    "interval_overlap",
+			content: `SELECT d.downtime_id, o.operation_id, {{ interval_overlap "o.start_timestamp" "o.end_timestamp" "d.downtime_start" "d.downtime_end" }} AS overlap FROM raw_downtime d JOIN raw_operations o ON o.resource_id = d.resource_id WHERE d.downtime_id = 'DT-007' AND overlap > 0 ORDER BY o.operation_id`,
+			want:    "DT-007|OP-10031|5400;DT-007|OP-10331|5400",
+		},
+		{
+			name:    "interval_overlap of partly overlapping intervals",
+			content: `SELECT {{ interval_overlap "'2024-01-01 08:00'" "'2024-01-01 10:00'" "'2024-01-01 09:30'" "'2024-01-01 11:00'" }}`,
+			want:    "1800",
+		},
+		{
+			name:    "interval_overlap of intervals apart",
+			content: `SELECT {{ interval_overlap "'2024-01-01 08:00'" "'2024-01-01 09:00'" "'2024-01-01 10:00'" "'2024-01-01 11:00'" }}`,
+			want:    "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New().Parse(tt.name, tt.content)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			db := NewDatabase(ctx, adapter.ExecuteQuery, adapter)
+			sql, err := Render(tmpl, NewContext(WithDatabase(db)), nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			result, err := adapter.ExecuteQuery(ctx, sql)
+			if err != nil {
+				t.Fatalf("ExecuteQuery(%s) error = %v", sql, err)
+			}
+			if got := formatRows(result); got != tt.want {
+				t.Errorf("rows = %q, want %q\nSQL: %s", got, tt.want, sql)
+			}
+
+			if tt.wantParse == "" {
+				return
+			}
+			got, err := Render(tmpl, NewContext(), nil)
+			if err != nil {
+				t.Fatalf("Render() in the parse phase error = %v", err)
+			}
+			if got != tt.wantParse {
+				t.Errorf("Render() in the parse phase = %q, want %q", got, tt.wantParse)
+			}
+		})
+	}
+}